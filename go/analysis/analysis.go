@@ -26,6 +26,10 @@ type Analyzer struct {
 	// (no capital or period, max ~60 letters).
 	Doc string
 
+	// URL holds an optional link to a web page with additional
+	// documentation for this analyzer.
+	URL string
+
 	// Flags defines any flags accepted by the analyzer.
 	// The manner in which these flags are exposed to the user
 	// depends on the driver which runs the analyzer.
@@ -45,6 +49,19 @@ type Analyzer struct {
 	// serializable.
 	Run func(*Pass) (interface{}, error)
 
+	// RunProgram, if non-nil, makes this analyzer a whole-program
+	// analyzer, which has no Run function: instead of applying it to
+	// each package, the driver calls RunProgram once, after it has
+	// applied the analyzers of Requires to all the packages under
+	// analysis, and provides it with their results and facts.
+	// It reports diagnostics only, and returns an error if the
+	// analyzer failed.
+	//
+	// A whole-program analyzer has neither FactTypes nor a
+	// ResultType, and no analyzer may require it. Drivers that analyze each package
+	// separately, such as unitchecker, cannot apply it.
+	RunProgram func(*ProgramPass) error
+
 	// RunDespiteErrors allows the driver to invoke
 	// the Run method of this analyzer even on a
 	// package that contains parse or type errors.
@@ -7,6 +7,7 @@ package analysistest
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"go/format"
 	"go/token"
@@ -66,6 +67,20 @@ var TestData = func() string {
 	return testdata
 }
 
+// UpdateGolden causes RunWithSuggestedFixes to write the results of the
+// suggested fixes to the golden files, instead of comparing them. It is
+// set by the -update-golden flag of the tests, as in:
+//
+//	$ go test ./... -update-golden
+//
+// or, for the tests that do not parse flags, by the
+// ANALYSISTEST_UPDATE_GOLDEN environment variable.
+var UpdateGolden, _ = strconv.ParseBool(os.Getenv("ANALYSISTEST_UPDATE_GOLDEN"))
+
+func init() {
+	flag.BoolVar(&UpdateGolden, "update-golden", UpdateGolden, "update the golden files of RunWithSuggestedFixes")
+}
+
 // Testing is an abstraction of a *testing.T.
 type Testing interface {
 	Errorf(format string, args ...interface{})
@@ -99,6 +114,12 @@ type Testing interface {
 //			println()
 //		}
 //	}
+//
+// If UpdateGolden is set, the golden files are rewritten from the actual
+// results instead of compared with them: the sections of an archive are
+// updated, added or removed to match the suggested fixes, and a missing
+// golden file is created, as an archive if there are fixes with
+// different messages.
 func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns ...string) []*Result {
 	r := Run(t, dir, a, patterns...)
 
@@ -116,6 +137,7 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 		// file -> message -> edits
 		fileEdits := make(map[*token.File]map[string][]diff.TextEdit)
 		fileContents := make(map[*token.File][]byte)
+		messages := make(map[*token.File][]string) // in order of appearance
 
 		// Validate edits, prepare the fileEdits map and read the file contents.
 		for _, diag := range act.Diagnostics {
@@ -150,6 +172,9 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 					if _, ok := fileEdits[file]; !ok {
 						fileEdits[file] = make(map[string][]diff.TextEdit)
 					}
+					if _, ok := fileEdits[file][sf.Message]; !ok {
+						messages[file] = append(messages[file], sf.Message)
+					}
 					fileEdits[file][sf.Message] = append(fileEdits[file][sf.Message], diff.TextEdit{
 						Span:    spn,
 						NewText: string(edit.NewText),
@@ -165,82 +190,148 @@ func RunWithSuggestedFixes(t Testing, dir string, a *analysis.Analyzer, patterns
 				t.Errorf("could not find file contents for %s", file.Name())
 				continue
 			}
+			checkGolden(t, file.Name(), orig, fixes, messages[file])
+		}
+	}
+	return r
+}
 
-			// Get the golden file and read the contents.
-			ar, err := txtar.ParseFile(file.Name() + ".golden")
-			if err != nil {
-				t.Errorf("error reading %s.golden: %v", file.Name(), err)
-				continue
+// checkGolden compares the result of applying the suggested fixes to the
+// contents of the named file with its golden file, or updates the golden
+// file if UpdateGolden is set. The messages of the fixes are listed in
+// order of appearance.
+func checkGolden(t Testing, filename string, orig []byte, fixes map[string][]diff.TextEdit, messages []string) {
+	golden := filename + ".golden"
+	ar, err := txtar.ParseFile(golden)
+	if err != nil {
+		if !UpdateGolden || !os.IsNotExist(err) {
+			t.Errorf("error reading %s: %v", golden, err)
+			return
+		}
+		// A new golden file is an archive only if there are
+		// alternative fixes.
+		ar = &txtar.Archive{}
+		if len(messages) > 1 {
+			for _, msg := range messages {
+				ar.Files = append(ar.Files, txtar.File{Name: msg})
 			}
+		}
+	}
 
-			if len(ar.Files) > 0 {
-				// one virtual file per kind of suggested fix
+	if len(ar.Files) == 0 {
+		// all suggested fixes are represented by a single file
 
-				if len(ar.Comment) != 0 {
-					// we allow either just the comment, or just virtual
-					// files, not both. it is not clear how "both" should
-					// behave.
-					t.Errorf("%s.golden has leading comment; we don't know what to do with it", file.Name())
-					continue
-				}
+		var catchallEdits []diff.TextEdit
+		for _, msg := range messages {
+			catchallEdits = append(catchallEdits, fixes[msg]...)
+		}
+		got, ok := applyFixes(t, filename, orig, catchallEdits)
+		if !ok {
+			return
+		}
+		if UpdateGolden {
+			if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+				t.Errorf("error writing %s: %v", golden, err)
+			}
+			return
+		}
+		compareGolden(t, filename, golden, string(ar.Comment), string(got))
+		return
+	}
 
-				for sf, edits := range fixes {
-					found := false
-					for _, vf := range ar.Files {
-						if vf.Name == sf {
-							found = true
-							out := diff.ApplyEdits(string(orig), edits)
-							// the file may contain multiple trailing
-							// newlines if the user places empty lines
-							// between files in the archive. normalize
-							// this to a single newline.
-							want := string(bytes.TrimRight(vf.Data, "\n")) + "\n"
-							formatted, err := format.Source([]byte(out))
-							if err != nil {
-								t.Errorf("%s: error formatting edited source: %v\n%s", file.Name(), err, out)
-								continue
-							}
-							if want != string(formatted) {
-								d, err := myers.ComputeEdits("", want, string(formatted))
-								if err != nil {
-									t.Errorf("failed to compute suggested fix diff: %v", err)
-								}
-								t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(fmt.Sprintf("%s.golden [%s]", file.Name(), sf), "actual", want, d))
-							}
-							break
-						}
-					}
-					if !found {
-						t.Errorf("no section for suggested fix %q in %s.golden", sf, file.Name())
-					}
-				}
-			} else {
-				// all suggested fixes are represented by a single file
+	// one virtual file per kind of suggested fix
 
-				var catchallEdits []diff.TextEdit
-				for _, edits := range fixes {
-					catchallEdits = append(catchallEdits, edits...)
-				}
+	if len(ar.Comment) != 0 {
+		// we allow either just the comment, or just virtual
+		// files, not both. it is not clear how "both" should
+		// behave.
+		t.Errorf("%s has leading comment; we don't know what to do with it", golden)
+		return
+	}
 
-				out := diff.ApplyEdits(string(orig), catchallEdits)
-				want := string(ar.Comment)
+	results := make(map[string][]byte) // message -> fixed file
+	for _, msg := range messages {
+		if got, ok := applyFixes(t, filename, orig, fixes[msg]); ok {
+			results[msg] = got
+		}
+	}
 
-				formatted, err := format.Source([]byte(out))
-				if err != nil {
-					t.Errorf("%s: error formatting resulting source: %v\n%s", file.Name(), err, out)
-					continue
-				}
-				if want != string(formatted) {
-					d, err := myers.ComputeEdits("", want, string(formatted))
-					if err != nil {
-						t.Errorf("%s: failed to compute suggested fix diff: %s", file.Name(), err)
-					}
-					t.Errorf("suggested fixes failed for %s:\n%s", file.Name(), diff.ToUnified(file.Name()+".golden", "actual", want, d))
-				}
+	if UpdateGolden {
+		// Keep the order of the existing sections, and add the new ones
+		// in order of appearance.
+		var files []txtar.File
+		seen := make(map[string]bool)
+		for _, f := range ar.Files {
+			if _, ok := fixes[f.Name]; ok {
+				seen[f.Name] = true
+				files = append(files, f)
+			}
+		}
+		for _, msg := range messages {
+			if !seen[msg] {
+				files = append(files, txtar.File{Name: msg})
+			}
+		}
+		for i, f := range files {
+			if got, ok := results[f.Name]; ok {
+				files[i].Data = got
 			}
 		}
+		ar.Files = files
+		if err := ioutil.WriteFile(golden, txtar.Format(ar), 0666); err != nil {
+			t.Errorf("error writing %s: %v", golden, err)
+		}
+		return
 	}
-	return r
+
+	sections := make(map[string][]byte)
+	for _, f := range ar.Files {
+		sections[f.Name] = f.Data
+	}
+	for _, msg := range messages {
+		got, ok := results[msg]
+		if !ok {
+			continue
+		}
+		data, ok := sections[msg]
+		if !ok {
+			t.Errorf("no section for suggested fix %q in %s", msg, golden)
+			continue
+		}
+		// the file may contain multiple trailing newlines if
+		// the user places empty lines between files in the
+		// archive. normalize this to a single newline.
+		want := string(bytes.TrimRight(data, "\n")) + "\n"
+		compareGolden(t, filename, fmt.Sprintf("%s [%s]", golden, msg), want, string(got))
+	}
+}
+
+// applyFixes returns the formatted result of applying the edits to the
+// contents of the named file.
+func applyFixes(t Testing, filename string, orig []byte, edits []diff.TextEdit) ([]byte, bool) {
+	out := diff.ApplyEdits(string(orig), edits)
+	formatted, err := format.Source([]byte(out))
+	if err != nil {
+		t.Errorf("%s: error formatting edited source: %v\n%s", filename, err, out)
+		return nil, false
+	}
+	return formatted, true
+}
+
+// compareGolden reports the differences between the expected contents of
+// the named file, as described by golden, and its actual contents after
+// the suggested fixes.
+func compareGolden(t Testing, filename, golden, want, got string) {
+	if want == got {
+		return
+	}
+	d, err := myers.ComputeEdits("", want, got)
+	if err != nil {
+		t.Errorf("%s: failed to compute suggested fix diff: %v", filename, err)
+		return
+	}
+	t.Errorf("suggested fixes failed for %s (rerun with ANALYSISTEST_UPDATE_GOLDEN=1 to accept the actual output):\n%s",
+		filename, diff.ToUnified(golden, "actual", want, d))
 }
 
 // Run applies an analysis to the packages denoted by the "go list" patterns.
@@ -5,13 +5,18 @@
 package analysistest_test
 
 import (
+	"flag"
 	"fmt"
+	"go/ast"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/cowpaths/golang-x-tools/go/analysis"
 	"github.com/cowpaths/golang-x-tools/go/analysis/analysistest"
 	"github.com/cowpaths/golang-x-tools/go/analysis/passes/findcall"
 	"github.com/cowpaths/golang-x-tools/internal/testenv"
@@ -155,6 +160,98 @@ func println(...interface{}) { println_TEST_() } // want println:"found" "call o
 	}
 }
 
+// renamecall reports the calls of f, with fixes renaming them to g or h.
+var renamecall = &analysis.Analyzer{
+	Name: "renamecall",
+	Doc:  "report calls of f",
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		for _, f := range pass.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "f" {
+						var fixes []analysis.SuggestedFix
+						for _, name := range []string{"g", "h"} {
+							fixes = append(fixes, analysis.SuggestedFix{
+								Message:   "Rename to " + name,
+								TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}},
+							})
+						}
+						pass.Report(analysis.Diagnostic{Pos: id.Pos(), Message: "call of f", SuggestedFixes: fixes})
+					}
+				}
+				return true
+			})
+		}
+		return nil, nil
+	},
+}
+
+// TestUpdateGolden tests that the golden files are rewritten from the
+// suggested fixes, and then match them.
+func TestUpdateGolden(t *testing.T) {
+	testenv.NeedsTool(t, "go")
+
+	const src = `package a
+
+func _() {
+	f() // want "call of f"
+}
+
+func f() {}
+func g() {}
+func h() {}
+`
+	filemap := map[string]string{
+		"a/a.go": src,
+		"a/a.go.golden": `-- Rename to g --
+package a
+
+func _() {
+	f() // want "call of f"
+}
+
+-- stale --
+package a
+`,
+		"b/b.go": strings.Replace(src, "package a", "package b", 1),
+	}
+	dir, cleanup, err := analysistest.WriteFiles(filemap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	var got []string
+	t2 := errorfunc(func(s string) { got = append(got, s) }) // a fake *testing.T
+	defer func(update bool) { analysistest.UpdateGolden = update }(analysistest.UpdateGolden)
+	if err := flag.Set("update-golden", "true"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.RunWithSuggestedFixes(t2, dir, renamecall, "a", "b")
+	analysistest.UpdateGolden = false
+	if got != nil {
+		t.Fatalf("errors while updating golden files:\n%s", strings.Join(got, "\n"))
+	}
+
+	for _, pkg := range []string{"a", "b"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "src", pkg, pkg+".go.golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixed := strings.Replace(src, "package a", "package "+pkg, 1)
+		want := "-- Rename to g --\n" + strings.Replace(fixed, "\tf()", "\tg()", 1) +
+			"-- Rename to h --\n" + strings.Replace(fixed, "\tf()", "\th()", 1)
+		if string(data) != want {
+			t.Errorf("%s.go.golden: got:\n%s\nwant:\n%s", pkg, data, want)
+		}
+	}
+
+	analysistest.RunWithSuggestedFixes(t2, dir, renamecall, "a", "b")
+	if got != nil {
+		t.Errorf("errors after updating golden files:\n%s", strings.Join(got, "\n"))
+	}
+}
+
 type errorfunc func(string)
 
 func (f errorfunc) Errorf(format string, args ...interface{}) {
@@ -278,6 +278,28 @@ this fact is built in to the analyzer so that it correctly checks
 calls to log.Printf even when run in a driver that does not apply
 it to standard packages. We would like to remove this limitation in future.
 
+# Whole-program analysis
+
+Facts flow only from the dependencies of a package to the package
+itself, so an ordinary analyzer cannot find, for example, the exported
+functions that no package calls. A whole-program analyzer, whose
+RunProgram field is set instead of Run, runs once after the analyzers it
+requires have been applied to all the packages under analysis. Its
+ProgramPass provides the syntax, type information and results of those
+analyzers for each package, and their facts about all of them:
+
+	type ProgramPass struct {
+		Analyzer *Analyzer
+		Fset     *token.FileSet
+		Packages []*ProgramPackage
+		Report   func(Diagnostic)
+		...
+	}
+
+Only drivers that analyze all the packages in one process, such as
+singlechecker, multichecker and gopls, apply whole-program analyzers;
+unitchecker, which runs once per package, rejects them.
+
 # Testing an Analyzer
 
 The analysistest subpackage provides utilities for testing an Analyzer.
@@ -0,0 +1,206 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/internal/typeparams"
+)
+
+// A Baseline records the diagnostics known at some point, so that the
+// drivers report only the new ones (see the -baseline flag).
+//
+// A diagnostic is identified by its analyzer, package, enclosing
+// declaration and message, but not by its position, so that it remains
+// known when the lines of its file shift. A baseline records the number of
+// diagnostics of each identity: the drivers report those beyond it.
+type Baseline struct {
+	counts map[baselineKey]int
+}
+
+type baselineKey struct {
+	Analyzer string `json:"analyzer"`
+	Package  string `json:"package"`
+	Decl     string `json:"decl"`
+	Message  string `json:"message"`
+}
+
+// A baselineEntry is the encoding of the diagnostics of one identity in a
+// baseline file, which holds a JSON list of them.
+type baselineEntry struct {
+	baselineKey
+	Count int `json:"count"`
+}
+
+// NewBaseline returns an empty baseline.
+func NewBaseline() *Baseline {
+	return &Baseline{counts: make(map[baselineKey]int)}
+}
+
+// ReadBaseline reads a baseline file written by Baseline.Write.
+func ReadBaseline(filename string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries []baselineEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("cannot decode baseline file %s: %v", filename, err)
+	}
+	b := NewBaseline()
+	for _, e := range entries {
+		b.counts[e.baselineKey] += e.Count
+	}
+	return b, nil
+}
+
+// Write writes the baseline to the named file, in a stable order.
+func (b *Baseline) Write(filename string) error {
+	entries := make([]baselineEntry, 0, len(b.counts))
+	for k, n := range b.counts {
+		entries = append(entries, baselineEntry{k, n})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i].baselineKey, entries[j].baselineKey
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		if x.Analyzer != y.Analyzer {
+			return x.Analyzer < y.Analyzer
+		}
+		if x.Decl != y.Decl {
+			return x.Decl < y.Decl
+		}
+		return x.Message < y.Message
+	})
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0666)
+}
+
+// Add records the diagnostics of an analyzer on the package of the given
+// path and files. The variants of a package, such as its test variant,
+// share its path; the baseline records the most diagnostics of each
+// identity among them.
+func (b *Baseline) Add(fset *token.FileSet, files []*ast.File, analyzer, pkgPath string, diags []analysis.Diagnostic) {
+	for k, n := range countDiagnostics(fset, files, analyzer, pkgPath, diags) {
+		if n > b.counts[k] {
+			b.counts[k] = n
+		}
+	}
+}
+
+// Filter returns the diagnostics of an analyzer on the package of the
+// given path and files that are not in the baseline. Among diagnostics of
+// the same identity, the last ones in position order are reported.
+func (b *Baseline) Filter(fset *token.FileSet, files []*ast.File, analyzer, pkgPath string, diags []analysis.Diagnostic) []analysis.Diagnostic {
+	diags = append([]analysis.Diagnostic(nil), diags...)
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Pos < diags[j].Pos })
+	seen := make(map[baselineKey]int)
+	var result []analysis.Diagnostic
+	for _, diag := range diags {
+		k := diagnosticKey(fset, files, analyzer, pkgPath, diag)
+		seen[k]++
+		if seen[k] > b.counts[k] {
+			result = append(result, diag)
+		}
+	}
+	return result
+}
+
+func countDiagnostics(fset *token.FileSet, files []*ast.File, analyzer, pkgPath string, diags []analysis.Diagnostic) map[baselineKey]int {
+	counts := make(map[baselineKey]int)
+	for _, diag := range diags {
+		counts[diagnosticKey(fset, files, analyzer, pkgPath, diag)]++
+	}
+	return counts
+}
+
+func diagnosticKey(fset *token.FileSet, files []*ast.File, analyzer, pkgPath string, diag analysis.Diagnostic) baselineKey {
+	// The go command reports the path of a variant with a suffix,
+	// such as "p [p.test]".
+	if i := strings.Index(pkgPath, " ["); i >= 0 {
+		pkgPath = pkgPath[:i]
+	}
+	return baselineKey{
+		Analyzer: analyzer,
+		Package:  pkgPath,
+		Decl:     enclosingDecl(fset, files, diag.Pos),
+		Message:  diag.Message,
+	}
+}
+
+// enclosingDecl returns a description of the top-level declaration that
+// encloses pos, such as "func (*T).M" or "var x, y", or "" if there is
+// none.
+func enclosingDecl(fset *token.FileSet, files []*ast.File, pos token.Pos) string {
+	var file *ast.File
+	for _, f := range files {
+		if tok := fset.File(f.Pos()); tok != nil && tok.Base() <= int(pos) && int(pos) <= tok.Base()+tok.Size() {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return ""
+	}
+	for _, decl := range file.Decls {
+		if pos < decl.Pos() || pos >= decl.End() {
+			continue
+		}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				return fmt.Sprintf("func (%s).%s", receiverType(decl.Recv.List[0].Type), decl.Name.Name)
+			}
+			return "func " + decl.Name.Name
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if pos < spec.Pos() || pos >= spec.End() {
+					continue
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					return "type " + spec.Name.Name
+				case *ast.ValueSpec:
+					var names []string
+					for _, name := range spec.Names {
+						names = append(names, name.Name)
+					}
+					return decl.Tok.String() + " " + strings.Join(names, ", ")
+				}
+			}
+			return decl.Tok.String()
+		}
+	}
+	return ""
+}
+
+// receiverType returns the name of a receiver type, without its type
+// parameters.
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiverType(expr.X)
+	case *ast.ParenExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	if x, _, _, _ := typeparams.UnpackIndexExpr(expr); x != nil {
+		return receiverType(x)
+	}
+	return "?"
+}
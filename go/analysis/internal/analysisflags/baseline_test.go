@@ -0,0 +1,113 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/analysisflags"
+)
+
+// parseCalls parses src and returns a diagnostic for each call of bad.
+func parseCalls(t *testing.T, src string) (*token.FileSet, []*ast.File, []analysis.Diagnostic) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []analysis.Diagnostic
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "bad" {
+				diags = append(diags, analysis.Diagnostic{Pos: call.Pos(), Message: "call of bad"})
+			}
+		}
+		return true
+	})
+	return fset, []*ast.File{f}, diags
+}
+
+func TestBaseline(t *testing.T) {
+	const before = `package p
+
+func F() {
+	bad()
+	bad()
+}
+
+type T struct{}
+
+func (*T) M() { bad() }
+
+var x, y = bad(), 0
+`
+	const after = `package p
+
+// The lines shifted.
+
+func F() {
+	bad()
+	bad()
+	bad()
+}
+
+type T struct{}
+
+func (*T) M() { bad() }
+
+var x, y = bad(), 0
+
+func G() { bad() }
+`
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	fset, files, diags := parseCalls(t, before)
+	b := analysisflags.NewBaseline()
+	b.Add(fset, files, "a", "example.com/p", diags)
+	// The test variant of the package has the same diagnostics.
+	b.Add(fset, files, "a", "example.com/p [example.com/p.test]", diags)
+	if err := b.Write(filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"decl": "func F",
+		"message": "call of bad",
+		"count": 2`,
+		`"decl": "func (*T).M"`,
+		`"decl": "var x, y"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("baseline file does not contain %q:\n%s", want, data)
+		}
+	}
+
+	b, err = analysisflags.ReadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset, files, diags = parseCalls(t, after)
+	var got []string
+	for _, diag := range b.Filter(fset, files, "a", "example.com/p", diags) {
+		got = append(got, fmt.Sprint(fset.Position(diag.Pos).Line))
+	}
+	if want := []string{"8", "17"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("new diagnostics on lines %v, want %v", got, want)
+	}
+	if got := b.Filter(fset, files, "other", "example.com/p", diags); len(got) != len(diags) {
+		t.Errorf("got %d new diagnostics of another analyzer, want %d", len(got), len(diags))
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -25,8 +26,11 @@ import (
 
 // flags common to all {single,multi,unit}checkers.
 var (
-	JSON    = false // -json
-	Context = -1    // -c=N: if N>0, display offending line plus N lines of context
+	JSON          = false // -json
+	SARIF         = false // -sarif
+	Context       = -1    // -c=N: if N>0, display offending line plus N lines of context
+	BaselineFile  = ""    // -baseline=file: report only the diagnostics not in file
+	WriteBaseline = false // -write-baseline: record the diagnostics in the -baseline file
 )
 
 // Parse creates a flag for each of the analyzer's flags,
@@ -74,7 +78,10 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 
 	// flags common to all checkers
 	flag.BoolVar(&JSON, "json", JSON, "emit JSON output")
+	flag.BoolVar(&SARIF, "sarif", SARIF, "emit a SARIF 2.1.0 log of all the packages (overrides -json; not supported by go vet)")
 	flag.IntVar(&Context, "c", Context, `display offending line with this many lines of context`)
+	flag.StringVar(&BaselineFile, "baseline", BaselineFile, "report only the diagnostics not recorded in this baseline file (an absolute path under go vet, which runs the tool in the directory of each package)")
+	flag.BoolVar(&WriteBaseline, "write-baseline", WriteBaseline, "record the current diagnostics in the -baseline file instead of reporting them")
 
 	// Add shims for legacy vet flags to enable existing
 	// scripts that run vet to continue to work.
@@ -97,6 +104,19 @@ func Parse(analyzers []*analysis.Analyzer, multi bool) []*analysis.Analyzer {
 		os.Exit(0)
 	}
 
+	if WriteBaseline && BaselineFile == "" {
+		log.Fatal("-write-baseline requires -baseline=file")
+	}
+	// The packages may be analyzed in other directories, so the
+	// -baseline file is resolved once, in that of the driver.
+	if BaselineFile != "" {
+		abs, err := filepath.Abs(BaselineFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		BaselineFile = abs
+	}
+
 	everything := expand(analyzers)
 
 	// If any -NAME flag is true,  run only those analyzers. Otherwise,
@@ -171,7 +191,7 @@ func printFlags() {
 		// flags or fix as these have no effect on unitchecker
 		// (as invoked by 'go vet').
 		switch f.Name {
-		case "debug", "cpuprofile", "memprofile", "trace", "fix":
+		case "debug", "cpuprofile", "memprofile", "trace", "fix", "diff", "diff-base", "cache", "p", "stats":
 			return
 		}
 
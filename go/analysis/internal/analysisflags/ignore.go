@@ -0,0 +1,60 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/internal/analysisinternal"
+)
+
+// IgnoreAnalyzer is the pseudo-analyzer to which the drivers attribute
+// the problems with the suppression directives of a package. It is never
+// run.
+var IgnoreAnalyzer = &analysis.Analyzer{
+	Name: "lintignore",
+	Doc: `report malformed and unused suppression directives
+
+A directive of the form
+
+	//lint:ignore analyzer[,analyzer...] reason
+
+suppresses the diagnostics of the named analyzers on the line it
+follows, or otherwise on the declaration or statement that starts on the
+next line. A //lint:file-ignore directive of the same form suppresses
+them in the whole file. A directive that suppresses no diagnostic of an
+analyzer that ran is reported, so that stale directives get removed.`,
+}
+
+// Suppress removes the diagnostics suppressed by the directives of the
+// files of a package from the diagnostics of each analyzer that ran on
+// it, and returns the problems with the directives as diagnostics of
+// IgnoreAnalyzer.
+func Suppress(fset *token.FileSet, files []*ast.File, diagnostics map[*analysis.Analyzer][]analysis.Diagnostic) []analysis.Diagnostic {
+	ignores := analysisinternal.ParseIgnores(fset, files)
+	ran := make(map[string]bool)
+	for a, diags := range diagnostics {
+		ran[a.Name] = true
+		var kept []analysis.Diagnostic
+		for _, diag := range diags {
+			posn := fset.PositionFor(diag.Pos, false)
+			if !ignores.Ignored(a.Name, posn.Filename, posn.Line) {
+				kept = append(kept, diag)
+			}
+		}
+		diagnostics[a] = kept
+	}
+	var problems []analysis.Diagnostic
+	for _, p := range ignores.Problems(func(name string) bool { return ran[name] }) {
+		problems = append(problems, analysis.Diagnostic{
+			Pos:     p.Pos,
+			End:     p.End,
+			Message: p.Message,
+		})
+	}
+	return problems
+}
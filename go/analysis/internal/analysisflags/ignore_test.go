@@ -0,0 +1,122 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/analysisflags"
+)
+
+func TestSuppress(t *testing.T) {
+	files := map[string]string{
+		"p.go": `package p
+
+//lint:ignore a declarations are fine
+func f() {
+	x := 1
+}
+
+func g() {
+	x := 1 //lint:ignore b trailing
+	y := 2
+
+	//lint:ignore a,b the next statement
+	if x > y {
+		return
+	}
+	z := 3
+}
+
+//lint:ignore b unused for b
+var v = 1
+
+//lint:ignore c unused, but c did not run
+var w = 1
+
+//lint:ignore a
+var u = 1
+`,
+		"q.go": `package p
+
+//lint:file-ignore b the whole file
+
+func h() {
+	x := 1
+}
+`,
+	}
+	// The lines of the diagnostics reported by each analyzer, and of
+	// those that remain.
+	reports := map[string][]string{
+		"a": {"p.go:5", "p.go:9", "p.go:10", "p.go:14", "p.go:16", "q.go:6"},
+		"b": {"p.go:5", "p.go:9", "p.go:10", "p.go:14", "q.go:6"},
+	}
+	want := map[string][]string{
+		"a": {"p.go:9", "p.go:10", "p.go:16", "q.go:6"},
+		"b": {"p.go:5", "p.go:10"},
+	}
+	wantProblems := []string{
+		"p.go:19: this directive suppresses no diagnostic of b",
+		"p.go:25: malformed //lint:ignore directive: want //lint:ignore analyzer[,analyzer...] reason",
+	}
+
+	fset := token.NewFileSet()
+	var syntax []*ast.File
+	for _, name := range []string{"p.go", "q.go"} {
+		f, err := parser.ParseFile(fset, name, files[name], parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		syntax = append(syntax, f)
+	}
+	lineStart := func(posn string) token.Pos {
+		var name string
+		var line int
+		fmt.Sscanf(strings.Replace(posn, ":", " ", 1), "%s %d", &name, &line)
+		for _, f := range syntax {
+			if tok := fset.File(f.Pos()); tok.Name() == name {
+				return tok.LineStart(line)
+			}
+		}
+		t.Fatalf("no file for %s", posn)
+		return token.NoPos
+	}
+	diagnostics := make(map[*analysis.Analyzer][]analysis.Diagnostic)
+	for name, lines := range reports {
+		a := &analysis.Analyzer{Name: name}
+		for _, line := range lines {
+			diagnostics[a] = append(diagnostics[a], analysis.Diagnostic{Pos: lineStart(line)})
+		}
+	}
+
+	problems := analysisflags.Suppress(fset, syntax, diagnostics)
+
+	got := make(map[string][]string)
+	for a, diags := range diagnostics {
+		for _, diag := range diags {
+			posn := fset.Position(diag.Pos)
+			got[a.Name] = append(got[a.Name], fmt.Sprintf("%s:%d", posn.Filename, posn.Line))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining diagnostics: got %v, want %v", got, want)
+	}
+	var gotProblems []string
+	for _, p := range problems {
+		posn := fset.Position(p.Pos)
+		gotProblems = append(gotProblems, fmt.Sprintf("%s:%d: %s", posn.Filename, posn.Line, p.Message))
+	}
+	if !reflect.DeepEqual(gotProblems, wantProblems) {
+		t.Errorf("problems: got %q, want %q", gotProblems, wantProblems)
+	}
+}
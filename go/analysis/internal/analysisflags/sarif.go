@@ -0,0 +1,349 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+)
+
+// A SARIFLog accumulates the results of analyses as a log in the Static
+// Analysis Results Interchange Format (SARIF), version 2.1.0.
+//
+// Each analyzer is described by a rule, and each diagnostic by a result
+// of that rule. Errors of analyzers are reported as notifications of the
+// tool execution. File names within the current directory are reported
+// relative to the %SRCROOT% base URI, which denotes that directory.
+type SARIFLog struct {
+	root          string // current directory, or "" if unknown
+	rules         []sarifRule
+	ruleIndex     map[*analysis.Analyzer]int
+	results       []sarifResult
+	notifications []sarifNotification
+	lines         map[string][]string // lines of the files, for columns
+}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRoot    = "%SRCROOT%"
+)
+
+// NewSARIFLog returns an empty SARIF log.
+func NewSARIFLog() *SARIFLog {
+	root, _ := os.Getwd()
+	return &SARIFLog{
+		root:      root,
+		ruleIndex: make(map[*analysis.Analyzer]int),
+		lines:     make(map[string][]string),
+	}
+}
+
+// Add adds the results of analyzer a on a package: either a list of
+// diagnostics or an error.
+func (l *SARIFLog) Add(fset *token.FileSet, a *analysis.Analyzer, diags []analysis.Diagnostic, err error) {
+	index := l.rule(a)
+	if err != nil {
+		l.notifications = append(l.notifications, sarifNotification{
+			Level:          "error",
+			Message:        sarifMessage{Text: err.Error()},
+			AssociatedRule: &sarifRuleReference{ID: a.Name, Index: index},
+		})
+		return
+	}
+	for _, diag := range diags {
+		result := sarifResult{
+			RuleID:    a.Name,
+			RuleIndex: index,
+			Level:     "warning",
+			Message:   sarifMessage{Text: diag.Message},
+			Locations: []sarifLocation{l.location(fset, diag.Pos, diag.End, "")},
+		}
+		if diag.Category != "" {
+			result.Properties = map[string]string{"category": diag.Category}
+		}
+		for _, rel := range diag.Related {
+			result.RelatedLocations = append(result.RelatedLocations, l.location(fset, rel.Pos, rel.End, rel.Message))
+		}
+		for _, fix := range diag.SuggestedFixes {
+			result.Fixes = append(result.Fixes, l.fix(fset, fix))
+		}
+		l.results = append(l.results, result)
+	}
+}
+
+// rule returns the index of the rule describing analyzer a, adding it
+// if needed.
+func (l *SARIFLog) rule(a *analysis.Analyzer) int {
+	if index, ok := l.ruleIndex[a]; ok {
+		return index
+	}
+	title := a.Doc
+	if i := strings.Index(title, "\n\n"); i >= 0 {
+		title = title[:i]
+	}
+	l.rules = append(l.rules, sarifRule{
+		ID:               a.Name,
+		ShortDescription: sarifMessage{Text: title},
+		FullDescription:  sarifMessage{Text: a.Doc},
+		HelpURI:          a.URL,
+	})
+	l.ruleIndex[a] = len(l.rules) - 1
+	return len(l.rules) - 1
+}
+
+// fix converts a suggested fix, grouping its edits by file.
+func (l *SARIFLog) fix(fset *token.FileSet, fix analysis.SuggestedFix) sarifFix {
+	result := sarifFix{Description: sarifMessage{Text: fix.Message}}
+	changes := make(map[string]int) // index in ArtifactChanges by file name
+	for _, edit := range fix.TextEdits {
+		end := edit.End
+		if !end.IsValid() {
+			end = edit.Pos
+		}
+		posn := fset.Position(edit.Pos)
+		i, ok := changes[posn.Filename]
+		if !ok {
+			i = len(result.ArtifactChanges)
+			changes[posn.Filename] = i
+			result.ArtifactChanges = append(result.ArtifactChanges, sarifArtifactChange{
+				ArtifactLocation: l.artifact(posn.Filename),
+			})
+		}
+		change := &result.ArtifactChanges[i]
+		change.Replacements = append(change.Replacements, sarifReplacement{
+			DeletedRegion:   l.region(posn, fset.Position(end)),
+			InsertedContent: &sarifArtifactContent{Text: string(edit.NewText)},
+		})
+	}
+	return result
+}
+
+// location returns the location of the range [pos, end), whose region
+// has no end if end is not valid.
+func (l *SARIFLog) location(fset *token.FileSet, pos, end token.Pos, message string) sarifLocation {
+	posn := fset.Position(pos)
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: l.artifact(posn.Filename),
+			Region:           l.region(posn, fset.Position(end)),
+		},
+	}
+	if message != "" {
+		loc.Message = &sarifMessage{Text: message}
+	}
+	return loc
+}
+
+// artifact returns the location of the named file: relative to %SRCROOT%
+// if it is within the current directory, or an absolute file URI
+// otherwise.
+func (l *SARIFLog) artifact(filename string) sarifArtifactLocation {
+	if l.root != "" {
+		if rel, err := filepath.Rel(l.root, filename); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: sarifRoot}
+		}
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	return sarifArtifactLocation{URI: fileURI(filename)}
+}
+
+// fileURI returns the file URI of an absolute file name.
+func fileURI(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows volume name
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// region returns the region of the range [posn, end). Columns are
+// counted in UTF-16 code units, as SARIF requires by default, if the
+// file can be read, and in bytes otherwise.
+func (l *SARIFLog) region(posn, end token.Position) *sarifRegion {
+	if !posn.IsValid() {
+		return nil
+	}
+	r := &sarifRegion{StartLine: posn.Line, StartColumn: l.column(posn)}
+	if end.IsValid() && end.Filename == posn.Filename {
+		r.EndLine = end.Line
+		r.EndColumn = l.column(end)
+	}
+	return r
+}
+
+// column returns the 1-based UTF-16 column of posn.
+func (l *SARIFLog) column(posn token.Position) int {
+	lines, ok := l.lines[posn.Filename]
+	if !ok {
+		if data, err := ioutil.ReadFile(posn.Filename); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		l.lines[posn.Filename] = lines
+	}
+	if posn.Line > len(lines) || posn.Column-1 > len(lines[posn.Line-1]) {
+		return posn.Column
+	}
+	prefix := lines[posn.Line-1][:posn.Column-1]
+	return len(utf16.Encode([]rune(prefix))) + 1
+}
+
+// Print prints the log in JSON form to standard output.
+func (l *SARIFLog) Print() {
+	if err := l.write(os.Stdout); err != nil {
+		log.Fatalf("writing SARIF output: %v", err)
+	}
+}
+
+func (l *SARIFLog) write(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:  filepath.Base(os.Args[0]),
+			Rules: l.rules,
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful:        len(l.notifications) == 0,
+			ToolExecutionNotifications: l.notifications,
+		}},
+		Results: l.results,
+	}
+	if run.Results == nil {
+		run.Results = []sarifResult{} // no results, as opposed to unknown
+	}
+	if l.root != "" {
+		root := fileURI(l.root)
+		if !strings.HasSuffix(root, "/") {
+			root += "/"
+		}
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{sarifRoot: {URI: root}}
+	}
+	data, err := json.MarshalIndent(sarifDocument{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	}, "", "\t")
+	if err != nil {
+		log.Panicf("internal error: JSON marshaling failed: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// The types below encode the subset of SARIF 2.1.0 used by SARIFLog.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifDocument struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	Invocations        []sarifInvocation                `json:"invocations"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level          string              `json:"level"`
+	Message        sarifMessage        `json:"message"`
+	AssociatedRule *sarifRuleReference `json:"associatedRule,omitempty"`
+}
+
+type sarifRuleReference struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+}
+
+type sarifResult struct {
+	RuleID           string            `json:"ruleId"`
+	RuleIndex        int               `json:"ruleIndex"`
+	Level            string            `json:"level"`
+	Message          sarifMessage      `json:"message"`
+	Locations        []sarifLocation   `json:"locations"`
+	RelatedLocations []sarifLocation   `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix        `json:"fixes,omitempty"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   *sarifRegion          `json:"deletedRegion"`
+	InsertedContent *sarifArtifactContent `json:"insertedContent,omitempty"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+)
+
+func TestSARIF(t *testing.T) {
+	dir := t.TempDir()
+	const src = "package p\n\nvar s = \"é\" + x\n"
+	filename := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile(filename, -1, len(src))
+	file.SetLinesForContent([]byte(src))
+	pos := func(substr string) token.Pos {
+		return file.Pos(strings.Index(src, substr))
+	}
+
+	a := &analysis.Analyzer{
+		Name: "demo",
+		Doc:  "report things\n\nThe demo analyzer reports things.",
+		URL:  "https://example.com/demo",
+	}
+	b := &analysis.Analyzer{Name: "broken", Doc: "fail"}
+	log := NewSARIFLog()
+	log.root = dir
+	log.Add(fset, a, []analysis.Diagnostic{{
+		Pos:      pos("x"),
+		End:      pos("x") + 1,
+		Category: "naming",
+		Message:  "x is undefined",
+		Related:  []analysis.RelatedInformation{{Pos: pos("var"), Message: "in this declaration"}},
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "use y",
+			TextEdits: []analysis.TextEdit{{Pos: pos("x"), End: pos("x") + 1, NewText: []byte("y")}},
+		}},
+	}}, nil)
+	log.Add(fset, b, nil, errors.New("failure"))
+
+	var buf bytes.Buffer
+	if err := log.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got sarifDocument
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.Bytes())
+	}
+
+	// The column of x follows "é", which is 2 bytes but 1 UTF-16 unit.
+	loc := sarifArtifactLocation{URI: "p.go", URIBaseID: sarifRoot}
+	want := sarifDocument{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name: filepath.Base(os.Args[0]),
+				Rules: []sarifRule{
+					{
+						ID:               "demo",
+						ShortDescription: sarifMessage{"report things"},
+						FullDescription:  sarifMessage{a.Doc},
+						HelpURI:          a.URL,
+					},
+					{
+						ID:               "broken",
+						ShortDescription: sarifMessage{"fail"},
+						FullDescription:  sarifMessage{"fail"},
+					},
+				},
+			}},
+			Invocations: []sarifInvocation{{
+				ExecutionSuccessful: false,
+				ToolExecutionNotifications: []sarifNotification{{
+					Level:          "error",
+					Message:        sarifMessage{"failure"},
+					AssociatedRule: &sarifRuleReference{ID: "broken", Index: 1},
+				}},
+			}},
+			OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+				sarifRoot: {URI: fileURI(dir) + "/"},
+			},
+			Results: []sarifResult{{
+				RuleID:    "demo",
+				RuleIndex: 0,
+				Level:     "warning",
+				Message:   sarifMessage{"x is undefined"},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: loc,
+						Region:           &sarifRegion{StartLine: 3, StartColumn: 15, EndLine: 3, EndColumn: 16},
+					},
+				}},
+				RelatedLocations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: loc,
+						Region:           &sarifRegion{StartLine: 3, StartColumn: 1},
+					},
+					Message: &sarifMessage{"in this declaration"},
+				}},
+				Fixes: []sarifFix{{
+					Description: sarifMessage{"use y"},
+					ArtifactChanges: []sarifArtifactChange{{
+						ArtifactLocation: loc,
+						Replacements: []sarifReplacement{{
+							DeletedRegion:   &sarifRegion{StartLine: 3, StartColumn: 15, EndLine: 3, EndColumn: 16},
+							InsertedContent: &sarifArtifactContent{"y"},
+						}},
+					}},
+				}},
+				Properties: map[string]string{"category": "naming"},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "\t")
+		wantJSON, _ := json.MarshalIndent(want, "", "\t")
+		t.Errorf("got:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+}
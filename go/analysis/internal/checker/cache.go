@@ -0,0 +1,440 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/facts"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+)
+
+// The persistent cache (see CacheDir) holds the facts and diagnostics of
+// actions, in files named by a hash of all the inputs of the action: the
+// executable, the analyzer and its flags, the content of the package and
+// of its dependencies, and the keys of the actions it depends on.
+//
+// An action whose entry is in the cache is not run, and neither are its
+// dependencies, unless another action that runs needs its result, which
+// only exists in memory: then it runs as if there were no entry.
+
+// cacheVersion identifies the format of the entries of the cache.
+const cacheVersion = "checker cache v1"
+
+// A cacheEntry is the content of the cache for an action.
+type cacheEntry struct {
+	Facts       []byte // encoded by facts.Set
+	Diagnostics []cachedDiagnostic
+}
+
+// A cachedPos is a token.Pos independent of the file set.
+type cachedPos struct {
+	File   string // empty for token.NoPos
+	Offset int
+}
+
+type cachedDiagnostic struct {
+	Pos, End cachedPos
+	Category string
+	Message  string
+	Fixes    []cachedFix
+	Related  []cachedRelated
+}
+
+type cachedFix struct {
+	Message string
+	Edits   []cachedEdit
+}
+
+type cachedEdit struct {
+	Pos, End cachedPos
+	NewText  []byte
+}
+
+type cachedRelated struct {
+	Pos, End cachedPos
+	Message  string
+}
+
+// A cachePlan computes the keys of the actions, and decides which ones
+// run and which ones are read from the cache.
+type cachePlan struct {
+	exe       [sha256.Size]byte
+	pkgHashes map[*packages.Package]*[sha256.Size]byte // nil if the package is not cacheable
+	keys      map[*action]*[sha256.Size]byte           // nil if the action is not cacheable
+	state     map[*action]int                          // 1: read from the cache, 2: run
+}
+
+// planCache prepares the actions of the graph of roots for the use of the
+// cache: it sets their keys, and the cache entries of those that are read
+// from the cache instead of run.
+func planCache(roots []*action) {
+	exe, err := executableHash()
+	if err != nil {
+		log.Printf("analysis cache disabled: %v", err)
+		return
+	}
+	p := &cachePlan{
+		exe:       exe,
+		pkgHashes: make(map[*packages.Package]*[sha256.Size]byte),
+		keys:      make(map[*action]*[sha256.Size]byte),
+		state:     make(map[*action]int),
+	}
+	registered := make(map[*analysis.Analyzer]bool)
+	var register func(a *analysis.Analyzer)
+	register = func(a *analysis.Analyzer) {
+		if !registered[a] {
+			registered[a] = true
+			for _, f := range a.FactTypes {
+				gob.Register(f)
+			}
+			for _, req := range a.Requires {
+				register(req)
+			}
+		}
+	}
+	for _, act := range roots {
+		register(act.a)
+		p.visit(act, false)
+	}
+}
+
+// visit decides whether act runs, or is read from the cache if it is not
+// needed for its result.
+func (p *cachePlan) visit(act *action, needResult bool) {
+	if act.program != nil {
+		// A whole-program analyzer is not cached, and needs the results
+		// of the analyzers it requires on all the packages.
+		if p.state[act] == 0 {
+			p.state[act] = 2
+			for _, dep := range act.deps {
+				p.visit(dep, true)
+			}
+		}
+		return
+	}
+	switch p.state[act] {
+	case 2:
+		return
+	case 1:
+		if !needResult {
+			return
+		}
+	}
+	act.cacheKey = p.key(act)
+	if !needResult && act.cacheKey != nil {
+		if data, err := readCache(*act.cacheKey); err == nil {
+			act.cached = data
+			p.state[act] = 1
+			return
+		}
+	}
+	act.cached = nil
+	p.state[act] = 2
+	for _, dep := range act.deps {
+		// A horizontal dependency runs for its result.
+		p.visit(dep, dep.pkg == act.pkg)
+	}
+}
+
+// key returns the key of the cache entry of act, or nil if it has none.
+func (p *cachePlan) key(act *action) *[sha256.Size]byte {
+	if key, ok := p.keys[act]; ok {
+		return key
+	}
+	p.keys[act] = nil // in case of error
+	pkgHash := p.pkgHash(act.pkg)
+	if pkgHash == nil {
+		return nil
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%x\n", cacheVersion, p.exe)
+	fmt.Fprintf(h, "analyzer %s\n", act.a.Name)
+	act.a.Flags.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "flag %s=%s\n", f.Name, f.Value)
+	})
+	fmt.Fprintf(h, "package %x\n", *pkgHash)
+	for _, dep := range act.deps {
+		depKey := p.key(dep)
+		if depKey == nil {
+			return nil
+		}
+		fmt.Fprintf(h, "dep %x\n", *depKey)
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	p.keys[act] = &key
+	return &key
+}
+
+// pkgHash returns a hash of the content of the package and of its
+// dependencies, or nil if some file cannot be read.
+func (p *cachePlan) pkgHash(pkg *packages.Package) *[sha256.Size]byte {
+	if hash, ok := p.pkgHashes[pkg]; ok {
+		return hash
+	}
+	p.pkgHashes[pkg] = nil // in case of error
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%t\n%v\n", pkg.ID, pkg.PkgPath, pkg.IllTyped, pkg.TypesSizes)
+	for _, names := range [][]string{pkg.CompiledGoFiles, pkg.OtherFiles, pkg.IgnoredFiles} {
+		for _, name := range names {
+			data, err := readFile(name)
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(h, "file %s %x\n", name, sha256.Sum256(data))
+		}
+	}
+	for _, err := range pkg.Errors {
+		fmt.Fprintf(h, "error %s\n", err)
+	}
+	paths := make([]string, 0, len(pkg.Imports))
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		impHash := p.pkgHash(pkg.Imports[path])
+		if impHash == nil {
+			return nil
+		}
+		fmt.Fprintf(h, "import %s %x\n", path, *impHash)
+	}
+	var hash [sha256.Size]byte
+	h.Sum(hash[:0])
+	p.pkgHashes[pkg] = &hash
+	return &hash
+}
+
+// executableHash returns a hash of the running executable, whose
+// analyzers determine the content of the cache.
+func executableHash() ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	exe, err := os.Executable()
+	if err != nil {
+		return hash, err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return hash, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return hash, err
+	}
+	h.Sum(hash[:0])
+	return hash, nil
+}
+
+// loadCache sets the facts and diagnostics of act from its cache entry.
+func (act *action) loadCache() error {
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(act.cached)).Decode(&entry); err != nil {
+		return err
+	}
+	act.objectFacts = make(map[objectFactKey]analysis.Fact)
+	act.packageFacts = make(map[packageFactKey]analysis.Fact)
+	if len(act.a.FactTypes) > 0 {
+		set, err := facts.DecodeOwn(act.pkg.Types, entry.Facts)
+		if err != nil {
+			return err
+		}
+		filter := make(map[reflect.Type]bool)
+		for _, f := range act.a.FactTypes {
+			filter[reflect.TypeOf(f)] = true
+		}
+		for _, f := range set.AllObjectFacts(filter) {
+			act.objectFacts[objectFactKey{f.Object, factType(f.Fact)}] = f.Fact
+		}
+		for _, f := range set.AllPackageFacts(filter) {
+			act.packageFacts[packageFactKey{f.Package, factType(f.Fact)}] = f.Fact
+		}
+	}
+
+	files := cachedFiles(act.pkg.Fset)
+	pos := func(p cachedPos) token.Pos { return files.pos(act.pkg.Fset, p) }
+	for _, d := range entry.Diagnostics {
+		diag := analysis.Diagnostic{
+			Pos:      pos(d.Pos),
+			End:      pos(d.End),
+			Category: d.Category,
+			Message:  d.Message,
+		}
+		for _, fix := range d.Fixes {
+			sf := analysis.SuggestedFix{Message: fix.Message}
+			for _, edit := range fix.Edits {
+				sf.TextEdits = append(sf.TextEdits, analysis.TextEdit{
+					Pos:     pos(edit.Pos),
+					End:     pos(edit.End),
+					NewText: edit.NewText,
+				})
+			}
+			diag.SuggestedFixes = append(diag.SuggestedFixes, sf)
+		}
+		for _, rel := range d.Related {
+			diag.Related = append(diag.Related, analysis.RelatedInformation{
+				Pos:     pos(rel.Pos),
+				End:     pos(rel.End),
+				Message: rel.Message,
+			})
+		}
+		act.diagnostics = append(act.diagnostics, diag)
+	}
+	return nil
+}
+
+// storeCache writes the facts and diagnostics of act to the cache.
+func (act *action) storeCache() error {
+	var entry cacheEntry
+	if len(act.a.FactTypes) > 0 {
+		entry.Facts = facts.NewSet(act.pkg.Types, act.allObjectFacts(), act.allPackageFacts()).Encode()
+	}
+	fset := act.pkg.Fset
+	pos := func(pos token.Pos) cachedPos {
+		if tok := fset.File(pos); tok != nil {
+			return cachedPos{tok.Name(), tok.Offset(pos)}
+		}
+		return cachedPos{}
+	}
+	for _, diag := range act.diagnostics {
+		d := cachedDiagnostic{
+			Pos:      pos(diag.Pos),
+			End:      pos(diag.End),
+			Category: diag.Category,
+			Message:  diag.Message,
+		}
+		for _, fix := range diag.SuggestedFixes {
+			cf := cachedFix{Message: fix.Message}
+			for _, edit := range fix.TextEdits {
+				cf.Edits = append(cf.Edits, cachedEdit{pos(edit.Pos), pos(edit.End), edit.NewText})
+			}
+			d.Fixes = append(d.Fixes, cf)
+		}
+		for _, rel := range diag.Related {
+			d.Related = append(d.Related, cachedRelated{pos(rel.Pos), pos(rel.End), rel.Message})
+		}
+		entry.Diagnostics = append(entry.Diagnostics, d)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	return writeCache(*act.cacheKey, buf.Bytes())
+}
+
+// A fileIndex maps the names of the files of a file set to their
+// token.File.
+type fileIndex struct {
+	mu    sync.Mutex
+	files map[string]*token.File
+}
+
+var (
+	fileIndexesMu sync.Mutex
+	fileIndexes   = make(map[*token.FileSet]*fileIndex)
+)
+
+// cachedFiles returns the index of the files of fset.
+func cachedFiles(fset *token.FileSet) *fileIndex {
+	fileIndexesMu.Lock()
+	defer fileIndexesMu.Unlock()
+	index, ok := fileIndexes[fset]
+	if !ok {
+		index = &fileIndex{files: make(map[string]*token.File)}
+		fset.Iterate(func(f *token.File) bool {
+			index.files[f.Name()] = f
+			return true
+		})
+		fileIndexes[fset] = index
+	}
+	return index
+}
+
+// pos returns the position of p in fset. A file that is not in fset,
+// such as an assembly file that an analyzer would read, is added to it.
+func (index *fileIndex) pos(fset *token.FileSet, p cachedPos) token.Pos {
+	if p.File == "" {
+		return token.NoPos
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	tok, ok := index.files[p.File]
+	if !ok {
+		if data, err := readFile(p.File); err == nil {
+			tok = fset.AddFile(p.File, -1, len(data))
+			tok.SetLinesForContent(data)
+		}
+		index.files[p.File] = tok
+	}
+	if tok == nil || p.Offset > tok.Size() {
+		return token.NoPos
+	}
+	return tok.Pos(p.Offset)
+}
+
+// cacheFile returns the name of the file of the cache entry of the key.
+func cacheFile(key [sha256.Size]byte) string {
+	hex := fmt.Sprintf("%x", key)
+	return filepath.Join(CacheDir, hex[:2], hex)
+}
+
+// readCache returns the cache entry of the key. The entry ends with its
+// checksum: a truncated or corrupt file is reported as a missing one.
+func readCache(key [sha256.Size]byte) ([]byte, error) {
+	data, err := ioutil.ReadFile(cacheFile(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sha256.Size {
+		return nil, fs.ErrNotExist
+	}
+	value, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if want := sha256.Sum256(value); !bytes.Equal(sum, want[:]) {
+		return nil, fs.ErrNotExist
+	}
+	return value, nil
+}
+
+// writeCache writes the cache entry of the key, atomically so that
+// concurrent processes never read a partial entry.
+func writeCache(key [sha256.Size]byte, value []byte) error {
+	name := cacheFile(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(value)
+	_, err = tmp.Write(append(value, sum[:]...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name()) // ignore error
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+)
+
+type cacheFact struct{ N int }
+
+func (*cacheFact) AFact() {}
+
+func TestCache(t *testing.T) {
+	gob.Register(new(cacheFact))
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", "package p\n\nfunc F() {}\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &packages.Package{PkgPath: "p", Fset: fset, Syntax: []*ast.File{f}, Types: tpkg}
+	a := &analysis.Analyzer{Name: "a", FactTypes: []analysis.Fact{new(cacheFact)}}
+
+	obj := tpkg.Scope().Lookup("F")
+	diag := analysis.Diagnostic{
+		Pos:     obj.Pos(),
+		End:     f.End(),
+		Message: "message",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "fix",
+			TextEdits: []analysis.TextEdit{{Pos: obj.Pos(), End: obj.Pos() + 1, NewText: []byte("G")}},
+		}},
+		Related: []analysis.RelatedInformation{{Pos: f.Package, Message: "related"}},
+	}
+	key := sha256.Sum256([]byte("key"))
+	act := &action{
+		a:            a,
+		pkg:          pkg,
+		objectFacts:  map[objectFactKey]analysis.Fact{{obj, factType(new(cacheFact))}: &cacheFact{1}},
+		packageFacts: map[packageFactKey]analysis.Fact{{tpkg, factType(new(cacheFact))}: &cacheFact{2}},
+		diagnostics:  []analysis.Diagnostic{diag},
+		cacheKey:     &key,
+	}
+	if err := act.storeCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readCache(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &action{a: a, pkg: pkg, cached: data}
+	if err := loaded.loadCache(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.objectFacts, act.objectFacts) {
+		t.Errorf("object facts: got %v, want %v", loaded.objectFacts, act.objectFacts)
+	}
+	if !reflect.DeepEqual(loaded.packageFacts, act.packageFacts) {
+		t.Errorf("package facts: got %v, want %v", loaded.packageFacts, act.packageFacts)
+	}
+	if !reflect.DeepEqual(loaded.diagnostics, act.diagnostics) {
+		t.Errorf("diagnostics: got %+v, want %+v", loaded.diagnostics, act.diagnostics)
+	}
+
+	// A corrupt entry is missing.
+	if err := ioutil.WriteFile(cacheFile(key), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCache(key); err == nil {
+		t.Errorf("readCache of a corrupt entry succeeded")
+	}
+}
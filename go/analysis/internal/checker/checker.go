@@ -10,15 +10,13 @@ package checker
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/gob"
 	"errors"
 	"flag"
 	"fmt"
-	"go/format"
-	"go/parser"
 	"go/token"
 	"go/types"
-	"io/ioutil"
 	"log"
 	"os"
 	"reflect"
@@ -56,6 +54,27 @@ var (
 
 	// Fix determines whether to apply all suggested fixes.
 	Fix bool
+
+	// Diff determines whether to print all suggested fixes as unified
+	// diffs instead of applying them. It may not be set with Fix.
+	Diff bool
+
+	// DiffBase is a git revision: if set, only the diagnostics on lines
+	// added or modified since that revision are reported.
+	DiffBase string
+
+	// CacheDir is the directory of a persistent cache of the facts and
+	// diagnostics of each analysis of each package: if set, the analyses
+	// whose inputs did not change since a previous run are not run again.
+	CacheDir string
+
+	// Workers is the number of analysis actions that execute in parallel.
+	Workers = runtime.GOMAXPROCS(0)
+
+	// Stats determines whether to print the CPU time spent running
+	// each analyzer. On platforms that do not measure the CPU time of a
+	// thread, the wall time is printed instead.
+	Stats bool
 )
 
 // RegisterFlags registers command-line flags used by the analysis driver.
@@ -71,6 +90,11 @@ func RegisterFlags() {
 	flag.BoolVar(&IncludeTests, "test", IncludeTests, "indicates whether test files should be analyzed, too")
 
 	flag.BoolVar(&Fix, "fix", false, "apply all suggested fixes")
+	flag.BoolVar(&Diff, "diff", false, "print all suggested fixes as unified diffs instead of applying them")
+	flag.StringVar(&DiffBase, "diff-base", "", "report only diagnostics on lines changed since this git revision")
+	flag.StringVar(&CacheDir, "cache", "", "cache the facts and diagnostics of analyses in this directory")
+	flag.IntVar(&Workers, "p", Workers, "number of analyses that can run in parallel")
+	flag.BoolVar(&Stats, "stats", false, "print the CPU time spent running each analyzer")
 }
 
 // Run loads the packages specified by args using go/packages,
@@ -80,6 +104,11 @@ func RegisterFlags() {
 // singlechecker and the multi-analysis commands.
 // It returns the appropriate exit code.
 func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
+	if Fix && Diff {
+		log.Print("-fix and -diff are mutually exclusive: -diff prints the fixes instead of applying them")
+		return 1
+	}
+
 	if CPUProfile != "" {
 		f, err := os.Create(CPUProfile)
 		if err != nil {
@@ -131,7 +160,7 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 
 	// Optimization: if the selected analyzers don't produce/consume
 	// facts, we need source only for the initial packages.
-	allSyntax := needFacts(analyzers)
+	allSyntax := NeedFacts(analyzers)
 	initial, err := load(args, allSyntax)
 	if err != nil {
 		if _, ok := err.(typeParseError); !ok {
@@ -144,12 +173,36 @@ func Run(args []string, analyzers []*analysis.Analyzer) (exitcode int) {
 	}
 
 	// Print the results.
-	roots := analyze(initial, analyzers)
+	t0 := time.Now()
+	roots, err := analyzeRoots(initial, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if Stats {
+		printStats(roots, time.Since(t0))
+	}
+	if DiffBase != "" {
+		if err := filterChangedLines(roots); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
 
-	if Fix {
-		applyFixes(roots)
+	unfixed := 0
+	if Fix || Diff {
+		roots, unfixed, err = applyFixes(args, analyzers, roots)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+	exitcode = printDiagnostics(roots)
+	if unfixed > 0 {
+		log.Printf("could not apply the suggested fixes of %d diagnostics", unfixed)
+		exitcode = 1
 	}
-	return printDiagnostics(roots)
+	return exitcode
 }
 
 // typeParseError represents a package load error
@@ -166,8 +219,9 @@ func load(patterns []string, allSyntax bool) ([]*packages.Package, error) {
 		mode = packages.LoadAllSyntax
 	}
 	conf := packages.Config{
-		Mode:  mode,
-		Tests: IncludeTests,
+		Mode:    mode,
+		Tests:   IncludeTests,
+		Overlay: overlay,
 	}
 	initial, err := packages.Load(&conf, patterns...)
 	if err == nil {
@@ -217,7 +271,7 @@ func loadingError(initial []*packages.Package) error {
 // This entry point is used only by analysistest.
 func TestAnalyzer(a *analysis.Analyzer, pkgs []*packages.Package) []*TestAnalyzerResult {
 	var results []*TestAnalyzerResult
-	for _, act := range analyze(pkgs, []*analysis.Analyzer{a}) {
+	for _, act := range analyze(pkgs, []*analysis.Analyzer{a}, nil) {
 		facts := make(map[types.Object][]analysis.Fact)
 		for key, fact := range act.objectFacts {
 			if key.obj.Pkg() == act.pass.Pkg {
@@ -230,7 +284,21 @@ func TestAnalyzer(a *analysis.Analyzer, pkgs []*packages.Package) []*TestAnalyze
 			}
 		}
 
-		results = append(results, &TestAnalyzerResult{act.pass, act.diagnostics, facts, act.result, act.err})
+		pass := act.pass
+		if act.program != nil {
+			// A whole-program analyzer has no pass for each package.
+			pass = &analysis.Pass{
+				Analyzer:     act.a,
+				Fset:         act.pkg.Fset,
+				Files:        act.pkg.Syntax,
+				OtherFiles:   act.pkg.OtherFiles,
+				IgnoredFiles: act.pkg.IgnoredFiles,
+				Pkg:          act.pkg.Types,
+				TypesInfo:    act.pkg.TypesInfo,
+				TypesSizes:   act.pkg.TypesSizes,
+			}
+		}
+		results = append(results, &TestAnalyzerResult{pass, act.diagnostics, facts, act.result, act.err})
 	}
 	return results
 }
@@ -243,7 +311,11 @@ type TestAnalyzerResult struct {
 	Err         error
 }
 
-func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action {
+// analyze applies the analyzers to the packages, and returns the root
+// actions. If finish is not nil, it is called with the root actions on
+// each package once all the actions on it have run, after which the
+// syntax and type information of the package are released (see execAll).
+func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer, finish func(*packages.Package, []*action)) []*action {
 	// Construct the action graph.
 	if dbg('v') {
 		log.Printf("building graph of analysis passes")
@@ -292,6 +364,13 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 	// Build nodes for initial packages.
 	var roots []*action
 	for _, a := range analyzers {
+		if a.RunProgram != nil {
+			for _, root := range programActions(a, pkgs, mkAction) {
+				root.isroot = true
+				roots = append(roots, root)
+			}
+			continue
+		}
 		for _, pkg := range pkgs {
 			root := mkAction(a, pkg)
 			root.isroot = true
@@ -299,149 +378,126 @@ func analyze(pkgs []*packages.Package, analyzers []*analysis.Analyzer) []*action
 		}
 	}
 
+	if CacheDir != "" {
+		planCache(roots)
+	}
+
 	// Execute the graph in parallel.
-	execAll(roots)
+	execAll(roots, finish)
 
 	return roots
 }
 
-func applyFixes(roots []*action) {
-	visited := make(map[*action]bool)
-	var apply func(*action) error
-	var visitAll func(actions []*action) error
-	visitAll = func(actions []*action) error {
-		for _, act := range actions {
-			if !visited[act] {
-				visited[act] = true
-				visitAll(act.deps)
-				if err := apply(act); err != nil {
-					return err
-				}
-			}
-		}
-		return nil
-	}
-
-	// TODO(matloob): Is this tree business too complicated? (After all this is Go!)
-	// Just create a set (map) of edits, sort by pos and call it a day?
-	type offsetedit struct {
-		start, end int
-		newText    []byte
-	} // TextEdit using byteOffsets instead of pos
-	type node struct {
-		edit        offsetedit
-		left, right *node
+// analyzeRoots applies the analyzers to the packages, and returns the
+// root actions, with their diagnostics filtered by a finisher.
+func analyzeRoots(pkgs []*packages.Package, analyzers []*analysis.Analyzer) ([]*action, error) {
+	f, err := newFinisher()
+	if err != nil {
+		return nil, err
 	}
+	return f.done(analyze(pkgs, analyzers, f.finish))
+}
 
-	var insert func(tree **node, edit offsetedit) error
-	insert = func(treeptr **node, edit offsetedit) error {
-		if *treeptr == nil {
-			*treeptr = &node{edit, nil, nil}
-			return nil
-		}
-		tree := *treeptr
-		if edit.end <= tree.edit.start {
-			return insert(&tree.left, edit)
-		} else if edit.start >= tree.edit.end {
-			return insert(&tree.right, edit)
-		}
+// A finisher removes from the root actions on each package the
+// diagnostics suppressed by //lint:ignore directives and those recorded
+// in the -baseline file, or with -write-baseline records them in the file
+// instead of reporting them. It does so as soon as the actions on the
+// package have run, before its syntax is released.
+type finisher struct {
+	baseline *analysisflags.Baseline // nil without -baseline
 
-		// Overlapping text edit.
-		return fmt.Errorf("analyses applying overlapping text edits affecting pos range (%v, %v) and (%v, %v)",
-			edit.start, edit.end, tree.edit.start, tree.edit.end)
+	mu       sync.Mutex                    // guards the baseline with -write-baseline, and problems
+	problems map[*packages.Package]*action // of analysisflags.IgnoreAnalyzer
+}
 
+func newFinisher() (*finisher, error) {
+	f := &finisher{problems: make(map[*packages.Package]*action)}
+	switch {
+	case analysisflags.BaselineFile == "":
+	case analysisflags.WriteBaseline:
+		f.baseline = analysisflags.NewBaseline()
+	default:
+		b, err := analysisflags.ReadBaseline(analysisflags.BaselineFile)
+		if err != nil {
+			return nil, err
+		}
+		f.baseline = b
 	}
+	return f, nil
+}
 
-	editsForFile := make(map[*token.File]*node)
-
-	apply = func(act *action) error {
-		for _, diag := range act.diagnostics {
-			for _, sf := range diag.SuggestedFixes {
-				for _, edit := range sf.TextEdits {
-					// Validate the edit.
-					if edit.Pos > edit.End {
-						return fmt.Errorf(
-							"diagnostic for analysis %v contains Suggested Fix with malformed edit: pos (%v) > end (%v)",
-							act.a.Name, edit.Pos, edit.End)
-					}
-					file, endfile := act.pkg.Fset.File(edit.Pos), act.pkg.Fset.File(edit.End)
-					if file == nil || endfile == nil || file != endfile {
-						return (fmt.Errorf(
-							"diagnostic for analysis %v contains Suggested Fix with malformed spanning files %v and %v",
-							act.a.Name, file.Name(), endfile.Name()))
-					}
-					start, end := file.Offset(edit.Pos), file.Offset(edit.End)
-
-					// TODO(matloob): Validate that edits do not affect other packages.
-					root := editsForFile[file]
-					if err := insert(&root, offsetedit{start, end, edit.NewText}); err != nil {
-						return err
-					}
-					editsForFile[file] = root // In case the root changed
-				}
+// finish filters the diagnostics of the root actions on the package, and
+// records the problems of its //lint:ignore directives in an action of
+// analysisflags.IgnoreAnalyzer.
+func (f *finisher) finish(pkg *packages.Package, roots []*action) {
+	diagnostics := make(map[*analysis.Analyzer][]analysis.Diagnostic)
+	for _, act := range roots {
+		if act.err == nil { // analysis failed; its directives may not be unused
+			diagnostics[act.a] = act.diagnostics
+		}
+	}
+	if len(diagnostics) > 0 {
+		if diags := analysisflags.Suppress(pkg.Fset, pkg.Syntax, diagnostics); len(diags) > 0 {
+			problem := &action{
+				a:           analysisflags.IgnoreAnalyzer,
+				pkg:         pkg,
+				isroot:      true,
+				diagnostics: diags,
+			}
+			roots = append(roots[:len(roots):len(roots)], problem)
+			f.mu.Lock()
+			f.problems[pkg] = problem
+			f.mu.Unlock()
+		}
+		for _, act := range roots {
+			if diags, ok := diagnostics[act.a]; ok && act.err == nil {
+				act.diagnostics = diags
 			}
 		}
-		return nil
 	}
 
-	visitAll(roots)
-
-	fset := token.NewFileSet() // Shared by parse calls below
-	// Now we've got a set of valid edits for each file. Get the new file contents.
-	for f, tree := range editsForFile {
-		contents, err := ioutil.ReadFile(f.Name())
-		if err != nil {
-			log.Fatal(err)
+	if f.baseline == nil {
+		return
+	}
+	for _, act := range roots {
+		if analysisflags.WriteBaseline {
+			f.mu.Lock()
+			f.baseline.Add(pkg.Fset, pkg.Syntax, act.a.Name, pkg.PkgPath, act.diagnostics)
+			f.mu.Unlock()
+			act.diagnostics = nil
+		} else {
+			act.diagnostics = f.baseline.Filter(pkg.Fset, pkg.Syntax, act.a.Name, pkg.PkgPath, act.diagnostics)
 		}
+	}
+}
 
-		cur := 0 // current position in the file
-
-		var out bytes.Buffer
-
-		var recurse func(*node)
-		recurse = func(node *node) {
-			if node.left != nil {
-				recurse(node.left)
-			}
-
-			edit := node.edit
-			if edit.start > cur {
-				out.Write(contents[cur:edit.start])
-				out.Write(edit.newText)
-			}
-			cur = edit.end
-
-			if node.right != nil {
-				recurse(node.right)
-			}
+// done returns the root actions along with the actions of the problems
+// of the directives of each package, and with -write-baseline writes
+// the baseline file.
+func (f *finisher) done(roots []*action) ([]*action, error) {
+	if analysisflags.WriteBaseline && f.baseline != nil {
+		if err := f.baseline.Write(analysisflags.BaselineFile); err != nil {
+			return nil, err
 		}
-		recurse(tree)
-		// Write out the rest of the file.
-		if cur < len(contents) {
-			out.Write(contents[cur:])
-		}
-
-		// Try to format the file.
-		ff, err := parser.ParseFile(fset, f.Name(), out.Bytes(), parser.ParseComments)
-		if err == nil {
-			var buf bytes.Buffer
-			if err = format.Node(&buf, fset, ff); err == nil {
-				out = buf
-			}
+	}
+	var problems []*action
+	for _, act := range roots {
+		if problem, ok := f.problems[act.pkg]; ok {
+			problems = append(problems, problem)
+			delete(f.problems, act.pkg)
 		}
-
-		ioutil.WriteFile(f.Name(), out.Bytes(), 0644)
 	}
+	return append(roots, problems...), nil
 }
 
-// printDiagnostics prints the diagnostics for the root packages in either
-// plain text or JSON format. JSON format also includes errors for any
-// dependencies.
+// printDiagnostics prints the diagnostics for the root packages in
+// plain text, JSON or SARIF format. JSON and SARIF formats also include
+// errors for any dependencies.
 //
 // It returns the exitcode: in plain mode, 0 for success, 1 for analysis
 // errors, and 3 for diagnostics. We avoid 2 since the flag package uses
-// it. JSON mode always succeeds at printing errors and diagnostics in a
-// structured form to stdout.
+// it. JSON and SARIF modes always succeed at printing errors and
+// diagnostics in a structured form to stdout.
 func printDiagnostics(roots []*action) (exitcode int) {
 	// Print the output.
 	//
@@ -460,7 +516,19 @@ func printDiagnostics(roots []*action) (exitcode int) {
 		}
 	}
 
-	if analysisflags.JSON {
+	if analysisflags.SARIF {
+		// SARIF output
+		sarif := analysisflags.NewSARIFLog()
+		print = func(act *action) {
+			var diags []analysis.Diagnostic
+			if act.isroot {
+				diags = act.diagnostics
+			}
+			sarif.Add(act.pkg.Fset, act.a, diags, act.err)
+		}
+		visitAll(roots)
+		sarif.Print()
+	} else if analysisflags.JSON {
 		// JSON output
 		tree := make(analysisflags.JSONTree)
 		print = func(act *action) {
@@ -545,9 +613,9 @@ func printDiagnostics(roots []*action) (exitcode int) {
 	return exitcode
 }
 
-// needFacts reports whether any analysis required by the specified set
+// NeedFacts reports whether any analysis required by the specified set
 // needs facts.  If so, we must load the entire program from source.
-func needFacts(analyzers []*analysis.Analyzer) bool {
+func NeedFacts(analyzers []*analysis.Analyzer) bool {
 	seen := make(map[*analysis.Analyzer]bool)
 	var q []*analysis.Analyzer // for BFS
 	q = append(q, analyzers...)
@@ -582,6 +650,10 @@ type action struct {
 	diagnostics  []analysis.Diagnostic
 	err          error
 	duration     time.Duration
+	cpuTime      time.Duration      // if Stats and haveThreadCPUTime
+	cacheKey     *[sha256.Size]byte // nil if not cacheable
+	cached       []byte             // cache entry, if read from the cache
+	program      *program           // for a whole-program analyzer
 }
 
 type objectFactKey struct {
@@ -598,29 +670,22 @@ func (act *action) String() string {
 	return fmt.Sprintf("%s@%s", act.a, act.pkg)
 }
 
-func execAll(actions []*action) {
-	sequential := dbg('p')
-	var wg sync.WaitGroup
-	for _, act := range actions {
-		wg.Add(1)
-		work := func(act *action) {
-			act.exec()
-			wg.Done()
-		}
-		if sequential {
-			work(act)
-		} else {
-			go work(act)
-		}
-	}
-	wg.Wait()
-}
-
 func (act *action) exec() { act.once.Do(act.execOnce) }
 
 func (act *action) execOnce() {
-	// Analyze dependencies.
-	execAll(act.deps)
+	if act.program != nil {
+		act.execProgram()
+		return
+	}
+
+	// An action read from the cache needs neither its dependencies
+	// nor running.
+	if act.cached != nil {
+		if err := act.loadCache(); err != nil {
+			act.err = fmt.Errorf("reading analysis cache: %v", err)
+		}
+		return
+	}
 
 	// TODO(adonovan): uncomment this during profiling.
 	// It won't build pre-go1.11 but conditional compilation
@@ -630,16 +695,6 @@ func (act *action) execOnce() {
 	// trace.Log(ctx, "pass", act.String())
 	// defer task.End()
 
-	// Record time spent in this node but not its dependencies.
-	// In parallel mode, due to GC/scheduler contention, the
-	// time is 5x higher than in sequential mode, even with a
-	// semaphore limiting the number of threads here.
-	// So use -debug=tp.
-	if dbg('t') {
-		t0 := time.Now()
-		defer func() { act.duration = time.Since(t0) }()
-	}
-
 	// Report an error if any dependency failed.
 	var failed []string
 	for _, dep := range act.deps {
@@ -742,6 +797,12 @@ func (act *action) execOnce() {
 	// disallow calls after Run
 	pass.ExportObjectFact = nil
 	pass.ExportPackageFact = nil
+
+	if act.cacheKey != nil && act.err == nil {
+		if err := act.storeCache(); err != nil && dbg('v') {
+			log.Printf("writing analysis cache for %s: %v", act, err)
+		}
+	}
 }
 
 // inheritFacts populates act.facts with
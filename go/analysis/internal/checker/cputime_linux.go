@@ -0,0 +1,24 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"syscall"
+	"time"
+)
+
+// haveThreadCPUTime reports whether threadCPUTime measures the CPU time
+// of a thread.
+const haveThreadCPUTime = true
+
+// threadCPUTime returns the CPU time, user and system, consumed so far
+// by the calling thread.
+func threadCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,18 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package checker
+
+import "time"
+
+// haveThreadCPUTime reports whether threadCPUTime measures the CPU time
+// of a thread.
+const haveThreadCPUTime = false
+
+// threadCPUTime returns the CPU time, user and system, consumed so far
+// by the calling thread.
+func threadCPUTime() time.Duration { return 0 }
@@ -0,0 +1,175 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+)
+
+// filterChangedLines removes from the root actions the diagnostics that
+// do not touch a line added or modified since the -diff-base revision.
+func filterChangedLines(roots []*action) error {
+	changed, err := changedLines("", DiffBase)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]string) // file names with symbolic links evaluated
+	touches := func(filename string, start, end int) bool {
+		real, ok := resolved[filename]
+		if !ok {
+			real = filename
+			if r, err := filepath.EvalSymlinks(filename); err == nil {
+				real = r
+			}
+			resolved[filename] = real
+		}
+		lines := changed[real]
+		if lines[0] {
+			return true // untracked file
+		}
+		for line := start; line <= end; line++ {
+			if lines[line] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, act := range roots {
+		var kept []analysis.Diagnostic
+		for _, diag := range act.diagnostics {
+			posn := act.pkg.Fset.PositionFor(diag.Pos, false)
+			end := posn.Line
+			if diag.End.IsValid() {
+				end = act.pkg.Fset.PositionFor(diag.End, false).Line
+			}
+			if touches(posn.Filename, posn.Line, end) {
+				kept = append(kept, diag)
+			}
+		}
+		act.diagnostics = kept
+	}
+	return nil
+}
+
+// changedLines returns the lines added or modified since revision rev in
+// the files of the git work tree that contains dir, or the current
+// directory if dir is empty, by absolute file name. All the lines of the
+// untracked files are changed, which line 0 represents.
+func changedLines(dir, rev string) (map[string]map[int]bool, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	diff, err := git(root, "diff", "--no-color", "--no-ext-diff", "--unified=0", "--src-prefix=a/", "--dst-prefix=b/", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := parseDiff(bytes.NewReader(diff), root)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git(root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name == "" {
+			continue
+		}
+		changed[filepath.Join(root, filepath.FromSlash(name))] = map[int]bool{0: true}
+	}
+	return changed, nil
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-c", "core.quotePath=false"}, args...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// parseDiff returns the lines added or modified by a diff with no
+// context lines, by absolute file name relative to root.
+func parseDiff(r io.Reader, root string) (map[string]map[int]bool, error) {
+	changed := make(map[string]map[int]bool)
+	var (
+		lines  map[int]bool // of the current file, or nil if deleted
+		header bool         // within the header of the diff of a file
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			header, lines = true, nil
+
+		case header && strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if strings.HasPrefix(name, `"`) {
+				unquoted, err := strconv.Unquote(name)
+				if err != nil {
+					return nil, fmt.Errorf("invalid file name in diff: %s", name)
+				}
+				name = unquoted
+			}
+			if name == "/dev/null" {
+				lines = nil
+				continue
+			}
+			filename := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+			lines = changed[filename]
+			if lines == nil {
+				lines = make(map[int]bool)
+				changed[filename] = lines
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			header = false
+			if lines == nil {
+				continue
+			}
+			// @@ -start[,count] +start[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("invalid hunk header in diff: %s", line)
+			}
+			added := strings.TrimPrefix(fields[2], "+")
+			count := 1
+			if i := strings.IndexByte(added, ','); i >= 0 {
+				n, err := strconv.Atoi(added[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid hunk header in diff: %s", line)
+				}
+				added, count = added[:i], n
+			}
+			start, err := strconv.Atoi(added)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hunk header in diff: %s", line)
+			}
+			for i := start; i < start+count; i++ {
+				lines[i] = true
+			}
+		}
+	}
+	return changed, scanner.Err()
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/testenv"
+)
+
+func TestChangedLines(t *testing.T) {
+	testenv.NeedsTool(t, "git")
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("a.go", "package a\n\nvar x = 1\n\nvar y = 2\n")
+	write("gone.go", "package a\n")
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	// Modify line 3, add lines 5 and 6 (which looks like a diff header),
+	// remove a file and add an untracked one.
+	write("a.go", "package a\n\nvar x = 10\n\nvar z = 3\n++ b/c.go\nvar y = 2\n")
+	run("rm", "-q", "gone.go")
+	write("new.go", "package a\n")
+
+	got, err := changedLines(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[int]bool{
+		filepath.Join(dir, "a.go"):   {3: true, 5: true, 6: true},
+		filepath.Join(dir, "new.go"): {0: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changedLines: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+)
+
+// execAll executes the graph of actions of roots with a bounded number
+// of workers, each action after all its dependencies.
+//
+// Once all the actions on a package that is not that of a root have
+// finished, its syntax trees and type information are released, as are
+// the results and passes of the actions that are not roots once all
+// the actions that depend on them have finished. Only the facts remain.
+// If finish is not nil, so are those of a root package and of the root
+// actions on it, once all the actions on it have finished and finish has
+// been called with its root actions.
+func execAll(roots []*action, finish func(*packages.Package, []*action)) {
+	// Find the actions to execute, and the dependencies each one waits
+	// for. The dependencies of an action read from the cache do not run,
+	// unless another action needs them. The actions of a whole-program
+	// analyzer on all but the first package wait for that one, which
+	// runs the analyzer.
+	var (
+		all        []*action
+		waits      = make(map[*action][]*action) // dependencies of each action
+		pending    = make(map[*action]int)       // number of unfinished dependencies
+		dependents = make(map[*action][]*action) // actions waiting for each action
+		users      = make(map[*action]int)       // number of unfinished dependents
+		pkgActions = make(map[*packages.Package]int)
+		rootActs   = make(map[*packages.Package][]*action)
+		programs   = make(map[*program]*action)
+	)
+	var visit func(act *action)
+	visit = func(act *action) {
+		if _, ok := pending[act]; ok {
+			return
+		}
+		pending[act] = 0
+		var deps []*action
+		if act.cached == nil {
+			deps = act.deps
+		}
+		if act.program != nil {
+			if first, ok := programs[act.program]; ok {
+				deps = append(deps[:len(deps):len(deps)], first)
+			} else {
+				programs[act.program] = act
+			}
+		}
+		for _, dep := range deps {
+			visit(dep)
+			pending[act]++
+			dependents[dep] = append(dependents[dep], act)
+			users[dep]++
+		}
+		waits[act] = deps
+		all = append(all, act)
+		pkgActions[act.pkg]++
+	}
+	for _, act := range roots {
+		visit(act)
+		rootActs[act.pkg] = append(rootActs[act.pkg], act)
+	}
+	if len(all) == 0 {
+		return
+	}
+
+	// The queue has room for all the actions, so that finishing an
+	// action never blocks.
+	ready := make(chan *action, len(all))
+	for _, act := range all {
+		if pending[act] == 0 {
+			ready <- act
+		}
+	}
+
+	var mu sync.Mutex // guards the maps and remaining
+	remaining := len(all)
+	done := func(act *action) {
+		mu.Lock()
+		for _, succ := range dependents[act] {
+			if pending[succ]--; pending[succ] == 0 {
+				ready <- succ
+			}
+		}
+		for _, dep := range waits[act] {
+			if users[dep]--; users[dep] == 0 && !dep.isroot {
+				dep.release()
+			}
+		}
+		var finished []*action // the root actions on a finished root package
+		if pkgActions[act.pkg]--; pkgActions[act.pkg] == 0 {
+			if acts, ok := rootActs[act.pkg]; !ok {
+				releasePackage(act.pkg)
+			} else if finish != nil {
+				finished = acts
+			}
+		}
+		if remaining--; remaining == 0 {
+			close(ready)
+		}
+		mu.Unlock()
+
+		if finished != nil {
+			finish(act.pkg, finished)
+			for _, root := range finished {
+				root.release()
+			}
+			releasePackage(act.pkg)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for act := range ready {
+				t0 := time.Now()
+				if Stats && haveThreadCPUTime {
+					act.cpuTime = execOnThread(act)
+				} else {
+					act.exec()
+				}
+				act.duration = time.Since(t0)
+				done(act)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// workers returns the number of actions that execute in parallel.
+func workers() int {
+	if Workers < 1 || dbg('p') {
+		return 1
+	}
+	return Workers
+}
+
+// execOnThread executes an action locked to the thread of its goroutine,
+// and returns the CPU time spent by the thread. It does not count the
+// goroutines that the analyzer starts, if any.
+func execOnThread(act *action) time.Duration {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	t0 := threadCPUTime()
+	act.exec()
+	return threadCPUTime() - t0
+}
+
+// release drops the result and pass of an action, keeping its facts.
+func (act *action) release() {
+	act.result = nil
+	act.pass = nil
+}
+
+// releasePackage drops the syntax trees and type information of a
+// package, keeping its types, to which the facts refer.
+func releasePackage(pkg *packages.Package) {
+	pkg.Syntax = nil
+	pkg.TypesInfo = nil
+}
+
+// printStats prints the CPU time spent running each analyzer, or the wall
+// time if the platform does not measure it, summed over the packages, from
+// the longest to the shortest. As the actions run in parallel, their total
+// may exceed the elapsed time.
+func printStats(roots []*action, elapsed time.Duration) {
+	type stat struct {
+		a        *analysis.Analyzer
+		actions  int
+		duration time.Duration
+	}
+	stats := make(map[*analysis.Analyzer]*stat)
+	seen := make(map[*action]bool)
+	var total time.Duration
+	var visit func(act *action)
+	visit = func(act *action) {
+		if seen[act] {
+			return
+		}
+		seen[act] = true
+		if act.cached == nil {
+			for _, dep := range act.deps {
+				visit(dep)
+			}
+		}
+		s, ok := stats[act.a]
+		if !ok {
+			s = &stat{a: act.a}
+			stats[act.a] = s
+		}
+		d := act.duration
+		if haveThreadCPUTime {
+			d = act.cpuTime
+		}
+		s.actions++
+		s.duration += d
+		total += d
+	}
+	for _, act := range roots {
+		visit(act)
+	}
+
+	all := make([]*stat, 0, len(stats))
+	for _, s := range stats {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].duration != all[j].duration {
+			return all[i].duration > all[j].duration
+		}
+		return all[i].a.Name < all[j].a.Name
+	})
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', tabwriter.AlignRight)
+	column := "wall time"
+	if haveThreadCPUTime {
+		column = "cpu time"
+	}
+	fmt.Fprintf(w, "analyzer\tpackages\t%s\t\n", column)
+	for _, s := range all {
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", s.a.Name, s.actions, s.duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "total\t%d\t%s\t\n", len(seen), total.Round(time.Millisecond))
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "%s elapsed with %d workers\n", elapsed.Round(time.Millisecond), workers())
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+)
+
+type leafFact struct{ Name string }
+
+func (*leafFact) AFact() {}
+
+func TestExecAll(t *testing.T) {
+	defer func(n int) { Workers = n }(Workers)
+	Workers = 2
+
+	// Package p imports the leaves p0, ..., p7.
+	fset := token.NewFileSet()
+	imported := make(map[string]*types.Package)
+	conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		if pkg, ok := imported[path]; ok {
+			return pkg, nil
+		}
+		return importer.Default().Import(path)
+	})}
+	newPackage := func(path, src string) *packages.Package {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		tpkg, err := conf.Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported[path] = tpkg
+		return &packages.Package{
+			ID:        path,
+			PkgPath:   path,
+			Fset:      fset,
+			Syntax:    []*ast.File{f},
+			Types:     tpkg,
+			TypesInfo: info,
+			Imports:   make(map[string]*packages.Package),
+		}
+	}
+	var imports []string
+	var leaves []*packages.Package
+	for i := 0; i < 8; i++ {
+		path := fmt.Sprintf("p%d", i)
+		leaves = append(leaves, newPackage(path, "package "+path+"\n\nvar V int\n"))
+		imports = append(imports, fmt.Sprintf("_ %q", path))
+	}
+	root := newPackage("p", "package p\n\nimport (\n"+strings.Join(imports, "\n")+"\n)\n")
+	for _, leaf := range leaves {
+		root.Imports[leaf.PkgPath] = leaf
+	}
+
+	// The analyzer exports a fact about each leaf, and reports the facts
+	// of the leaves on the root. It records how many of its passes run
+	// at the same time.
+	var (
+		mu             sync.Mutex
+		running, limit int
+	)
+	syntax := &analysis.Analyzer{
+		Name:       "syntax",
+		Run:        func(pass *analysis.Pass) (interface{}, error) { return pass.Files, nil },
+		ResultType: reflect.TypeOf([]*ast.File(nil)),
+	}
+	a := &analysis.Analyzer{
+		Name:      "leaves",
+		Requires:  []*analysis.Analyzer{syntax},
+		FactTypes: []analysis.Fact{new(leafFact)},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			mu.Lock()
+			if running++; running > limit {
+				limit = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			if len(pass.ResultOf[syntax].([]*ast.File)) != 1 {
+				return nil, fmt.Errorf("no syntax")
+			}
+			if pass.Pkg.Path() != "p" {
+				pass.ExportPackageFact(&leafFact{pass.Pkg.Path()})
+				return nil, nil
+			}
+			var names []string
+			for _, f := range pass.AllPackageFacts() {
+				names = append(names, f.Fact.(*leafFact).Name)
+			}
+			sort.Strings(names)
+			pass.Reportf(pass.Files[0].Package, "%s", strings.Join(names, " "))
+			return nil, nil
+		},
+	}
+
+	roots := analyze([]*packages.Package{root}, []*analysis.Analyzer{a}, nil)
+	if len(roots) != 1 {
+		t.Fatalf("got %d roots, want 1", len(roots))
+	}
+	act := roots[0]
+	if act.err != nil {
+		t.Fatal(act.err)
+	}
+	if got, want := len(act.diagnostics), 1; got != want {
+		t.Fatalf("got %d diagnostics, want %d", got, want)
+	}
+	if got, want := act.diagnostics[0].Message, "p0 p1 p2 p3 p4 p5 p6 p7"; got != want {
+		t.Errorf("got facts %q, want %q", got, want)
+	}
+	if limit > Workers {
+		t.Errorf("%d passes ran at the same time, want at most %d", limit, Workers)
+	}
+
+	// Only the facts of the dependencies remain, and the syntax of the
+	// root package.
+	for _, leaf := range leaves {
+		if leaf.Syntax != nil || leaf.TypesInfo != nil {
+			t.Errorf("syntax or type information of %s was not released", leaf)
+		}
+	}
+	if root.Syntax == nil || root.TypesInfo == nil {
+		t.Errorf("syntax or type information of the root package was released")
+	}
+	for _, dep := range act.deps {
+		if dep.result != nil || dep.pass != nil {
+			t.Errorf("result or pass of %s was not released", dep)
+		}
+		if dep.a == a && len(dep.packageFacts) == 0 {
+			t.Errorf("facts of %s were released", dep)
+		}
+	}
+}
+
+// TestExecAllFinish checks that the syntax and type information of the
+// root packages are released once their root actions are finished.
+func TestExecAllFinish(t *testing.T) {
+	fset := token.NewFileSet()
+	var roots []*packages.Package
+	for _, path := range []string{"q1", "q2"} {
+		f, err := parser.ParseFile(fset, path+".go", "package "+path+"\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		tpkg, err := new(types.Config).Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, &packages.Package{
+			ID:        path,
+			PkgPath:   path,
+			Fset:      fset,
+			Syntax:    []*ast.File{f},
+			Types:     tpkg,
+			TypesInfo: info,
+		})
+	}
+	a := &analysis.Analyzer{
+		Name: "name",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.Reportf(pass.Files[0].Package, "%s", pass.Pkg.Name())
+			return pass.Pkg.Name(), nil
+		},
+		ResultType: reflect.TypeOf(""),
+	}
+
+	var mu sync.Mutex
+	finished := make(map[*packages.Package]int)
+	finish := func(pkg *packages.Package, acts []*action) {
+		mu.Lock()
+		defer mu.Unlock()
+		if pkg.Syntax == nil || pkg.TypesInfo == nil {
+			t.Errorf("syntax or type information of %s released before it is finished", pkg)
+		}
+		finished[pkg] += len(acts)
+	}
+	acts := analyze(roots, []*analysis.Analyzer{a}, finish)
+	for _, pkg := range roots {
+		if finished[pkg] != 1 {
+			t.Errorf("%s finished with %d root actions, want 1", pkg, finished[pkg])
+		}
+		if pkg.Syntax != nil || pkg.TypesInfo != nil {
+			t.Errorf("syntax or type information of %s was not released", pkg)
+		}
+	}
+	for _, act := range acts {
+		if act.result != nil || act.pass != nil {
+			t.Errorf("result or pass of %s was not released", act)
+		}
+		if len(act.diagnostics) != 1 || act.diagnostics[0].Message != act.pkg.PkgPath {
+			t.Errorf("diagnostics of %s: got %v, want one for its package", act, act.diagnostics)
+		}
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// TestThreadCPUTime checks that the time a thread spends sleeping is not
+// counted as CPU time, unlike the time it spends computing.
+func TestThreadCPUTime(t *testing.T) {
+	if !haveThreadCPUTime {
+		t.Skip("no CPU time of threads on this platform")
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	t0 := threadCPUTime()
+	time.Sleep(100 * time.Millisecond)
+	t1 := threadCPUTime()
+	for start := time.Now(); time.Since(start) < 100*time.Millisecond; {
+	}
+	t2 := threadCPUTime()
+	if slept := t1 - t0; slept >= 50*time.Millisecond {
+		t.Errorf("sleeping for 100ms took %s of CPU time", slept)
+	}
+	if spun := t2 - t1; spun < 10*time.Millisecond {
+		t.Errorf("spinning for 100ms took %s of CPU time", spun)
+	}
+}
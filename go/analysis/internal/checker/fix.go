@@ -0,0 +1,320 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"sort"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/diff"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/diff/myers"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// maxFixRounds bounds the number of rounds of fixes: each round applies
+// the fixes of the diagnostics of the previous one, then analyzes the
+// fixed packages again.
+const maxFixRounds = 10
+
+// overlay holds the contents of the files fixed by the previous rounds of
+// fixes, which are analyzed instead of the files on disk.
+var overlay map[string][]byte
+
+// readFile returns the contents of the named file, from the overlay if
+// it is there.
+func readFile(name string) ([]byte, error) {
+	if data, ok := overlay[name]; ok {
+		return data, nil
+	}
+	return ioutil.ReadFile(name)
+}
+
+// An offsetEdit is a TextEdit using byte offsets instead of positions.
+type offsetEdit struct {
+	start, end int
+	newText    []byte
+}
+
+func (e offsetEdit) equal(x offsetEdit) bool {
+	return e.start == x.start && e.end == x.end && bytes.Equal(e.newText, x.newText)
+}
+
+// conflicts reports whether e and x cannot both apply: they overlap, or
+// they insert at the same offset, whose order would be ambiguous.
+func (e offsetEdit) conflicts(x offsetEdit) bool {
+	if e.equal(x) {
+		return false
+	}
+	if e.start == e.end && x.start == x.end {
+		return e.start == x.start
+	}
+	return e.start < x.end && x.start < e.end
+}
+
+// applyFixes applies the suggested fixes of the diagnostics of the
+// actions, then analyzes the fixed packages again and applies the new
+// fixes, until no fix changes any file. It writes the fixed files and
+// returns the actions of their analysis, whose diagnostics are those that
+// remain, or with Diff prints their diffs and returns the actions
+// unchanged. It also returns the number of diagnostics that remain with
+// suggested fixes, which could not be applied.
+func applyFixes(args []string, analyzers []*analysis.Analyzer, roots []*action) ([]*action, int, error) {
+	defer func() { overlay = nil }()
+	original := make(map[string][]byte) // contents of the fixed files on disk
+	fset := token.NewFileSet()          // shared by parse calls below
+	fixed := roots                      // the actions of the analysis of the fixed files
+	for round := 1; ; round++ {
+		changed := false
+		for name, edits := range collectFixes(fixed) {
+			contents, err := readFile(name)
+			if err != nil {
+				return nil, 0, err
+			}
+			if _, ok := original[name]; !ok {
+				original[name] = contents
+			}
+			out := applyEdits(contents, edits)
+
+			// Try to format the file.
+			if f, err := parser.ParseFile(fset, name, out, parser.ParseComments); err == nil {
+				var buf bytes.Buffer
+				if err := format.Node(&buf, fset, f); err == nil {
+					out = buf.Bytes()
+				}
+			}
+
+			if !bytes.Equal(out, contents) {
+				if overlay == nil {
+					overlay = make(map[string][]byte)
+				}
+				overlay[name] = out
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// The lines changed since -diff-base are those of the files on
+		// disk, so the fixed packages are analyzed again only once they
+		// are written.
+		if round == maxFixRounds || DiffBase != "" {
+			fixed = nil
+			break
+		}
+		if dbg('v') {
+			log.Printf("analyzing fixed packages (round %d)", round+1)
+		}
+		var err error
+		if fixed, err = reanalyze(args, analyzers, false); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	names := make([]string, 0, len(overlay))
+	for name := range overlay {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		before, after := original[name], overlay[name]
+		if bytes.Equal(before, after) {
+			continue
+		}
+		if Diff {
+			edits, err := myers.ComputeEdits(span.URIFromPath(name), string(before), string(after))
+			if err != nil {
+				return nil, 0, err
+			}
+			fmt.Print(diff.ToUnified(name+".orig", name, string(before), edits))
+			continue
+		}
+		if err := ioutil.WriteFile(name, after, 0644); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Analyze the fixed files if the last fixes were not analyzed.
+	if fixed == nil {
+		if !Diff {
+			overlay = nil // written
+		}
+		var err error
+		if fixed, err = reanalyze(args, analyzers, !Diff && DiffBase != ""); err != nil {
+			return nil, 0, err
+		}
+	}
+	unfixed := countFixable(fixed)
+	if Diff {
+		return roots, unfixed, nil
+	}
+	return fixed, unfixed, nil
+}
+
+// reanalyze loads the packages again, with the files of the overlay, and
+// analyzes them, removing the suppressed diagnostics, and if diffBase is
+// set those on lines not changed since DiffBase.
+func reanalyze(args []string, analyzers []*analysis.Analyzer, diffBase bool) ([]*action, error) {
+	initial, err := load(args, NeedFacts(analyzers))
+	if err != nil {
+		if _, ok := err.(typeParseError); !ok {
+			return nil, err
+		}
+	}
+	roots, err := analyzeRoots(initial, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	if diffBase {
+		if err := filterChangedLines(roots); err != nil {
+			return nil, err
+		}
+	}
+	return roots, nil
+}
+
+// countFixable returns the number of distinct diagnostics of the root
+// actions that have suggested fixes.
+func countFixable(roots []*action) int {
+	type key struct {
+		posn    token.Position
+		message string
+	}
+	seen := make(map[key]bool) // files may belong to several packages
+	for _, act := range roots {
+		if !act.isroot {
+			continue
+		}
+		for _, diag := range act.diagnostics {
+			if len(diag.SuggestedFixes) > 0 {
+				seen[key{act.pkg.Fset.Position(diag.Pos), diag.Message}] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+// collectFixes returns the edits of the suggested fixes of the
+// diagnostics of the actions, by file name.
+//
+// A fix applies entirely or not at all. Of the alternative fixes of a
+// diagnostic, the first one that does not conflict with the fixes
+// selected before applies; if none does, the diagnostic is logged.
+// Identical edits, such as those of the diagnostics of a file that
+// belongs to several packages, do not conflict.
+func collectFixes(roots []*action) map[string][]offsetEdit {
+	selected := make(map[string][]offsetEdit)
+	visited := make(map[*action]bool)
+	var visitAll func(actions []*action)
+	visitAll = func(actions []*action) {
+		for _, act := range actions {
+			if !visited[act] {
+				visited[act] = true
+				visitAll(act.deps)
+				for _, diag := range act.diagnostics {
+					selectFix(act, diag, selected)
+				}
+			}
+		}
+	}
+	visitAll(roots)
+	return selected
+}
+
+// selectFix adds to selected the edits of the first suggested fix of the
+// diagnostic that does not conflict with them.
+func selectFix(act *action, diag analysis.Diagnostic, selected map[string][]offsetEdit) {
+	if len(diag.SuggestedFixes) == 0 {
+		return
+	}
+	valid := false
+fixes:
+	for _, sf := range diag.SuggestedFixes {
+		edits, err := fixEdits(act, sf)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		valid = true
+		for name, fileEdits := range edits {
+			for _, e := range fileEdits {
+				for _, x := range selected[name] {
+					if e.conflicts(x) {
+						continue fixes
+					}
+				}
+			}
+		}
+		for name, fileEdits := range edits {
+			selected[name] = append(selected[name], fileEdits...)
+		}
+		return
+	}
+	if valid {
+		posn := act.pkg.Fset.Position(diag.Pos)
+		log.Printf("%s: analysis %v: skipped conflicting suggested fixes: %s", posn, act.a.Name, diag.Message)
+	}
+}
+
+// fixEdits returns the edits of a suggested fix by file name, or an error
+// if the fix is malformed.
+func fixEdits(act *action, sf analysis.SuggestedFix) (map[string][]offsetEdit, error) {
+	edits := make(map[string][]offsetEdit)
+	for _, edit := range sf.TextEdits {
+		// Validate the edit.
+		if edit.Pos > edit.End {
+			return nil, fmt.Errorf(
+				"diagnostic for analysis %v contains Suggested Fix with malformed edit: pos (%v) > end (%v)",
+				act.a.Name, edit.Pos, edit.End)
+		}
+		file, endfile := act.pkg.Fset.File(edit.Pos), act.pkg.Fset.File(edit.End)
+		if file == nil || endfile == nil || file != endfile {
+			return nil, fmt.Errorf(
+				"diagnostic for analysis %v contains Suggested Fix with malformed edit spanning files %v and %v",
+				act.a.Name, act.pkg.Fset.Position(edit.Pos).Filename, act.pkg.Fset.Position(edit.End).Filename)
+		}
+		// TODO(matloob): Validate that edits do not affect other packages.
+		e := offsetEdit{file.Offset(edit.Pos), file.Offset(edit.End), edit.NewText}
+		for _, x := range edits[file.Name()] {
+			if e.conflicts(x) {
+				return nil, fmt.Errorf(
+					"diagnostic for analysis %v contains Suggested Fix with overlapping edits affecting offset ranges (%v, %v) and (%v, %v)",
+					act.a.Name, e.start, e.end, x.start, x.end)
+			}
+		}
+		edits[file.Name()] = append(edits[file.Name()], e)
+	}
+	return edits, nil
+}
+
+// applyEdits returns the contents of a file with the edits applied.
+// The edits do not conflict, but some may be identical.
+func applyEdits(contents []byte, edits []offsetEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+	var out bytes.Buffer
+	cur := 0 // current offset in contents
+	for i, edit := range edits {
+		if i > 0 && edit.equal(edits[i-1]) {
+			continue
+		}
+		out.Write(contents[cur:edit.start])
+		out.Write(edit.newText)
+		cur = edit.end
+	}
+	out.Write(contents[cur:])
+	return out.Bytes()
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker_test
+
+import (
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/analysistest"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/checker"
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/inspect"
+	"github.com/cowpaths/golang-x-tools/go/ast/inspector"
+	"github.com/cowpaths/golang-x-tools/internal/testenv"
+)
+
+// identAnalyzer returns an analyzer that reports each identifier for
+// which fixes returns suggested fixes.
+func identAnalyzer(name string, fixes func(*ast.Ident) []analysis.SuggestedFix) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     name,
+		Doc:      name,
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+			inspect.Preorder([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node) {
+				id := n.(*ast.Ident)
+				if sf := fixes(id); sf != nil {
+					pass.Report(analysis.Diagnostic{Pos: id.Pos(), Message: id.Name, SuggestedFixes: sf})
+				}
+			})
+			return nil, nil
+		},
+	}
+}
+
+func rename(id *ast.Ident, to string) analysis.SuggestedFix {
+	return analysis.SuggestedFix{
+		Message:   "rename to " + to,
+		TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(to)}},
+	}
+}
+
+func TestFixConflicts(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// chain renames a1 to a2, and a2 to a3 once a1 is gone.
+	chain := identAnalyzer("chain", func(id *ast.Ident) []analysis.SuggestedFix {
+		switch id.Name {
+		case "a1":
+			return []analysis.SuggestedFix{rename(id, "a2")}
+		case "a2":
+			return []analysis.SuggestedFix{rename(id, "a3")}
+		}
+		return nil
+	})
+	// other would rename a1 to b, which conflicts with chain, or else
+	// insert a comment before it.
+	other := identAnalyzer("other", func(id *ast.Ident) []analysis.SuggestedFix {
+		if id.Name != "a1" {
+			return nil
+		}
+		return []analysis.SuggestedFix{rename(id, "b"), {
+			Message:   "comment",
+			TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.Pos(), NewText: []byte("/* other */ ")}},
+		}}
+	})
+	// same suggests the same fix as chain.
+	same := identAnalyzer("same", func(id *ast.Ident) []analysis.SuggestedFix {
+		if id.Name == "a1" {
+			return []analysis.SuggestedFix{rename(id, "a2")}
+		}
+		return nil
+	})
+	// conflict only suggests a fix that conflicts with chain: it is
+	// skipped.
+	conflict := identAnalyzer("conflict", func(id *ast.Ident) []analysis.SuggestedFix {
+		if id.Name == "a1" {
+			return []analysis.SuggestedFix{rename(id, "c")}
+		}
+		return nil
+	})
+
+	const src = `package p
+
+var a1 = 1
+`
+	testdata, cleanup, err := analysistest.WriteFiles(map[string]string{"p/p.go": src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(testdata, "src/p/p.go")
+	analyzers := []*analysis.Analyzer{chain, other, same, conflict}
+
+	defer func(fix, diff bool) { checker.Fix, checker.Diff = fix, diff }(checker.Fix, checker.Diff)
+
+	checker.Fix, checker.Diff = true, true
+	if code := checker.Run([]string{"file=" + path}, analyzers); code != 1 {
+		t.Errorf("-fix -diff: exit code %d, want 1", code)
+	}
+
+	// -diff leaves the file unchanged, and reports the diagnostics.
+	checker.Fix, checker.Diff = false, true
+	if code := checker.Run([]string{"file=" + path}, analyzers); code != 3 {
+		t.Errorf("-diff: exit code %d, want 3", code)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil || string(got) != src {
+		t.Errorf("-diff changed file to %q (%v)", got, err)
+	}
+
+	// -fix leaves no diagnostics: the skipped fix of conflict is gone
+	// with a1.
+	checker.Fix, checker.Diff = true, false
+	if code := checker.Run([]string{"file=" + path}, analyzers); code != 0 {
+		t.Errorf("-fix: exit code %d, want 0", code)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `package p
+
+var /* other */ a3 = 1
+`
+	if string(got) != want {
+		t.Errorf("contents of fixed file\ngot: %s\nwant: %s", got, want)
+	}
+}
+
+func TestFixUnapplied(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	// swap renames d to e and e to d, so its fixes never run out.
+	swap := identAnalyzer("swap", func(id *ast.Ident) []analysis.SuggestedFix {
+		switch id.Name {
+		case "d":
+			return []analysis.SuggestedFix{rename(id, "e")}
+		case "e":
+			return []analysis.SuggestedFix{rename(id, "d")}
+		}
+		return nil
+	})
+
+	testdata, cleanup, err := analysistest.WriteFiles(map[string]string{"p/p.go": "package p\n\nvar d = 1\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	path := filepath.Join(testdata, "src/p/p.go")
+
+	defer func(fix, diff bool) { checker.Fix, checker.Diff = fix, diff }(checker.Fix, checker.Diff)
+	checker.Fix, checker.Diff = true, false
+	if code := checker.Run([]string{"file=" + path}, []*analysis.Analyzer{swap}); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checker
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+)
+
+// A program represents the application of a whole-program analyzer to
+// the root packages. It runs once, but the action of the analyzer on
+// each package reports the diagnostics about that package.
+type program struct {
+	once        sync.Once
+	a           *analysis.Analyzer
+	pkgs        []*packages.Package
+	deps        []*action // the required analyzers on each package
+	diagnostics map[*packages.Package][]analysis.Diagnostic
+	err         error
+}
+
+// programActions returns the actions of a whole-program analyzer on each
+// of the packages, which all depend on the required analyzers on all of
+// them.
+func programActions(a *analysis.Analyzer, pkgs []*packages.Package, mkAction func(*analysis.Analyzer, *packages.Package) *action) []*action {
+	prog := &program{a: a, pkgs: pkgs}
+	for _, pkg := range pkgs {
+		for _, req := range a.Requires {
+			prog.deps = append(prog.deps, mkAction(req, pkg))
+		}
+	}
+	var actions []*action
+	for _, pkg := range pkgs {
+		actions = append(actions, &action{a: a, pkg: pkg, deps: prog.deps, program: prog})
+	}
+	return actions
+}
+
+// execProgram sets the diagnostics and error of the action of a whole-program analyzer
+// on a package. The error of the program, if any, is that of the action
+// on its first package only, so that it is reported once.
+func (act *action) execProgram() {
+	prog := act.program
+	prog.once.Do(prog.exec)
+	act.diagnostics = prog.diagnostics[act.pkg]
+	if act.pkg == prog.pkgs[0] {
+		act.err = prog.err
+	}
+}
+
+func (prog *program) exec() {
+	// Report an error if any dependency failed.
+	var failed []string
+	for _, dep := range prog.deps {
+		if dep.err != nil {
+			failed = append(failed, dep.String())
+		}
+	}
+	if failed != nil {
+		sort.Strings(failed)
+		prog.err = fmt.Errorf("failed prerequisites: %s", strings.Join(failed, ", "))
+		return
+	}
+	if !prog.a.RunDespiteErrors {
+		var illTyped []string
+		for _, pkg := range prog.pkgs {
+			if pkg.IllTyped {
+				illTyped = append(illTyped, pkg.String())
+			}
+		}
+		if illTyped != nil {
+			prog.err = fmt.Errorf("analysis skipped due to errors in packages: %s", strings.Join(illTyped, ", "))
+			return
+		}
+	}
+
+	// Plumb the output values of the dependencies into the inputs of
+	// the pass, and their facts, whatever their package.
+	facts := &action{
+		objectFacts:  make(map[objectFactKey]analysis.Fact),
+		packageFacts: make(map[packageFactKey]analysis.Fact),
+	}
+	inputs := make(map[*packages.Package]map[*analysis.Analyzer]interface{})
+	for _, dep := range prog.deps {
+		if inputs[dep.pkg] == nil {
+			inputs[dep.pkg] = make(map[*analysis.Analyzer]interface{})
+		}
+		inputs[dep.pkg][dep.a] = dep.result
+		for key, fact := range dep.objectFacts {
+			facts.objectFacts[key] = fact
+		}
+		for key, fact := range dep.packageFacts {
+			facts.packageFacts[key] = fact
+		}
+	}
+
+	// Run the analysis.
+	fset := prog.pkgs[0].Fset
+	var diagnostics []analysis.Diagnostic
+	pass := &analysis.ProgramPass{
+		Analyzer:          prog.a,
+		Fset:              fset,
+		Report:            func(d analysis.Diagnostic) { diagnostics = append(diagnostics, d) },
+		ImportObjectFact:  facts.importObjectFact,
+		ImportPackageFact: facts.importPackageFact,
+		AllObjectFacts:    facts.allObjectFacts,
+		AllPackageFacts:   facts.allPackageFacts,
+	}
+	for _, pkg := range prog.pkgs {
+		pass.Packages = append(pass.Packages, &analysis.ProgramPackage{
+			Files:        pkg.Syntax,
+			OtherFiles:   pkg.OtherFiles,
+			IgnoredFiles: pkg.IgnoredFiles,
+			Pkg:          pkg.Types,
+			TypesInfo:    pkg.TypesInfo,
+			TypesSizes:   pkg.TypesSizes,
+			ResultOf:     inputs[pkg],
+		})
+	}
+	prog.err = prog.a.RunProgram(pass)
+
+	// Attribute each diagnostic to the packages of its file, which may
+	// be shared by the variants of a package, or to the first package if
+	// it has none.
+	owners := make(map[*token.File][]*packages.Package)
+	otherOwners := make(map[string][]*packages.Package)
+	for _, pkg := range prog.pkgs {
+		for _, f := range pkg.Syntax {
+			if tok := fset.File(f.Pos()); tok != nil {
+				owners[tok] = append(owners[tok], pkg)
+			}
+		}
+		for _, name := range pkg.OtherFiles {
+			otherOwners[name] = append(otherOwners[name], pkg)
+		}
+	}
+	prog.diagnostics = make(map[*packages.Package][]analysis.Diagnostic)
+	for _, d := range diagnostics {
+		var pkgs []*packages.Package
+		if tok := fset.File(d.Pos); tok != nil {
+			pkgs = owners[tok]
+			if pkgs == nil {
+				pkgs = otherOwners[tok.Name()]
+			}
+		}
+		if pkgs == nil {
+			pkgs = prog.pkgs[:1]
+		}
+		for _, pkg := range pkgs {
+			prog.diagnostics[pkg] = append(prog.diagnostics[pkg], d)
+		}
+	}
+}
@@ -71,6 +71,20 @@ type key struct {
 	t   reflect.Type
 }
 
+// NewSet returns the set of the given facts of package pkg: those about
+// pkg and its objects, and those about its dependencies. It allows a
+// driver that holds the facts of each package in memory to encode them.
+func NewSet(pkg *types.Package, objectFacts []analysis.ObjectFact, packageFacts []analysis.PackageFact) *Set {
+	m := make(map[key]analysis.Fact)
+	for _, f := range objectFacts {
+		m[key{pkg: f.Object.Pkg(), obj: f.Object, t: reflect.TypeOf(f.Fact)}] = f.Fact
+	}
+	for _, f := range packageFacts {
+		m[key{pkg: f.Package, t: reflect.TypeOf(f.Fact)}] = f.Fact
+	}
+	return &Set{pkg: pkg, m: m}
+}
+
 // ImportObjectFact implements analysis.Pass.ImportObjectFact.
 func (s *Set) ImportObjectFact(obj types.Object, ptr analysis.Fact) bool {
 	if obj == nil {
@@ -182,47 +196,74 @@ func Decode(pkg *types.Package, read func(packagePath string) ([]byte, error)) (
 			return nil, fmt.Errorf("in %s, can't import facts for package %q: %v",
 				pkg.Path(), imp.Path(), err)
 		}
-		if len(data) == 0 {
-			continue // no facts
-		}
-		var gobFacts []gobFact
-		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gobFacts); err != nil {
+		if err := decodeFacts(data, packages, m, logf); err != nil {
 			return nil, fmt.Errorf("decoding facts for %q: %v", imp.Path(), err)
 		}
+	}
+
+	return &Set{pkg: pkg, m: m}, nil
+}
+
+// DecodeOwn decodes the facts of package pkg itself, as encoded by the
+// Encode method of a set of facts of pkg, such as one returned by NewSet.
+// Unlike Decode, it retains the facts about pkg and its objects.
+func DecodeOwn(pkg *types.Package, data []byte) (*Set, error) {
+	packages := importMap(pkg.Imports())
+	packages[pkg.Path()] = pkg
+	m := make(map[key]analysis.Fact)
+	logf := func(format string, args ...interface{}) {
 		if debug {
-			logf("decoded %d facts: %v", len(gobFacts), gobFacts)
+			log.Print(fmt.Sprintf("in %s: ", pkg.Path()), fmt.Sprintf(format, args...))
 		}
+	}
+	if err := decodeFacts(data, packages, m, logf); err != nil {
+		return nil, fmt.Errorf("decoding facts for %q: %v", pkg.Path(), err)
+	}
+	return &Set{pkg: pkg, m: m}, nil
+}
+
+// decodeFacts decodes the gob-encoded facts of data into m, discarding
+// those about packages absent from the import map, or their objects.
+func decodeFacts(data []byte, packages map[string]*types.Package, m map[key]analysis.Fact, logf func(format string, args ...interface{})) error {
+	if len(data) == 0 {
+		return nil // no facts
+	}
+	var gobFacts []gobFact
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gobFacts); err != nil {
+		return err
+	}
+	if debug {
+		logf("decoded %d facts: %v", len(gobFacts), gobFacts)
+	}
 
-		// Parse each one into a key and a Fact.
-		for _, f := range gobFacts {
-			factPkg := packages[f.PkgPath]
-			if factPkg == nil {
-				// Fact relates to a dependency that was
-				// unused in this translation unit. Skip.
-				logf("no package %q; discarding %v", f.PkgPath, f.Fact)
+	// Parse each one into a key and a Fact.
+	for _, f := range gobFacts {
+		factPkg := packages[f.PkgPath]
+		if factPkg == nil {
+			// Fact relates to a dependency that was
+			// unused in this translation unit. Skip.
+			logf("no package %q; discarding %v", f.PkgPath, f.Fact)
+			continue
+		}
+		key := key{pkg: factPkg, t: reflect.TypeOf(f.Fact)}
+		if f.Object != "" {
+			// object fact
+			obj, err := objectpath.Object(factPkg, f.Object)
+			if err != nil {
+				// (most likely due to unexported object)
+				// TODO(adonovan): audit for other possibilities.
+				logf("no object for path: %v; discarding %s", err, f.Fact)
 				continue
 			}
-			key := key{pkg: factPkg, t: reflect.TypeOf(f.Fact)}
-			if f.Object != "" {
-				// object fact
-				obj, err := objectpath.Object(factPkg, f.Object)
-				if err != nil {
-					// (most likely due to unexported object)
-					// TODO(adonovan): audit for other possibilities.
-					logf("no object for path: %v; discarding %s", err, f.Fact)
-					continue
-				}
-				key.obj = obj
-				logf("read %T fact %s for %v", f.Fact, f.Fact, key.obj)
-			} else {
-				// package fact
-				logf("read %T fact %s for %v", f.Fact, f.Fact, factPkg)
-			}
-			m[key] = f.Fact
+			key.obj = obj
+			logf("read %T fact %s for %v", f.Fact, f.Fact, key.obj)
+		} else {
+			// package fact
+			logf("read %T fact %s for %v", f.Fact, f.Fact, factPkg)
 		}
+		m[key] = f.Fact
 	}
-
-	return &Set{pkg: pkg, m: m}, nil
+	return nil
 }
 
 // Encode encodes a set of facts to a memory buffer.
@@ -7,12 +7,15 @@ package facts_test
 import (
 	"encoding/gob"
 	"fmt"
+	"go/ast"
+	"go/parser"
 	"go/token"
 	"go/types"
 	"os"
 	"reflect"
 	"testing"
 
+	"github.com/cowpaths/golang-x-tools/go/analysis"
 	"github.com/cowpaths/golang-x-tools/go/analysis/analysistest"
 	"github.com/cowpaths/golang-x-tools/go/analysis/internal/facts"
 	"github.com/cowpaths/golang-x-tools/go/packages"
@@ -382,3 +385,32 @@ func TestFactFilter(t *testing.T) {
 		t.Errorf("AllObjectFacts: got %v, want %v", got, wantObjFacts)
 	}
 }
+
+func TestDecodeOwn(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", `package a; type A int`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("a", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := pkg.Scope().Lookup("A")
+	s := facts.NewSet(pkg,
+		[]analysis.ObjectFact{{Object: obj, Fact: &myFact{"object fact"}}},
+		[]analysis.PackageFact{{Package: pkg, Fact: &myFact{"package fact"}}})
+
+	// Unlike Decode, DecodeOwn retains the facts of the package itself.
+	s, err = facts.DecodeOwn(pkg, s.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got myFact
+	if !s.ImportObjectFact(obj, &got) || got.S != "object fact" {
+		t.Errorf("ImportObjectFact: got %v, want object fact", got)
+	}
+	if !s.ImportPackageFact(pkg, &got) || got.S != "package fact" {
+		t.Errorf("ImportPackageFact: got %v, want package fact", got)
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The pluginchecker package defines the main function for an analysis
+// driver that applies analyzers to a single package for gopls, so that
+// analyzers that are not compiled into gopls can show up in the editor.
+// A tool built with it is named in the analysisPlugins setting of gopls:
+//
+//	"analysisPlugins": ["/path/to/tool", "/path/to/other -name.flag=value"]
+//
+// It supports the following command-line protocol:
+//
+//	-V=full         describe executable
+//	-flags          describe flags
+//	facts           report whether the analyzers use facts (to gopls)
+//	foo.json        description of the package (from gopls)
+//
+// Like unitchecker, the tool analyzes a single package, described by the
+// JSON encoding of a Config: it type-checks all its files, with the
+// contents of the files modified in the editor, using the export data of
+// its dependencies, which gopls has already type-checked, and the facts
+// of the analyzers about them, which gopls obtains by running the tool
+// on them in "facts only" mode. It prints the JSON encoding of a Result,
+// which holds the diagnostics and suggested fixes of the analyzers, with
+// positions given as byte offsets.
+//
+// Whole-program analyzers are not applied.
+package pluginchecker
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/analysisflags"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/checker"
+	"github.com/cowpaths/golang-x-tools/go/analysis/internal/facts"
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
+	"github.com/cowpaths/golang-x-tools/internal/typeparams"
+)
+
+// A Config describes a package to be analyzed.
+// It is provided to the tool in a JSON-encoded file
+// whose name ends with ".json".
+type Config struct {
+	ID                 string            // the package ID of go/packages, e.g. "fmt [fmt.test]"
+	ImportPath         string            // the package path
+	GoFiles            []string          // compiled Go files of the package
+	Overlay            map[string][]byte // contents of the modified files, by name
+	WordSize, MaxAlign int64             // sizes of the target architecture (see types.StdSizes)
+	ImportMap          map[string]string // maps import path to package path
+	PackageFile        map[string]string // maps package path to export data file (see gcexportdata.Write)
+	PackageVetx        map[string]string // maps package path to facts file
+	VetxOnly           bool              // run only the analyzers that produce facts
+	VetxOutput         string            // where to write the facts of the analyzers, if set
+	Analyses           map[string]bool   // analyzers enabled or disabled by name; others are enabled
+}
+
+// A Result is the output of the tool.
+type Result struct {
+	Diagnostics []Diagnostic
+	Errors      []string // errors of the analyzers that failed
+}
+
+// A Diagnostic is a diagnostic reported by an analyzer.
+type Diagnostic struct {
+	Analyzer       string
+	Category       string `json:",omitempty"`
+	Range          Range
+	Message        string
+	SuggestedFixes []SuggestedFix       `json:",omitempty"`
+	Related        []RelatedInformation `json:",omitempty"`
+}
+
+// A Range is a range of bytes of a file.
+type Range struct {
+	File       string
+	Start, End int // byte offsets
+}
+
+// A SuggestedFix is a fix of a diagnostic.
+type SuggestedFix struct {
+	Message   string
+	TextEdits []TextEdit
+}
+
+// A TextEdit replaces a range of a file by new text.
+type TextEdit struct {
+	Range   Range
+	NewText string
+}
+
+// A RelatedInformation is a message about a range related to a diagnostic.
+type RelatedInformation struct {
+	Range   Range
+	Message string
+}
+
+// Main is the main function of an analysis tool that gopls invokes to
+// analyze a single package.
+//
+// The protocol required by gopls is that the tool must support:
+//
+//	facts           print the JSON encoding of whether any of the
+//	                analyzers uses facts, and so needs those of the
+//	                dependencies of the packages.
+//	foo.json        analyze the single package described by a JSON
+//	                config file foo.json, and print the JSON result.
+func Main(analyzers ...*analysis.Analyzer) {
+	progname := filepath.Base(os.Args[0])
+	log.SetFlags(0)
+	log.SetPrefix(progname + ": ")
+
+	if err := analysis.Validate(analyzers); err != nil {
+		log.Fatal(err)
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `%[1]s is a tool for static analysis of Go programs, run by gopls.
+
+Usage of %[1]s:
+	%.16[1]s package.json	# execute analysis specified by config file
+	%.16[1]s help        	# general help, including listing analyzers and flags
+	%.16[1]s help name   	# help on specific analyzer and its flags
+`, progname)
+		os.Exit(1)
+	}
+
+	analyzers = analysisflags.Parse(analyzers, true)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+	}
+	if args[0] == "help" {
+		analysisflags.Help(progname, analyzers, args[1:])
+		os.Exit(0)
+	}
+	if len(args) == 1 && args[0] == "facts" {
+		data, _ := json.Marshal(checker.NeedFacts(analyzers))
+		os.Stdout.Write(data)
+		os.Exit(0)
+	}
+	if len(args) != 1 || !strings.HasSuffix(args[0], ".json") {
+		log.Fatalf(`%s is invoked by gopls; name it in the "analysisPlugins" setting`, progname)
+	}
+	Run(args[0], analyzers)
+}
+
+// Run reads the *.json file, runs the analysis, prints the result,
+// and calls os.Exit with an appropriate error code.
+// It assumes flags have already been set.
+func Run(configFile string, analyzers []*analysis.Analyzer) {
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	result, err := run(cfg, analyzers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(data)
+	os.Exit(0)
+}
+
+func readConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(Config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode JSON config file %s: %v", filename, err)
+	}
+	if len(cfg.GoFiles) == 0 {
+		return nil, fmt.Errorf("package has no files: %s", cfg.ID)
+	}
+	return cfg, nil
+}
+
+// errSkipped is the error of an analyzer that is not applied to an
+// ill-typed package.
+var errSkipped = fmt.Errorf("analysis skipped due to errors in package")
+
+func run(cfg *Config, analyzers []*analysis.Analyzer) (*Result, error) {
+	// Whole-program analyzers need all the packages at once.
+	var enabled []*analysis.Analyzer
+	for _, a := range analyzers {
+		if on, ok := cfg.Analyses[a.Name]; a.RunProgram == nil && (on || !ok) {
+			enabled = append(enabled, a)
+		}
+	}
+
+	// Register fact types with gob.
+	// In VetxOnly mode, analyzers are only for their facts,
+	// so we can skip any analysis that neither produces facts
+	// nor depends on any analysis that produces facts.
+	// Also build a map to hold working state and result.
+	type action struct {
+		once        sync.Once
+		result      interface{}
+		err         error
+		usesFacts   bool // (transitively uses)
+		diagnostics []analysis.Diagnostic
+	}
+	actions := make(map[*analysis.Analyzer]*action)
+	var registerFacts func(a *analysis.Analyzer) bool
+	registerFacts = func(a *analysis.Analyzer) bool {
+		act, ok := actions[a]
+		if !ok {
+			act = new(action)
+			var usesFacts bool
+			for _, f := range a.FactTypes {
+				usesFacts = true
+				gob.Register(f)
+			}
+			for _, req := range a.Requires {
+				if registerFacts(req) {
+					usesFacts = true
+				}
+			}
+			act.usesFacts = usesFacts
+			actions[a] = act
+		}
+		return act.usesFacts
+	}
+	var filtered []*analysis.Analyzer
+	for _, a := range enabled {
+		if registerFacts(a) || !cfg.VetxOnly {
+			filtered = append(filtered, a)
+		}
+	}
+	enabled = filtered
+	result := new(Result)
+	if len(enabled) == 0 {
+		return result, writeVetx(cfg, nil)
+	}
+
+	// Parse and type-check the package, with the export data of its
+	// dependencies. As in gopls, the analyzers that can are applied to
+	// an ill-typed package.
+	fset := token.NewFileSet()
+	illTyped := false
+	var files []*ast.File
+	for _, name := range cfg.GoFiles {
+		var src interface{}
+		if data, ok := cfg.Overlay[name]; ok {
+			src = data
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			illTyped = true
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	imports := make(map[string]*types.Package)
+	tc := &types.Config{
+		Importer: importerFunc(func(importPath string) (*types.Package, error) {
+			path, ok := cfg.ImportMap[importPath] // resolve vendoring, etc
+			if !ok {
+				return nil, fmt.Errorf("can't resolve import %q", importPath)
+			}
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			if pkg, ok := imports[path]; ok && pkg.Complete() {
+				return pkg, nil
+			}
+			file, ok := cfg.PackageFile[path]
+			if !ok {
+				return nil, fmt.Errorf("no package file for %q", path)
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return gcexportdata.Read(bufio.NewReader(f), fset, imports, path)
+		}),
+		Error: func(error) { illTyped = true },
+		Sizes: &types.StdSizes{WordSize: cfg.WordSize, MaxAlign: cfg.MaxAlign},
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	typeparams.InitInstanceInfo(info)
+	pkg, _ := tc.Check(cfg.ImportPath, fset, files, info) // errors are recorded by tc.Error
+
+	// Read facts from imported packages.
+	read := func(path string) ([]byte, error) {
+		if vetx, ok := cfg.PackageVetx[path]; ok {
+			return ioutil.ReadFile(vetx)
+		}
+		return nil, nil // no .vetx file, no facts
+	}
+	facts, err := facts.Decode(pkg, read)
+	if err != nil {
+		return nil, err
+	}
+
+	// In parallel, execute the DAG of analyzers.
+	var exec func(a *analysis.Analyzer) *action
+	var execAll func(analyzers []*analysis.Analyzer)
+	exec = func(a *analysis.Analyzer) *action {
+		act := actions[a]
+		act.once.Do(func() {
+			execAll(a.Requires) // prefetch dependencies in parallel
+
+			if illTyped && !a.RunDespiteErrors {
+				act.err = errSkipped
+				return
+			}
+
+			// The inputs to this analysis are the
+			// results of its prerequisites.
+			inputs := make(map[*analysis.Analyzer]interface{})
+			var failed []string
+			for _, req := range a.Requires {
+				reqact := exec(req)
+				if reqact.err != nil {
+					failed = append(failed, req.String())
+					continue
+				}
+				inputs[req] = reqact.result
+			}
+
+			// Report an error if any dependency failed.
+			if failed != nil {
+				sort.Strings(failed)
+				act.err = fmt.Errorf("failed prerequisites: %s", strings.Join(failed, ", "))
+				return
+			}
+
+			factFilter := make(map[reflect.Type]bool)
+			for _, f := range a.FactTypes {
+				factFilter[reflect.TypeOf(f)] = true
+			}
+
+			pass := &analysis.Pass{
+				Analyzer:          a,
+				Fset:              fset,
+				Files:             files,
+				Pkg:               pkg,
+				TypesInfo:         info,
+				TypesSizes:        tc.Sizes,
+				ResultOf:          inputs,
+				Report:            func(d analysis.Diagnostic) { act.diagnostics = append(act.diagnostics, d) },
+				ImportObjectFact:  facts.ImportObjectFact,
+				ExportObjectFact:  facts.ExportObjectFact,
+				AllObjectFacts:    func() []analysis.ObjectFact { return facts.AllObjectFacts(factFilter) },
+				ImportPackageFact: facts.ImportPackageFact,
+				ExportPackageFact: facts.ExportPackageFact,
+				AllPackageFacts:   func() []analysis.PackageFact { return facts.AllPackageFacts(factFilter) },
+			}
+			act.result, act.err = a.Run(pass)
+		})
+		return act
+	}
+	execAll = func(analyzers []*analysis.Analyzer) {
+		var wg sync.WaitGroup
+		for _, a := range analyzers {
+			wg.Add(1)
+			go func(a *analysis.Analyzer) {
+				_ = exec(a)
+				wg.Done()
+			}(a)
+		}
+		wg.Wait()
+	}
+
+	execAll(enabled)
+
+	if err := writeVetx(cfg, facts); err != nil {
+		return nil, err
+	}
+	if cfg.VetxOnly {
+		return result, nil
+	}
+	for _, a := range enabled {
+		act := actions[a]
+		if act.err != nil {
+			if act.err != errSkipped {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", a.Name, act.err))
+			}
+			continue
+		}
+		for _, diag := range act.diagnostics {
+			if d, ok := newDiagnostic(fset, a, diag); ok {
+				result.Diagnostics = append(result.Diagnostics, d)
+			}
+		}
+	}
+	return result, nil
+}
+
+// writeVetx writes the facts, if any, to the VetxOutput file of the
+// config, if set.
+func writeVetx(cfg *Config, set *facts.Set) error {
+	if cfg.VetxOutput == "" {
+		return nil
+	}
+	var data []byte
+	if set != nil {
+		data = set.Encode()
+	}
+	if err := ioutil.WriteFile(cfg.VetxOutput, data, 0666); err != nil {
+		return fmt.Errorf("failed to write analysis facts: %v", err)
+	}
+	return nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// newDiagnostic returns the Diagnostic for a diagnostic of an analyzer,
+// or false if it has no valid position.
+func newDiagnostic(fset *token.FileSet, a *analysis.Analyzer, diag analysis.Diagnostic) (Diagnostic, bool) {
+	rng, ok := newRange(fset, diag.Pos, diag.End)
+	if !ok {
+		return Diagnostic{}, false
+	}
+	d := Diagnostic{
+		Analyzer: a.Name,
+		Category: diag.Category,
+		Range:    rng,
+		Message:  diag.Message,
+	}
+	for _, sf := range diag.SuggestedFixes {
+		fix := SuggestedFix{Message: sf.Message}
+		for _, edit := range sf.TextEdits {
+			rng, ok := newRange(fset, edit.Pos, edit.End)
+			if !ok {
+				return Diagnostic{}, false
+			}
+			fix.TextEdits = append(fix.TextEdits, TextEdit{rng, string(edit.NewText)})
+		}
+		d.SuggestedFixes = append(d.SuggestedFixes, fix)
+	}
+	for _, related := range diag.Related {
+		if rng, ok := newRange(fset, related.Pos, related.End); ok {
+			d.Related = append(d.Related, RelatedInformation{rng, related.Message})
+		}
+	}
+	return d, true
+}
+
+// newRange returns the Range from pos to end, which may be invalid for
+// an empty range, or false if they are not in the same file.
+func newRange(fset *token.FileSet, pos, end token.Pos) (Range, bool) {
+	if !end.IsValid() {
+		end = pos
+	}
+	file := fset.File(pos)
+	if file == nil || fset.File(end) != file || end < pos {
+		return Range{}, false
+	}
+	return Range{file.Name(), file.Offset(pos), file.Offset(end)}, true
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pluginchecker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/findcall"
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/printf"
+	"github.com/cowpaths/golang-x-tools/go/analysis/pluginchecker"
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+	"github.com/cowpaths/golang-x-tools/go/packages/packagestest"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("PLUGINCHECKER_CHILD") == "1" {
+		// child process
+		main()
+		panic("unreachable")
+	}
+
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func main() {
+	pluginchecker.Main(
+		findcall.Analyzer,
+		printf.Analyzer,
+	)
+}
+
+// TestPlugin runs the main function above as gopls would, on a package
+// with a file modified in the editor, after running it on its dependency
+// for its facts.
+func TestPlugin(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skipf("skipping fork/exec test on this platform")
+	}
+
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go": `package a
+
+import "fmt"
+
+func MyFunc123() {}
+
+func Wrapf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+`,
+			"b/b.go": `package b
+
+import "golang.org/fake/a"
+
+func _() {
+	a.MyFunc123()
+}
+
+func MyFunc123() {}
+`,
+		}}})
+	defer exported.Cleanup()
+
+	// gopls provides the export data of the dependencies of a package,
+	// which it has already type-checked.
+	conf := *exported.Config
+	conf.Mode = packages.LoadAllSyntax
+	initial, err := packages.Load(&conf, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || len(initial[0].Errors) > 0 {
+		t.Fatalf("cannot load package a: %v", initial)
+	}
+	pkgA := initial[0]
+	dir := t.TempDir()
+	exportData := func(pkg *packages.Package) string {
+		name := filepath.Join(dir, strings.Replace(pkg.PkgPath, "/", "_", -1)+".x")
+		var buf bytes.Buffer
+		if err := gcexportdata.Write(&buf, pkg.Fset, pkg.Types); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	// The facts of a tell that Wrapf is a printf wrapper.
+	vetx := filepath.Join(dir, "a.vetx")
+	result := runPlugin(t, pluginchecker.Config{
+		ID:          "golang.org/fake/a",
+		ImportPath:  "golang.org/fake/a",
+		GoFiles:     []string{exported.File("golang.org/fake", "a/a.go")},
+		WordSize:    8,
+		MaxAlign:    8,
+		ImportMap:   map[string]string{"fmt": "fmt"},
+		PackageFile: map[string]string{"fmt": exportData(pkgA.Imports["fmt"])},
+		VetxOnly:    true,
+		VetxOutput:  vetx,
+	})
+	if len(result.Diagnostics) > 0 || len(result.Errors) > 0 {
+		t.Errorf("facts only run reported %+v", result)
+	}
+
+	// The overlay adds a call, and a wrong call of a.Wrapf.
+	b := exported.File("golang.org/fake", "b/b.go")
+	modified := `package b
+
+import "golang.org/fake/a"
+
+func _() {
+	a.MyFunc123()
+	MyFunc123()
+	a.Wrapf("%d", "x")
+}
+
+func MyFunc123() {}
+`
+	result = runPlugin(t, pluginchecker.Config{
+		ID:          "golang.org/fake/b",
+		ImportPath:  "golang.org/fake/b",
+		GoFiles:     []string{b},
+		Overlay:     map[string][]byte{b: []byte(modified)},
+		WordSize:    8,
+		MaxAlign:    8,
+		ImportMap:   map[string]string{"golang.org/fake/a": "golang.org/fake/a"},
+		PackageFile: map[string]string{"golang.org/fake/a": exportData(pkgA)},
+		PackageVetx: map[string]string{"golang.org/fake/a": vetx},
+	})
+
+	if len(result.Errors) > 0 {
+		t.Errorf("analyzers failed: %s", strings.Join(result.Errors, "\n"))
+	}
+	var got []string
+	for _, d := range result.Diagnostics {
+		if d.Range.File != b {
+			t.Errorf("diagnostic %q in file %s, want %s", d.Message, d.Range.File, b)
+			continue
+		}
+		line := modified[strings.LastIndex(modified[:d.Range.Start], "\n")+1 : d.Range.Start]
+		got = append(got, d.Analyzer+": "+strings.TrimSpace(line)+": "+d.Message)
+		if d.Analyzer != "findcall" {
+			continue
+		}
+		// The diagnostics of findcall are at the parentheses of the
+		// calls, and so are the insertions of their fixes.
+		if len(d.SuggestedFixes) != 1 || len(d.SuggestedFixes[0].TextEdits) != 1 ||
+			d.SuggestedFixes[0].TextEdits[0].Range != d.Range || d.SuggestedFixes[0].TextEdits[0].NewText != "_TEST_" {
+			t.Errorf("unexpected fixes of diagnostic %q: %+v", d.Message, d.SuggestedFixes)
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"findcall: MyFunc123: call of MyFunc123(...)",
+		"findcall: a.MyFunc123: call of MyFunc123(...)",
+		`printf: : golang.org/fake/a.Wrapf format %d has arg "x" of wrong type string`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The analyzers of the plugin use facts.
+	cmd := exec.Command(os.Args[0], "facts")
+	cmd.Env = append(os.Environ(), "PLUGINCHECKER_CHILD=1")
+	if out, err := cmd.Output(); err != nil || string(out) != "true" {
+		t.Errorf("facts query: got %q, %v, want true", out, err)
+	}
+}
+
+// runPlugin runs the main function above on the config.
+func runPlugin(t *testing.T, cfg pluginchecker.Config) *pluginchecker.Result {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgFile := filepath.Join(t.TempDir(), "package.json")
+	if err := ioutil.WriteFile(cfgFile, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-findcall.name=MyFunc123", cfgFile)
+	cmd.Env = append(os.Environ(), "PLUGINCHECKER_CHILD=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("plugin failed: %v\n%s", err, err.(*exec.ExitError).Stderr)
+	}
+	result := new(pluginchecker.Result)
+	if err := json.Unmarshal(out, result); err != nil {
+		t.Fatalf("cannot decode result %s: %v", out, err)
+	}
+	return result
+}
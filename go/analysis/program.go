@@ -0,0 +1,89 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// A ProgramPass provides information to the RunProgram function that
+// applies a whole-program analyzer to all the packages under analysis,
+// after they have been analyzed by the analyzers it requires.
+//
+// Whereas the facts of a Pass flow only from the dependencies of a
+// package to the package itself, a ProgramPass sees those of every
+// package, so that it can find, for example, the exported functions
+// that no package calls. The packages need not share the objects of
+// their common dependencies, so an object referred to by several
+// packages is best identified by its package path and name.
+//
+// The RunProgram function should not call any of the ProgramPass
+// functions concurrently.
+type ProgramPass struct {
+	Analyzer *Analyzer // the identity of the current analyzer
+
+	Fset     *token.FileSet    // file position information
+	Packages []*ProgramPackage // the packages under analysis, but not their dependencies
+
+	// Report reports a Diagnostic about any of the packages.
+	// It may be called by the RunProgram function.
+	Report func(Diagnostic)
+
+	// -- facts --
+
+	// ImportObjectFact retrieves a fact associated with obj, exported
+	// by one of the required analyzers during the analysis of any
+	// package. See Pass.ImportObjectFact.
+	ImportObjectFact func(obj types.Object, fact Fact) bool
+
+	// ImportPackageFact retrieves a fact associated with package pkg.
+	// See comments for ImportObjectFact.
+	ImportPackageFact func(pkg *types.Package, fact Fact) bool
+
+	// AllPackageFacts returns a new slice containing all package facts
+	// of the FactTypes of the required analyzers, in unspecified order.
+	// WARNING: This is an experimental API and may change in the future.
+	AllPackageFacts func() []PackageFact
+
+	// AllObjectFacts returns a new slice containing all object facts
+	// of the FactTypes of the required analyzers, in unspecified order.
+	// WARNING: This is an experimental API and may change in the future.
+	AllObjectFacts func() []ObjectFact
+}
+
+// A ProgramPackage describes one of the packages of a ProgramPass.
+type ProgramPackage struct {
+	Files        []*ast.File    // the abstract syntax tree of each file
+	OtherFiles   []string       // names of non-Go files of this package
+	IgnoredFiles []string       // names of ignored source files in this package
+	Pkg          *types.Package // type information about the package
+	TypesInfo    *types.Info    // type information about the syntax trees
+	TypesSizes   types.Sizes    // function for computing sizes of types
+
+	// ResultOf holds the results of the analyzers of Analyzer.Requires
+	// on this package.
+	ResultOf map[*Analyzer]interface{}
+}
+
+// Reportf is a helper function that reports a Diagnostic using the
+// specified position and formatted error message.
+func (pass *ProgramPass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	pass.Report(Diagnostic{Pos: pos, Message: msg})
+}
+
+// ReportRangef is a helper function that reports a Diagnostic using the
+// range provided.
+func (pass *ProgramPass) ReportRangef(rng Range, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	pass.Report(Diagnostic{Pos: rng.Pos(), End: rng.End(), Message: msg})
+}
+
+func (pass *ProgramPass) String() string {
+	return fmt.Sprintf("%s@%d packages", pass.Analyzer.Name, len(pass.Packages))
+}
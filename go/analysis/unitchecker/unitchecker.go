@@ -123,6 +123,24 @@ Usage of %[1]s:
 // and calls os.Exit with an appropriate error code.
 // It assumes flags have already been set.
 func Run(configFile string, analyzers []*analysis.Analyzer) {
+	// Each invocation analyzes a single package.
+	if analysisflags.WriteBaseline {
+		log.Fatal("-write-baseline requires a driver that analyzes all packages at once, such as a singlechecker or multichecker command")
+	}
+	if analysisflags.SARIF {
+		log.Fatal("-sarif requires a driver that analyzes all packages at once, such as a singlechecker or multichecker command")
+	}
+	var program []string
+	for _, a := range analyzers {
+		if a.RunProgram != nil {
+			program = append(program, a.Name)
+		}
+	}
+	if len(program) > 0 {
+		log.Fatalf("whole-program analyzers require a driver that analyzes all packages at once, such as a singlechecker or multichecker command; disable them with %s",
+			"-"+strings.Join(program, "=false -")+"=false")
+	}
+
 	cfg, err := readConfig(configFile)
 	if err != nil {
 		log.Fatal(err)
@@ -372,13 +390,35 @@ func run(fset *token.FileSet, cfg *Config, analyzers []*analysis.Analyzer) ([]re
 
 	execAll(analyzers)
 
-	// Return diagnostics and errors from root analyzers.
+	// Return diagnostics and errors from root analyzers, without the
+	// diagnostics suppressed by //lint:ignore directives or recorded in
+	// the -baseline file.
+	diagnostics := make(map[*analysis.Analyzer][]analysis.Diagnostic)
+	for _, a := range analyzers {
+		if act := actions[a]; act.err == nil {
+			diagnostics[a] = act.diagnostics
+		}
+	}
+	problems := analysisflags.Suppress(fset, files, diagnostics)
+	if analysisflags.BaselineFile != "" {
+		b, err := analysisflags.ReadBaseline(analysisflags.BaselineFile)
+		if err != nil {
+			return nil, err
+		}
+		for a, diags := range diagnostics {
+			diagnostics[a] = b.Filter(fset, files, a.Name, cfg.ImportPath, diags)
+		}
+		problems = b.Filter(fset, files, analysisflags.IgnoreAnalyzer.Name, cfg.ImportPath, problems)
+	}
 	results := make([]result, len(analyzers))
 	for i, a := range analyzers {
 		act := actions[a]
 		results[i].a = a
 		results[i].err = act.err
-		results[i].diagnostics = act.diagnostics
+		results[i].diagnostics = diagnostics[a]
+	}
+	if len(problems) > 0 {
+		results = append(results, result{a: analysisflags.IgnoreAnalyzer, diagnostics: problems})
 	}
 
 	data := facts.Encode()
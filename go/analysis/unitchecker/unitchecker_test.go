@@ -98,6 +98,9 @@ func MyFunc123() {}
 \}
 `
 
+	// A SARIF log describes a whole run, which go vet splits by package.
+	const wantSARIF = `-sarif requires a driver that analyzes all packages at once`
+
 	for _, test := range []struct {
 		args     string
 		wantOut  string
@@ -107,6 +110,7 @@ func MyFunc123() {}
 		{args: "golang.org/fake/b", wantOut: wantB, wantExit: 2},
 		{args: "golang.org/fake/a golang.org/fake/b", wantOut: wantA + wantB, wantExit: 2},
 		{args: "-json golang.org/fake/a", wantOut: wantAJSON, wantExit: 0},
+		{args: "-sarif golang.org/fake/a", wantOut: wantSARIF, wantExit: 1},
 		{args: "-c=0 golang.org/fake/a", wantOut: wantA + "4		MyFunc123\\(\\)\n", wantExit: 2},
 	} {
 		cmd := exec.Command("go", "vet", "-vettool="+os.Args[0], "-findcall.name=MyFunc123")
@@ -15,7 +15,9 @@ import (
 // Checks include:
 // that the name is a valid identifier;
 // that the Doc is not empty;
-// that the Run is non-nil;
+// that exactly one of Run and RunProgram is non-nil;
+// that whole-program analyzers have no fact types nor result type and
+// are not required;
 // that the Requires graph is acyclic;
 // that analyzer fact types are unique;
 // that each fact type is a pointer.
@@ -48,7 +50,17 @@ func Validate(analyzers []*Analyzer) error {
 				return fmt.Errorf("analyzer %q is undocumented", a)
 			}
 
-			if a.Run == nil {
+			if a.RunProgram != nil {
+				if a.Run != nil {
+					return fmt.Errorf("analyzer %q has both Run and RunProgram", a)
+				}
+				if len(a.FactTypes) > 0 {
+					return fmt.Errorf("whole-program analyzer %q has fact types", a)
+				}
+				if a.ResultType != nil {
+					return fmt.Errorf("whole-program analyzer %q has a result type", a)
+				}
+			} else if a.Run == nil {
 				return fmt.Errorf("analyzer %q has nil Run", a)
 			}
 			// fact types
@@ -69,6 +81,9 @@ func Validate(analyzers []*Analyzer) error {
 
 			// recursion
 			for _, req := range a.Requires {
+				if req != nil && req.RunProgram != nil {
+					return fmt.Errorf("analyzer %q requires whole-program analyzer %q", a, req)
+				}
 				if err := visit(req); err != nil {
 					return err
 				}
@@ -5,6 +5,7 @@
 package analysis
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -150,3 +151,35 @@ func TestValidateNoRun(t *testing.T) {
 		t.Errorf("got unexpected error while validating analyzers withoutRun: %v", err)
 	}
 }
+
+func TestValidateProgram(t *testing.T) {
+	run := func(p *Pass) (interface{}, error) { return nil, nil }
+	runProgram := func(p *ProgramPass) error { return nil }
+	program := &Analyzer{
+		Name:       "program",
+		Doc:        "this analyzer is a whole-program analyzer",
+		RunProgram: runProgram,
+	}
+	if err := Validate([]*Analyzer{program}); err != nil {
+		t.Errorf("got unexpected error while validating analyzers program: %v", err)
+	}
+
+	for _, test := range []struct {
+		a    *Analyzer
+		want string
+	}{
+		{&Analyzer{Name: "both", Doc: "both", Run: run, RunProgram: runProgram}, "has both Run and RunProgram"},
+		{&Analyzer{Name: "facts", Doc: "facts", RunProgram: runProgram, FactTypes: []Fact{new(fact)}}, "has fact types"},
+		{&Analyzer{Name: "result", Doc: "result", RunProgram: runProgram, ResultType: reflect.TypeOf(0)}, "has a result type"},
+		{&Analyzer{Name: "requires", Doc: "requires", Run: run, Requires: []*Analyzer{program}}, "requires whole-program analyzer"},
+	} {
+		err := Validate([]*Analyzer{test.a})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("got unexpected error while validating analyzers %s: %v", test.a, err)
+		}
+	}
+}
+
+type fact struct{}
+
+func (*fact) AFact() {}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"testing"
 
 	"github.com/cowpaths/golang-x-tools/gopls/internal/hooks"
@@ -193,6 +194,63 @@ func TestBenchmarkDidChange(t *testing.T) {
 	})
 }
 
+var bodyEditDeps = flag.Int("bodyedit_deps", 0, "If set, run the body edit benchmark with this many dependents of the edited package.")
+
+// TestBenchmarkBodyEdit benchmarks edits of a function body in a package
+// with many dependents, with and without the experimentalEarlyCutoff
+// setting, waiting for the diagnostics of each change to complete. The
+// edits leave the API of the package unchanged, so with the setting its
+// dependents are not type-checked again.
+//
+// Run it by passing -bodyedit_deps, the number of dependent packages of
+// the synthetic workspace, e.g.:
+//
+//	go test -run=TestBenchmarkBodyEdit -bodyedit_deps=200
+func TestBenchmarkBodyEdit(t *testing.T) {
+	if *bodyEditDeps == 0 {
+		t.Skip("-bodyedit_deps is not set")
+	}
+
+	var files strings.Builder
+	files.WriteString(`
+-- go.mod --
+module mod.com
+
+go 1.12
+-- base/base.go --
+package base
+
+func F() int {
+	return 0
+}
+`)
+	for i := 0; i < *bodyEditDeps; i++ {
+		fmt.Fprintf(&files, "-- dep%d/dep.go --\npackage dep%d\n\nimport \"mod.com/base\"\n", i, i)
+		for j := 0; j < 50; j++ {
+			fmt.Fprintf(&files, "\nfunc F%d() int {\n\treturn base.F() + %d\n}\n", j, j)
+		}
+	}
+
+	for _, cutoff := range []bool{false, true} {
+		t.Run(fmt.Sprintf("earlyCutoff=%v", cutoff), func(t *testing.T) {
+			WithOptions(
+				Settings{"experimentalEarlyCutoff": cutoff},
+				Modes(Singleton),
+			).Run(t, files.String(), func(_ *testing.T, env *Env) {
+				env.OpenFile("base/base.go")
+				env.Await(env.DoneWithOpen())
+				result := testing.Benchmark(func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						env.RegexpReplace("base/base.go", `return [0-9]+`, fmt.Sprintf("return %d", i+1))
+						env.Await(env.DoneWithChange())
+					}
+				})
+				printBenchmarkResults(result)
+			})
+		})
+	}
+}
+
 // TestPrintMemStats measures the memory usage of loading a project.
 // It uses the same -didchange_dir flag as above.
 // Always run it in isolation since it measures global heap usage.
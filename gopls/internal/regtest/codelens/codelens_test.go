@@ -350,3 +350,126 @@ func main() {
 		)
 	})
 }
+
+func TestReferencesAndImplementationsCodeLens(t *testing.T) {
+	const mod = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a.go --
+package a
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct{ side int }
+
+func (s Square) Area() int { return s.side * s.side }
+
+func NewSquare(side int) Square {
+	return Square{side}
+}
+-- b.go --
+package a
+
+var _ Shape = NewSquare(1)
+
+var _ = NewSquare(2)
+`
+	WithOptions(
+		Settings{
+			"codelenses": map[string]bool{
+				"references":      true,
+				"implementations": true,
+			},
+		},
+	).Run(t, mod, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		got := make(map[string]bool)
+		for _, lens := range env.CodeLens("a.go") {
+			if lens.Command.Command != "" {
+				continue // not a lazily resolved lens
+			}
+			resolved := env.ResolveCodeLens(lens)
+			got[fmt.Sprintf("%d: %s", resolved.Range.Start.Line, resolved.Command.Title)] = true
+		}
+		for _, want := range []string{
+			"2: 1 reference",      // Shape
+			"2: 1 implementation", // Shape
+			"6: 3 references",     // Square
+			"6: 1 implementation", // Square
+			"8: 0 references",     // Square.Area
+			"10: 2 references",    // NewSquare
+		} {
+			if !got[want] {
+				t.Errorf("missing code lens %q; got %v", want, got)
+			}
+		}
+	})
+}
+
+func TestProfileBenchmark(t *testing.T) {
+	const mod = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- alloc.go --
+package alloc
+
+var sink []byte
+
+func Alloc() {
+	sink = make([]byte, 1<<20)
+}
+-- alloc_test.go --
+package alloc
+
+import "testing"
+
+func BenchmarkAlloc(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Alloc()
+	}
+}
+`
+	WithOptions(
+		Settings{
+			"codelenses": map[string]bool{
+				"profile_benchmark": true,
+			},
+		},
+	).Run(t, mod, func(t *testing.T, env *Env) {
+		env.OpenFile("alloc_test.go")
+		var lens *protocol.CodeLens
+		for _, l := range env.CodeLens("alloc_test.go") {
+			if l.Command.Title == "profile benchmark (mem)" {
+				l := l
+				lens = &l
+			}
+		}
+		if lens == nil {
+			t.Fatal("found no profile benchmark (mem) code lens")
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   lens.Command.Command,
+			Arguments: lens.Command.Arguments,
+		}, nil)
+		d := &protocol.PublishDiagnosticsParams{}
+		env.Await(
+			OnceMet(
+				env.DiagnosticAtRegexpWithMessage("alloc.go", `sink = make`, "BenchmarkAlloc"),
+				ReadDiagnostics("alloc.go", d),
+			),
+		)
+		// The call of Alloc is as hot as Alloc itself.
+		env.Await(env.DiagnosticAtRegexpWithMessage("alloc_test.go", `Alloc\(\)`, "in this line and its calls"))
+		for _, d := range d.Diagnostics {
+			if d.Severity != protocol.SeverityHint {
+				t.Errorf("unexpected diagnostic severity %v, wanted Hint", d.Severity)
+			}
+		}
+	})
+}
@@ -2236,3 +2236,62 @@ func F[T any](_ T) {
 		)
 	})
 }
+
+func TestBuildConfigurations(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- main.go --
+package main
+
+func main() {}
+-- main_windows.go --
+package main
+
+import "fmt"
+
+func init() {
+	fmt.Printf("%d", "s")
+}
+-- a/a.go --
+package a
+-- a/a_windows.go --
+package a
+
+var x int = ""
+-- b/b.go --
+package b
+-- b/b_windows.go --
+package b
+
+var y int = ""
+`
+	WithOptions(
+		Settings{"buildConfigurations": []interface{}{"windows/amd64"}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main_windows.go")
+		env.Await(
+			env.DiagnosticAtRegexpFromSource("main_windows.go", `fmt.Printf`, "printf (windows/amd64)"),
+			env.DiagnosticAtRegexpFromSource("a/a_windows.go", `""`, "compiler (windows/amd64)"),
+			env.DiagnosticAtRegexpFromSource("b/b_windows.go", `""`, "compiler (windows/amd64)"),
+			NoDiagnostics("main.go"),
+		)
+		env.Await(NoDiagnosticWithMessage("main_windows.go", "No packages found"))
+
+		// Edits to the files compiled only in the configuration are
+		// diagnosed before they are saved.
+		env.OpenFile("b/b_windows.go")
+		env.Await(OnceMet(env.DoneWithOpen(), env.DiagnosticAtRegexpFromSource("b/b_windows.go", `""`, "compiler (windows/amd64)")))
+		env.RegexpReplace("b/b_windows.go", `""`, "1")
+		env.Await(OnceMet(env.DoneWithChange(), EmptyDiagnostics("b/b_windows.go")))
+
+		cfg := env.Editor.Config()
+		cfg.Settings = map[string]interface{}{
+			"buildConfigurations": []interface{}{},
+		}
+		env.ChangeConfiguration(cfg)
+		env.Await(EmptyDiagnostics("a/a_windows.go"))
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+)
+
+func TestShowCoverage(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- sign.go --
+package sign
+
+func Sign(x int) int {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
+
+func Abs(x int) int {
+	return x * Sign(x)
+}
+-- sign_test.go --
+package sign
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	if Sign(2) != 1 {
+		t.Error("Sign(2) != 1")
+	}
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("sign.go")
+		uri := env.Sandbox.Workdir.URI("sign.go")
+		cmd, err := command.NewShowCoverageCommand("Show coverage", command.CoverageArgs{URI: uri})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   cmd.Command,
+			Arguments: cmd.Arguments,
+		}, nil)
+		d := &protocol.PublishDiagnosticsParams{}
+		env.Await(
+			OnceMet(
+				CompletedWork("Computing test coverage", 1, true),
+				ReadDiagnostics("sign.go", d),
+			),
+		)
+		// The extent of coverage blocks depends on the Go version, so just
+		// check that the uncovered lines are annotated.
+		for _, re := range []string{"return -1", "return x"} {
+			line := uint32(env.RegexpSearch("sign.go", re).Line)
+			var found bool
+			for _, d := range d.Diagnostics {
+				if d.Range.Start.Line <= line && line <= d.Range.End.Line {
+					found = true
+					if d.Severity != protocol.SeverityHint || d.Message != "not covered by tests" {
+						t.Errorf("unexpected diagnostic %+v", d)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("no coverage diagnostic for %q", re)
+			}
+		}
+
+		for re, want := range map[string]string{
+			"func (Sign)": "Test coverage of Sign: 66.7% of statements",
+			"func (Abs)":  "Test coverage of Abs: 0.0% of statements",
+			// The coverage of a function is shown in its body too.
+			"x \\* (Sign)": "Test coverage of Abs: 0.0% of statements",
+		} {
+			content, _ := env.Hover("sign.go", env.RegexpSearch("sign.go", re))
+			if !strings.Contains(content.Value, want) {
+				t.Errorf("hover over %s = %q, want it to contain %q", re, content.Value, want)
+			}
+		}
+
+		cmd, err = command.NewClearCoverageCommand("Clear coverage", command.URIArg{URI: uri})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   cmd.Command,
+			Arguments: cmd.Arguments,
+		}, nil)
+		env.Await(EmptyDiagnostics("sign.go"))
+	})
+}
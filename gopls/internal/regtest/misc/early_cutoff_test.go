@@ -0,0 +1,149 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+func TestEarlyCutoff(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+const N = "n"
+
+type T struct{}
+
+func (T) M() {}
+
+type I interface{ M() }
+
+// A returns a T.
+func A() T {
+	return T{}
+}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+var _ int = a.N
+
+var x = a.A()
+
+var _ a.I = x
+
+func B() {
+	a.A()
+}
+`
+	WithOptions(
+		Settings{"experimentalEarlyCutoff": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.OpenFile("b/b.go")
+		env.Await(env.DiagnosticAtRegexp("b/b.go", `a.N`))
+
+		// An edit of the body and of the doc comment of A leaves the export
+		// data of a unchanged, so b keeps its types. The features that go
+		// from b to a find the current declarations of a.
+		env.RegexpReplace("a/a.go", `return T\{\}`, "return (T{})")
+		env.RegexpReplace("a/a.go", "returns a T", "returns a new T")
+		env.Await(
+			OnceMet(
+				env.DoneWithChange(),
+				env.DiagnosticAtRegexp("b/b.go", `a.N`),
+			),
+		)
+		name, pos := env.GoToDefinition("b/b.go", env.RegexpSearch("b/b.go", `A\(\)`))
+		if want := env.RegexpSearch("a/a.go", "func (A)"); name != "a/a.go" || pos != want {
+			t.Errorf("GoToDefinition: got %s:%v, want a/a.go:%v", name, pos, want)
+		}
+		if content, _ := env.Hover("b/b.go", env.RegexpSearch("b/b.go", `A\(\)`)); !strings.Contains(content.Value, "A returns a new T") {
+			t.Errorf("Hover: got %q, want the current doc comment of A", content.Value)
+		}
+		checkLocation := func(feature string, locs []protocol.Location, wantRe string) {
+			t.Helper()
+			want := env.RegexpSearch("a/a.go", wantRe)
+			if len(locs) != 1 || locs[0].URI != env.Sandbox.Workdir.URI("a/a.go") || locs[0].Range.Start != want.ToProtocolPosition() {
+				t.Errorf("%s: got %v, want a/a.go:%v", feature, locs, want)
+			}
+		}
+		var typeDef protocol.TypeDefinitionParams
+		typeDef.TextDocument.URI = env.Sandbox.Workdir.URI("b/b.go")
+		typeDef.Position = env.RegexpSearch("b/b.go", `_ a.I = (x)`).ToProtocolPosition()
+		locs, err := env.Editor.Server.TypeDefinition(env.Ctx, &typeDef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLocation("TypeDefinition", locs, "type (T)")
+		var impl protocol.ImplementationParams
+		impl.TextDocument.URI = env.Sandbox.Workdir.URI("b/b.go")
+		impl.Position = env.RegexpSearch("b/b.go", `a.(I)`).ToProtocolPosition()
+		locs, err = env.Editor.Server.Implementation(env.Ctx, &impl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLocation("Implementation", locs, "type (T)")
+		refs := env.References("a/a.go", env.RegexpSearch("a/a.go", "func (A)"))
+		if got := len(refs); got != 3 {
+			t.Errorf("References: got %d locations, want 3 (declaration and uses in b)", got)
+		}
+		var prepare protocol.CallHierarchyPrepareParams
+		prepare.TextDocument.URI = env.Sandbox.Workdir.URI("a/a.go")
+		prepare.Position = env.RegexpSearch("a/a.go", "func (A)").ToProtocolPosition()
+		items, err := env.Editor.Server.PrepareCallHierarchy(env.Ctx, &prepare)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("PrepareCallHierarchy: got %v, %v, want one item", items, err)
+		}
+		calls, err := env.Editor.Server.IncomingCalls(env.Ctx, &protocol.CallHierarchyIncomingCallsParams{Item: items[0]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var callers []string
+		for _, call := range calls {
+			callers = append(callers, call.From.Name)
+		}
+		if got, want := strings.Join(callers, " "), "b B"; got != want {
+			t.Errorf("IncomingCalls: got calls from %q, want from %q", got, want)
+		}
+
+		// An edit that moves the declaration of A changes the export data
+		// of a, so b is type-checked again.
+		env.RegexpReplace("a/a.go", "// A returns", "// A is a function.\n// A returns")
+		env.Await(env.DoneWithChange())
+		name, pos = env.GoToDefinition("b/b.go", env.RegexpSearch("b/b.go", `A\(\)`))
+		if want := env.RegexpSearch("a/a.go", "func (A)"); name != "a/a.go" || pos != want {
+			t.Errorf("GoToDefinition after move: got %s:%v, want a/a.go:%v", name, pos, want)
+		}
+
+		// So does a change of the API of a.
+		env.RegexpReplace("a/a.go", `N = "n"`, "N = 1")
+		env.Await(
+			OnceMet(
+				env.DoneWithChange(),
+				EmptyDiagnostics("b/b.go"),
+			),
+		)
+
+		// Renaming A from b renames its declaration and all its uses.
+		env.Rename("b/b.go", env.RegexpSearch("b/b.go", `A\(\)`), "A2")
+		if got := env.Editor.BufferText("a/a.go"); !strings.Contains(got, "func A2()") {
+			t.Errorf("after renaming, a/a.go does not declare A2:\n%s", got)
+		}
+		if got := strings.Count(env.Editor.BufferText("b/b.go"), "a.A2()"); got != 2 {
+			t.Errorf("after renaming, b/b.go has %d calls of a.A2, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+// TestAnalysisFileCache checks that the diagnostics of the analyzers and
+// the export data of the dependencies are stored in the file cache, and
+// are used again by another session that reads them from there.
+func TestAnalysisFileCache(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+import "fmt"
+
+func _() {
+	fmt.Printf("%d", "s")
+}
+`
+	dir := t.TempDir()
+	t.Setenv("GOPLSCACHE", dir)
+
+	for i := 0; i < 2; i++ {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("a/a.go")
+			env.Await(env.DiagnosticAtRegexpWithMessage("a/a.go", "fmt.Printf", "wrong type"))
+			// The declaration of Printf, read from the export data of fmt,
+			// is found in its syntax.
+			if content, _ := env.Hover("a/a.go", env.RegexpSearch("a/a.go", "Printf")); !strings.Contains(content.Value, "Printf formats") {
+				t.Errorf("session %d: Hover: got %q, want the doc comment of Printf", i, content.Value)
+			}
+		})
+		for _, kind := range []string{"analysis", "export"} {
+			entries, err := ioutil.ReadDir(filepath.Join(dir, kind))
+			if err != nil || len(entries) == 0 {
+				t.Fatalf("session %d: no %s entries in the file cache (err: %v)", i, kind, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"testing"
+
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+func TestLintIgnore(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+import "fmt"
+
+func _() {
+	//lint:ignore printf the argument is deliberately wrong
+	fmt.Printf("%d", "x")
+	fmt.Printf("%d", "y")
+}
+
+//lint:ignore printf there is no call to suppress
+func _() {}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `fmt.Printf\("%d", "y"\)`, "wrong type"),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `//lint:ignore printf there`, "suppresses no diagnostic of printf"),
+				env.NoDiagnosticAtRegexp("a/a.go", `fmt.Printf\("%d", "x"\)`),
+			),
+		)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+import "mod.com/b"
+
+func _() {
+	b.F()
+}
+-- b/b.go --
+package b
+
+// F does nothing.
+func F() {}
+
+type I interface{ M() }
+
+type T struct{}
+
+func (T) M() {}
+-- d/d.go --
+package d
+
+import "mod.com/b"
+
+func _() {
+	b.F()
+}
+-- c/c.go --
+package c
+
+func _() {
+	var _ int = ""
+}
+`
+	// With a budget of one byte, the packages without open files are
+	// evicted once they have been type-checked, and held as export data,
+	// but are still diagnosed and searched by the workspace-wide
+	// features, and the features that go from their importers to their
+	// declarations find them.
+	WithOptions(
+		Settings{"memoryBudget": "1B"},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.Await(env.DiagnosticAtRegexp("c/c.go", `""`))
+		env.OpenFile("a/a.go")
+		env.RegexpReplace("a/a.go", "func _", "func G")
+		env.Await(
+			OnceMet(
+				env.DoneWithChange(),
+				env.DiagnosticAtRegexp("c/c.go", `""`),
+			),
+		)
+		name, pos := env.GoToDefinition("a/a.go", env.RegexpSearch("a/a.go", "F"))
+		if want := env.RegexpSearch("b/b.go", "func (F)"); name != "b/b.go" || pos != want {
+			t.Errorf("GoToDefinition: got %s:%v, want b/b.go:%v", name, pos, want)
+		}
+		if content, _ := env.Hover("a/a.go", env.RegexpSearch("a/a.go", "F")); !strings.Contains(content.Value, "F does nothing") {
+			t.Errorf("Hover: got %q, want the doc comment of F", content.Value)
+		}
+		env.RegexpReplace("a/a.go", `b.F\(\)`, "var _ b.I = nil")
+		env.Await(env.DoneWithChange())
+		var impl protocol.ImplementationParams
+		impl.TextDocument.URI = env.Sandbox.Workdir.URI("a/a.go")
+		impl.Position = env.RegexpSearch("a/a.go", `b.(I)`).ToProtocolPosition()
+		locs, err := env.Editor.Server.Implementation(env.Ctx, &impl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := env.RegexpSearch("b/b.go", "type (T)"); len(locs) != 1 || locs[0].URI != env.Sandbox.Workdir.URI("b/b.go") || locs[0].Range.Start != want.ToProtocolPosition() {
+			t.Errorf("Implementation: got %v, want b/b.go:%v", locs, want)
+		}
+		refs := env.References(name, pos)
+		var found bool
+		for _, ref := range refs {
+			if env.Sandbox.Workdir.URIToPath(ref.URI) == "d/d.go" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("References: got %v, want a reference in d/d.go", refs)
+		}
+	})
+}
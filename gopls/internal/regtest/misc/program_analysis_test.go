@@ -0,0 +1,61 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"testing"
+
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+func TestProgramAnalysis(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+func Used() {}
+
+func Unused() {}
+
+//lint:ignore unusedfunc kept for compatibility
+func Kept() {}
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() {
+	a.Used()
+}
+`
+	WithOptions(
+		Settings{"analyses": map[string]bool{"unusedfunc": true}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `Unused`, "exported function Unused is unused"),
+				env.NoDiagnosticAtRegexp("a/a.go", `Used\(\)`),
+				env.NoDiagnosticAtRegexp("a/a.go", `Kept`),
+			),
+		)
+
+		// Removing the use in another package reports the function.
+		env.OpenFile("b/b.go")
+		env.RegexpReplace("b/b.go", `a.Used\(\)`, "_ = a.Unused")
+		env.Await(
+			OnceMet(
+				env.DoneWithChange(),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `Used\(\)`, "exported function Used is unused"),
+				env.NoDiagnosticAtRegexp("a/a.go", `Unused`),
+			),
+		)
+	})
+}
@@ -5,10 +5,17 @@
 package misc
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/testenv"
 )
 
 func TestRunVulncheckExpError(t *testing.T) {
@@ -40,3 +47,117 @@ package foo
 		}
 	})
 }
+
+const vulnProxy = `
+-- golang.org/amod@v1.0.0/go.mod --
+module golang.org/amod
+
+go 1.14
+-- golang.org/amod@v1.0.0/avuln/avuln.go --
+package avuln
+
+func Vuln() {}
+
+func Safe() {}
+-- golang.org/bmod@v0.5.0/go.mod --
+module golang.org/bmod
+
+go 1.14
+-- golang.org/bmod@v0.5.0/bvuln/bvuln.go --
+package bvuln
+
+func Vuln() {}
+
+func Safe() {}
+`
+
+// vulnDB is a vulnerability database in the layout of a local GOVULNDB
+// directory: an index of the modules and a file of entries for each.
+var vulnDB = map[string]string{
+	"index.json": `{"golang.org/amod": "2022-06-01T00:00:00Z", "golang.org/bmod": "2022-06-01T00:00:00Z"}`,
+	"golang.org/amod.json": `[{
+		"id": "GO-2022-01",
+		"details": "vulnerable function",
+		"affected": [{
+			"package": {"name": "golang.org/amod/avuln"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"fixed": "1.0.4"}]}],
+			"ecosystem_specific": {"symbols": ["Vuln"]}
+		}]
+	}]`,
+	"golang.org/bmod.json": `[{
+		"id": "GO-2022-02",
+		"details": "unused vulnerable function",
+		"affected": [{
+			"package": {"name": "golang.org/bmod/bvuln"},
+			"ranges": [{"type": "SEMVER"}],
+			"ecosystem_specific": {"symbols": ["Vuln"]}
+		}]
+	}]`,
+}
+
+func TestVulncheckDiagnostics(t *testing.T) {
+	testenv.NeedsGo1Point(t, 18)
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.14
+
+require (
+	golang.org/amod v1.0.0
+	golang.org/bmod v0.5.0
+)
+-- main.go --
+package main
+
+import (
+	"golang.org/amod/avuln"
+	"golang.org/bmod/bvuln"
+)
+
+func main() {
+	avuln.Vuln()
+	avuln.Safe()
+	bvuln.Safe()
+}
+`
+	db := t.TempDir()
+	for name, content := range vulnDB {
+		filename := filepath.Join(db, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	WithOptions(
+		ProxyFiles(vulnProxy),
+		EnvVars{"GOVULNDB": "file://" + filepath.ToSlash(db)},
+		Settings{"vulncheck": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.RunGoCommand("mod", "download", "all")
+		env.OpenFile("go.mod")
+		env.OpenFile("main.go")
+		var d protocol.PublishDiagnosticsParams
+		env.Await(
+			OnceMet(
+				env.DiagnosticAtRegexpWithMessage("go.mod", "golang.org/amod", "golang.org/amod has vulnerabilities used in the code: GO-2022-01."),
+				ReadDiagnostics("go.mod", &d),
+			),
+			env.DiagnosticAtRegexpWithMessage("go.mod", "golang.org/bmod", "golang.org/bmod has vulnerabilities not used in the code: GO-2022-02."),
+			env.DiagnosticAtRegexpWithMessage("main.go", `avuln\.Vuln`, "this call reaches golang.org/amod/avuln.Vuln, which has vulnerability GO-2022-01 (fixed in golang.org/amod@v1.0.4)"),
+			env.NoDiagnosticAtRegexp("main.go", `avuln\.Safe`),
+		)
+		var fixes []protocol.Diagnostic
+		for _, diag := range d.Diagnostics {
+			if diag.Source == string(source.Vulncheck) && strings.Contains(diag.Message, "GO-2022-01") {
+				fixes = append(fixes, diag)
+			}
+		}
+		codeActions := env.CodeAction("go.mod", fixes)
+		if len(codeActions) != 1 || codeActions[0].Title != "Upgrade to v1.0.4" {
+			t.Errorf("code actions for go.mod: got %v, want one upgrading to v1.0.4", codeActions)
+		}
+	})
+}
@@ -5,6 +5,7 @@
 package misc
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
@@ -112,6 +113,70 @@ const (
 	})
 }
 
+func TestWorkspaceSymbolFilters(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.17
+-- a/a.go --
+package a
+
+import "strings"
+
+var _ strings.Builder
+
+type Foo struct{}
+
+func (Foo) Method() {}
+
+func Func() {}
+-- a/a_test.go --
+package a
+
+func FuncTest() {}
+-- b/b.go --
+package b
+
+func Func() {}
+`
+
+	var symbolMatcher = string(source.SymbolFastFuzzy)
+	WithOptions(
+		Settings{"symbolMatcher": symbolMatcher},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.Await(env.DoneWithOpen())
+
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("pkg:mod.com/b Func"), []string{"mod.com/b Func"})
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("pkg:mod.com/... kind:method"), []string{"mod.com/a Foo.Method"})
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("file:*_test.go Func"), []string{"mod.com/a FuncTest"})
+
+		// Dependencies are searched only with dep:.
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("pkg:strings kind:type 'Builder"), nil)
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("dep: pkg:strings kind:type 'Builder"), []string{"strings Builder"})
+
+		// The symbols closest to the current file come first.
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("'Func kind:func"), []string{"mod.com/a Func", "mod.com/a FuncTest", "mod.com/b Func"})
+		env.OpenFile("b/b.go")
+		env.Await(env.DoneWithOpen())
+		compareQualifiedSymbols(t, env.WorkspaceSymbol("'Func kind:func"), []string{"mod.com/b Func", "mod.com/a Func", "mod.com/a FuncTest"})
+	})
+}
+
+// compareQualifiedSymbols compares the symbols with the wanted ones, each
+// described by its container and name.
+func compareQualifiedSymbols(t *testing.T, got []protocol.SymbolInformation, want []string) {
+	t.Helper()
+	var names []string
+	for _, sym := range got {
+		names = append(names, sym.ContainerName+" "+sym.Name)
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got symbols %q, want %q", names, want)
+	}
+}
+
 func compareSymbols(t *testing.T, got []protocol.SymbolInformation, want []string) {
 	t.Helper()
 	if len(got) != len(want) {
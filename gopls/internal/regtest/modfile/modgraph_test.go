@@ -0,0 +1,120 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/google/go-cmp/cmp"
+)
+
+const graphProxy = `
+-- example.com/a@v1.0.0/go.mod --
+module example.com/a
+
+go 1.12
+
+require example.com/b v1.0.0
+-- example.com/a@v1.0.0/a.go --
+package a
+
+import "example.com/b"
+
+const A = b.B
+-- example.com/b@v1.0.0/go.mod --
+module example.com/b
+
+go 1.12
+-- example.com/b@v1.0.0/b.go --
+package b
+
+const B = 1
+-- example.com/b@v1.1.0/go.mod --
+module example.com/b
+
+go 1.12
+-- example.com/b@v1.1.0/b.go --
+package b
+
+const B = 2
+`
+
+func TestModuleGraph(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0
+)
+-- main.go --
+package main
+
+import "example.com/a"
+
+func main() {
+	println(a.A)
+}
+`
+	WithOptions(
+		ProxyFiles(graphProxy),
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.RunGoCommand("mod", "download", "all")
+		env.OpenFile("go.mod")
+
+		content, _ := env.Hover("go.mod", env.RegexpSearch("go.mod", "example.com/b"))
+		for _, want := range []string{
+			"Selected version: v1.1.0",
+			"- mod.com at v1.1.0",
+			"- example.com/a@v1.0.0 at v1.0.0",
+		} {
+			if content == nil || !strings.Contains(content.Value, want) {
+				t.Errorf("hover: got %v, want it to contain %q", content, want)
+			}
+		}
+
+		for _, test := range []struct {
+			module, query string
+			want          []string
+		}{
+			{"example.com/b", "somepath", []string{"mod.com example.com/b@v1.1.0"}},
+			{"example.com/b@v1.0.0", "somepath", []string{
+				"mod.com example.com/a@v1.0.0",
+				"example.com/a@v1.0.0 example.com/b@v1.0.0",
+			}},
+			{"example.com/b@v1.0.0", "reverse", []string{
+				"example.com/a@v1.0.0 example.com/b@v1.0.0",
+				"mod.com example.com/a@v1.0.0",
+			}},
+			{"example.com/a", "", []string{
+				"example.com/a@v1.0.0 example.com/b@v1.0.0",
+				"mod.com example.com/a@v1.0.0",
+			}},
+		} {
+			cmd, err := command.NewModuleGraphCommand("", command.ModuleGraphArgs{
+				URI:    env.Sandbox.Workdir.URI("go.mod"),
+				Module: test.module,
+				Query:  test.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result command.ModuleGraphResult
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   command.ModuleGraph.ID(),
+				Arguments: cmd.Arguments,
+			}, &result)
+			if diff := cmp.Diff(test.want, result.Requirements); diff != "" {
+				t.Errorf("module graph %s %s: unexpected requirements (-want +got):\n%s", test.query, test.module, diff)
+			}
+		}
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/findcall"
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/printf"
+	"github.com/cowpaths/golang-x-tools/go/analysis/pluginchecker"
+	"github.com/cowpaths/golang-x-tools/gopls/internal/hooks"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/bug"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	. "github.com/cowpaths/golang-x-tools/internal/lsp/regtest"
+)
+
+// pluginFlag makes the test binary an analysis plugin.
+const pluginFlag = "-plugin"
+
+// pluginPrintf is the printf analyzer under another name, so that its
+// diagnostics are told apart from those of gopls.
+var pluginPrintf = func() analysis.Analyzer {
+	a := *printf.Analyzer
+	a.Name = "pluginprintf"
+	return a
+}()
+
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == pluginFlag {
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
+		pluginchecker.Main(findcall.Analyzer, &pluginPrintf)
+		panic("unreachable")
+	}
+	bug.PanicOnBugs = true
+	Main(m, hooks.Options)
+}
+
+func TestPlugin(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+func _() {
+	MyFunc123()
+	//lint:ignore findcall the call is deliberate
+	MyFunc123()
+}
+
+func MyFunc123() {}
+`
+	WithOptions(
+		Settings{"analysisPlugins": []string{os.Args[0] + " " + pluginFlag + " -findcall.name=MyFunc123"}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		var d protocol.PublishDiagnosticsParams
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `MyFunc123(\()`, "call of MyFunc123(...)"),
+				ReadDiagnostics("a/a.go", &d),
+			),
+		)
+		if len(d.Diagnostics) != 1 {
+			t.Fatalf("got %d diagnostics, want 1 (the other call is suppressed): %v", len(d.Diagnostics), d.Diagnostics)
+		}
+
+		// The plugin sees the unsaved contents of the file, and so does
+		// its suggested fix.
+		env.RegexpReplace("a/a.go", `func _\(\) {()`, "\n\t_ = 1")
+		env.Await(
+			OnceMet(
+				env.DoneWithChange(),
+				env.DiagnosticAtRegexpWithMessage("a/a.go", `MyFunc123(\()`, "call of MyFunc123(...)"),
+				ReadDiagnostics("a/a.go", &d),
+			),
+		)
+		env.ApplyQuickFixes("a/a.go", d.Diagnostics)
+		if got, want := env.Editor.BufferText("a/a.go"), "\t_ = 1\n\tMyFunc123_TEST_()\n"; !strings.Contains(got, want) {
+			t.Errorf("after the fix, got:\n%s\nwant it to contain:\n%s", got, want)
+		}
+	})
+}
+
+// TestPluginFacts checks that the analyzers of a plugin see their facts
+// about the dependencies of a package.
+func TestPluginFacts(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+import "fmt"
+
+func Wrapf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+-- b/b.go --
+package b
+
+import "mod.com/a"
+
+func _() {
+	a.Wrapf("%d", "x")
+}
+`
+	WithOptions(
+		Settings{"analysisPlugins": []string{os.Args[0] + " " + pluginFlag}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("b/b.go")
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpFromSource("b/b.go", `a.Wrapf`, "pluginprintf"),
+			),
+		)
+	})
+}
+
+// TestPluginImports checks that the plugins resolve the renamed, blank
+// and dot imports of a package.
+func TestPluginImports(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- a/a.go --
+package a
+
+import "fmt"
+
+func Wrapf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+-- b/b.go --
+package b
+
+import (
+	_ "fmt"
+	. "strings"
+
+	w "mod.com/a"
+)
+
+var _ = ToUpper
+
+func _() {
+	w.Wrapf("%d", "x")
+}
+`
+	WithOptions(
+		Settings{"analysisPlugins": []string{os.Args[0] + " " + pluginFlag}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("b/b.go")
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpFromSource("b/b.go", `w.Wrapf`, "pluginprintf"),
+			),
+		)
+	})
+}
@@ -207,6 +207,199 @@ go 1.12
 	})
 }
 
+func TestTypedTemplate(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- views/views.go --
+package views
+
+type Page struct {
+	Title string
+	Items []Item
+	User  *User
+}
+
+type Item struct {
+	Name string
+}
+
+func (i Item) Label(prefix string) string { return prefix + i.Name }
+
+type User struct {
+	Name  string
+	Email string
+}
+-- page.tmpl --
+{{/* gotype: mod.com/views.Page */}}
+<h1>{{.Titel}}</h1>
+{{range .Items}}{{.Name}} {{.Label}}{{end}}
+{{with .User}}{{.Name}}{{end}}
+`
+	WithOptions(
+		Settings{
+			"templateExtensions": []string{"tmpl"},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.Await(
+			env.DiagnosticAtRegexpWithMessage("page.tmpl", "Titel", "can't evaluate field Titel in type views.Page"),
+			env.DiagnosticAtRegexpWithMessage("page.tmpl", "Label", "wrong number of args for Label: want 1 got 0"),
+		)
+
+		env.OpenFile("page.tmpl")
+		file, pos := env.GoToDefinition("page.tmpl", env.RegexpSearch("page.tmpl", `User}}{{\.(Name)`))
+		if want := env.RegexpSearch("views/views.go", `Name  string`); file != "views/views.go" || pos != want {
+			t.Errorf("GoToDefinition: got %s:%v, want views/views.go:%v", file, pos, want)
+		}
+
+		env.RegexpReplace("page.tmpl", `{{\.Name}}{{end}}\n$`, "{{.Name}}{{end}}\n{{.User.}}")
+		pos = env.RegexpSearch("page.tmpl", `{{\.User\.()}}`)
+		var got []string
+		for _, item := range env.Completion("page.tmpl", pos).Items {
+			got = append(got, item.Label)
+		}
+		if want := []string{"Email", "Name"}; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Completion: got %v, want %v", got, want)
+		}
+
+		env.RegexpReplace("page.tmpl", `\.Titel`, ".Title")
+		env.RegexpReplace("page.tmpl", `\.Label`, `.Label "- "`)
+		env.RegexpReplace("page.tmpl", `{{\.User\.}}`, "")
+		env.Await(EmptyDiagnostics("page.tmpl"))
+	})
+}
+
+func TestTemplateFuncMap(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- main.go --
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+}
+
+func main() {
+	funcs["join"] = func(sep string, elems ...string) string { return strings.Join(elems, sep) }
+	template.Must(template.New("").Funcs(funcs).ParseGlob("*.tmpl"))
+}
+-- page.tmpl --
+{{upper "a"}} {{join ", " "b" "c"}}
+`
+	WithOptions(
+		Settings{
+			"templateExtensions": []string{"tmpl"},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("page.tmpl")
+
+		content, _ := env.Hover("page.tmpl", env.RegexpSearch("page.tmpl", "upper"))
+		if want := "func upper(s string) string"; content == nil || !strings.Contains(content.Value, want) {
+			t.Errorf("Hover: got %v, want %q", content, want)
+		}
+
+		file, _ := env.GoToDefinition("page.tmpl", env.RegexpSearch("page.tmpl", "join"))
+		if file != "main.go" {
+			t.Errorf("GoToDefinition: got %s, want main.go", file)
+		}
+
+		help := env.SignatureHelp("page.tmpl", env.RegexpSearch("page.tmpl", `join ", " ()"b"`))
+		if help == nil || len(help.Signatures) != 1 {
+			t.Fatalf("SignatureHelp: got %v, want one signature", help)
+		}
+		if got, want := help.Signatures[0].Label, "join(sep string, elems ...string) string"; got != want {
+			t.Errorf("SignatureHelp: got label %q, want %q", got, want)
+		}
+		if help.ActiveParameter != 1 {
+			t.Errorf("SignatureHelp: got active parameter %d, want 1", help.ActiveParameter)
+		}
+
+		env.RegexpReplace("page.tmpl", "\n$", "\n{{up}}")
+		var got []string
+		for _, item := range env.Completion("page.tmpl", env.RegexpSearch("page.tmpl", `{{up()}}`)).Items {
+			got = append(got, item.Label+": "+item.Detail)
+		}
+		if want := "upper: upper(s string) string"; !strings.Contains(strings.Join(got, "\n"), want) {
+			t.Errorf("Completion: got %v, want %q", got, want)
+		}
+	})
+}
+
+func TestCrossFileTemplates(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- base.tmpl --
+{{define "header"}}<h1>{{.}}</h1>{{end}}
+{{block "footer" .}}<hr>{{end}}
+-- page.tmpl --
+{{template "header" .Title}}
+{{template "footer" .}}
+{{template "base.tmpl" .}}
+{{template "sidebar" .}}
+-- main.go --
+package main
+
+import (
+	"html/template"
+	"os"
+)
+
+func main() {
+	t := template.Must(template.ParseGlob("*.tmpl"))
+	t.ExecuteTemplate(os.Stdout, "header", "Title")
+}
+`
+	WithOptions(
+		Settings{
+			"templateExtensions": []string{"tmpl"},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.Await(
+			env.DiagnosticAtRegexpWithMessage("page.tmpl", "sidebar", `no template named "sidebar" is defined`),
+		)
+		env.OpenFile("page.tmpl")
+		env.OpenFile("base.tmpl")
+		env.OpenFile("main.go")
+
+		file, pos := env.GoToDefinition("page.tmpl", env.RegexpSearch("page.tmpl", `"(header)"`))
+		if want := env.RegexpSearch("base.tmpl", `"(header)"`); file != "base.tmpl" || pos != want {
+			t.Errorf("GoToDefinition: got %s:%v, want base.tmpl:%v", file, pos, want)
+		}
+		file, pos = env.GoToDefinition("page.tmpl", env.RegexpSearch("page.tmpl", `"(footer)"`))
+		if want := env.RegexpSearch("base.tmpl", `"(footer)"`); file != "base.tmpl" || pos != want {
+			t.Errorf("GoToDefinition: got %s:%v, want base.tmpl:%v", file, pos, want)
+		}
+
+		env.Rename("page.tmpl", env.RegexpSearch("page.tmpl", `"(header)"`), "title")
+		if got, want := env.Editor.BufferText("base.tmpl"), `{{define "title"}}`; !strings.Contains(got, want) {
+			t.Errorf("after rename, base.tmpl is %q, want it to contain %q", got, want)
+		}
+		if got, want := env.Editor.BufferText("page.tmpl"), `{{template "title" .Title}}`; !strings.Contains(got, want) {
+			t.Errorf("after rename, page.tmpl is %q, want it to contain %q", got, want)
+		}
+		if got, want := env.Editor.BufferText("main.go"), `t.ExecuteTemplate(os.Stdout, "title", "Title")`; !strings.Contains(got, want) {
+			t.Errorf("after rename, main.go is %q, want it to contain %q", got, want)
+		}
+
+		// Defining the template in another file resolves the invocation.
+		env.RegexpReplace("base.tmpl", `{{block`, `{{define "sidebar"}}{{end}}\n{{block`)
+		env.Await(EmptyDiagnostics("page.tmpl"))
+	})
+}
+
 // shorten long URIs
 func shorten(fn protocol.DocumentURI) string {
 	if len(fn) <= 20 {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/cowpaths/golang-x-tools/gopls/internal/hooks"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/bug"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/fake"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
@@ -893,6 +894,219 @@ module example.com/bar/baz
 	})
 }
 
+func TestGoWorkUnusedModule(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module example.com/a
+
+go 1.18
+-- a/a.go --
+package a
+-- b/go.mod --
+module example.com/b
+
+go 1.18
+-- b/b.go --
+package b
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/a.go")
+		env.OpenFile("b/b.go")
+		var d protocol.PublishDiagnosticsParams
+		env.Await(
+			OnceMet(
+				env.DiagnosticAtRegexpWithMessage("b/b.go", "package (b)", "module example.com/b containing this file is not used by go.work"),
+				ReadDiagnostics("b/b.go", &d),
+			),
+			EmptyOrNoDiagnostics("a/a.go"),
+		)
+		env.OpenFile("go.work")
+		env.ApplyQuickFixes("b/b.go", d.Diagnostics)
+		env.SaveBuffer("go.work")
+		env.Await(EmptyOrNoDiagnostics("b/b.go"))
+		got := env.ReadWorkspaceFile("go.work")
+		want := `go 1.18
+
+use (
+	./a
+	./b
+)
+`
+		if got != want {
+			t.Errorf("go.work after quick fix: got %q, want %q", got, want)
+		}
+	})
+}
+
+// Dependency files in the module cache are not in the use list of go.work,
+// but are not reported as unused modules.
+func TestGoWorkUnusedModuleCache(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module example.com/a
+
+go 1.18
+-- a/a.go --
+package a
+-- b/go.mod --
+module example.com/b
+
+go 1.18
+-- b/b.go --
+package b
+-- gopath/pkg/mod/example.com/dep@v1.0.0/go.mod --
+module example.com/dep
+
+go 1.18
+-- gopath/pkg/mod/example.com/dep@v1.0.0/dep.go --
+package dep
+`
+	WithOptions(
+		EnvVars{"GOMODCACHE": filepath.FromSlash("$SANDBOX_WORKDIR/gopath/pkg/mod")},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("gopath/pkg/mod/example.com/dep@v1.0.0/dep.go")
+		env.OpenFile("b/b.go")
+		env.Await(
+			OnceMet(
+				env.DoneWithOpen(),
+				env.DiagnosticAtRegexpWithMessage("b/b.go", "package (b)", "module example.com/b containing this file is not used by go.work"),
+				NoDiagnostics("gopath/pkg/mod/example.com/dep@v1.0.0/dep.go"),
+			),
+		)
+	})
+}
+
+func TestGoWorkGoVersion(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.18
+
+use ./a
+-- a/go.mod --
+module example.com/a
+
+go 1.19
+-- a/a.go --
+package a
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("go.work")
+		var d protocol.PublishDiagnosticsParams
+		env.Await(
+			OnceMet(
+				env.DiagnosticAtRegexpWithMessage("go.work", "go 1.18", "go.work requires go 1.18, lower than go 1.19 required by module ./a"),
+				ReadDiagnostics("go.work", &d),
+			),
+		)
+		env.ApplyQuickFixes("go.work", d.Diagnostics)
+		env.Await(env.NoDiagnosticAtRegexp("go.work", "go 1.19"))
+		if got := env.Editor.BufferText("go.work"); !strings.Contains(got, "go 1.19") {
+			t.Errorf("go.work after quick fix: got %q, want go 1.19", got)
+		}
+	})
+}
+
+func TestGoWorkReplaceConflict(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.18
+
+use (
+	./a
+	./b
+)
+-- a/go.mod --
+module example.com/a
+
+go 1.18
+
+replace example.com/x => ../x1
+-- a/a.go --
+package a
+-- b/go.mod --
+module example.com/b
+
+go 1.18
+
+replace example.com/x => ../x2
+-- b/b.go --
+package b
+-- x1/go.mod --
+module example.com/x
+-- x2/go.mod --
+module example.com/x
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("go.work")
+		const msg = "conflicting replacements for example.com/x in the used modules"
+		var d protocol.PublishDiagnosticsParams
+		env.Await(
+			OnceMet(
+				env.DiagnosticAtRegexpWithMessage("go.work", `\./a`, msg),
+				env.DiagnosticAtRegexpWithMessage("go.work", `\./b`, msg),
+				ReadDiagnostics("go.work", &d),
+			),
+		)
+		// Both diagnostics offer the same fixes; apply the first, which
+		// replaces the module as ./a does.
+		env.ApplyQuickFixes("go.work", d.Diagnostics[:1])
+		env.Await(env.NoDiagnosticAtRegexp("go.work", `\./a`))
+		if got := env.Editor.BufferText("go.work"); !strings.Contains(got, "replace example.com/x => ./x1") {
+			t.Errorf("go.work after quick fix: got %q, want it to replace example.com/x with ./x1", got)
+		}
+	})
+}
+
+func TestGenerateWorkFile(t *testing.T) {
+	const files = `
+-- a/go.mod --
+module example.com/a
+
+go 1.18
+-- a/a.go --
+package a
+-- b/c/go.mod --
+module example.com/c
+
+go 1.19
+-- b/c/c.go --
+package c
+-- a/testdata/go.mod --
+module example.com/ignored
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		cmd, err := command.NewGenerateWorkFileCommand("", command.URIArg{
+			URI: env.Sandbox.Workdir.RootURI(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   command.GenerateWorkFile.ID(),
+			Arguments: cmd.Arguments,
+		}, nil)
+		got := env.ReadWorkspaceFile("go.work")
+		want := `go 1.19
+
+use (
+	./a
+	./b/c
+)
+`
+		if got != want {
+			t.Errorf("generated go.work: got %q, want %q", got, want)
+		}
+	})
+}
+
 func TestExpandToGoWork(t *testing.T) {
 	testenv.NeedsGo1Point(t, 18)
 	const workspace = `
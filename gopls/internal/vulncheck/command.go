@@ -11,6 +11,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -46,18 +47,36 @@ func govulncheck(ctx context.Context, cfg *packages.Config, args command.Vulnche
 	return res, err
 }
 
+// findGOVULNDB returns the vulnerability databases named by the GOVULNDB
+// variable of the configuration's environment, or else of the process. A
+// database may be a local directory, named by a file:// URL or an absolute
+// path, so that no network access is needed.
 func findGOVULNDB(cfg *packages.Config) []string {
-	for _, kv := range cfg.Env {
-		if strings.HasPrefix(kv, "GOVULNDB=") {
-			return strings.Split(kv[len("GOVULNDB="):], ",")
+	// Later settings in the environment override earlier ones.
+	for i := len(cfg.Env) - 1; i >= 0; i-- {
+		if kv := cfg.Env[i]; strings.HasPrefix(kv, "GOVULNDB=") {
+			return dbURLs(kv[len("GOVULNDB="):])
 		}
 	}
 	if GOVULNDB := os.Getenv("GOVULNDB"); GOVULNDB != "" {
-		return strings.Split(GOVULNDB, ",")
+		return dbURLs(GOVULNDB)
 	}
 	return []string{"https://vuln.go.dev"}
 }
 
+// dbURLs splits a comma-separated list of databases into URLs, converting
+// absolute paths into file:// URLs.
+func dbURLs(list string) []string {
+	var urls []string
+	for _, db := range strings.Split(list, ",") {
+		if filepath.IsAbs(db) {
+			db = "file://" + filepath.ToSlash(db)
+		}
+		urls = append(urls, db)
+	}
+	return urls
+}
+
 type Vuln = command.Vuln
 type CallStack = command.CallStack
 type StackEntry = command.StackEntry
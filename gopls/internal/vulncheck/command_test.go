@@ -134,6 +134,26 @@ func CallStackString(callstack CallStack) string {
 	return b.String()
 }
 
+func TestFindGOVULNDB(t *testing.T) {
+	abs, err := filepath.Abs("vulndb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		env  []string
+		want []string
+	}{
+		{[]string{"GOVULNDB=https://example.com"}, []string{"https://example.com"}},
+		{[]string{"GOVULNDB=https://example.com", "GOVULNDB=file:///vulndb"}, []string{"file:///vulndb"}},
+		{[]string{"GOVULNDB=" + abs + ",https://example.com"}, []string{"file://" + filepath.ToSlash(abs), "https://example.com"}},
+	} {
+		got := findGOVULNDB(&packages.Config{Env: test.env})
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("findGOVULNDB(%q): unexpected databases (-want +got):\n%s", test.env, diff)
+		}
+	}
+}
+
 const workspace1 = `
 -- go.mod --
 module golang.org/entry
@@ -0,0 +1,190 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisinternal
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Ignores holds the suppression directives of the files of a package:
+//
+//	//lint:ignore analyzer[,analyzer...] reason
+//	//lint:file-ignore analyzer[,analyzer...] reason
+//
+// A //lint:ignore directive that follows code on its line suppresses the
+// diagnostics of the named analyzers on that line. Otherwise it applies
+// to the line that follows its comment group and to the whole of the
+// outermost syntax node, such as a declaration or statement, that starts
+// on that line. A //lint:file-ignore directive applies to the whole
+// file.
+//
+// Lines are those of the files, not those set by //line directives.
+type Ignores struct {
+	fset       *token.FileSet
+	directives []*ignoreDirective
+	malformed  []IgnoreProblem
+}
+
+type ignoreDirective struct {
+	pos, end  token.Pos
+	filename  string
+	start     int // first line covered, or 0 for the whole file
+	last      int // last line covered
+	analyzers []string
+	used      map[string]bool // analyzers whose diagnostics were ignored
+}
+
+// An IgnoreProblem is a malformed or unused suppression directive.
+type IgnoreProblem struct {
+	Pos, End token.Pos
+	Message  string
+}
+
+// ParseIgnores returns the suppression directives of files.
+func ParseIgnores(fset *token.FileSet, files []*ast.File) *Ignores {
+	ig := &Ignores{fset: fset}
+	for _, f := range files {
+		ig.parseFile(f)
+	}
+	return ig
+}
+
+// HasIgnores reports whether the file has suppression directives.
+func HasIgnores(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//lint:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (ig *Ignores) parseFile(f *ast.File) {
+	var lines map[int]nodeLines // computed lazily
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//lint:") {
+				continue
+			}
+			fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+			kind := fields[0]
+			if kind != "lint:ignore" && kind != "lint:file-ignore" {
+				continue
+			}
+			if len(fields) < 3 {
+				ig.malformed = append(ig.malformed, IgnoreProblem{
+					Pos:     c.Pos(),
+					End:     c.End(),
+					Message: fmt.Sprintf("malformed //%s directive: want //%s analyzer[,analyzer...] reason", kind, kind),
+				})
+				continue
+			}
+			posn := ig.fset.PositionFor(c.Pos(), false)
+			d := &ignoreDirective{
+				pos:       c.Pos(),
+				end:       c.End(),
+				filename:  posn.Filename,
+				analyzers: strings.Split(fields[1], ","),
+				used:      make(map[string]bool),
+			}
+			if kind == "lint:ignore" {
+				if lines == nil {
+					lines = ig.nodeLines(f)
+				}
+				if l, ok := lines[posn.Line]; ok && l.firstEnd <= c.Pos() {
+					// The directive follows code on its line.
+					d.start, d.last = posn.Line, posn.Line
+				} else {
+					d.start = ig.fset.PositionFor(cg.End(), false).Line + 1
+					d.last = d.start
+					if l, ok := lines[d.start]; ok && l.lastLine > d.last {
+						d.last = l.lastLine
+					}
+				}
+			}
+			ig.directives = append(ig.directives, d)
+		}
+	}
+}
+
+// nodeLines describes the syntax nodes of a line.
+type nodeLines struct {
+	firstEnd token.Pos // least end of the nodes that end on the line
+	lastLine int       // last line of the nodes that start on the line
+}
+
+func (ig *Ignores) nodeLines(f *ast.File) map[int]nodeLines {
+	lines := make(map[int]nodeLines)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.File, *ast.CommentGroup, *ast.Comment:
+			return true
+		}
+		start := ig.fset.PositionFor(n.Pos(), false).Line
+		end := ig.fset.PositionFor(n.End(), false).Line
+		l := lines[start]
+		if end > l.lastLine {
+			l.lastLine = end
+		}
+		lines[start] = l
+		l = lines[end]
+		if l.firstEnd == token.NoPos || n.End() < l.firstEnd {
+			l.firstEnd = n.End()
+		}
+		lines[end] = l
+		return true
+	})
+	return lines
+}
+
+// Ignored reports whether a directive suppresses the diagnostics of the
+// named analyzer at the given line of a file, and if so records the use
+// of the directive.
+func (ig *Ignores) Ignored(analyzer, filename string, line int) bool {
+	ignored := false
+	for _, d := range ig.directives {
+		if d.filename != filename || (d.start > 0 && (line < d.start || line > d.last)) {
+			continue
+		}
+		for _, name := range d.analyzers {
+			if name == analyzer {
+				d.used[name] = true
+				ignored = true
+			}
+		}
+	}
+	return ignored
+}
+
+// Problems returns the malformed directives and the directives that
+// suppressed no diagnostic of an analyzer they name, among the analyzers
+// for which ran reports true. It is meaningful only once Ignored has been
+// called for all the diagnostics of those analyzers.
+func (ig *Ignores) Problems(ran func(analyzer string) bool) []IgnoreProblem {
+	problems := append([]IgnoreProblem(nil), ig.malformed...)
+	for _, d := range ig.directives {
+		var unused []string
+		for _, name := range d.analyzers {
+			if ran(name) && !d.used[name] {
+				unused = append(unused, name)
+			}
+		}
+		if len(unused) > 0 {
+			problems = append(problems, IgnoreProblem{
+				Pos:     d.pos,
+				End:     d.end,
+				Message: fmt.Sprintf("this directive suppresses no diagnostic of %s", strings.Join(unused, ", ")),
+			})
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Pos < problems[j].Pos })
+	return problems
+}
@@ -0,0 +1,22 @@
+package a
+
+import _ "unsafe"
+
+func Used() { helper(); T{}.Shadowed() }
+
+func Unused() {} // want "exported function Unused is unused"
+
+func UsedInTest() {}
+
+func helper() {}
+
+type T struct{}
+
+func (T) Method() {}
+
+func (T) Shadowed() {}
+
+func Shadowed() {} // want "exported function Shadowed is unused"
+
+//go:linkname Linked runtime.nanotime
+func Linked() int64
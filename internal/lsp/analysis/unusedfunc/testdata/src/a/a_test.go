@@ -0,0 +1,5 @@
+package a
+
+import "testing"
+
+func TestUsedInTest(t *testing.T) { UsedInTest() }
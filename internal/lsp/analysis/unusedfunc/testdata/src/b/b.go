@@ -0,0 +1,5 @@
+package b
+
+import "a"
+
+func F() { a.Used() } // want "exported function F is unused"
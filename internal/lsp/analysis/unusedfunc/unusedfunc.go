@@ -0,0 +1,105 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unusedfunc defines a whole-program analyzer that checks for
+// exported functions that no package uses.
+package unusedfunc
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+)
+
+const Doc = `check for exported functions that no package uses
+
+The unusedfunc analyzer reports the exported functions that none of
+the analyzed packages uses, such as the packages of the workspace and
+their tests. Unlike most analyzers, it looks at all the packages at once.
+
+To reduce false positives it ignores:
+- methods, which may implement interfaces
+- functions in test files
+- functions with a directive comment, such as //export or //go:linkname`
+
+var Analyzer = &analysis.Analyzer{
+	Name:       "unusedfunc",
+	Doc:        Doc,
+	RunProgram: run,
+}
+
+// A funcKey identifies a function or method across packages, which may
+// not share the objects of their common dependencies. The recv field
+// holds the name of the receiver type of a method, so that a method
+// does not mark a function of the same name as used.
+type funcKey struct {
+	pkgPath, recv, name string
+}
+
+// keyOf returns the funcKey of fn.
+func keyOf(fn *types.Func) funcKey {
+	key := funcKey{pkgPath: fn.Pkg().Path(), name: fn.Name()}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		key.recv = "?" // e.g. a method of an unnamed interface
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			key.recv = named.Obj().Name()
+		}
+	}
+	return key
+}
+
+func run(pass *analysis.ProgramPass) error {
+	used := make(map[funcKey]bool)
+	for _, pkg := range pass.Packages {
+		for _, obj := range pkg.TypesInfo.Uses {
+			if fn, ok := obj.(*types.Func); ok && fn.Pkg() != nil {
+				used[keyOf(fn)] = true
+			}
+		}
+	}
+
+	// The variants of a package, such as its test variant, may share
+	// its files.
+	reported := make(map[token.Pos]bool)
+	for _, pkg := range pass.Packages {
+		for _, file := range pkg.Files {
+			if strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || !fn.Name.IsExported() || hasDirective(fn.Doc) {
+					continue
+				}
+				if !used[funcKey{pkgPath: pkg.Pkg.Path(), name: fn.Name.Name}] && !reported[fn.Pos()] {
+					reported[fn.Pos()] = true
+					pass.ReportRangef(fn.Name, "exported function %s is unused", fn.Name.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasDirective reports whether the doc comment contains a directive,
+// such as //export or //go:linkname, after which the function may be
+// used outside of Go code.
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.HasPrefix(c.Text, "//export ") || strings.HasPrefix(c.Text, "//go:") {
+			return true
+		}
+	}
+	return false
+}
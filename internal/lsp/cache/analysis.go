@@ -6,9 +6,12 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/types"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"sync"
 
@@ -16,46 +19,65 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/analysisinternal"
 	"github.com/cowpaths/golang-x-tools/internal/event"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/filecache"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
 	"github.com/cowpaths/golang-x-tools/internal/memoize"
 	"github.com/cowpaths/golang-x-tools/internal/span"
 	"golang.org/x/sync/errgroup"
 )
 
-func (s *snapshot) Analyze(ctx context.Context, id string, analyzers []*source.Analyzer) ([]*source.Diagnostic, error) {
+func (s *snapshot) Analyze(ctx context.Context, id string, analyzers []*source.Analyzer) ([]*source.Diagnostic, []*source.Analyzer, error) {
 	// TODO(adonovan): merge these two loops. There's no need to
 	// construct all the root action handles before beginning
 	// analysis. Operations should be concurrent (though that first
 	// requires buildPackageHandle not to be inefficient when
 	// called in parallel.)
-	var roots []*actionHandle
+	var (
+		roots []*actionHandle
+		sas   []*source.Analyzer // the analyzer of each root
+	)
 	for _, a := range analyzers {
-		if !a.IsEnabled(s.view) {
+		// Whole-program analyzers are run by AnalyzeProgram.
+		if !a.IsEnabled(s.view) || a.Analyzer.RunProgram != nil {
 			continue
 		}
-		ah, err := s.actionHandle(ctx, PackageID(id), a.Analyzer)
+		ah, err := s.actionHandle(ctx, PackageID(id), a)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		roots = append(roots, ah)
+		sas = append(sas, a)
 	}
 
 	// Check if the context has been canceled before running the analyses.
 	if ctx.Err() != nil {
-		return nil, ctx.Err()
+		return nil, nil, ctx.Err()
 	}
 
-	var results []*source.Diagnostic
-	for _, ah := range roots {
-		diagnostics, _, err := ah.analyze(ctx, s)
+	var (
+		results []*source.Diagnostic
+		failed  []*source.Analyzer
+	)
+	for i, ah := range roots {
+		diagnostics, err := ah.diagnose(ctx, s)
 		if err != nil {
 			// Keep going if a single analyzer failed.
 			event.Error(ctx, fmt.Sprintf("analyzer %q failed", ah.analyzer.Name), err)
+			failed = append(failed, sas[i])
 			continue
 		}
 		results = append(results, diagnostics...)
 	}
-	return results, nil
+	return results, failed, nil
+}
+
+func (s *snapshot) AnalysisResult(ctx context.Context, id string, analyzer *source.Analyzer) (interface{}, error) {
+	ah, err := s.actionHandle(ctx, PackageID(id), analyzer)
+	if err != nil {
+		return nil, err
+	}
+	_, result, err := ah.analyze(ctx, s)
+	return result, err
 }
 
 type actionKey struct {
@@ -70,10 +92,16 @@ type actionHandleKey source.Hash
 // package (as different analyzers are applied, either in sequence or
 // parallel), and across packages (as dependencies are analyzed).
 type actionHandle struct {
-	promise *memoize.Promise
+	promise *memoize.Promise // [*actionData]
+
+	// cached is the future result of the diagnostics of the action,
+	// which are read from the file cache if possible, so that the
+	// package need not be type-checked at all. It is nil for the
+	// analyzers that are only required by others.
+	cached *memoize.Promise // [*actionData]
 
 	analyzer *analysis.Analyzer
-	pkg      *pkg
+	ph       *packageHandle
 }
 
 type actionData struct {
@@ -94,7 +122,14 @@ type packageFactKey struct {
 	typ reflect.Type
 }
 
-func (s *snapshot) actionHandle(ctx context.Context, id PackageID, a *analysis.Analyzer) (*actionHandle, error) {
+// actionHandle returns the handle for the analysis of the package by the
+// analyzer, described by sa if the analyzer is one of the options, or nil
+// if it is only required by another.
+func (s *snapshot) actionHandle(ctx context.Context, id PackageID, sa *source.Analyzer) (*actionHandle, error) {
+	return s.analyzerActionHandle(ctx, id, sa.Analyzer, sa)
+}
+
+func (s *snapshot) analyzerActionHandle(ctx context.Context, id PackageID, a *analysis.Analyzer, sa *source.Analyzer) (*actionHandle, error) {
 	const mode = source.ParseFull
 	key := actionKey{
 		pkg:      packageKey{id: id, mode: mode},
@@ -109,29 +144,28 @@ func (s *snapshot) actionHandle(ctx context.Context, id PackageID, a *analysis.A
 		return entry.(*actionHandle), nil
 	}
 
-	// TODO(adonovan): opt: this block of code sequentially loads a package
-	// (and all its dependencies), then sequentially creates action handles
-	// for the direct dependencies (whose packages have by then been loaded
-	// as a consequence of ph.check) which does a sequential recursion
-	// down the action graph. Only once all that work is complete do we
-	// put a handle in the cache. As with buildPackageHandle, this does
-	// not exploit the natural parallelism in the problem, and the naive
-	// use of concurrency would lead to an exponential amount of duplicated
-	// work. We should instead use an atomically updated future cache
-	// and a parallel graph traversal.
+	// TODO(adonovan): opt: this block of code sequentially builds the
+	// handles of a package and all its dependencies, then sequentially
+	// creates action handles for the direct dependencies, which does a
+	// sequential recursion down the action graph. Only once all that
+	// work is complete do we put a handle in the cache. As with
+	// buildPackageHandle, this does not exploit the natural parallelism
+	// in the problem, and the naive use of concurrency would lead to an
+	// exponential amount of duplicated work. We should instead use an
+	// atomically updated future cache and a parallel graph traversal.
+	//
+	// The package is not type-checked until the analysis is run, so
+	// that the diagnostics of the action may be read from the file
+	// cache without type-checking it.
 	ph, err := s.buildPackageHandle(ctx, id, mode)
 	if err != nil {
 		return nil, err
 	}
-	pkg, err := ph.await(ctx, s)
-	if err != nil {
-		return nil, err
-	}
 
 	// Add a dependency on each required analyzer.
 	var deps []*actionHandle
 	for _, req := range a.Requires {
-		reqActionHandle, err := s.actionHandle(ctx, id, req)
+		reqActionHandle, err := s.analyzerActionHandle(ctx, id, req, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +180,7 @@ func (s *snapshot) actionHandle(ctx context.Context, id PackageID, a *analysis.A
 		// must run on the package's dependencies too.
 		if len(a.FactTypes) > 0 {
 			for _, importID := range ph.m.Deps {
-				depActionHandle, err := s.actionHandle(ctx, importID, a)
+				depActionHandle, err := s.analyzerActionHandle(ctx, importID, a, nil)
 				if err != nil {
 					return nil, err
 				}
@@ -157,6 +191,12 @@ func (s *snapshot) actionHandle(ctx context.Context, id PackageID, a *analysis.A
 
 	promise, release := s.store.Promise(buildActionKey(a, ph), func(ctx context.Context, arg interface{}) interface{} {
 		snapshot := arg.(*snapshot)
+		pkg, err := ph.await(ctx, snapshot)
+		if err != nil {
+			return &actionData{
+				err: err,
+			}
+		}
 		// Analyze dependencies first.
 		results, err := execAll(ctx, snapshot, deps)
 		if err != nil {
@@ -169,35 +209,65 @@ func (s *snapshot) actionHandle(ctx context.Context, id PackageID, a *analysis.A
 
 	ah := &actionHandle{
 		analyzer: a,
-		pkg:      pkg,
+		ph:       ph,
 		promise:  promise,
 	}
 
+	releaseCached := func() {}
+	if sa != nil {
+		// The diagnostics are persisted, and so is the export data of the
+		// dependencies (see typeCheckDependency), but no facts: the
+		// analyzers do not run on the dependencies (see golang/go#35089).
+		diskKey := analysisCacheKey(s, ph, sa)
+		ah.cached, releaseCached = s.store.Promise(actionHandleKey(diskKey), func(ctx context.Context, arg interface{}) interface{} {
+			return cachedAnalysis(ctx, arg.(*snapshot), ah, sa, diskKey)
+		})
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Check cache again in case another thread got there first.
 	if result, ok := s.actions.Get(key); ok {
 		release()
+		releaseCached()
 		return result.(*actionHandle), nil
 	}
 
-	s.actions.Set(key, ah, func(_, _ interface{}) { release() })
+	s.actions.Set(key, ah, func(_, _ interface{}) {
+		release()
+		releaseCached()
+	})
 
 	return ah, nil
 }
 
 func (act *actionHandle) analyze(ctx context.Context, snapshot *snapshot) ([]*source.Diagnostic, interface{}, error) {
-	d, err := snapshot.awaitPromise(ctx, act.promise)
+	return act.await(ctx, snapshot, act.promise)
+}
+
+// diagnose returns the diagnostics of the action, from the file cache if
+// possible.
+func (act *actionHandle) diagnose(ctx context.Context, snapshot *snapshot) ([]*source.Diagnostic, error) {
+	promise := act.cached
+	if promise == nil {
+		promise = act.promise
+	}
+	diagnostics, _, err := act.await(ctx, snapshot, promise)
+	return diagnostics, err
+}
+
+func (act *actionHandle) await(ctx context.Context, snapshot *snapshot, promise *memoize.Promise) ([]*source.Diagnostic, interface{}, error) {
+	d, err := snapshot.awaitPromise(ctx, promise)
 	if err != nil {
 		return nil, nil, err
 	}
 	data, ok := d.(*actionData)
 	if !ok {
-		return nil, nil, fmt.Errorf("unexpected type for %s:%s", act.pkg.ID(), act.analyzer.Name)
+		return nil, nil, fmt.Errorf("unexpected type for %s:%s", act.ph.m.ID, act.analyzer.Name)
 	}
 	if data == nil {
-		return nil, nil, fmt.Errorf("unexpected nil analysis for %s:%s", act.pkg.ID(), act.analyzer.Name)
+		return nil, nil, fmt.Errorf("unexpected nil analysis for %s:%s", act.ph.m.ID, act.analyzer.Name)
 	}
 	return data.diagnostics, data.result, data.err
 }
@@ -207,7 +277,78 @@ func buildActionKey(a *analysis.Analyzer, ph *packageHandle) actionHandleKey {
 }
 
 func (act *actionHandle) String() string {
-	return fmt.Sprintf("%s@%s", act.analyzer, act.pkg.PkgPath())
+	return fmt.Sprintf("%s@%s", act.analyzer, act.ph.m.PkgPath)
+}
+
+// analysisCacheKind is the kind of the entries of the file cache that hold
+// the diagnostics of an analyzer on a package.
+const analysisCacheKind = "analysis"
+
+// analysisCacheKey returns the key of the diagnostics of the analyzer on
+// the package in the file cache. The key of the package handle covers the
+// content of the package and its dependencies; the configuration of the
+// build and the version of Go determine their type-check, the analyzer's
+// options the form of its diagnostics, and the executable of gopls their
+// computation.
+func analysisCacheKey(s *snapshot, ph *packageHandle, sa *source.Analyzer) source.Hash {
+	return source.Hashf("%s %x %x %d %s %s %d %s %v", analysisCacheKind, ph.key[:], hashConfig(ph.m.Config), s.view.goversion, executableHash(),
+		sa.Analyzer.Name, sa.Severity, sa.Fix, sa.ActionKind)
+}
+
+var (
+	executableHashOnce  sync.Once
+	executableHashValue string
+)
+
+// executableHash returns a hash of the executable of the process, or of
+// its path if it cannot be read, so that the results cached by a version
+// of gopls are not used by another.
+func executableHash() string {
+	executableHashOnce.Do(func() {
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		data, err := ioutil.ReadFile(exe)
+		if err != nil {
+			executableHashValue = exe
+			return
+		}
+		executableHashValue = fmt.Sprintf("%x", source.HashOf(data))
+	})
+	return executableHashValue
+}
+
+// cachedAnalysis returns the diagnostics of the action from the file cache,
+// or runs the analysis and stores them there.
+func cachedAnalysis(ctx context.Context, snapshot *snapshot, ah *actionHandle, sa *source.Analyzer, key source.Hash) *actionData {
+	if data, err := filecache.Get(analysisCacheKind, key); err == nil {
+		var diagnostics []*source.Diagnostic
+		if err := json.Unmarshal(data, &diagnostics); err == nil {
+			for _, d := range diagnostics {
+				d.Analyzer = sa
+			}
+			return &actionData{diagnostics: diagnostics}
+		}
+	}
+	diagnostics, _, err := ah.analyze(ctx, snapshot)
+	if err != nil {
+		return &actionData{err: err}
+	}
+	// The analyzer is restored when reading the diagnostics.
+	encoded := make([]source.Diagnostic, len(diagnostics))
+	for i, d := range diagnostics {
+		encoded[i] = *d
+		encoded[i].Analyzer = nil
+	}
+	data, err := json.Marshal(encoded)
+	if err == nil {
+		err = filecache.Set(analysisCacheKind, key, data)
+	}
+	if err != nil {
+		event.Error(ctx, "storing analysis diagnostics", err, tag.Package.Of(string(ah.ph.m.ID)))
+	}
+	return &actionData{diagnostics: diagnostics}
 }
 
 func execAll(ctx context.Context, snapshot *snapshot, actions []*actionHandle) (map[*actionHandle]*actionData, error) {
@@ -237,23 +378,18 @@ func execAll(ctx context.Context, snapshot *snapshot, actions []*actionHandle) (
 	return results, g.Wait()
 }
 
-func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Analyzer, pkg *pkg, deps map[*actionHandle]*actionData) (data *actionData) {
-	data = &actionData{
+func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Analyzer, pkg *pkg, deps map[*actionHandle]*actionData) *actionData {
+	data := &actionData{
 		objectFacts:  make(map[objectFactKey]analysis.Fact),
 		packageFacts: make(map[packageFactKey]analysis.Fact),
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			data.err = fmt.Errorf("analysis %s for package %s panicked: %v", analyzer.Name, pkg.PkgPath(), r)
-		}
-	}()
 
 	// Plumb the output values of the dependencies
 	// into the inputs of this action.  Also facts.
 	inputs := make(map[*analysis.Analyzer]interface{})
 
 	for depHandle, depData := range deps {
-		if depHandle.pkg == pkg {
+		if string(depHandle.ph.m.ID) == pkg.ID() {
 			// Same package, different analysis (horizontal edge):
 			// in-memory outputs of prerequisite analyzers
 			// become inputs to this analysis pass.
@@ -266,7 +402,8 @@ func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Ana
 				// Filter out facts related to objects
 				// that are irrelevant downstream
 				// (equivalently: not in the compiler export data).
-				if !exportedFrom(key.obj, depHandle.pkg.types) {
+				depPkg, err := depHandle.ph.cached()
+				if err != nil || !exportedFrom(key.obj, depPkg.types) {
 					continue
 				}
 				data.objectFacts[key] = fact
@@ -281,6 +418,20 @@ func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Ana
 		}
 	}
 
+	runPass(ctx, snapshot, analyzer, pkg, inputs, data)
+	return data
+}
+
+// runPass runs the analyzer on the package, given the results of the
+// analyzers it requires and the facts in data, and records its result,
+// diagnostics, facts and error in data.
+func runPass(ctx context.Context, snapshot *snapshot, analyzer *analysis.Analyzer, pkg *pkg, inputs map[*analysis.Analyzer]interface{}, data *actionData) {
+	defer func() {
+		if r := recover(); r != nil {
+			data.err = fmt.Errorf("analysis %s for package %s panicked: %v", analyzer.Name, pkg.PkgPath(), r)
+		}
+	}()
+
 	var syntax []*ast.File
 	for _, cgf := range pkg.compiledGoFiles {
 		syntax = append(syntax, cgf.File)
@@ -360,18 +511,18 @@ func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Ana
 
 	if pkg.IsIllTyped() {
 		data.err = fmt.Errorf("analysis skipped due to errors in package")
-		return data
+		return
 	}
 	data.result, data.err = pass.Analyzer.Run(pass)
 	if data.err != nil {
-		return data
+		return
 	}
 
 	if got, want := reflect.TypeOf(data.result), pass.Analyzer.ResultType; got != want {
 		data.err = fmt.Errorf(
 			"internal error: on package %s, analyzer %s returned a result of type %v, but declared ResultType %v",
 			pass.Pkg.Path(), pass.Analyzer, got, want)
-		return data
+		return
 	}
 
 	// disallow calls after Run
@@ -390,11 +541,10 @@ func runAnalysis(ctx context.Context, snapshot *snapshot, analyzer *analysis.Ana
 		}
 		if ctx.Err() != nil {
 			data.err = ctx.Err()
-			return data
+			return
 		}
 		data.diagnostics = append(data.diagnostics, srcDiags...)
 	}
-	return data
 }
 
 // exportedFrom reports whether obj may be visible to a package that imports pkg.
@@ -435,9 +585,11 @@ func (s *snapshot) DiagnosePackage(ctx context.Context, spkg source.Package) (ma
 		analyzers = append(analyzers, a)
 	}
 	var errorAnalyzerDiag []*source.Diagnostic
-	if pkg.HasTypeErrors() {
+	// The type error analyzers need the syntax of the package, which a
+	// package held as export data lacks; its type errors keep no fixes.
+	if pkg.HasTypeErrors() && pkg.mode == source.ParseFull {
 		var err error
-		errorAnalyzerDiag, err = s.Analyze(ctx, pkg.ID(), analyzers)
+		errorAnalyzerDiag, _, err = s.Analyze(ctx, pkg.ID(), analyzers)
 		if err != nil {
 			// Keep going: analysis failures should not block diagnostics.
 			event.Error(ctx, "type error analysis failed", err, tag.Package.Of(pkg.ID()))
@@ -0,0 +1,368 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/packages"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/gocommand"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/safetoken"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/memoize"
+	"github.com/cowpaths/golang-x-tools/internal/packagesinternal"
+	"github.com/cowpaths/golang-x-tools/internal/persistent"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// BuildConfigDiagnostics returns the diagnostics of the files of the
+// workspace packages that are compiled only in the build configuration, a
+// GOOS/GOARCH pair such as "windows/amd64", and not in that of the view:
+// their type errors and the diagnostics of the enabled analyzers. Their
+// sources are qualified by the configuration. The result has an entry,
+// possibly empty, for each such file.
+func (s *snapshot) BuildConfigDiagnostics(ctx context.Context, config string) (map[span.URI][]*source.Diagnostic, error) {
+	s.mu.Lock()
+	entry, hit := s.buildConfigHandles.Get(config)
+	s.mu.Unlock()
+
+	// cache miss?
+	if !hit {
+		handle := memoize.NewPromise("buildConfig", func(ctx context.Context, arg interface{}) interface{} {
+			diagnostics, err := buildConfigImpl(ctx, arg.(*snapshot), config)
+			return buildConfigResult{diagnostics, err}
+		})
+
+		entry = handle
+		s.mu.Lock()
+		s.buildConfigHandles.Set(config, entry, nil)
+		s.mu.Unlock()
+	}
+
+	// Await result.
+	v, err := s.awaitPromise(ctx, entry.(*memoize.Promise))
+	if err != nil {
+		return nil, err
+	}
+	res := v.(buildConfigResult)
+	return res.diagnostics, res.err
+}
+
+type buildConfigResult struct {
+	diagnostics map[span.URI][]*source.Diagnostic
+	err         error
+}
+
+// invalidateBuildConfigs deletes the handles of the build configurations
+// that compile the file only in their configuration, and those not yet
+// computed, which may not know it.
+func invalidateBuildConfigs(handles *persistent.Map, uri span.URI) {
+	var stale []interface{}
+	handles.Range(func(config, entry interface{}) {
+		res, ok := entry.(*memoize.Promise).Cached().(buildConfigResult)
+		if !ok {
+			stale = append(stale, config)
+			return
+		}
+		if _, ok := res.diagnostics[uri]; ok {
+			stale = append(stale, config)
+		}
+	})
+	for _, config := range stale {
+		handles.Delete(config)
+	}
+}
+
+// buildConfigImpl lists the workspace packages in the build configuration,
+// then loads, type-checks and analyzes only those that have files not
+// compiled in the view's configuration.
+func buildConfigImpl(ctx context.Context, snapshot *snapshot, config string) (map[span.URI][]*source.Diagnostic, error) {
+	ctx, done := event.Start(ctx, "cache.BuildConfig", tag.Snapshot.Of(snapshot.ID()))
+	defer done()
+
+	goos, goarch, err := source.ParseBuildConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	query, err := snapshot.workspaceQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := loadBuildConfig(ctx, snapshot, goos, goarch, packages.NeedName|packages.NeedFiles|packages.NeedCompiledGoFiles, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files compiled only in this configuration are those that the
+	// view's metadata does not know.
+	snapshot.mu.Lock()
+	known := make(map[span.URI]bool)
+	for uri, ids := range snapshot.meta.ids {
+		known[uri] = len(ids) > 0
+	}
+	snapshot.mu.Unlock()
+	only := make(map[span.URI]bool)
+	var affected []string
+	seenPath := make(map[string]bool)
+	for _, pkg := range listed {
+		for _, filename := range pkg.CompiledGoFiles {
+			uri := span.URIFromPath(filename)
+			if known[uri] || !source.InDir(snapshot.view.folder.Filename(), filename) {
+				continue
+			}
+			only[uri] = true
+			if !seenPath[pkg.PkgPath] {
+				seenPath[pkg.PkgPath] = true
+				affected = append(affected, pkg.PkgPath)
+			}
+		}
+	}
+	diagnostics := make(map[span.URI][]*source.Diagnostic)
+	for uri := range only {
+		diagnostics[uri] = nil
+	}
+	if len(affected) == 0 {
+		return diagnostics, nil
+	}
+	// As in the rest of the cache, dependencies are type-checked from
+	// source rather than loaded from export data.
+	pkgs, err := loadBuildConfig(ctx, snapshot, goos, goarch, packages.NeedName|
+		packages.NeedFiles|
+		packages.NeedCompiledGoFiles|
+		packages.NeedImports|
+		packages.NeedDeps|
+		packages.NeedTypes|
+		packages.NeedTypesSizes|
+		packages.NeedSyntax|
+		packages.NeedTypesInfo, affected)
+	if err != nil {
+		return nil, err
+	}
+
+	// As in source.Analyze, the analyzers that only provide fixes are not
+	// run, nor are the whole-program analyzers.
+	var analyzers []*source.Analyzer
+	opts := snapshot.view.Options()
+	for _, cat := range []map[string]*source.Analyzer{opts.DefaultAnalyzers, opts.StaticcheckAnalyzers} {
+		for _, a := range cat {
+			if a.IsEnabled(snapshot.view) && a.Analyzer.RunProgram == nil {
+				analyzers = append(analyzers, a)
+			}
+		}
+	}
+	fset := snapshot.FileSet()
+	seen := make(map[string]bool) // files are compiled in test variants too
+	add := func(d *source.Diagnostic) {
+		if !only[d.URI] {
+			return
+		}
+		d.Source = source.DiagnosticSource(fmt.Sprintf("%s (%s)", d.Source, config))
+		if key := fmt.Sprintf("%s:%v:%s:%s", d.URI, d.Range, d.Source, d.Message); !seen[key] {
+			seen[key] = true
+			diagnostics[d.URI] = append(diagnostics[d.URI], d)
+		}
+	}
+	for _, lpkg := range pkgs {
+		pkg, err := buildConfigPackage(ctx, snapshot, lpkg)
+		if err != nil {
+			return nil, err
+		}
+		hasErrors := false
+		for _, e := range lpkg.Errors {
+			hasErrors = true
+			pos, ok := errorPos(fset, lpkg.Syntax, e)
+			if !ok {
+				continue
+			}
+			src := source.ListError
+			switch e.Kind {
+			case packages.ParseError:
+				src = source.ParseError
+			case packages.TypeError:
+				src = source.TypeError
+			}
+			pgf, err := pkg.File(span.URIFromPath(fset.File(pos).Name()))
+			if err != nil {
+				continue
+			}
+			rng, err := source.NewMappedRange(pgf.Tok, pgf.Mapper, pos, pos).Range()
+			if err != nil {
+				event.Error(ctx, "computing build configuration diagnostic", err, tag.Package.Of(lpkg.ID))
+				continue
+			}
+			add(&source.Diagnostic{URI: pgf.URI, Range: rng, Severity: protocol.SeverityError, Source: src, Message: e.Msg})
+		}
+		if hasErrors {
+			continue
+		}
+		for _, d := range analyzeBuildConfigPackage(ctx, snapshot, pkg, analyzers) {
+			add(d)
+		}
+	}
+	return diagnostics, nil
+}
+
+// loadBuildConfig loads the packages matching the patterns in the build
+// configuration of goos and goarch, with their tests, parsing the files
+// into the snapshot's file set.
+func loadBuildConfig(ctx context.Context, snapshot *snapshot, goos, goarch string, mode packages.LoadMode, patterns []string) ([]*packages.Package, error) {
+	_, inv, cleanup, err := snapshot.goCommandInvocation(ctx, source.Normal, &gocommand.Invocation{
+		WorkingDir: snapshot.view.rootURI.Filename(),
+		Env:        []string{"GOOS=" + goos, "GOARCH=" + goarch},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cfg := &packages.Config{
+		Context:    ctx,
+		Dir:        inv.WorkingDir,
+		Env:        inv.Env,
+		BuildFlags: inv.BuildFlags,
+		Mode:       mode,
+		Fset:       snapshot.FileSet(),
+		Overlay:    snapshot.buildOverlay(),
+		Tests:      true,
+	}
+	packagesinternal.SetModFile(cfg, inv.ModFile)
+	packagesinternal.SetModFlag(cfg, inv.ModFlag)
+	return packages.Load(cfg, patterns...)
+}
+
+// buildConfigPackage returns the package, loaded in another build
+// configuration, as a pkg whose files have the content of the snapshot,
+// so that the snapshot's analyses can run on it.
+func buildConfigPackage(ctx context.Context, snapshot *snapshot, lpkg *packages.Package) (*pkg, error) {
+	m := &Metadata{
+		ID:         PackageID(lpkg.ID),
+		PkgPath:    PackagePath(lpkg.PkgPath),
+		Name:       PackageName(lpkg.Name),
+		TypesSizes: lpkg.TypesSizes,
+		Errors:     lpkg.Errors,
+	}
+	pkg := &pkg{
+		m:          m,
+		mode:       source.ParseFull,
+		types:      lpkg.Types,
+		typesInfo:  lpkg.TypesInfo,
+		typesSizes: lpkg.TypesSizes,
+	}
+	for _, f := range lpkg.Syntax {
+		tok := snapshot.FileSet().File(f.Pos())
+		uri := span.URIFromPath(tok.Name())
+		fh, err := snapshot.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		src, err := fh.Read()
+		if err != nil {
+			return nil, err
+		}
+		m.CompiledGoFiles = append(m.CompiledGoFiles, uri)
+		pkg.compiledGoFiles = append(pkg.compiledGoFiles, &source.ParsedGoFile{
+			URI:    uri,
+			Mode:   source.ParseFull,
+			File:   f,
+			Tok:    tok,
+			Src:    src,
+			Mapper: protocol.NewColumnMapper(uri, src),
+		})
+	}
+	return pkg, nil
+}
+
+// analyzeBuildConfigPackage runs the analyzers on the package after
+// those they require, as the snapshot's actions do, and returns their
+// diagnostics.
+func analyzeBuildConfigPackage(ctx context.Context, snapshot *snapshot, pkg *pkg, analyzers []*source.Analyzer) []*source.Diagnostic {
+	results := make(map[*analysis.Analyzer]*actionData)
+	var run func(a *analysis.Analyzer) *actionData
+	run = func(a *analysis.Analyzer) *actionData {
+		if data, ok := results[a]; ok {
+			return data
+		}
+		data := &actionData{
+			objectFacts:  make(map[objectFactKey]analysis.Fact),
+			packageFacts: make(map[packageFactKey]analysis.Fact),
+		}
+		results[a] = data
+		inputs := make(map[*analysis.Analyzer]interface{})
+		for _, req := range a.Requires {
+			reqData := run(req)
+			if reqData.err != nil {
+				data.err = reqData.err
+				return data
+			}
+			inputs[req] = reqData.result
+		}
+		runPass(ctx, snapshot, a, pkg, inputs, data)
+		return data
+	}
+	var diagnostics []*source.Diagnostic
+	for _, a := range analyzers {
+		data := run(a.Analyzer)
+		if data.err != nil {
+			event.Error(ctx, "analyzing build configuration", data.err, tag.Package.Of(pkg.ID()))
+			continue
+		}
+		diagnostics = append(diagnostics, data.diagnostics...)
+	}
+	return diagnostics
+}
+
+// workspaceQuery returns the go list patterns of the workspace packages, as
+// loaded by the initial workspace load.
+func (s *snapshot) workspaceQuery(ctx context.Context) ([]string, error) {
+	if s.workspaceMode()&moduleMode == 0 {
+		return []string{"./..."}, nil
+	}
+	var query []string
+	for modURI := range s.workspace.getActiveModFiles() {
+		fh, err := s.GetFile(ctx, modURI)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := s.ParseMod(ctx, fh)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.File == nil || parsed.File.Module == nil {
+			return nil, fmt.Errorf("no module path for %s", modURI)
+		}
+		query = append(query, parsed.File.Module.Mod.Path+"/...")
+	}
+	return query, nil
+}
+
+// errorPos returns the position of a go/packages error in one of the files.
+func errorPos(fset *token.FileSet, files []*ast.File, e packages.Error) (token.Pos, bool) {
+	spn := span.Parse(e.Pos)
+	for _, f := range files {
+		tok := fset.File(f.Pos())
+		if span.URIFromPath(tok.Name()) != spn.URI() || !spn.HasPosition() {
+			continue
+		}
+		line := spn.Start().Line()
+		if line < 1 || line > tok.LineCount() {
+			return token.NoPos, false
+		}
+		pos := tok.LineStart(line)
+		if col := spn.Start().Column(); col > 1 {
+			offset, err := safetoken.Offset(tok, pos)
+			if err == nil && offset+col-1 <= tok.Size() {
+				pos += token.Pos(col - 1)
+			}
+		}
+		return pos, true
+	}
+	return token.NoPos, false
+}
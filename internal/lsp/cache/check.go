@@ -7,9 +7,11 @@ package cache
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
 	"path"
 	"path/filepath"
@@ -18,6 +20,7 @@ import (
 	"sync"
 
 	"github.com/cowpaths/golang-x-tools/go/ast/astutil"
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
 	"github.com/cowpaths/golang-x-tools/go/packages"
 	"github.com/cowpaths/golang-x-tools/internal/event"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/bug"
@@ -77,6 +80,21 @@ func (s *snapshot) buildPackageHandle(ctx context.Context, id PackageID, mode so
 	s.mu.Lock()
 	entry, hit := s.packages.Get(packageKey)
 	m := s.meta.metadata[id]
+	prevEntry, hasPrev := s.previousPackages.Get(packageKey)
+	evicted := mode == source.ParseExported && s.evicted[id]
+	// The dependencies of the workspace are held as export data, which is
+	// persisted in the file cache (see typeCheckDependency), unless one of
+	// their files is open, as the features in it need its syntax.
+	_, workspace := s.workspacePackages[id]
+	dependency := mode == source.ParseExported && !workspace && m != nil && m.PkgPath != "unsafe"
+	if dependency {
+		for _, uri := range m.CompiledGoFiles {
+			if s.isOpenLocked(uri) {
+				dependency = false
+				break
+			}
+		}
+	}
 	s.mu.Unlock()
 
 	if m == nil {
@@ -145,9 +163,46 @@ func (s *snapshot) buildPackageHandle(ctx context.Context, id PackageID, mode so
 	// Create a handle for the result of type checking.
 	experimentalKey := s.View().Options().ExperimentalPackageCacheKey
 	phKey := computePackageKey(m.ID, compiledGoFiles, m, depKeys, mode, experimentalKey)
-	promise, release := s.store.Promise(phKey, func(ctx context.Context, arg interface{}) interface{} {
+	if evicted {
+		// An evicted package is held as the export data of its full
+		// type-check, which is kept until it or its dependencies change.
+		fullKey := computePackageKey(m.ID, compiledGoFiles, m, depKeys, source.ParseFull, experimentalKey)
+		phKey = packageHandleKey(source.Hashf("evicted %x", fullKey[:]))
+	}
+	if dependency {
+		phKey = packageHandleKey(source.Hashf("dependency %x", phKey[:]))
+	}
 
-		pkg, err := typeCheckImpl(ctx, arg.(*snapshot), goFiles, compiledGoFiles, m.Metadata, mode, deps)
+	// If the package is unchanged since its previous type-check, and its
+	// dependencies export the same APIs as it imported then, keep its type
+	// information (see ExperimentalEarlyCutoff). Whether they do is only
+	// known once they are type-checked, so it is decided by the promise.
+	var prev *previousPackage
+	if hasPrev {
+		p := prevEntry.(previousPackage)
+		prev = &p
+	}
+	reuse := prev != nil && !prev.changed && prev.ph.m.Metadata == m.Metadata
+	earlyCutoff := s.View().Options().ExperimentalEarlyCutoff
+	promise, release := s.store.Promise(phKey, func(ctx context.Context, arg interface{}) interface{} {
+		snapshot := arg.(*snapshot)
+		if reuse && importsUnchanged(ctx, snapshot, prev.ph, deps) {
+			pkg, err := prev.ph.cached()
+			return typeCheckResult{pkg, err}
+		}
+		var pkg *pkg
+		var err error
+		switch {
+		case evicted:
+			pkg, err = typeCheckEvicted(ctx, snapshot, goFiles, compiledGoFiles, m.Metadata, deps)
+		case dependency:
+			pkg, err = typeCheckDependency(ctx, snapshot, goFiles, compiledGoFiles, m.Metadata, deps, exportCacheKey(snapshot, m.Metadata, phKey))
+		default:
+			pkg, err = typeCheckImpl(ctx, snapshot, goFiles, compiledGoFiles, m.Metadata, mode, deps)
+		}
+		if err == nil && earlyCutoff {
+			setAPI(snapshot.FileSet(), pkg, prev)
+		}
 		return typeCheckResult{pkg, err}
 	})
 
@@ -187,6 +242,91 @@ func (s *snapshot) buildPackageHandle(ctx context.Context, id PackageID, mode so
 	return ph, nil
 }
 
+// A previousPackage is the handle of the last type-check of a package
+// whose type information has been invalidated since, kept with the
+// ExperimentalEarlyCutoff option so that the package and its reverse
+// dependencies need not be type-checked again if its API and those of its
+// dependencies are unchanged. It is forgotten once the package has been
+// type-checked again, or after previousPackageLifetime snapshots.
+type previousPackage struct {
+	ph      *packageHandle
+	changed bool   // whether the package itself changed, not only its dependencies
+	since   uint64 // the ID of the first snapshot in which it is previous
+}
+
+// previousPackageLifetime is the number of snapshots in which the previous
+// type-check of a package may be reused.
+const previousPackageLifetime = 10
+
+// importsUnchanged reports whether the dependencies of a package export
+// the APIs that the previous type-check of the package imported, so that
+// its type information remains valid. It is called by the promise of the
+// type-check, which awaits the dependencies in any case.
+func importsUnchanged(ctx context.Context, s *snapshot, prev *packageHandle, deps map[PackagePath]*packageHandle) bool {
+	pkg, err := prev.cached()
+	if err != nil {
+		return false
+	}
+	for path, imp := range pkg.imports {
+		dep, ok := deps[path]
+		if !ok {
+			return false
+		}
+		depPkg, err := dep.await(ctx, s)
+		if err != nil || depPkg.exported() != imp {
+			return false
+		}
+	}
+	return true
+}
+
+// setAPI computes the hash of the API of the package. If it is the hash
+// of the previous type-check of the package, if any, and the package
+// imports the same APIs, importers keep using the previous one.
+func setAPI(fset *token.FileSet, pkg *pkg, prev *previousPackage) {
+	if pkg.types == types.Unsafe {
+		return
+	}
+	hash, err := apiHash(fset, pkg.types)
+	if err != nil {
+		return // importers use this type-check
+	}
+	pkg.apiHash = hash
+
+	if prev == nil {
+		return
+	}
+	prevPkg, err := prev.ph.cached()
+	if err != nil || prevPkg.apiHash != pkg.apiHash {
+		return
+	}
+	api := prevPkg.exported()
+	if len(api.imports) != len(pkg.imports) {
+		return
+	}
+	for path, imp := range pkg.imports {
+		if api.imports[path] != imp {
+			return
+		}
+	}
+	pkg.api = api
+}
+
+// apiHash returns the hash of the export data of a package, which
+// records the declarations of its exported objects, and of those they
+// refer to, with their positions. Importers that keep the types of a
+// previous type-check with the same hash thus see the same declarations
+// at the same positions.
+func apiHash(fset *token.FileSet, tpkg *types.Package) (source.Hash, error) {
+	h := sha256.New()
+	if err := gcexportdata.Write(h, fset, tpkg); err != nil {
+		return source.Hash{}, err
+	}
+	var hash source.Hash
+	h.Sum(hash[:0])
+	return hash, nil
+}
+
 // readGoFiles reads the content of Metadata.GoFiles and
 // Metadata.CompiledGoFiles, in parallel.
 func readGoFiles(ctx context.Context, s *snapshot, m *Metadata) (goFiles, compiledGoFiles []source.FileHandle, err error) {
@@ -214,7 +354,7 @@ func (s *snapshot) workspaceParseMode(id PackageID) source.ParseMode {
 	if !ws {
 		return source.ParseExported
 	}
-	if s.view.Options().MemoryMode == source.ModeNormal {
+	if s.view.Options().MemoryMode == source.ModeNormal && !s.evicted[id] {
 		return source.ParseFull
 	}
 	if s.isActiveLocked(id) {
@@ -223,6 +363,23 @@ func (s *snapshot) workspaceParseMode(id PackageID) source.ParseMode {
 	return source.ParseExported
 }
 
+// queryParseMode returns the parse mode in which the searches of the
+// reverse dependencies of a package, such as for references, see the
+// package: that of workspaceParseMode, except for the packages held as
+// export data, that is, the packages outside of the workspace and those
+// evicted to keep within the memory budget, which are type-checked in full
+// again, as they need their syntax.
+func (s *snapshot) queryParseMode(id PackageID) source.ParseMode {
+	s.mu.Lock()
+	_, workspace := s.workspacePackages[id]
+	evicted := s.evicted[id]
+	s.mu.Unlock()
+	if !workspace || evicted {
+		return source.ParseFull
+	}
+	return s.workspaceParseMode(id)
+}
+
 // computePackageKey returns a key representing the act of type checking
 // a package named id containing the specified files, metadata, and
 // dependency hashes.
@@ -298,6 +455,23 @@ func (ph *packageHandle) cached() (*pkg, error) {
 	return data.pkg, data.err
 }
 
+// moduleVersion returns the module version of the package, if any.
+func moduleVersion(m *Metadata) *module.Version {
+	if m.Module == nil {
+		return nil
+	}
+	// If this is a replaced module in the workspace, the version is
+	// meaningless, and we don't want clients to access it.
+	version := m.Module.Version
+	if source.IsWorkspaceModuleVersion(version) {
+		version = ""
+	}
+	return &module.Version{
+		Path:    m.Module.Path,
+		Version: version,
+	}
+}
+
 // typeCheckImpl type checks the parsed source files in compiledGoFiles.
 // (The resulting pkg also holds the parsed but not type-checked goFiles.)
 // deps holds the future results of type-checking the direct dependencies.
@@ -352,24 +526,18 @@ func typeCheckImpl(ctx context.Context, snapshot *snapshot, goFiles, compiledGoF
 			}
 		}
 	}
-	// If this is a replaced module in the workspace, the version is
-	// meaningless, and we don't want clients to access it.
-	if m.Module != nil {
-		version := m.Module.Version
-		if source.IsWorkspaceModuleVersion(version) {
-			version = ""
-		}
-		pkg.version = &module.Version{
-			Path:    m.Module.Path,
-			Version: version,
-		}
-	}
+	pkg.version = moduleVersion(m)
 
 	// We don't care about a package's errors unless we have parsed it in full.
 	if mode != source.ParseFull {
 		return pkg, nil
 	}
 
+	// Record the memory used by the workspace packages checked in full.
+	if snapshot.isWorkspacePackage(m.ID) {
+		snapshot.view.residency.checked(m.ID, packageSize(pkg))
+	}
+
 	for _, e := range m.Errors {
 		diags, err := goPackagesErrorDiagnostics(snapshot, pkg, e)
 		if err != nil {
@@ -530,6 +698,7 @@ func doTypeCheck(ctx context.Context, snapshot *snapshot, goFiles, compiledGoFil
 			if err != nil {
 				return nil, err
 			}
+			depPkg = depPkg.exported()
 			pkg.imports[depPkg.m.PkgPath] = depPkg
 			return depPkg.types, nil
 		}),
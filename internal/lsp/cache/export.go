@@ -0,0 +1,172 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/filecache"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/typeparams"
+)
+
+// exportCacheKind is the kind of the entries of the file cache that hold
+// the export data of a dependency of the workspace.
+const exportCacheKind = "export"
+
+// exportCacheKey returns the key of the export data of a package in the
+// file cache. The key of the package handle covers the content of the
+// package and its dependencies; the configuration of the build and the
+// version of Go determine their type-check, and the executable of gopls
+// the encoding of the export data.
+func exportCacheKey(s *snapshot, m *Metadata, phKey packageHandleKey) source.Hash {
+	return source.Hashf("%s %x %x %d %s", exportCacheKind, phKey[:], hashConfig(m.Config), s.view.goversion, executableHash())
+}
+
+// typeCheckDependency type-checks a package outside of the workspace for
+// its importers, and returns it held as export data (see
+// newExportedPackage). The export data is persisted in the file cache, so
+// that the package is type-checked again only when it or its
+// dependencies change, even by another gopls process.
+//
+// Only the export data is persisted: no analysis facts are computed for
+// the dependencies of the workspace (see golang/go#35089).
+func typeCheckDependency(ctx context.Context, snapshot *snapshot, goFiles, compiledGoFiles []source.FileHandle, m *Metadata, deps map[PackagePath]*packageHandle, key source.Hash) (*pkg, error) {
+	imports := make(map[PackagePath]*pkg)
+	var depTypes []*types.Package
+	for _, dep := range deps {
+		depPkg, err := dep.await(ctx, snapshot)
+		if err != nil {
+			// The importer could not find the types of the dependency.
+			return typeCheckImpl(ctx, snapshot, goFiles, compiledGoFiles, m, source.ParseExported, deps)
+		}
+		depPkg = depPkg.exported()
+		imports[depPkg.m.PkgPath] = depPkg
+		depTypes = append(depTypes, depPkg.types)
+	}
+	if data, err := filecache.Get(exportCacheKind, key); err == nil {
+		if pkg, err := newExportedPackage(ctx, snapshot, goFiles, compiledGoFiles, m, data, depTypes); err == nil {
+			pkg.imports = imports
+			return pkg, nil
+		}
+	}
+
+	checked, err := typeCheckImpl(ctx, snapshot, goFiles, compiledGoFiles, m, source.ParseExported, deps)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gcexportdata.Write(&buf, snapshot.FileSet(), checked.types); err != nil {
+		return checked, nil
+	}
+	// Export data that cannot be read back, which the importer rejects for
+	// some uses of type parameters, is not persisted: the package keeps
+	// its syntax instead.
+	pkg, err := newExportedPackage(ctx, snapshot, goFiles, compiledGoFiles, m, buf.Bytes(), depTypes)
+	if err != nil {
+		event.Error(ctx, "reading export data", err, tag.Package.Of(string(m.ID)))
+		return checked, nil
+	}
+	pkg.imports = imports
+	if err := filecache.Set(exportCacheKind, key, buf.Bytes()); err != nil {
+		event.Error(ctx, "storing export data", err, tag.Package.Of(string(m.ID)))
+	}
+	return pkg, nil
+}
+
+// newExportedPackage returns a package held as export data: its types are
+// read from data, and so are only those of its declarations, and its files
+// are parsed only up to their imports. The types of deps, and of their own
+// imports, are those the export data refers to.
+func newExportedPackage(ctx context.Context, snapshot *snapshot, goFiles, compiledGoFiles []source.FileHandle, m *Metadata, data []byte, deps []*types.Package) (*pkg, error) {
+	pkg := &pkg{
+		m:          m,
+		mode:       source.ParseExported,
+		imports:    make(map[PackagePath]*pkg),
+		version:    moduleVersion(m),
+		typesSizes: m.TypesSizes,
+		typesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	typeparams.InitInstanceInfo(pkg.typesInfo)
+	for _, fh := range goFiles {
+		pgf, err := snapshot.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil {
+			return nil, err
+		}
+		pkg.goFiles = append(pkg.goFiles, pgf)
+	}
+	for _, fh := range compiledGoFiles {
+		pgf, err := snapshot.ParseGo(ctx, fh, source.ParseHeader)
+		if err != nil {
+			return nil, err
+		}
+		pkg.compiledGoFiles = append(pkg.compiledGoFiles, pgf)
+	}
+
+	// The importer must reuse the packages of the dependencies for the
+	// types to be identical.
+	imports := make(map[string]*types.Package)
+	var addImports func(*types.Package)
+	addImports = func(tpkg *types.Package) {
+		if _, ok := imports[tpkg.Path()]; ok {
+			return
+		}
+		imports[tpkg.Path()] = tpkg
+		for _, imp := range tpkg.Imports() {
+			addImports(imp)
+		}
+	}
+	for _, dep := range deps {
+		addImports(dep)
+	}
+	tpkg, err := gcexportdata.Read(bytes.NewReader(data), snapshot.FileSet(), imports, string(m.PkgPath))
+	if err != nil {
+		return nil, err
+	}
+	pkg.types = tpkg
+	pkg.exportFiles = exportFiles(snapshot.FileSet(), tpkg)
+	return pkg, nil
+}
+
+// exportFiles returns the files of the positions of the objects of a
+// package read from export data, which the importer creates with one line
+// per position.
+func exportFiles(fset *token.FileSet, tpkg *types.Package) []*token.File {
+	seen := make(map[*token.File]bool)
+	var files []*token.File
+	add := func(pos token.Pos) {
+		if tok := fset.File(pos); tok != nil && !seen[tok] {
+			seen[tok] = true
+			files = append(files, tok)
+		}
+	}
+	scope := tpkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		add(obj.Pos())
+		if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+			if named, ok := tn.Type().(*types.Named); ok {
+				for i := 0; i < named.NumMethods(); i++ {
+					add(named.Method(i).Pos())
+				}
+			}
+		}
+	}
+	return files
+}
@@ -249,6 +249,7 @@ func (s *snapshot) load(ctx context.Context, allowNetwork bool, scopes ...interf
 		for _, mode := range source.AllParseModes {
 			key := packageKey{mode, id}
 			s.packages.Delete(key)
+			s.previousPackages.Delete(key)
 		}
 	}
 
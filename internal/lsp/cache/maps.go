@@ -21,6 +21,11 @@ func uriLessInterface(a, b interface{}) bool {
 	return a.(span.URI) < b.(span.URI)
 }
 
+// stringLessInterface is the < relation for "any" values containing strings.
+func stringLessInterface(a, b interface{}) bool {
+	return a.(string) < b.(string)
+}
+
 func newFilesMap() filesMap {
 	return filesMap{
 		impl: persistent.NewMap(uriLessInterface),
@@ -214,3 +219,13 @@ func actionKeyLessInterface(a, b interface{}) bool {
 	}
 	return packageKeyLess(x.pkg, y.pkg)
 }
+
+// pluginKeyLessInterface is the less-than relation for pluginKey values
+// wrapped in an interface.
+func pluginKeyLessInterface(a, b interface{}) bool {
+	x, y := a.(pluginKey), b.(pluginKey)
+	if x.id != y.id {
+		return x.id < y.id
+	}
+	return !x.factsOnly && y.factsOnly
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/gocommand"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/memoize"
+	"golang.org/x/mod/module"
+)
+
+// ModGraph returns the requirements of the module graph of the go.mod file,
+// as printed by "go mod graph".
+func (s *snapshot) ModGraph(ctx context.Context, fh source.FileHandle) ([]source.ModuleEdge, error) {
+	uri := fh.URI()
+
+	if s.View().FileKind(fh) != source.Mod {
+		return nil, fmt.Errorf("%s is not a go.mod file", uri)
+	}
+
+	s.mu.Lock()
+	entry, hit := s.modGraphHandles.Get(uri)
+	s.mu.Unlock()
+
+	type modGraphResult struct {
+		edges []source.ModuleEdge
+		err   error
+	}
+
+	// cache miss?
+	if !hit {
+		handle := memoize.NewPromise("modGraph", func(ctx context.Context, arg interface{}) interface{} {
+			edges, err := modGraphImpl(ctx, arg.(*snapshot), fh)
+			return modGraphResult{edges, err}
+		})
+
+		entry = handle
+		s.mu.Lock()
+		s.modGraphHandles.Set(uri, entry, nil)
+		s.mu.Unlock()
+	}
+
+	// Await result.
+	v, err := s.awaitPromise(ctx, entry.(*memoize.Promise))
+	if err != nil {
+		return nil, err
+	}
+	res := v.(modGraphResult)
+	return res.edges, res.err
+}
+
+// modGraphImpl returns the result of "go mod graph" on the specified go.mod
+// file.
+func modGraphImpl(ctx context.Context, snapshot *snapshot, fh source.FileHandle) ([]source.ModuleEdge, error) {
+	ctx, done := event.Start(ctx, "cache.ModGraph", tag.URI.Of(fh.URI()))
+	defer done()
+
+	inv := &gocommand.Invocation{
+		Verb:       "mod",
+		Args:       []string{"graph"},
+		WorkingDir: filepath.Dir(fh.URI().Filename()),
+	}
+	stdout, err := snapshot.RunGoCommandDirect(ctx, source.Normal, inv)
+	if err != nil {
+		return nil, err
+	}
+	var edges []source.ModuleEdge
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		edges = append(edges, source.ModuleEdge{
+			From: parseModuleVersion(fields[0]),
+			To:   parseModuleVersion(fields[1]),
+		})
+	}
+	return edges, nil
+}
+
+// parseModuleVersion parses a module as printed by "go mod graph": a path,
+// followed by @ and a version unless it is a main module.
+func parseModuleVersion(s string) module.Version {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return module.Version{Path: s[:i], Version: s[i+1:]}
+	}
+	return module.Version{Path: s}
+}
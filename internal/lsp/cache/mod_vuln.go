@@ -0,0 +1,93 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cowpaths/golang-x-tools/go/packages"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/gocommand"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/memoize"
+	"github.com/cowpaths/golang-x-tools/internal/packagesinternal"
+)
+
+// Vulnerabilities returns the vulnerabilities that the Govulncheck hook
+// finds in the packages of the view, as saved on disk.
+func (s *snapshot) Vulnerabilities(ctx context.Context) ([]command.Vuln, error) {
+	uri := s.view.folder
+
+	s.mu.Lock()
+	entry, hit := s.modVulnHandles.Get(uri)
+	s.mu.Unlock()
+
+	type modVulnResult struct {
+		vulns []command.Vuln
+		err   error
+	}
+
+	// cache miss?
+	if !hit {
+		handle := memoize.NewPromise("modVuln", func(ctx context.Context, arg interface{}) interface{} {
+			vulns, err := modVulnImpl(ctx, arg.(*snapshot))
+			return modVulnResult{vulns, err}
+		})
+
+		entry = handle
+		s.mu.Lock()
+		s.modVulnHandles.Set(uri, entry, nil)
+		s.mu.Unlock()
+	}
+
+	// Await result.
+	v, err := s.awaitPromise(ctx, entry.(*memoize.Promise))
+	if err != nil {
+		return nil, err
+	}
+	res := v.(modVulnResult)
+	return res.vulns, res.err
+}
+
+// modVulnImpl runs the Govulncheck hook on all the packages of the view.
+// The vulnerability database is taken from the GOVULNDB variable of the
+// view's environment, which may name a local directory with a file:// URL.
+func modVulnImpl(ctx context.Context, snapshot *snapshot) ([]command.Vuln, error) {
+	ctx, done := event.Start(ctx, "cache.ModVuln", tag.Directory.Of(snapshot.view.folder.Filename()))
+	defer done()
+
+	opts := snapshot.view.Options()
+	if opts.Hooks.Govulncheck == nil {
+		return nil, errors.New("vulncheck feature is not available")
+	}
+	_, inv, cleanup, err := snapshot.goCommandInvocation(ctx, source.Normal, &gocommand.Invocation{
+		WorkingDir: snapshot.view.rootURI.Filename(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cfg := &packages.Config{
+		Context:    ctx,
+		Dir:        inv.WorkingDir,
+		Env:        inv.Env,
+		BuildFlags: inv.BuildFlags,
+		Tests:      true,
+	}
+	packagesinternal.SetModFile(cfg, inv.ModFile)
+	packagesinternal.SetModFlag(cfg, inv.ModFlag)
+	res, err := opts.Hooks.Govulncheck(ctx, cfg, command.VulncheckArgs{
+		Dir:     protocol.URIFromSpanURI(snapshot.view.rootURI),
+		Pattern: "./...",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Vuln, nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/scanner"
+	"go/token"
 	"go/types"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
@@ -30,6 +31,20 @@ type pkg struct {
 	typesInfo       *types.Info
 	typesSizes      types.Sizes
 	hasFixedFiles   bool // if true, AST was sufficiently mangled that we should hide type errors
+
+	// apiHash is the hash of the API of the package (see apiHash). It is
+	// computed only with the ExperimentalEarlyCutoff option.
+	apiHash source.Hash
+
+	// api, if non-nil, is a previous type-check of the package with the
+	// same API and imports, which importers use in place of this
+	// one so that their type information remains valid.
+	api *pkg
+
+	// exportFiles holds the files of the positions of the types of a
+	// package held as export data, which are read from it (see
+	// newExportedPackage).
+	exportFiles []*token.File
 }
 
 // Declare explicit types for files and directories to distinguish between the two.
@@ -39,6 +54,27 @@ type (
 	viewLoadScope   span.URI
 )
 
+// exported returns the type-check of the package that importers use.
+func (p *pkg) exported() *pkg {
+	if p.api != nil {
+		return p.api
+	}
+	return p
+}
+
+func (p *pkg) APIPackage() source.Package {
+	return p.exported()
+}
+
+func (p *pkg) InExportData(pos token.Pos) bool {
+	for _, tok := range p.exportFiles {
+		if int(pos) >= tok.Base() && int(pos) <= tok.Base()+tok.Size() {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *pkg) ID() string {
 	return string(p.m.ID)
 }
@@ -0,0 +1,400 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/go/analysis/pluginchecker"
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/memoize"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// PluginDiagnostics returns the diagnostics of the analyzers of the tools
+// of the analysisPlugins option on the package. A plugin that fails is
+// logged, and does not prevent the others from running.
+func (s *snapshot) PluginDiagnostics(ctx context.Context, id string) ([]*source.Diagnostic, error) {
+	if len(s.view.Options().AnalysisPlugins) == 0 {
+		return nil, nil
+	}
+	res, err := s.runPlugins(ctx, PackageID(id), false)
+	if err != nil {
+		return nil, err
+	}
+	return res.diagnostics, res.err
+}
+
+// A pluginKey identifies the analysis of a package by the plugins, or
+// only the computation of the facts about it that their analyzers need
+// to analyze its importers.
+type pluginKey struct {
+	id        PackageID
+	factsOnly bool
+}
+
+// pluginHandleKey is the key of the result of the plugins in the store.
+type pluginHandleKey source.Hash
+
+type pluginResult struct {
+	diagnostics []*source.Diagnostic
+	facts       map[string][]byte // by plugin, for those whose analyzers use facts
+	err         error
+}
+
+// runPlugins returns the result of the plugins on the package, or only
+// their facts about it if factsOnly is set.
+func (s *snapshot) runPlugins(ctx context.Context, id PackageID, factsOnly bool) (*pluginResult, error) {
+	key := pluginKey{id, factsOnly}
+	s.mu.Lock()
+	entry, hit := s.pluginHandles.Get(key)
+	s.mu.Unlock()
+
+	// cache miss?
+	if !hit {
+		// The plugins see the package as it is type-checked for its
+		// diagnostics, or for its importers.
+		mode := source.ParseFull
+		if factsOnly {
+			mode = s.workspaceParseMode(id)
+		}
+		ph, err := s.buildPackageHandle(ctx, id, mode)
+		if err != nil {
+			return nil, err
+		}
+		opts := s.view.Options()
+		handleKey := pluginHandleKey(source.Hashf("%x %t %q %v", ph.key, factsOnly, opts.AnalysisPlugins, opts.Analyses))
+		promise, release := s.store.Promise(handleKey, func(ctx context.Context, arg interface{}) interface{} {
+			return pluginImpl(ctx, arg.(*snapshot), ph, factsOnly)
+		})
+
+		s.mu.Lock()
+		if prev, ok := s.pluginHandles.Get(key); ok {
+			// Another thread got there first.
+			release()
+			entry = prev
+		} else {
+			entry = promise
+			s.pluginHandles.Set(key, entry, func(_, _ interface{}) { release() })
+		}
+		s.mu.Unlock()
+	}
+
+	// Await result.
+	v, err := s.awaitPromise(ctx, entry.(*memoize.Promise))
+	if err != nil {
+		return nil, err
+	}
+	return v.(*pluginResult), nil
+}
+
+// pluginImpl runs the plugins on the package, with the contents of its
+// modified files, the export data of its dependencies and the facts of
+// the plugins about them, and converts their diagnostics. If factsOnly is
+// set, it only runs the plugins whose analyzers use facts, for the facts.
+func pluginImpl(ctx context.Context, snapshot *snapshot, ph *packageHandle, factsOnly bool) *pluginResult {
+	ctx, done := event.Start(ctx, "cache.Plugin", tag.Snapshot.Of(snapshot.ID()), tag.Package.Of(string(ph.m.ID)))
+	defer done()
+
+	var plugins [][]string // command lines
+	needFacts := false
+	for _, plugin := range snapshot.view.Options().AnalysisPlugins {
+		args := strings.Fields(plugin)
+		usesFacts := snapshot.view.pluginUsesFacts(ctx, args)
+		if usesFacts || !factsOnly {
+			plugins = append(plugins, args)
+		}
+		needFacts = needFacts || usesFacts
+	}
+	if len(plugins) == 0 {
+		return &pluginResult{}
+	}
+
+	pkg, err := ph.await(ctx, snapshot)
+	if err != nil {
+		return &pluginResult{err: err}
+	}
+	dir, err := ioutil.TempDir("", "gopls-plugin-")
+	if err != nil {
+		return &pluginResult{err: err}
+	}
+	defer os.RemoveAll(dir)
+
+	// The plugins type-check all the files of the package, with the
+	// imports resolved as they are by gopls.
+	cfg := pluginchecker.Config{
+		ID:          string(pkg.m.ID),
+		ImportPath:  string(pkg.m.PkgPath),
+		WordSize:    8,
+		MaxAlign:    8,
+		ImportMap:   make(map[string]string),
+		PackageFile: make(map[string]string),
+		Analyses:    snapshot.view.Options().Analyses,
+		VetxOnly:    factsOnly,
+	}
+	if sizes := pkg.m.TypesSizes; sizes != nil {
+		cfg.WordSize = sizes.Sizeof(types.Typ[types.Uintptr])
+		cfg.MaxAlign = sizes.Alignof(types.Typ[types.Complex128])
+	}
+	for _, pgf := range pkg.compiledGoFiles {
+		name := pgf.URI.Filename()
+		cfg.GoFiles = append(cfg.GoFiles, name)
+		fh, err := snapshot.GetFile(ctx, pgf.URI)
+		if err != nil {
+			return &pluginResult{err: err}
+		}
+		if o, ok := fh.(*overlay); ok && !o.saved {
+			if cfg.Overlay == nil {
+				cfg.Overlay = make(map[string][]byte)
+			}
+			cfg.Overlay[name] = o.text
+		}
+		for _, spec := range pgf.File.Imports {
+			// Renamed, blank and dot imports define their name.
+			obj := pkg.typesInfo.Implicits[spec]
+			if spec.Name != nil {
+				obj = pkg.typesInfo.Defs[spec.Name]
+			}
+			if pkgName, ok := obj.(*types.PkgName); ok {
+				importPath, _ := strconv.Unquote(spec.Path.Value)
+				cfg.ImportMap[importPath] = pkgName.Imported().Path()
+			}
+		}
+	}
+	for _, dep := range pkg.imports {
+		if dep.types == types.Unsafe {
+			continue
+		}
+		file := filepath.Join(dir, fmt.Sprintf("%d.x", len(cfg.PackageFile)))
+		var buf bytes.Buffer
+		if err := gcexportdata.Write(&buf, snapshot.FileSet(), dep.types); err != nil {
+			return &pluginResult{err: err}
+		}
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0666); err != nil {
+			return &pluginResult{err: err}
+		}
+		cfg.PackageFile[string(dep.m.PkgPath)] = file
+	}
+
+	// The facts of the plugins about the dependencies.
+	depFacts := make(map[string]map[string][]byte) // by package path, then plugin
+	if needFacts {
+		for _, dep := range pkg.imports {
+			if dep.types == types.Unsafe {
+				continue
+			}
+			res, err := snapshot.runPlugins(ctx, dep.m.ID, true)
+			if err == nil {
+				err = res.err
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return &pluginResult{err: ctx.Err()}
+				}
+				// The analyzers only miss the facts of this dependency.
+				event.Error(ctx, "no analysis plugin facts", err, tag.Package.Of(string(dep.m.ID)))
+				continue
+			}
+			depFacts[string(dep.m.PkgPath)] = res.facts
+		}
+	}
+
+	result := &pluginResult{facts: make(map[string][]byte)}
+	for i, args := range plugins {
+		plugin := strings.Join(args, " ")
+		cfg.PackageVetx = make(map[string]string)
+		for path, facts := range depFacts {
+			if data, ok := facts[plugin]; ok {
+				file := filepath.Join(dir, fmt.Sprintf("%d.%d.vetx", i, len(cfg.PackageVetx)))
+				if err := ioutil.WriteFile(file, data, 0666); err != nil {
+					return &pluginResult{err: err}
+				}
+				cfg.PackageVetx[path] = file
+			}
+		}
+		cfg.VetxOutput = filepath.Join(dir, fmt.Sprintf("%d.vetx", i))
+		cfgFile := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return &pluginResult{err: err}
+		}
+		if err := ioutil.WriteFile(cfgFile, data, 0666); err != nil {
+			return &pluginResult{err: err}
+		}
+
+		res, err := runPlugin(ctx, filepath.Dir(cfg.GoFiles[0]), args, cfgFile)
+		if ctx.Err() != nil {
+			return &pluginResult{err: ctx.Err()}
+		}
+		if err != nil {
+			// Keep going if a single plugin failed.
+			event.Error(ctx, fmt.Sprintf("analysis plugin %s failed", args[0]), err, tag.Package.Of(string(pkg.m.ID)))
+			continue
+		}
+		if facts, err := ioutil.ReadFile(cfg.VetxOutput); err == nil {
+			result.facts[plugin] = facts
+		}
+		if factsOnly {
+			continue
+		}
+		for _, msg := range res.Errors {
+			event.Error(ctx, fmt.Sprintf("analysis plugin %s", args[0]), errors.New(msg), tag.Package.Of(string(pkg.m.ID)))
+		}
+		analyzers := make(map[string]*source.Analyzer)
+		for _, d := range res.Diagnostics {
+			a, ok := analyzers[d.Analyzer]
+			if !ok {
+				// The analyzer is not compiled into gopls, but
+				// //lint:ignore directives may name it.
+				a = &source.Analyzer{
+					Analyzer: &analysis.Analyzer{Name: d.Analyzer, Doc: "analyzer of " + args[0]},
+					Enabled:  true,
+				}
+				analyzers[d.Analyzer] = a
+			}
+			diag, err := pluginDiagnostic(ctx, snapshot, a, d)
+			if err != nil {
+				event.Error(ctx, "unable to compute analysis plugin diagnostic position", err, tag.Package.Of(string(pkg.m.ID)))
+				continue
+			}
+			result.diagnostics = append(result.diagnostics, diag)
+		}
+	}
+	return result
+}
+
+// pluginUsesFacts reports whether the analyzers of the plugin with the
+// given command line use facts, by asking it once per view. If it cannot
+// tell, it assumes that they do not.
+func (v *View) pluginUsesFacts(ctx context.Context, args []string) bool {
+	plugin := strings.Join(args, " ")
+	v.pluginFactsMu.Lock()
+	defer v.pluginFactsMu.Unlock()
+	if usesFacts, ok := v.pluginFacts[plugin]; ok {
+		return usesFacts
+	}
+	usesFacts := false
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], "facts")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil {
+		err = json.Unmarshal(out, &usesFacts)
+	} else {
+		err = fmt.Errorf("%v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return false // ask again next time
+		}
+		event.Error(ctx, fmt.Sprintf("analysis plugin %s cannot tell whether it uses facts", args[0]), err)
+	}
+	if v.pluginFacts == nil {
+		v.pluginFacts = make(map[string]bool)
+	}
+	v.pluginFacts[plugin] = usesFacts
+	return usesFacts
+}
+
+// runPlugin runs the command of a plugin on the config file, and decodes
+// its result.
+func runPlugin(ctx context.Context, dir string, args []string, cfgFile string) (*pluginchecker.Result, error) {
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], cfgFile)...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	result := new(pluginchecker.Result)
+	if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+		return nil, fmt.Errorf("cannot decode result: %v", err)
+	}
+	return result, nil
+}
+
+// pluginDiagnostic converts a diagnostic of a plugin.
+func pluginDiagnostic(ctx context.Context, snapshot *snapshot, a *source.Analyzer, d pluginchecker.Diagnostic) (*source.Diagnostic, error) {
+	uri, rng, err := pluginRange(ctx, snapshot, d.Range)
+	if err != nil {
+		return nil, err
+	}
+	category := d.Analyzer
+	if d.Category != "" {
+		category += "." + d.Category
+	}
+	diag := &source.Diagnostic{
+		URI:      uri,
+		Range:    rng,
+		Severity: protocol.SeverityWarning,
+		Source:   source.AnalyzerErrorKind(category),
+		Message:  d.Message,
+		Analyzer: a,
+	}
+	for _, sf := range d.SuggestedFixes {
+		edits := make(map[span.URI][]protocol.TextEdit)
+		for _, e := range sf.TextEdits {
+			uri, rng, err := pluginRange(ctx, snapshot, e.Range)
+			if err != nil {
+				return nil, err
+			}
+			edits[uri] = append(edits[uri], protocol.TextEdit{Range: rng, NewText: e.NewText})
+		}
+		diag.SuggestedFixes = append(diag.SuggestedFixes, source.SuggestedFix{
+			Title:      sf.Message,
+			Edits:      edits,
+			ActionKind: protocol.QuickFix,
+		})
+	}
+	for _, related := range d.Related {
+		uri, rng, err := pluginRange(ctx, snapshot, related.Range)
+		if err != nil {
+			return nil, err
+		}
+		diag.Related = append(diag.Related, source.RelatedInformation{URI: uri, Range: rng, Message: related.Message})
+	}
+	// If the fixes only delete code, assume that the diagnostic is reporting dead code.
+	if onlyDeletions(diag.SuggestedFixes) {
+		diag.Tags = []protocol.DiagnosticTag{protocol.Unnecessary}
+	}
+	return diag, nil
+}
+
+// pluginRange converts a range of bytes of a file, as seen by a plugin
+// through the overlay, into a protocol range.
+func pluginRange(ctx context.Context, snapshot *snapshot, r pluginchecker.Range) (span.URI, protocol.Range, error) {
+	uri := span.URIFromPath(r.File)
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return "", protocol.Range{}, err
+	}
+	content, err := fh.Read()
+	if err != nil {
+		return "", protocol.Range{}, err
+	}
+	spn := span.New(uri, span.NewPoint(0, 0, r.Start), span.NewPoint(0, 0, r.End))
+	rng, err := protocol.NewColumnMapper(uri, content).Range(spn)
+	if err != nil {
+		return "", protocol.Range{}, err
+	}
+	return uri, rng, nil
+}
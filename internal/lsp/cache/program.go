@@ -0,0 +1,175 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+)
+
+func (s *snapshot) AnalyzeProgram(ctx context.Context, pkgIDs []string, analyzers []*source.Analyzer) ([]*source.Diagnostic, error) {
+	var results []*source.Diagnostic
+	for _, a := range analyzers {
+		if a.Analyzer.RunProgram == nil || !a.IsEnabled(s.view) {
+			continue
+		}
+		diagnostics, err := s.analyzeProgram(ctx, pkgIDs, a.Analyzer)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			// Keep going if a single analyzer failed.
+			event.Error(ctx, fmt.Sprintf("analyzer %q failed", a.Analyzer.Name), err)
+			continue
+		}
+		results = append(results, diagnostics...)
+	}
+	return results, nil
+}
+
+// analyzeProgram applies a whole-program analyzer to the packages, after
+// the analyzers it requires, whose actions are shared with the analysis
+// of each package. Unlike the checker drivers, it leaves out the
+// packages with type errors, unless the analyzer runs despite errors,
+// and those on which a required analyzer failed.
+func (s *snapshot) analyzeProgram(ctx context.Context, pkgIDs []string, analyzer *analysis.Analyzer) (_ []*source.Diagnostic, err error) {
+	objectFacts := make(map[objectFactKey]analysis.Fact)
+	packageFacts := make(map[packageFactKey]analysis.Fact)
+	var diagnostics []*analysis.Diagnostic
+	pass := &analysis.ProgramPass{
+		Analyzer: analyzer,
+		Fset:     s.FileSet(),
+		Report: func(d analysis.Diagnostic) {
+			// Prefix the diagnostic category with the analyzer's name.
+			if d.Category == "" {
+				d.Category = analyzer.Name
+			} else {
+				d.Category = analyzer.Name + "." + d.Category
+			}
+			diagnostics = append(diagnostics, &d)
+		},
+		ImportObjectFact: func(obj types.Object, ptr analysis.Fact) bool {
+			if obj == nil {
+				panic("nil object")
+			}
+			if v, ok := objectFacts[objectFactKey{obj, factType(ptr)}]; ok {
+				reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v).Elem())
+				return true
+			}
+			return false
+		},
+		ImportPackageFact: func(pkg *types.Package, ptr analysis.Fact) bool {
+			if pkg == nil {
+				panic("nil package")
+			}
+			if v, ok := packageFacts[packageFactKey{pkg, factType(ptr)}]; ok {
+				reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v).Elem())
+				return true
+			}
+			return false
+		},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			facts := make([]analysis.ObjectFact, 0, len(objectFacts))
+			for k, fact := range objectFacts {
+				facts = append(facts, analysis.ObjectFact{Object: k.obj, Fact: fact})
+			}
+			return facts
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			facts := make([]analysis.PackageFact, 0, len(packageFacts))
+			for k, fact := range packageFacts {
+				facts = append(facts, analysis.PackageFact{Package: k.pkg, Fact: fact})
+			}
+			return facts
+		},
+	}
+
+	owners := make(map[*token.File]*pkg)
+packages:
+	for _, id := range pkgIDs {
+		ph, err := s.buildPackageHandle(ctx, PackageID(id), source.ParseFull)
+		if err != nil {
+			return nil, err
+		}
+		p, err := ph.await(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		if p.IsIllTyped() && !analyzer.RunDespiteErrors {
+			continue
+		}
+		inputs := make(map[*analysis.Analyzer]interface{})
+		for _, req := range analyzer.Requires {
+			ah, err := s.analyzerActionHandle(ctx, PackageID(id), req, nil)
+			if err != nil {
+				return nil, err
+			}
+			v, err := s.awaitPromise(ctx, ah.promise)
+			if err != nil {
+				return nil, err
+			}
+			data, ok := v.(*actionData)
+			if !ok || data.err != nil {
+				continue packages
+			}
+			inputs[req] = data.result
+			for k, fact := range data.objectFacts {
+				objectFacts[k] = fact
+			}
+			for k, fact := range data.packageFacts {
+				packageFacts[k] = fact
+			}
+		}
+
+		var syntax []*ast.File
+		for _, pgf := range p.compiledGoFiles {
+			syntax = append(syntax, pgf.File)
+			if owners[pgf.Tok] == nil {
+				owners[pgf.Tok] = p
+			}
+		}
+		pass.Packages = append(pass.Packages, &analysis.ProgramPackage{
+			Files:      syntax,
+			Pkg:        p.GetTypes(),
+			TypesInfo:  p.GetTypesInfo(),
+			TypesSizes: p.GetTypesSizes(),
+			ResultOf:   inputs,
+		})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("analysis %s panicked: %v", analyzer.Name, r)
+		}
+	}()
+	if err := analyzer.RunProgram(pass); err != nil {
+		return nil, err
+	}
+
+	// Only the diagnostics about the files of the packages are reported.
+	var results []*source.Diagnostic
+	for _, diag := range diagnostics {
+		p := owners[s.FileSet().File(diag.Pos)]
+		if p == nil {
+			continue
+		}
+		srcDiags, err := analysisDiagnosticDiagnostics(s, p, analyzer, diag)
+		if err != nil {
+			event.Error(ctx, "unable to compute analysis error position", err, tag.Category.Of(diag.Category), tag.Package.Of(p.ID()))
+			continue
+		}
+		results = append(results, srcDiags...)
+	}
+	return results, nil
+}
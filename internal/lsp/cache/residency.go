@@ -0,0 +1,269 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"github.com/cowpaths/golang-x-tools/go/gcexportdata"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+)
+
+// A residency tracks the workspace packages of a view that have been
+// type-checked in full, with their memory use and their last use by a
+// feature, so as to keep them within the memoryBudget option.
+//
+// It outlives snapshots, but each snapshot fixes the set of packages it
+// evicts when it is cloned (see evict), so that all the packages of a
+// snapshot are type-checked against the same dependencies.
+type residency struct {
+	mu    sync.Mutex
+	clock uint64 // incremented at each use
+	pkgs  map[PackageID]*residentPackage
+}
+
+type residentPackage struct {
+	size       int64  // bytes of syntax and type information (see packageSize)
+	exportSize int64  // bytes of export data, once evicted
+	lastUse    uint64 // value of the clock at the last use
+}
+
+func newResidency() *residency {
+	return &residency{pkgs: make(map[PackageID]*residentPackage)}
+}
+
+// checked records that the package has been type-checked in full, using
+// size bytes (see packageSize).
+func (r *residency) checked(id PackageID, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pkgs[id]; ok {
+		p.size = size
+		return
+	}
+	// A package that has not yet been used by a feature is the least
+	// recently used.
+	r.pkgs[id] = &residentPackage{size: size}
+}
+
+// exported records the size of the export data of an evicted package.
+func (r *residency) exported(id PackageID, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pkgs[id]; ok {
+		p.exportSize = size
+	}
+}
+
+// used records the use of the packages by a feature.
+func (r *residency) used(ids ...PackageID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock++
+	for _, id := range ids {
+		if p, ok := r.pkgs[id]; ok {
+			p.lastUse = r.clock
+		}
+	}
+}
+
+// evict returns the set of workspace packages to hold as export data: the
+// least recently used ones beyond the budget. The active packages, those
+// with open files, are never evicted, and count first against the
+// budget. A budget of 0 means no limit. The packages that are no longer
+// workspace packages are forgotten.
+func (r *residency) evict(budget int64, workspacePackages map[PackageID]PackagePath, active map[PackageID]bool) map[PackageID]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]PackageID, 0, len(workspacePackages))
+	for id := range r.pkgs {
+		if _, ok := workspacePackages[id]; ok {
+			ids = append(ids, id)
+		} else {
+			delete(r.pkgs, id)
+		}
+	}
+	if budget <= 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if active[ids[i]] != active[ids[j]] {
+			return active[ids[i]]
+		}
+		pi, pj := r.pkgs[ids[i]], r.pkgs[ids[j]]
+		if pi.lastUse != pj.lastUse {
+			return pi.lastUse > pj.lastUse
+		}
+		return ids[i] < ids[j]
+	})
+	var (
+		total   int64
+		evicted map[PackageID]bool
+	)
+	for _, id := range ids {
+		total += r.pkgs[id].size
+		if total > budget && !active[id] {
+			if evicted == nil {
+				evicted = make(map[PackageID]bool)
+			}
+			evicted[id] = true
+		}
+	}
+	return evicted
+}
+
+// packageSize returns the number of bytes used by the syntax and type
+// information of a package type-checked in full, as derived from their
+// structures: its source, the nodes and comments of its syntax trees,
+// the line tables of its files, the entries of its type information and
+// the objects it defines. The overhead of the allocator and of the maps
+// is not counted.
+func packageSize(pkg *pkg) int64 {
+	var size int64
+	for _, pgf := range pkg.compiledGoFiles {
+		size += int64(len(pgf.Src))
+		size += int64(pgf.Tok.LineCount()) * int64(unsafe.Sizeof(int(0)))
+		ast.Inspect(pgf.File, func(n ast.Node) bool {
+			if n == nil {
+				return false
+			}
+			size += int64(reflect.TypeOf(n).Elem().Size())
+			if id, ok := n.(*ast.Ident); ok {
+				size += int64(len(id.Name))
+			}
+			return true
+		})
+		for _, cg := range pgf.File.Comments {
+			for _, c := range cg.List {
+				size += int64(unsafe.Sizeof(*c)) + int64(len(c.Text))
+			}
+		}
+	}
+	info := pkg.typesInfo
+	if info == nil {
+		return size
+	}
+	var (
+		exprSize   = int64(unsafe.Sizeof(ast.Expr(nil)))
+		nodeSize   = int64(unsafe.Sizeof(ast.Node(nil)))
+		objectSize = int64(unsafe.Sizeof(types.Object(nil)))
+		ptrSize    = int64(unsafe.Sizeof(uintptr(0)))
+	)
+	size += int64(len(info.Types)) * (exprSize + int64(unsafe.Sizeof(types.TypeAndValue{})))
+	size += int64(len(info.Defs)+len(info.Uses)) * (ptrSize + objectSize)
+	size += int64(len(info.Implicits)) * (nodeSize + objectSize)
+	size += int64(len(info.Selections)) * (2*ptrSize + int64(unsafe.Sizeof(types.Selection{})))
+	size += int64(len(info.Scopes)) * (nodeSize + ptrSize + int64(unsafe.Sizeof(types.Scope{})))
+	for _, obj := range info.Defs {
+		if obj != nil {
+			size += int64(reflect.TypeOf(obj).Elem().Size())
+		}
+	}
+	return size
+}
+
+// typeCheckEvicted type-checks in full a workspace package evicted to keep
+// within the memoryBudget option, for its diagnostics, and returns it held
+// as the export data of the full type-check (see newExportedPackage). The
+// full type-check is then dropped.
+func typeCheckEvicted(ctx context.Context, snapshot *snapshot, goFiles, compiledGoFiles []source.FileHandle, m *Metadata, deps map[PackagePath]*packageHandle) (*pkg, error) {
+	full, err := typeCheckImpl(ctx, snapshot, goFiles, compiledGoFiles, m, source.ParseFull, deps)
+	if err != nil {
+		return nil, err
+	}
+	if full.types == types.Unsafe {
+		return full, nil
+	}
+	var data bytes.Buffer
+	if err := gcexportdata.Write(&data, snapshot.FileSet(), full.types); err != nil {
+		return nil, err
+	}
+	var depTypes []*types.Package
+	for _, imp := range full.imports {
+		depTypes = append(depTypes, imp.types)
+	}
+	pkg, err := newExportedPackage(ctx, snapshot, goFiles, compiledGoFiles, m, data.Bytes(), depTypes)
+	if err != nil {
+		// The package is then held in full.
+		event.Error(ctx, "reading export data", err, tag.Package.Of(string(m.ID)))
+		return full, nil
+	}
+	snapshot.view.residency.exported(m.ID, int64(data.Len()))
+	pkg.diagnostics = full.diagnostics
+	pkg.imports = full.imports
+	pkg.parseErrors = full.parseErrors
+	pkg.typeErrors = full.typeErrors
+	pkg.hasFixedFiles = full.hasFixedFiles
+	return pkg, nil
+}
+
+// ResidencyStats describes the memory used by the workspace packages of a
+// view, as measured to keep it within the memoryBudget option.
+type ResidencyStats struct {
+	Budget   int64 // 0 if unlimited
+	Resident int64 // bytes used by the packages type-checked in full
+	Evicted  int   // number of packages held as export data
+	Exported int64 // bytes of export data of the evicted packages
+	Packages []ResidentPackageStats
+}
+
+// ResidentPackageStats describes the memory used by a workspace package.
+type ResidentPackageStats struct {
+	ID         string
+	Size       int64 // bytes when type-checked in full (see packageSize)
+	ExportSize int64 // bytes of export data, if evicted
+	Evicted    bool
+}
+
+// Residency returns statistics on the memory used by the workspace packages
+// of the view's current snapshot, most recently used first.
+func (v *View) Residency() ResidencyStats {
+	v.snapshotMu.Lock()
+	var evicted map[PackageID]bool
+	if v.snapshot != nil {
+		evicted = v.snapshot.evicted
+	}
+	v.snapshotMu.Unlock()
+
+	budget, _ := source.ParseMemorySize(v.Options().MemoryBudget)
+	stats := ResidencyStats{Budget: budget}
+
+	r := v.residency
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, p := range r.pkgs {
+		ps := ResidentPackageStats{
+			ID:      string(id),
+			Size:    p.size,
+			Evicted: evicted[id],
+		}
+		if evicted[id] {
+			ps.ExportSize = p.exportSize
+			stats.Evicted++
+			stats.Exported += p.exportSize
+		} else {
+			stats.Resident += p.size
+		}
+		stats.Packages = append(stats.Packages, ps)
+	}
+	sort.Slice(stats.Packages, func(i, j int) bool {
+		pi, pj := r.pkgs[PackageID(stats.Packages[i].ID)], r.pkgs[PackageID(stats.Packages[j].ID)]
+		if pi.lastUse != pj.lastUse {
+			return pi.lastUse > pj.lastUse
+		}
+		return stats.Packages[i].ID < stats.Packages[j].ID
+	})
+	return stats
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+)
+
+func TestResidencyEvict(t *testing.T) {
+	r := newResidency()
+	ws := map[PackageID]PackagePath{"a": "a", "b": "b", "c": "c"}
+	const size = 100
+	for _, id := range []PackageID{"a", "b", "c"} {
+		r.checked(id, size)
+	}
+
+	if got := r.evict(0, ws, nil); got != nil {
+		t.Errorf("evict without budget = %v, want none", got)
+	}
+	if got := r.evict(3*size, ws, nil); got != nil {
+		t.Errorf("evict within budget = %v, want none", got)
+	}
+
+	// The least recently used packages are evicted first.
+	r.used("c")
+	r.used("a")
+	if got, want := r.evict(size, ws, nil), map[PackageID]bool{"b": true, "c": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evict = %v, want %v", got, want)
+	}
+	r.used("b")
+	if got, want := r.evict(2*size, ws, nil), map[PackageID]bool{"c": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evict after use = %v, want %v", got, want)
+	}
+
+	// The active packages are never evicted, and count first.
+	active := map[PackageID]bool{"c": true}
+	if got, want := r.evict(2*size, ws, active), map[PackageID]bool{"a": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evict with active package = %v, want %v", got, want)
+	}
+	if got, want := r.evict(1, ws, active), map[PackageID]bool{"a": true, "b": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evict beyond active package = %v, want %v", got, want)
+	}
+
+	// Packages that are no longer in the workspace are forgotten.
+	delete(ws, "c")
+	if got := r.evict(2*size, ws, nil); got != nil {
+		t.Errorf("evict after removal = %v, want none", got)
+	}
+	if _, ok := r.pkgs["c"]; ok {
+		t.Errorf("removed package is still tracked")
+	}
+}
+
+func TestPackageSize(t *testing.T) {
+	check := func(src string) *pkg {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := &pkg{
+			compiledGoFiles: []*source.ParsedGoFile{{File: f, Tok: fset.File(f.Pos()), Src: []byte(src)}},
+			typesInfo: &types.Info{
+				Types:      make(map[ast.Expr]types.TypeAndValue),
+				Defs:       make(map[*ast.Ident]types.Object),
+				Uses:       make(map[*ast.Ident]types.Object),
+				Implicits:  make(map[ast.Node]types.Object),
+				Selections: make(map[*ast.SelectorExpr]*types.Selection),
+				Scopes:     make(map[ast.Node]*types.Scope),
+			},
+		}
+		if _, err := new(types.Config).Check("p", fset, []*ast.File{f}, pkg.typesInfo); err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	const decl = "\n// F%d adds.\nfunc F%d(x, y int) int { return x + y }\n"
+	var b strings.Builder
+	b.WriteString("package p\n")
+	small := check(b.String())
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, decl, i, i)
+	}
+	large := check(b.String())
+
+	// The syntax and type information take much more memory than the
+	// source, in proportion to the declarations.
+	srcSize := int64(len(large.compiledGoFiles[0].Src))
+	if got := packageSize(large); got < 5*srcSize {
+		t.Errorf("packageSize = %d, want at least 5 times the %d bytes of source", got, srcSize)
+	}
+	if got, min := packageSize(large)-packageSize(small), 100*int64(len(decl)); got < min {
+		t.Errorf("size of 100 declarations = %d, want at least %d", got, min)
+	}
+}
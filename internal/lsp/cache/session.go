@@ -217,6 +217,7 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		filesByURI:           map[span.URI]*fileBase{},
 		filesByBase:          map[string][]*fileBase{},
 		rootURI:              root,
+		residency:            newResidency(),
 		workspaceInformation: *ws,
 	}
 	v.importsState = &importsState{
@@ -233,6 +234,7 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		initializeOnce:       &sync.Once{},
 		store:                &s.cache.store,
 		packages:             persistent.NewMap(packageKeyLessInterface),
+		previousPackages:     persistent.NewMap(packageKeyLessInterface),
 		meta:                 &metadataGraph{},
 		files:                newFilesMap(),
 		isActivePackageCache: newIsActivePackageCacheMap(),
@@ -246,6 +248,11 @@ func (s *Session) createView(ctx context.Context, name string, folder span.URI,
 		parseWorkHandles:     persistent.NewMap(uriLessInterface),
 		modTidyHandles:       persistent.NewMap(uriLessInterface),
 		modWhyHandles:        persistent.NewMap(uriLessInterface),
+		modGraphHandles:      persistent.NewMap(uriLessInterface),
+		modVulnHandles:       persistent.NewMap(uriLessInterface),
+		buildConfigHandles:   persistent.NewMap(stringLessInterface),
+		pluginHandles:        persistent.NewMap(pluginKeyLessInterface),
+		templateHandles:      persistent.NewMap(stringLessInterface),
 		knownSubdirs:         newKnownDirsSet(),
 		workspace:            workspace,
 	}
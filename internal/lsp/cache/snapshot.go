@@ -105,6 +105,11 @@ type snapshot struct {
 	//    be in packages, unless there is a missing import
 	packages *persistent.Map // from packageKey to *memoize.Promise[*packageHandle]
 
+	// previousPackages maps a packageKey whose type information has been
+	// invalidated to the handle of its last type-check, with the
+	// ExperimentalEarlyCutoff option (see previousPackage).
+	previousPackages *persistent.Map // from packageKey to previousPackage
+
 	// isActivePackageCache maps package ID to the cached value if it is active or not.
 	// It may be invalidated when metadata changes or a new file is opened or closed.
 	isActivePackageCache isActivePackageCacheMap
@@ -131,8 +136,27 @@ type snapshot struct {
 	// Preserve go.mod-related handles to avoid garbage-collecting the results
 	// of various calls to the go command. The handles need not refer to only
 	// the view's go.mod file.
-	modTidyHandles *persistent.Map // from span.URI to *memoize.Promise[modTidyResult]
-	modWhyHandles  *persistent.Map // from span.URI to *memoize.Promise[modWhyResult]
+	modTidyHandles  *persistent.Map // from span.URI to *memoize.Promise[modTidyResult]
+	modWhyHandles   *persistent.Map // from span.URI to *memoize.Promise[modWhyResult]
+	modGraphHandles *persistent.Map // from span.URI to *memoize.Promise[modGraphResult]
+	modVulnHandles  *persistent.Map // from span.URI to *memoize.Promise[modVulnResult]
+
+	// buildConfigHandles keeps track of the diagnostics of the additional
+	// build configurations of the view.
+	buildConfigHandles *persistent.Map // from string to *memoize.Promise[buildConfigResult]
+
+	// pluginHandles keeps track of the diagnostics of the analysis
+	// plugins on each package, and of their facts.
+	pluginHandles *persistent.Map // from pluginKey to *memoize.Promise[*pluginResult]
+
+	// templateHandles keeps track of the results computed from the
+	// template files or the Go packages for template features.
+	templateHandles *persistent.Map // from string to *templateHandle
+
+	// evicted is the set of workspace packages without open files that
+	// are held as export data to keep within the memory budget (see
+	// typeCheckEvicted). It is fixed for the snapshot.
+	evicted map[PackageID]bool
 
 	workspace *workspace // (not guarded by mu)
 
@@ -207,6 +231,7 @@ func (s *snapshot) destroy(destroyedBy string) {
 	}
 
 	s.packages.Destroy()
+	s.previousPackages.Destroy()
 	s.isActivePackageCache.Destroy()
 	s.actions.Destroy()
 	s.files.Destroy()
@@ -218,6 +243,11 @@ func (s *snapshot) destroy(destroyedBy string) {
 	s.parseWorkHandles.Destroy()
 	s.modTidyHandles.Destroy()
 	s.modWhyHandles.Destroy()
+	s.modGraphHandles.Destroy()
+	s.modVulnHandles.Destroy()
+	s.buildConfigHandles.Destroy()
+	s.pluginHandles.Destroy()
+	s.templateHandles.Destroy()
 
 	if s.workspaceDir != "" {
 		if err := os.RemoveAll(s.workspaceDir); err != nil {
@@ -659,6 +689,7 @@ func (s *snapshot) packageHandlesForFile(ctx context.Context, uri span.URI, mode
 	}
 
 	var phs []*packageHandle
+	s.view.residency.used(knownIDs...)
 	for _, id := range knownIDs {
 		// Filter out any intermediate test variants. We typically aren't
 		// interested in these packages for file= style queries.
@@ -742,7 +773,7 @@ func (s *snapshot) GetReverseDependencies(ctx context.Context, id string) ([]sou
 
 	var pkgs []source.Package
 	for id := range ids {
-		pkg, err := s.checkedPackage(ctx, id, s.workspaceParseMode(id))
+		pkg, err := s.checkedPackage(ctx, id, s.queryParseMode(id))
 		if err != nil {
 			return nil, err
 		}
@@ -1655,6 +1686,7 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		initializeOnce:       s.initializeOnce,
 		initializedErr:       s.initializedErr,
 		packages:             s.packages.Clone(),
+		previousPackages:     s.previousPackages.Clone(),
 		isActivePackageCache: s.isActivePackageCache.Clone(),
 		actions:              s.actions.Clone(),
 		files:                s.files.Clone(),
@@ -1667,6 +1699,11 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		parseWorkHandles:     s.parseWorkHandles.Clone(),
 		modTidyHandles:       s.modTidyHandles.Clone(),
 		modWhyHandles:        s.modWhyHandles.Clone(),
+		modGraphHandles:      s.modGraphHandles.Clone(),
+		modVulnHandles:       s.modVulnHandles.Clone(),
+		buildConfigHandles:   s.buildConfigHandles.Clone(),
+		pluginHandles:        s.pluginHandles.Clone(),
+		templateHandles:      s.templateHandles.Clone(),
 		knownSubdirs:         s.knownSubdirs.Clone(),
 		workspace:            newWorkspace,
 	}
@@ -1693,9 +1730,13 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		// Invalidate go.mod-related handles.
 		result.modTidyHandles.Delete(uri)
 		result.modWhyHandles.Delete(uri)
+		result.modGraphHandles.Delete(uri)
 
 		// Invalidate handles for cached symbols.
 		result.symbolizeHandles.Delete(uri)
+
+		// Invalidate the template results that depend on the file.
+		invalidateTemplateResults(result.templateHandles, s.view.FileKind(changes[uri].fileHandle))
 	}
 
 	// Add all of the known subdirectories, but don't update them for the
@@ -1759,6 +1800,10 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 			directIDs[id] = directIDs[id] || invalidateMetadata
 		}
 
+		// The Go files compiled only in other build configurations are
+		// diagnosed only by the build configurations.
+		invalidateBuildConfigs(result.buildConfigHandles, uri)
+
 		// Invalidate the previous modTidyHandle if any of the files have been
 		// saved or if any of the metadata has been invalidated.
 		if invalidateMetadata || fileWasSaved(originalFH, change.fileHandle) {
@@ -1768,6 +1813,10 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 
 			result.modTidyHandles.Clear()
 			result.modWhyHandles.Clear()
+			result.modGraphHandles.Clear()
+			result.modVulnHandles.Clear()
+			result.buildConfigHandles.Clear()
+			result.pluginHandles.Clear()
 		}
 
 		result.parseModHandles.Delete(uri)
@@ -1804,6 +1853,60 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		}
 	}
 
+	// Packages that enter or leave the memory budget change parse mode, and
+	// so invalidate the types of their reverse dependencies, as if they had
+	// changed. The evicted ones are held as export data, which is kept
+	// until they change; they are type-checked in full only for the
+	// snapshot that needs their syntax: their full syntax and types are
+	// dropped.
+	budget, _ := source.ParseMemorySize(s.view.Options().MemoryBudget)
+	active := make(map[PackageID]bool)
+	if budget > 0 {
+		for id := range s.workspacePackages {
+			if m := s.meta.metadata[id]; m != nil {
+				for _, uri := range m.CompiledGoFiles {
+					if result.isOpenLocked(uri) {
+						active[id] = true
+						break
+					}
+				}
+			}
+		}
+	}
+	result.evicted = s.view.residency.evict(budget, s.workspacePackages, active)
+	for id := range s.workspacePackages {
+		if result.evicted[id] != s.evicted[id] {
+			if _, ok := directIDs[id]; !ok {
+				directIDs[id] = false
+			}
+		}
+		if !result.evicted[id] {
+			continue
+		}
+		m := s.meta.metadata[id]
+		if m == nil {
+			continue
+		}
+		result.packages.Delete(packageKey{source.ParseFull, id})
+		for _, uri := range m.CompiledGoFiles {
+			if s.isOpenLocked(uri) {
+				continue
+			}
+			keys, _ := result.parseKeysByURI.Get(uri)
+			var kept []parseKey
+			for _, key := range keys {
+				if key.mode == source.ParseFull {
+					result.parsedGoFiles.Delete(key)
+				} else {
+					kept = append(kept, key)
+				}
+			}
+			if len(kept) < len(keys) {
+				result.parseKeysByURI.Set(uri, kept)
+			}
+		}
+	}
+
 	// Invalidate reverse dependencies too.
 	// idsToInvalidate keeps track of transitive reverse dependencies.
 	// If an ID is present in the map, invalidate its types.
@@ -1828,26 +1931,59 @@ func (s *snapshot) clone(ctx, bgCtx context.Context, changes map[span.URI]*fileC
 		addRevDeps(id, invalidateMetadata)
 	}
 
-	// Delete invalidated package type information.
-	for id := range idsToInvalidate {
+	// Forget the previous type-checks of the packages that have been
+	// type-checked since, or that have outlived their lifetime.
+	var previousToDelete []packageKey
+	s.previousPackages.Range(func(k, v interface{}) {
+		key := k.(packageKey)
+		if result.id-v.(previousPackage).since >= previousPackageLifetime {
+			previousToDelete = append(previousToDelete, key)
+		} else if v, ok := s.packages.Get(key); ok && v.(*packageHandle).promise.Cached() != nil {
+			previousToDelete = append(previousToDelete, key)
+		}
+	})
+	for _, key := range previousToDelete {
+		result.previousPackages.Delete(key)
+	}
+
+	// Delete invalidated package type information, keeping the last
+	// type-check of each package unless its metadata is invalidated.
+	earlyCutoff := s.view.Options().ExperimentalEarlyCutoff
+	for id, invalidateMetadata := range idsToInvalidate {
+		_, changed := directIDs[id]
 		for _, mode := range source.AllParseModes {
 			key := packageKey{mode, id}
+			if !earlyCutoff || invalidateMetadata {
+				result.previousPackages.Delete(key)
+			} else if v, ok := s.packages.Get(key); ok && v.(*packageHandle).promise.Cached() != nil {
+				result.previousPackages.Set(key, previousPackage{v.(*packageHandle), changed, result.id}, nil)
+			} else if v, ok := result.previousPackages.Get(key); ok && changed {
+				prev := v.(previousPackage)
+				prev.changed = true
+				result.previousPackages.Set(key, prev, nil)
+			}
 			result.packages.Delete(key)
 		}
 	}
 
-	// Copy actions.
+	// Copy actions. Those of the evicted packages hold their full
+	// type-check, so they are dropped too.
 	// TODO(adonovan): opt: avoid iteration over s.actions.
 	var actionsToDelete []actionKey
 	s.actions.Range(func(k, _ interface{}) {
 		key := k.(actionKey)
-		if _, ok := idsToInvalidate[key.pkg.id]; ok {
+		if _, ok := idsToInvalidate[key.pkg.id]; ok || result.evicted[key.pkg.id] {
 			actionsToDelete = append(actionsToDelete, key)
 		}
 	})
 	for _, key := range actionsToDelete {
 		result.actions.Delete(key)
 	}
+	// Likewise, the plugins see the dependencies of the packages.
+	for id := range idsToInvalidate {
+		result.pluginHandles.Delete(pluginKey{id, false})
+		result.pluginHandles.Delete(pluginKey{id, true})
+	}
 
 	// If the workspace mode has changed, we must delete all metadata, as it
 	// is unusable and may produce confusing or incorrect diagnostics.
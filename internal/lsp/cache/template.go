@@ -0,0 +1,62 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/memoize"
+	"github.com/cowpaths/golang-x-tools/internal/persistent"
+)
+
+// A templateHandle is the future result of a computation over the template
+// files or the Go packages of a snapshot.
+type templateHandle struct {
+	kind    source.FileKind // the kind of the files the result depends on
+	promise *memoize.Promise
+}
+
+func (s *snapshot) TemplateResult(ctx context.Context, key string, kind source.FileKind, compute func(context.Context, source.Snapshot) interface{}) (interface{}, error) {
+	s.mu.Lock()
+	entry, hit := s.templateHandles.Get(key)
+	s.mu.Unlock()
+
+	// cache miss?
+	if !hit {
+		handle := &templateHandle{
+			kind: kind,
+			promise: memoize.NewPromise("template."+key, func(ctx context.Context, arg interface{}) interface{} {
+				return compute(ctx, arg.(*snapshot))
+			}),
+		}
+
+		entry = handle
+		s.mu.Lock()
+		s.templateHandles.Set(key, entry, nil)
+		s.mu.Unlock()
+	}
+
+	// Await result.
+	return s.awaitPromise(ctx, entry.(*templateHandle).promise)
+}
+
+// invalidateTemplateResults deletes the template results that depend on
+// files of the given kind: template files, or Go packages for any other
+// kind.
+func invalidateTemplateResults(handles *persistent.Map, kind source.FileKind) {
+	if kind != source.Tmpl {
+		kind = source.Go
+	}
+	var keys []interface{}
+	handles.Range(func(key, value interface{}) {
+		if value.(*templateHandle).kind == kind {
+			keys = append(keys, key)
+		}
+	})
+	for _, key := range keys {
+		handles.Delete(key)
+	}
+}
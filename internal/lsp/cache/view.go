@@ -94,6 +94,15 @@ type View struct {
 	// is just the folder. If we are in module mode, this is the module rootURI.
 	rootURI span.URI
 
+	// residency tracks the memory used by the workspace packages, to keep
+	// it within the memoryBudget option.
+	residency *residency
+
+	// pluginFacts records whether the analyzers of each analysis plugin,
+	// by command line, use facts (see pluginUsesFacts).
+	pluginFactsMu sync.Mutex
+	pluginFacts   map[string]bool
+
 	// workspaceInformation tracks various details about this view's
 	// environment variables, go version, and use of modules.
 	workspaceInformation
@@ -275,6 +284,10 @@ func minorOptionsChange(a, b *source.Options) bool {
 	if a.MemoryMode != b.MemoryMode {
 		return false
 	}
+	// The diagnostics of the build configurations are kept by the snapshot.
+	if !reflect.DeepEqual(a.BuildConfigurations, b.BuildConfigurations) {
+		return false
+	}
 	aBuildFlags := make([]string, len(a.BuildFlags))
 	bBuildFlags := make([]string, len(b.BuildFlags))
 	copy(aBuildFlags, a.BuildFlags)
@@ -561,6 +574,16 @@ func (v *View) shutdown(ctx context.Context) {
 	v.snapshotWG.Wait()
 }
 
+func (v *View) IsDependencyFile(uri span.URI) bool {
+	filename := uri.Filename()
+	for _, dir := range []string{v.goroot, v.gomodcache} {
+		if dir != "" && source.InDir(dir, filename) {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *View) Session() *Session {
 	return v.session
 }
@@ -113,7 +113,7 @@ func (r *runner) Hover(t *testing.T, spn span.Span, info string) {
 	//TODO: hovering not supported on command line
 }
 
-func (r *runner) InlayHints(t *testing.T, spn span.Span) {
+func (r *runner) InlayHints(t *testing.T, spn span.Span, hints []string) {
 	// TODO: inlayHints not supported on command line
 }
 
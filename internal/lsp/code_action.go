@@ -17,6 +17,7 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/lsp/mod"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/work"
 	"github.com/cowpaths/golang-x-tools/internal/span"
 )
 
@@ -77,6 +78,23 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 			if err != nil {
 				return nil, err
 			}
+			vulnDiags, err := mod.VulnerabilityDiagnosticsForMod(ctx, snapshot, fh)
+			if err != nil {
+				event.Error(ctx, "vulnerability fixes", err, tag.File.Of(fh.URI().Filename()))
+			}
+			diags = append(diags, vulnDiags...)
+			quickFixes, err := codeActionsMatchingDiagnostics(ctx, snapshot, diagnostics, diags)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, quickFixes...)
+		}
+	case source.Work:
+		if diagnostics := params.Context.Diagnostics; len(diagnostics) > 0 {
+			diags, err := work.DiagnosticsForWork(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
 			quickFixes, err := codeActionsMatchingDiagnostics(ctx, snapshot, diagnostics, diags)
 			if err != nil {
 				return nil, err
@@ -91,6 +109,20 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 		}
 		diagnostics := params.Context.Diagnostics
 
+		// Files of modules that are not used by go.work are not in any
+		// package, so their fix must be found first.
+		if wanted[protocol.QuickFix] && len(diagnostics) > 0 {
+			workDiags, err := work.DiagnosticsForFile(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
+			workFixes, err := codeActionsMatchingDiagnostics(ctx, snapshot, diagnostics, workDiags)
+			if err != nil {
+				return nil, err
+			}
+			codeActions = append(codeActions, workFixes...)
+		}
+
 		// First, process any missing imports and pair them with the
 		// diagnostics they fix.
 		if wantQuickFixes := wanted[protocol.QuickFix] && len(diagnostics) > 0; wantQuickFixes || wanted[protocol.SourceOrganizeImports] {
@@ -134,6 +166,9 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 		}
 		pkg, err := snapshot.PackageForFile(ctx, fh.URI(), source.TypecheckFull, source.WidestPackage)
 		if err != nil {
+			if len(codeActions) > 0 {
+				return codeActions, nil
+			}
 			return nil, err
 		}
 
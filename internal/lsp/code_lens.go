@@ -6,6 +6,7 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 
@@ -49,9 +50,49 @@ func (s *Server) codeLens(ctx context.Context, params *protocol.CodeLensParams)
 	sort.Slice(result, func(i, j int) bool {
 		a, b := result[i], result[j]
 		if protocol.CompareRange(a.Range, b.Range) == 0 {
-			return a.Command.Command < b.Command.Command
+			if lensID(a) == lensID(b) {
+				return a.Command.Title < b.Command.Title
+			}
+			return lensID(a) < lensID(b)
 		}
 		return protocol.CompareRange(a.Range, b.Range) < 0
 	})
 	return result, nil
 }
+
+// lensID returns the command ID of a code lens, or for an unresolved code
+// lens, the ID of the lens that produced it.
+func lensID(lens protocol.CodeLens) string {
+	if data, ok := lens.Data.(source.LensData); ok && lens.Command.Command == "" {
+		return data.Lens.ID()
+	}
+	return lens.Command.Command
+}
+
+func (s *Server) resolveCodeLens(ctx context.Context, lens *protocol.CodeLens) (*protocol.CodeLens, error) {
+	// The data field has been round-tripped through the client, and so is
+	// no longer a source.LensData.
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data source.LensData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("decoding code lens data: %v", err)
+	}
+	resolve, ok := source.ResolveLensFuncs()[data.Lens]
+	if !ok {
+		return nil, fmt.Errorf("code lens %q cannot be resolved", data.Lens)
+	}
+	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, data.Args.URI, source.Go)
+	defer release()
+	if !ok {
+		return nil, err
+	}
+	cmd, err := resolve(ctx, snapshot, fh, data.Args)
+	if err != nil {
+		return nil, err
+	}
+	lens.Command = cmd
+	return lens, nil
+}
@@ -23,9 +23,11 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/gocommand"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/debug"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/mod"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/progress"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/work"
 	"github.com/cowpaths/golang-x-tools/internal/span"
 	"github.com/cowpaths/golang-x-tools/internal/xcontext"
 	"golang.org/x/mod/modfile"
@@ -390,6 +392,104 @@ func (c *commandHandler) RunTests(ctx context.Context, args command.RunTestsArgs
 	})
 }
 
+func (c *commandHandler) ProfileBenchmark(ctx context.Context, args command.ProfileBenchmarkArgs) error {
+	return c.run(ctx, commandConfig{
+		async:       true,
+		progress:    "Profiling benchmark",
+		requireSave: true,
+		forURI:      args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		reports, err := source.BenchmarkProfile(ctx, deps.snapshot, deps.fh.URI(), args.Benchmark, source.ProfileKind(args.Kind))
+		if err != nil {
+			return fmt.Errorf("profiling benchmark failed: %w", err)
+		}
+		pkgs, err := deps.snapshot.PackagesForFile(ctx, deps.fh.URI(), source.TypecheckWorkspace, true)
+		if err != nil {
+			return err
+		}
+		c.s.profileHotSpotsMu.Lock()
+		// Replace the results of any earlier profile of the package.
+		for _, pkg := range pkgs {
+			for _, cgf := range pkg.CompiledGoFiles() {
+				delete(c.s.profileHotSpots, cgf.URI)
+			}
+		}
+		for uri, diags := range reports {
+			fh := deps.snapshot.FindFile(uri)
+			if fh == nil {
+				continue
+			}
+			c.s.profileHotSpots[uri] = profileHotSpots{
+				hash:  fh.FileIdentity().Hash,
+				diags: diags,
+			}
+		}
+		c.s.clearDiagnosticSource(profileSource)
+		c.s.profileHotSpotsMu.Unlock()
+		c.s.diagnoseSnapshot(deps.snapshot, nil, false)
+		if len(reports) == 0 {
+			return c.s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Info,
+				Message: fmt.Sprintf("%s: no profile samples in package", args.Benchmark),
+			})
+		}
+		return nil
+	})
+}
+
+func (c *commandHandler) ShowCoverage(ctx context.Context, args command.CoverageArgs) error {
+	return c.run(ctx, commandConfig{
+		async:       true,
+		progress:    "Computing test coverage",
+		requireSave: true,
+		forURI:      args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		coverage, err := source.Coverage(ctx, deps.snapshot, deps.fh.URI(), args.Profile.SpanURI())
+		if err != nil {
+			return fmt.Errorf("computing coverage failed: %w", err)
+		}
+		c.s.coverageMu.Lock()
+		// Replace the results of any earlier coverage of the packages,
+		// which has an entry for each of their files.
+		for uri, cov := range coverage {
+			delete(c.s.coverage, uri)
+			fh := deps.snapshot.FindFile(uri)
+			if fh == nil {
+				continue
+			}
+			c.s.coverage[uri] = fileCoverage{
+				hash: fh.FileIdentity().Hash,
+				cov:  cov,
+			}
+		}
+		c.s.clearDiagnosticSource(coverageSource)
+		c.s.coverageMu.Unlock()
+		c.s.diagnoseSnapshot(deps.snapshot, nil, false)
+		return nil
+	})
+}
+
+func (c *commandHandler) ClearCoverage(ctx context.Context, args command.URIArg) error {
+	return c.run(ctx, commandConfig{
+		forURI: args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		pkgs, err := deps.snapshot.PackagesForFile(ctx, deps.fh.URI(), source.TypecheckWorkspace, true)
+		if err != nil {
+			return err
+		}
+		c.s.coverageMu.Lock()
+		for _, pkg := range pkgs {
+			for _, cgf := range pkg.CompiledGoFiles() {
+				delete(c.s.coverage, cgf.URI)
+			}
+		}
+		c.s.clearDiagnosticSource(coverageSource)
+		c.s.coverageMu.Unlock()
+		c.s.diagnoseSnapshot(deps.snapshot, nil, false)
+		return nil
+	})
+}
+
 func (c *commandHandler) runTests(ctx context.Context, snapshot source.Snapshot, work *progress.WorkDone, uri protocol.DocumentURI, tests, benchmarks []string) error {
 	// TODO: fix the error reporting when this runs async.
 	pkgs, err := snapshot.PackagesForFile(ctx, uri.SpanURI(), source.TypecheckWorkspace, false)
@@ -699,6 +799,17 @@ func (c *commandHandler) GenerateGoplsMod(ctx context.Context, args command.URIA
 	})
 }
 
+func (c *commandHandler) GenerateWorkFile(ctx context.Context, args command.URIArg) error {
+	return c.run(ctx, commandConfig{
+		progress: "Generating go.work",
+	}, func(ctx context.Context, deps commandDeps) error {
+		if err := work.WriteGenerated(args.URI.SpanURI().Filename()); err != nil {
+			return fmt.Errorf("generating go.work: %w", err)
+		}
+		return nil
+	})
+}
+
 func (c *commandHandler) ListKnownPackages(ctx context.Context, args command.URIArg) (command.ListKnownPackagesResult, error) {
 	var result command.ListKnownPackagesResult
 	err := c.run(ctx, commandConfig{
@@ -712,6 +823,18 @@ func (c *commandHandler) ListKnownPackages(ctx context.Context, args command.URI
 	return result, err
 }
 
+func (c *commandHandler) ModuleGraph(ctx context.Context, args command.ModuleGraphArgs) (command.ModuleGraphResult, error) {
+	var result command.ModuleGraphResult
+	err := c.run(ctx, commandConfig{
+		forURI: args.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		reqs, err := mod.ModuleGraph(ctx, deps.snapshot, deps.fh, args.Module, args.Query)
+		result.Requirements = reqs
+		return err
+	})
+	return result, err
+}
+
 func (c *commandHandler) ListImports(ctx context.Context, args command.URIArg) (command.ListImportsResult, error) {
 	var result command.ListImportsResult
 	err := c.run(ctx, commandConfig{
@@ -753,6 +876,24 @@ func (c *commandHandler) ListImports(ctx context.Context, args command.URIArg) (
 	return result, err
 }
 
+func (c *commandHandler) References(ctx context.Context, args command.PositionArgs) ([]protocol.Location, error) {
+	return c.s.references(ctx, &protocol.ReferenceParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: args.URI},
+			Position:     args.Position,
+		},
+	})
+}
+
+func (c *commandHandler) Implementations(ctx context.Context, args command.PositionArgs) ([]protocol.Location, error) {
+	return c.s.implementation(ctx, &protocol.ImplementationParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: args.URI},
+			Position:     args.Position,
+		},
+	})
+}
+
 func (c *commandHandler) AddImport(ctx context.Context, args command.AddImportArgs) error {
 	return c.run(ctx, commandConfig{
 		progress: "Adding import",
@@ -23,17 +23,24 @@ const (
 	AddImport         Command = "add_import"
 	ApplyFix          Command = "apply_fix"
 	CheckUpgrades     Command = "check_upgrades"
+	ClearCoverage     Command = "clear_coverage"
 	EditGoDirective   Command = "edit_go_directive"
 	GCDetails         Command = "gc_details"
 	Generate          Command = "generate"
 	GenerateGoplsMod  Command = "generate_gopls_mod"
+	GenerateWorkFile  Command = "generate_work_file"
 	GoGetPackage      Command = "go_get_package"
+	Implementations   Command = "implementations"
 	ListImports       Command = "list_imports"
 	ListKnownPackages Command = "list_known_packages"
+	ModuleGraph       Command = "module_graph"
+	ProfileBenchmark  Command = "profile_benchmark"
+	References        Command = "references"
 	RegenerateCgo     Command = "regenerate_cgo"
 	RemoveDependency  Command = "remove_dependency"
 	RunTests          Command = "run_tests"
 	RunVulncheckExp   Command = "run_vulncheck_exp"
+	ShowCoverage      Command = "show_coverage"
 	StartDebugging    Command = "start_debugging"
 	Test              Command = "test"
 	Tidy              Command = "tidy"
@@ -48,17 +55,24 @@ var Commands = []Command{
 	AddImport,
 	ApplyFix,
 	CheckUpgrades,
+	ClearCoverage,
 	EditGoDirective,
 	GCDetails,
 	Generate,
 	GenerateGoplsMod,
+	GenerateWorkFile,
 	GoGetPackage,
+	Implementations,
 	ListImports,
 	ListKnownPackages,
+	ModuleGraph,
+	ProfileBenchmark,
+	References,
 	RegenerateCgo,
 	RemoveDependency,
 	RunTests,
 	RunVulncheckExp,
+	ShowCoverage,
 	StartDebugging,
 	Test,
 	Tidy,
@@ -94,6 +108,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.CheckUpgrades(ctx, a0)
+	case "gopls.clear_coverage":
+		var a0 URIArg
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.ClearCoverage(ctx, a0)
 	case "gopls.edit_go_directive":
 		var a0 EditGoDirectiveArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -118,12 +138,24 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.GenerateGoplsMod(ctx, a0)
+	case "gopls.generate_work_file":
+		var a0 URIArg
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.GenerateWorkFile(ctx, a0)
 	case "gopls.go_get_package":
 		var a0 GoGetPackageArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
 			return nil, err
 		}
 		return nil, s.GoGetPackage(ctx, a0)
+	case "gopls.implementations":
+		var a0 PositionArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.Implementations(ctx, a0)
 	case "gopls.list_imports":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -136,6 +168,24 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return s.ListKnownPackages(ctx, a0)
+	case "gopls.module_graph":
+		var a0 ModuleGraphArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.ModuleGraph(ctx, a0)
+	case "gopls.profile_benchmark":
+		var a0 ProfileBenchmarkArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.ProfileBenchmark(ctx, a0)
+	case "gopls.references":
+		var a0 PositionArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.References(ctx, a0)
 	case "gopls.regenerate_cgo":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -160,6 +210,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return s.RunVulncheckExp(ctx, a0)
+	case "gopls.show_coverage":
+		var a0 CoverageArgs
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.ShowCoverage(ctx, a0)
 	case "gopls.start_debugging":
 		var a0 DebuggingArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -256,6 +312,18 @@ func NewCheckUpgradesCommand(title string, a0 CheckUpgradesArgs) (protocol.Comma
 	}, nil
 }
 
+func NewClearCoverageCommand(title string, a0 URIArg) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.clear_coverage",
+		Arguments: args,
+	}, nil
+}
+
 func NewEditGoDirectiveCommand(title string, a0 EditGoDirectiveArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -304,6 +372,18 @@ func NewGenerateGoplsModCommand(title string, a0 URIArg) (protocol.Command, erro
 	}, nil
 }
 
+func NewGenerateWorkFileCommand(title string, a0 URIArg) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.generate_work_file",
+		Arguments: args,
+	}, nil
+}
+
 func NewGoGetPackageCommand(title string, a0 GoGetPackageArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -316,6 +396,18 @@ func NewGoGetPackageCommand(title string, a0 GoGetPackageArgs) (protocol.Command
 	}, nil
 }
 
+func NewImplementationsCommand(title string, a0 PositionArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.implementations",
+		Arguments: args,
+	}, nil
+}
+
 func NewListImportsCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -340,6 +432,42 @@ func NewListKnownPackagesCommand(title string, a0 URIArg) (protocol.Command, err
 	}, nil
 }
 
+func NewModuleGraphCommand(title string, a0 ModuleGraphArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.module_graph",
+		Arguments: args,
+	}, nil
+}
+
+func NewProfileBenchmarkCommand(title string, a0 ProfileBenchmarkArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.profile_benchmark",
+		Arguments: args,
+	}, nil
+}
+
+func NewReferencesCommand(title string, a0 PositionArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.references",
+		Arguments: args,
+	}, nil
+}
+
 func NewRegenerateCgoCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -388,6 +516,18 @@ func NewRunVulncheckExpCommand(title string, a0 VulncheckArgs) (protocol.Command
 	}, nil
 }
 
+func NewShowCoverageCommand(title string, a0 CoverageArgs) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.show_coverage",
+		Arguments: args,
+	}, nil
+}
+
 func NewStartDebuggingCommand(title string, a0 DebuggingArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -48,6 +48,23 @@ type Interface interface {
 	// Runs `go test` for a specific set of test or benchmark functions.
 	RunTests(context.Context, RunTestsArgs) error
 
+	// ProfileBenchmark: Profile a benchmark
+	//
+	// Runs a benchmark with CPU or memory profiling, and annotates the
+	// hottest lines of its package with hint diagnostics.
+	ProfileBenchmark(context.Context, ProfileBenchmarkArgs) error
+
+	// ShowCoverage: Show test coverage
+	//
+	// Runs `go test -coverprofile` for a package, or reads an existing
+	// coverage profile, and annotates the blocks that were not executed.
+	ShowCoverage(context.Context, CoverageArgs) error
+
+	// ClearCoverage: Clear test coverage
+	//
+	// Clears the test coverage annotations of a package.
+	ClearCoverage(context.Context, URIArg) error
+
 	// Generate: Run go generate
 	//
 	// Runs `go generate` for a given directory.
@@ -98,6 +115,12 @@ type Interface interface {
 	// Removes a dependency from the go.mod file of a module.
 	RemoveDependency(context.Context, RemoveDependencyArgs) error
 
+	// ModuleGraph: Show the module graph
+	//
+	// Returns the requirements of the module graph of a go.mod file, as
+	// printed by `go mod graph`, that are relevant to a module.
+	ModuleGraph(context.Context, ModuleGraphArgs) (ModuleGraphResult, error)
+
 	// GoGetPackage: go get a package
 	//
 	// Runs `go get` to fetch a package.
@@ -120,6 +143,12 @@ type Interface interface {
 	// (Re)generate the gopls.mod file for a workspace.
 	GenerateGoplsMod(context.Context, URIArg) error
 
+	// GenerateWorkFile: Generate go.work
+	//
+	// Generates a go.work file in a directory that uses all the modules in
+	// and beneath it. The directory must not already have a go.work file.
+	GenerateWorkFile(context.Context, URIArg) error
+
 	// ListKnownPackages: List known packages
 	//
 	// Retrieve a list of packages that are importable from the given URI.
@@ -131,6 +160,16 @@ type Interface interface {
 	// belongs to.
 	ListImports(context.Context, URIArg) (ListImportsResult, error)
 
+	// References: Show references
+	//
+	// Shows the references to a top-level declaration.
+	References(context.Context, PositionArgs) ([]protocol.Location, error)
+
+	// Implementations: Show implementations
+	//
+	// Shows the implementations of an interface or type.
+	Implementations(context.Context, PositionArgs) ([]protocol.Location, error)
+
 	// AddImport: Add an import
 	//
 	// Ask the server to add an import path to a given Go file.  The method will
@@ -161,6 +200,27 @@ type RunTestsArgs struct {
 	Benchmarks []string
 }
 
+type ProfileBenchmarkArgs struct {
+	// The test file containing the benchmark to profile.
+	URI protocol.DocumentURI
+
+	// The benchmark to profile, e.g. BenchmarkFoo.
+	Benchmark string
+
+	// The kind of profile to collect: "cpu" (the default) or "mem".
+	Kind string
+}
+
+type CoverageArgs struct {
+	// Any file in the package to test.
+	URI protocol.DocumentURI
+
+	// Optional: an existing coverage profile to read, in the format written
+	// by `go test -coverprofile`. If set, no tests are run, and coverage is
+	// shown for all workspace packages described by the profile.
+	Profile protocol.DocumentURI
+}
+
 type GenerateArgs struct {
 	// URI for the directory to generate.
 	Dir protocol.DocumentURI
@@ -185,6 +245,13 @@ type URIArg struct {
 	URI protocol.DocumentURI
 }
 
+type PositionArgs struct {
+	// The file URI.
+	URI protocol.DocumentURI
+	// The position of the declaring identifier.
+	Position protocol.Position
+}
+
 type URIArgs struct {
 	// The file URIs.
 	URIs []protocol.DocumentURI
@@ -238,6 +305,25 @@ type AddImportArgs struct {
 	URI protocol.DocumentURI
 }
 
+type ModuleGraphArgs struct {
+	// The go.mod file.
+	URI protocol.DocumentURI
+	// The module path, optionally followed by @ and a version. Without a
+	// version, the version selected by minimal version selection is used.
+	Module string
+	// The query, named after those of cmd/digraph: "somepath" for the
+	// shortest chain of requirements from a main module to the module,
+	// "reverse" for all the requirements through which the module is
+	// reached, or empty for the requirements of the module and those on it.
+	Query string
+}
+
+type ModuleGraphResult struct {
+	// Requirements in the format of `go mod graph`: a module and a module
+	// it requires, separated by a space.
+	Requirements []string
+}
+
 type ListKnownPackagesResult struct {
 	// Packages is a list of packages relative
 	// to the URIArg passed by the command request.
@@ -904,6 +904,17 @@ Folder: <b>{{.Folder}}</b><br>
 From: <b>{{template "sessionlink" .Session.ID}}</b><br>
 <h2>Environment</h2>
 <ul>{{range .Options.Env}}<li>{{.}}</li>{{end}}</ul>
+{{with .Residency}}
+<h2>Package memory</h2>
+Budget: <b>{{if .Budget}}{{.Budget}} bytes{{else}}unlimited{{end}}</b><br>
+Resident: <b>{{.Resident}} bytes</b><br>
+Evicted: <b>{{.Evicted}} packages, {{.Exported}} bytes of export data</b><br>
+<table>
+<tr><th>Package</th><th>Size</th><th>State</th></tr>
+{{range .Packages}}<tr><td>{{.ID}}</td><td>{{.Size}}</td><td>{{if .Evicted}}evicted ({{.ExportSize}} bytes of export data){{else}}resident{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
 {{end}}
 `))
 
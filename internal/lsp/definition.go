@@ -20,7 +20,7 @@ func (s *Server) definition(ctx context.Context, params *protocol.DefinitionPara
 		return nil, err
 	}
 	if snapshot.View().FileKind(fh) == source.Tmpl {
-		return template.Definition(snapshot, fh, params.Position)
+		return template.Definition(ctx, snapshot, fh, params.Position)
 	}
 	ident, err := source.Identifier(ctx, snapshot, fh, params.Position)
 	if err != nil {
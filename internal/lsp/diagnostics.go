@@ -37,6 +37,11 @@ const (
 	typeCheckSource
 	orphanedSource
 	workSource
+	profileSource
+	coverageSource
+	vulncheckSource
+	buildConfigSource
+	programAnalysisSource
 )
 
 // A diagnosticReport holds results for a single diagnostic source.
@@ -46,6 +51,21 @@ type diagnosticReport struct {
 	diags         map[string]*source.Diagnostic
 }
 
+// profileHotSpots holds the hint diagnostics produced for a single file by
+// profiling a benchmark, along with the hash of the file content they
+// describe.
+type profileHotSpots struct {
+	hash  source.Hash
+	diags []*source.Diagnostic
+}
+
+// fileCoverage holds the test coverage of a single file, along with the hash
+// of the file content it describes.
+type fileCoverage struct {
+	hash source.Hash
+	cov  *source.FileCoverage
+}
+
 // fileReports holds a collection of diagnostic reports for a single file, as
 // well as the hash of the last published set of diagnostics.
 type fileReports struct {
@@ -68,6 +88,16 @@ func (d diagnosticSource) String() string {
 		return "FromOrphans"
 	case workSource:
 		return "FromGoWork"
+	case profileSource:
+		return "FromProfile"
+	case coverageSource:
+		return "FromCoverage"
+	case vulncheckSource:
+		return "FromVulncheck"
+	case buildConfigSource:
+		return "FromBuildConfig"
+	case programAnalysisSource:
+		return "FromProgramAnalysis"
 	default:
 		return fmt.Sprintf("From?%d?", d)
 	}
@@ -230,6 +260,24 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, forceAn
 		}
 		s.storeDiagnostics(snapshot, id.URI, workSource, diags)
 	}
+	// Diagnose open Go files in modules that the go.work file does not use.
+	if snapshot.WorkFile() != "" {
+		for _, o := range s.session.Overlays() {
+			if v, err := s.session.ViewOf(o.URI()); err != nil || v != snapshot.View() {
+				continue // diagnosed by the view of the file
+			}
+			fh, err := snapshot.GetVersionedFile(ctx, o.URI())
+			if err != nil {
+				continue
+			}
+			diags, err := work.DiagnosticsForFile(ctx, snapshot, fh)
+			if err != nil {
+				event.Error(ctx, "warning: diagnose go.work use list", err, tag.URI.Of(o.URI()))
+				continue
+			}
+			s.storeDiagnostics(snapshot, o.URI(), workSource, diags)
+		}
+	}
 
 	// Diagnose all of the packages in the workspace.
 	wsPkgs, err := snapshot.ActivePackages(ctx)
@@ -246,7 +294,7 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, forceAn
 
 	// There may be .tmpl files.
 	for _, f := range snapshot.Templates() {
-		diags := template.Diagnose(f)
+		diags := template.Diagnose(ctx, snapshot, f)
 		s.storeDiagnostics(snapshot, f.URI(), typeCheckSource, diags)
 	}
 
@@ -256,10 +304,33 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, forceAn
 		return
 	}
 
+	// Checking for vulnerabilities runs govulncheck on the whole program,
+	// which may take much longer than the rest of the diagnosis, so its
+	// results are published on their own once they are ready.
+	go func() {
+		if s.diagnoseVulnerabilities(ctx, snapshot) {
+			s.publishDiagnostics(ctx, false, snapshot)
+		}
+	}()
+
 	var (
 		wg   sync.WaitGroup
 		seen = map[span.URI]struct{}{}
 	)
+	// The whole-program analyses of the workspace packages run alongside
+	// the diagnosis of the packages.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.diagnoseProgram(ctx, snapshot, wsPkgs)
+	}()
+	// So is loading the packages in the additional build configurations.
+	var configured map[span.URI]bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		configured = s.diagnoseBuildConfigs(ctx, snapshot)
+	}()
 	for _, pkg := range wsPkgs {
 		wg.Add(1)
 
@@ -278,7 +349,7 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, forceAn
 	// Confirm that every opened file belongs to a package (if any exist in
 	// the workspace). Otherwise, add a diagnostic to the file.
 	for _, o := range s.session.Overlays() {
-		if _, ok := seen[o.URI()]; ok {
+		if _, ok := seen[o.URI()]; ok || configured[o.URI()] {
 			continue
 		}
 		diagnostic := s.checkForOrphanedFile(ctx, snapshot, o)
@@ -356,6 +427,110 @@ func (s *Server) diagnosePkg(ctx context.Context, snapshot source.Snapshot, pkg
 		}
 		s.gcOptimizationDetailsMu.Unlock()
 	}
+
+	// Add any benchmark profile hot spots that still describe the current
+	// file contents.
+	s.profileHotSpotsMu.Lock()
+	for _, cgf := range pkg.CompiledGoFiles() {
+		hotSpots, ok := s.profileHotSpots[cgf.URI]
+		if !ok {
+			continue
+		}
+		fh := snapshot.FindFile(cgf.URI)
+		if fh == nil || !fh.Saved() || fh.FileIdentity().Hash != hotSpots.hash {
+			continue
+		}
+		s.storeDiagnostics(snapshot, cgf.URI, profileSource, hotSpots.diags)
+	}
+	s.profileHotSpotsMu.Unlock()
+
+	// Add test coverage annotations, as long as they still describe the
+	// current file contents.
+	s.coverageMu.Lock()
+	for _, cgf := range pkg.CompiledGoFiles() {
+		fc, ok := s.coverage[cgf.URI]
+		if !ok {
+			continue
+		}
+		fh := snapshot.FindFile(cgf.URI)
+		if fh == nil || !fh.Saved() || fh.FileIdentity().Hash != fc.hash {
+			continue
+		}
+		s.storeDiagnostics(snapshot, cgf.URI, coverageSource, fc.cov.Uncovered)
+	}
+	s.coverageMu.Unlock()
+}
+
+// diagnoseVulnerabilities stores the diagnostics for the vulnerabilities
+// that govulncheck finds in the view, if the vulncheck option is set, and
+// reports whether it stored any.
+func (s *Server) diagnoseVulnerabilities(ctx context.Context, snapshot source.Snapshot) bool {
+	reports, err := mod.VulnerabilityDiagnostics(ctx, snapshot)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		event.Error(ctx, "warning: diagnose vulnerabilities", err, tag.Directory.Of(snapshot.View().Folder().Filename()), tag.Snapshot.Of(snapshot.ID()))
+		return false
+	}
+	for id, diags := range reports {
+		s.storeDiagnostics(snapshot, id.URI, vulncheckSource, diags)
+	}
+	return len(reports) > 0
+}
+
+// diagnoseProgram stores the diagnostics of the whole-program analyzers
+// on the workspace packages without list or parse errors.
+func (s *Server) diagnoseProgram(ctx context.Context, snapshot source.Snapshot, wsPkgs []source.Package) {
+	var pkgs []source.Package
+	for _, pkg := range wsPkgs {
+		if !pkg.HasListOrParseErrors() {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	reports, err := source.AnalyzeProgram(ctx, snapshot, pkgs)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		event.Error(ctx, "warning: analyzing program", err, tag.Snapshot.Of(snapshot.ID()))
+		return
+	}
+	// Store the diagnostics of every file, even if there are none, to
+	// clear those of an earlier snapshot.
+	for _, pkg := range pkgs {
+		for _, cgf := range pkg.CompiledGoFiles() {
+			if !snapshot.IgnoredFile(cgf.URI) && !snapshot.IsBuiltin(ctx, cgf.URI) {
+				s.storeDiagnostics(snapshot, cgf.URI, programAnalysisSource, reports[cgf.URI])
+			}
+		}
+	}
+}
+
+// diagnoseBuildConfigs stores the diagnostics of the files that are compiled
+// only in the build configurations of the buildConfigurations option, and
+// returns the set of those files.
+func (s *Server) diagnoseBuildConfigs(ctx context.Context, snapshot source.Snapshot) map[span.URI]bool {
+	reports := make(map[span.URI][]*source.Diagnostic)
+	for _, config := range snapshot.View().Options().BuildConfigurations {
+		diagnostics, err := snapshot.BuildConfigDiagnostics(ctx, config)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			event.Error(ctx, "warning: diagnose build configuration "+config, err, tag.Directory.Of(snapshot.View().Folder().Filename()), tag.Snapshot.Of(snapshot.ID()))
+			continue
+		}
+		for uri, diags := range diagnostics {
+			reports[uri] = append(reports[uri], diags...)
+		}
+	}
+	configured := make(map[span.URI]bool)
+	for uri, diags := range reports {
+		configured[uri] = true
+		s.storeDiagnostics(snapshot, uri, buildConfigSource, diags)
+	}
+	return configured
 }
 
 // storeDiagnostics stores results from a single diagnostic source. If merge is
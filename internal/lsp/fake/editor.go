@@ -1017,6 +1017,14 @@ func (e *Editor) CodeLens(ctx context.Context, path string) ([]protocol.CodeLens
 	return lens, nil
 }
 
+// ResolveCodeLens executes a codeLens/resolve request on the server.
+func (e *Editor) ResolveCodeLens(ctx context.Context, lens protocol.CodeLens) (*protocol.CodeLens, error) {
+	if e.Server == nil {
+		return nil, nil
+	}
+	return e.Server.ResolveCodeLens(ctx, &lens)
+}
+
 // Completion executes a completion request on the server.
 func (e *Editor) Completion(ctx context.Context, path string, pos Pos) (*protocol.CompletionList, error) {
 	if e.Server == nil {
@@ -1229,6 +1237,17 @@ func (e *Editor) Hover(ctx context.Context, path string, pos Pos) (*protocol.Mar
 	return &resp.Contents, fromProtocolPosition(resp.Range.Start), nil
 }
 
+// SignatureHelp executes a signatureHelp request on the server.
+func (e *Editor) SignatureHelp(ctx context.Context, path string, pos Pos) (*protocol.SignatureHelp, error) {
+	if err := e.checkBufferPosition(path, pos); err != nil {
+		return nil, err
+	}
+	params := &protocol.SignatureHelpParams{}
+	params.TextDocument.URI = e.sandbox.Workdir.URI(path)
+	params.Position = pos.ToProtocolPosition()
+	return e.Server.SignatureHelp(ctx, params)
+}
+
 func (e *Editor) DocumentLink(ctx context.Context, path string) ([]protocol.DocumentLink, error) {
 	if e.Server == nil {
 		return nil, nil
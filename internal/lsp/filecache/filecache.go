@@ -0,0 +1,200 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The filecache package provides a file-based, persistent,
+// content-addressed key/value cache, shared by all the gopls processes
+// of a user.
+//
+// Values are stored in files named by their kind and key, under the
+// directory named by the GOPLSCACHE environment variable, or by default
+// the gopls/filecache subdirectory of os.UserCacheDir. Each file is
+// written to a temporary file and then renamed, and ends with a
+// checksum of its content, so that concurrent readers and writers, in
+// one process or several, never observe a partial value. Keys are
+// expected to be hashes of all the inputs of the computation of the
+// value, so a value is never updated, only replaced by an identical one.
+//
+// The cache is bounded by a budget (see SetBudget): a background
+// goroutine evicts the least recently used files that exceed it.
+package filecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound is the error returned by Get when the cache has no value
+// for the key.
+var ErrNotFound = errors.New("not found")
+
+// Get returns the value of the given kind for the key, or ErrNotFound.
+// A corrupt or truncated file is treated as a missing one.
+func Get(kind string, key [32]byte) ([]byte, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	name := filename(dir, kind, key)
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if len(data) < sha256.Size {
+		return nil, ErrNotFound
+	}
+	value, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if want := sha256.Sum256(value); !bytes.Equal(sum, want[:]) {
+		return nil, ErrNotFound
+	}
+
+	// Record the use of the file for the eviction of the least recently
+	// used ones. The modification time is updated at most once per
+	// hour, to avoid a write for each read.
+	if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > time.Hour {
+		now := time.Now()
+		os.Chtimes(name, now, now) // ignore error
+	}
+	return value, nil
+}
+
+// Set stores the value of the given kind for the key.
+func Set(kind string, key [32]byte, value []byte) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	startGC.Do(func() { go gcLoop(dir) })
+
+	name := filename(dir, kind, key)
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(value)
+	_, err = tmp.Write(value)
+	if err == nil {
+		_, err = tmp.Write(sum[:])
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// The rename is atomic, so readers see either no file or the
+		// complete one. On Windows it may fail if a reader has the
+		// file open, in which case the existing file holds the same
+		// value anyway.
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name()) // ignore error
+		return err
+	}
+	return nil
+}
+
+// filename returns the name of the file holding the value of the given
+// kind for the key. Files are spread over 256 directories per kind.
+func filename(dir, kind string, key [32]byte) string {
+	hex := fmt.Sprintf("%x", key)
+	return filepath.Join(dir, kind, hex[:2], hex)
+}
+
+// cacheDir returns the directory of the cache.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("GOPLSCACHE"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopls", "filecache"), nil
+}
+
+// budget is the maximum total size in bytes of the files of the cache.
+var budget int64 = 1e9
+
+// SetBudget sets the maximum total size in bytes of the files of the
+// cache, and returns the previous one. The files exceeding it are
+// evicted in the background, least recently used first.
+func SetBudget(new int64) (old int64) {
+	return atomic.SwapInt64(&budget, new)
+}
+
+var startGC sync.Once
+
+// gcInterval is the interval between two collections of the cache by
+// the process.
+const gcInterval = time.Minute
+
+func gcLoop(dir string) {
+	for {
+		gc(dir, atomic.LoadInt64(&budget))
+		time.Sleep(gcInterval)
+	}
+}
+
+// gc removes the least recently used files of the cache until their total
+// size is within the budget, along with the temporary files left over by
+// processes that were interrupted while writing.
+func gc(dir string, budget int64) {
+	type file struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+	var (
+		files []file
+		total int64
+	)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil // keep going
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed concurrently
+		}
+		if strings.Contains(d.Name(), ".tmp") {
+			if time.Since(info.ModTime()) > time.Hour {
+				os.Remove(path) // ignore error
+			}
+			return nil
+		}
+		files = append(files, file{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if total <= budget {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime.Before(files[j].mtime)
+	})
+	for _, f := range files {
+		if total <= budget {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+}
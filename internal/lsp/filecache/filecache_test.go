@@ -0,0 +1,120 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGetSet(t *testing.T) {
+	t.Setenv("GOPLSCACHE", t.TempDir())
+
+	key := sha256.Sum256([]byte("key"))
+	if _, err := Get("test", key); err != ErrNotFound {
+		t.Fatalf("Get of missing key returned error %v, want ErrNotFound", err)
+	}
+	if err := Set("test", key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Get("test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "value" {
+		t.Errorf("Get returned %q, want %q", got, "value")
+	}
+	if _, err := Get("other", key); err != ErrNotFound {
+		t.Errorf("Get of another kind returned error %v, want ErrNotFound", err)
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOPLSCACHE", dir)
+
+	key := sha256.Sum256([]byte("key"))
+	if err := Set("test", key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	name := filename(dir, "test", key)
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, data[:len(data)-1], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get("test", key); err != ErrNotFound {
+		t.Errorf("Get of truncated file returned error %v, want ErrNotFound", err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	t.Setenv("GOPLSCACHE", t.TempDir())
+
+	key := sha256.Sum256([]byte("key"))
+	value := bytes.Repeat([]byte("value"), 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := Set("test", key, value); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			got, err := Get("test", key)
+			if err == ErrNotFound {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, value) {
+				t.Errorf("Get returned a value of %d bytes, want %d", len(got), len(value))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOPLSCACHE", dir)
+
+	value := make([]byte, 100)
+	var keys [][32]byte
+	for i := 0; i < 10; i++ {
+		key := sha256.Sum256([]byte(fmt.Sprint(i)))
+		if err := Set("test", key, value); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	// Each file holds the value and its checksum.
+	size := int64(len(value) + sha256.Size)
+	gc(dir, 5*size)
+
+	var count int
+	for _, key := range keys {
+		if _, err := os.Stat(filename(dir, "test", key)); err == nil {
+			count++
+		}
+	}
+	if count != 5 {
+		t.Errorf("%d files remain after collection, want 5", count)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "test", "*", "*.tmp*")); len(matches) > 0 {
+		t.Errorf("temporary files remain: %v", matches)
+	}
+}
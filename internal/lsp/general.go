@@ -137,6 +137,9 @@ See https://github.com/golang/go/issues/45732 for more information.`,
 		Capabilities: protocol.ServerCapabilities{
 			CallHierarchyProvider: true,
 			CodeActionProvider:    codeActionProvider,
+			CodeLensProvider: protocol.CodeLensOptions{
+				ResolveProvider: true,
+			},
 			CompletionProvider: protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
 			},
@@ -6,6 +6,7 @@ package lsp
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/mod"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
@@ -24,7 +25,11 @@ func (s *Server) hover(ctx context.Context, params *protocol.HoverParams) (*prot
 	case source.Mod:
 		return mod.Hover(ctx, snapshot, fh, params.Position)
 	case source.Go:
-		return source.Hover(ctx, snapshot, fh, params.Position)
+		hover, err := source.Hover(ctx, snapshot, fh, params.Position)
+		if err != nil {
+			return nil, err
+		}
+		return s.addCoverage(fh, params.Position, hover), nil
 	case source.Tmpl:
 		return template.Hover(ctx, snapshot, fh, params.Position)
 	case source.Work:
@@ -32,3 +37,24 @@ func (s *Server) hover(ctx context.Context, params *protocol.HoverParams) (*prot
 	}
 	return nil, nil
 }
+
+// addCoverage adds the test coverage of the function whose declaration
+// contains pos, if known, to a hover result.
+func (s *Server) addCoverage(fh source.FileHandle, pos protocol.Position, hover *protocol.Hover) *protocol.Hover {
+	if hover == nil {
+		return nil
+	}
+	s.coverageMu.Lock()
+	fc, ok := s.coverage[fh.URI()]
+	s.coverageMu.Unlock()
+	if !ok || fc.hash != fh.FileIdentity().Hash {
+		return hover
+	}
+	for _, fn := range fc.cov.Funcs {
+		if protocol.ComparePosition(fn.Range.Start, pos) <= 0 && protocol.ComparePosition(pos, fn.Range.End) <= 0 {
+			hover.Contents.Value += fmt.Sprintf("\n\nTest coverage of %s: %.1f%% of statements", fn.Name, fn.Percent())
+			break
+		}
+	}
+	return hover
+}
@@ -935,10 +935,41 @@ func (r *runner) References(t *testing.T, src span.Span, itemList []span.Span) {
 	}
 }
 
-func (r *runner) InlayHints(t *testing.T, spn span.Span) {
+func (r *runner) InlayHints(t *testing.T, spn span.Span, enabled []string) {
 	uri := spn.URI()
 	filename := uri.Filename()
 
+	// Enable the hints named by the test, or else all the hints that no
+	// other test names, so that each golden shows only the hints it tests.
+	if len(enabled) == 0 {
+		named := make(map[string]bool)
+		for _, hints := range r.data.InlayHints {
+			for _, hint := range hints {
+				named[hint] = true
+			}
+		}
+		for name := range source.AllInlayHints {
+			if !named[name] {
+				enabled = append(enabled, name)
+			}
+		}
+	}
+	view, err := r.server.session.ViewOf(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := view.Options()
+	modified := original.Clone()
+	modified.Hints = make(map[string]bool)
+	for _, name := range enabled {
+		modified.Hints[name] = true
+	}
+	view, err = view.SetOptions(r.ctx, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.SetOptions(r.ctx, original)
+
 	hints, err := r.server.InlayHint(r.ctx, &protocol.InlayHintParams{
 		TextDocument: protocol.TextDocumentIdentifier{
 			URI: protocol.URIFromSpanURI(uri),
@@ -0,0 +1,194 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Queries of the module graph, named after those of cmd/digraph.
+const (
+	// QuerySomepath finds the shortest chain of requirements from a main
+	// module to the module.
+	QuerySomepath = "somepath"
+	// QueryReverse finds all the requirements through which the module
+	// is reached.
+	QueryReverse = "reverse"
+)
+
+// ModuleGraph returns the requirements of the module graph of the go.mod
+// file that match query for the target module, which is a module path,
+// optionally followed by @ and a version; without a version, it is the
+// version selected by minimal version selection. An empty query matches the
+// requirements of the target and those on it. The requirements are in the
+// format of `go mod graph`, and are sorted.
+func ModuleGraph(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, target, query string) ([]string, error) {
+	edges, err := snapshot.ModGraph(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	node := module.Version{Path: target}
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		node = module.Version{Path: target[:i], Version: target[i+1:]}
+	} else {
+		selected := selectedVersions(edges)
+		v, ok := selected[target]
+		if !ok {
+			return nil, fmt.Errorf("module %s is not in the module graph", target)
+		}
+		node.Version = v
+	}
+	var matched []source.ModuleEdge
+	switch query {
+	case "":
+		for _, e := range edges {
+			if e.From == node || e.To == node {
+				matched = append(matched, e)
+			}
+		}
+	case QuerySomepath:
+		matched = somepath(edges, node)
+		if matched == nil {
+			return nil, fmt.Errorf("no path from a main module to %s", modString(node))
+		}
+	case QueryReverse:
+		matched = reverse(edges, node)
+	default:
+		return nil, fmt.Errorf("unknown module graph query %q", query)
+	}
+	var lines []string
+	for _, e := range matched {
+		lines = append(lines, modString(e.From)+" "+modString(e.To))
+	}
+	if query != QuerySomepath { // a path is in order
+		sort.Strings(lines)
+	}
+	return lines, nil
+}
+
+// modString formats m as `go mod graph` does.
+func modString(m module.Version) string {
+	if m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
+}
+
+// selectedVersions returns the version of each module selected by minimal
+// version selection, which is the highest version of it in the graph. Main
+// modules have no version.
+func selectedVersions(edges []source.ModuleEdge) map[string]string {
+	selected := make(map[string]string)
+	add := func(m module.Version) {
+		v, ok := selected[m.Path]
+		if !ok || v != "" && semver.Compare(m.Version, v) > 0 {
+			selected[m.Path] = m.Version
+		}
+	}
+	for _, e := range edges {
+		if e.From.Version == "" {
+			selected[e.From.Path] = ""
+		}
+	}
+	for _, e := range edges {
+		add(e.From)
+		add(e.To)
+	}
+	return selected
+}
+
+// somepath returns the requirements along the shortest path from a main
+// module to node, or nil if there is none.
+func somepath(edges []source.ModuleEdge, node module.Version) []source.ModuleEdge {
+	succs := make(map[module.Version][]source.ModuleEdge)
+	var queue []module.Version
+	seen := make(map[module.Version]bool)
+	for _, e := range edges {
+		succs[e.From] = append(succs[e.From], e)
+		if e.From.Version == "" && !seen[e.From] {
+			seen[e.From] = true
+			queue = append(queue, e.From)
+		}
+	}
+	via := make(map[module.Version]source.ModuleEdge)
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		if m == node {
+			var path []source.ModuleEdge
+			for m.Version != "" {
+				e := via[m]
+				path = append([]source.ModuleEdge{e}, path...)
+				m = e.From
+			}
+			return path
+		}
+		for _, e := range succs[m] {
+			if !seen[e.To] {
+				seen[e.To] = true
+				via[e.To] = e
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	return nil
+}
+
+// reverse returns the requirements among the modules from which node is
+// reachable, and node itself.
+func reverse(edges []source.ModuleEdge, node module.Version) []source.ModuleEdge {
+	preds := make(map[module.Version][]module.Version)
+	for _, e := range edges {
+		preds[e.To] = append(preds[e.To], e.From)
+	}
+	reaches := map[module.Version]bool{node: true}
+	queue := []module.Version{node}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		for _, p := range preds[m] {
+			if !reaches[p] {
+				reaches[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	var matched []source.ModuleEdge
+	for _, e := range edges {
+		if reaches[e.From] && reaches[e.To] {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// requiredBy returns the modules in the build list that require path, and
+// the versions they require, sorted by module.
+func requiredBy(edges []source.ModuleEdge, selected map[string]string, path string) []source.ModuleEdge {
+	var reqs []source.ModuleEdge
+	for _, e := range edges {
+		if e.To.Path != path {
+			continue
+		}
+		if v, ok := selected[e.From.Path]; !ok || v != e.From.Version {
+			continue // not in the build list
+		}
+		reqs = append(reqs, e)
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		if reqs[i].From.Version == "" != (reqs[j].From.Version == "") {
+			return reqs[i].From.Version == "" // main modules first
+		}
+		return reqs[i].From.Path < reqs[j].From.Path
+	})
+	return reqs
+}
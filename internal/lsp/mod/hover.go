@@ -88,6 +88,11 @@ func Hover(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle,
 	options := snapshot.View().Options()
 	isPrivate := snapshot.View().IsGoPrivatePath(req.Mod.Path)
 	explanation = formatExplanation(explanation, req, options, isPrivate)
+	if edges, err := snapshot.ModGraph(ctx, fh); err != nil {
+		event.Error(ctx, "computing module graph", err)
+	} else {
+		explanation += formatRequirers(edges, req)
+	}
 	return &protocol.Hover{
 		Contents: protocol.MarkupContent{
 			Kind:  options.PreferredContentFormat,
@@ -155,3 +160,26 @@ func formatExplanation(text string, req *modfile.Require, options *source.Option
 	b.WriteString("\n```")
 	return b.String()
 }
+
+// formatRequirers describes the version of the required module selected by
+// minimal version selection, and the modules in the build list that require
+// it, at the versions they require.
+func formatRequirers(edges []source.ModuleEdge, req *modfile.Require) string {
+	selected := selectedVersions(edges)
+	v, ok := selected[req.Mod.Path]
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Selected version: %s", v)
+	reqs := requiredBy(edges, selected, req.Mod.Path)
+	if len(reqs) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n\nRequired by:")
+	for _, e := range reqs {
+		fmt.Fprintf(&b, "\n- %s at %s", modString(e.From), e.To.Version)
+	}
+	return b.String()
+}
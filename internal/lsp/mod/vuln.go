@@ -0,0 +1,261 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/internal/event"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+	"golang.org/x/mod/semver"
+)
+
+// VulnerabilityDiagnostics returns the diagnostics for the vulnerabilities
+// that govulncheck finds in the packages of the view: on the require
+// directives of the vulnerable modules in the go.mod files, and on the calls
+// in the workspace that reach a vulnerable symbol. There are none unless the
+// vulncheck option is set.
+func VulnerabilityDiagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.VersionedFileIdentity][]*source.Diagnostic, error) {
+	if !snapshot.View().Options().Vulncheck {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.VulnerabilityDiagnostics", tag.Snapshot.Of(snapshot.ID()))
+	defer done()
+
+	vulns, err := snapshot.Vulnerabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reports := map[source.VersionedFileIdentity][]*source.Diagnostic{}
+	for _, uri := range snapshot.ModFiles() {
+		fh, err := snapshot.GetVersionedFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics, err := vulnDiagnosticsForMod(ctx, snapshot, fh, vulns)
+		if err != nil {
+			return nil, err
+		}
+		reports[fh.VersionedFileIdentity()] = diagnostics
+	}
+	calls, err := vulnCallDiagnostics(ctx, snapshot, vulns)
+	if err != nil {
+		return nil, err
+	}
+	for uri, diagnostics := range calls {
+		fh, err := snapshot.GetVersionedFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		reports[fh.VersionedFileIdentity()] = diagnostics
+	}
+	return reports, nil
+}
+
+// VulnerabilityDiagnosticsForMod returns the diagnostics for the vulnerable
+// modules required by the go.mod file, with fixes that upgrade them.
+func VulnerabilityDiagnosticsForMod(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]*source.Diagnostic, error) {
+	if !snapshot.View().Options().Vulncheck {
+		return nil, nil
+	}
+	vulns, err := snapshot.Vulnerabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return vulnDiagnosticsForMod(ctx, snapshot, fh, vulns)
+}
+
+func vulnDiagnosticsForMod(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, vulns []command.Vuln) ([]*source.Diagnostic, error) {
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil {
+		return nil, nil // reported by DiagnosticsForMod
+	}
+	byModule := make(map[string][]command.Vuln)
+	for _, v := range vulns {
+		byModule[v.ModPath] = append(byModule[v.ModPath], v)
+	}
+	var diagnostics []*source.Diagnostic
+	for _, req := range pm.File.Require {
+		vs := byModule[req.Mod.Path]
+		if len(vs) == 0 {
+			continue
+		}
+		rng, err := source.LineToRange(pm.Mapper, fh.URI(), req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		// A vulnerability is used if one of its symbols is reached.
+		used, unused := make(map[string]bool), make(map[string]bool)
+		fixed := ""
+		for _, v := range vs {
+			if len(v.CallStacks) > 0 {
+				used[v.ID] = true
+			}
+			if v.FixedVersion != "" && (fixed == "" || semver.Compare(v.FixedVersion, fixed) > 0) {
+				fixed = v.FixedVersion
+			}
+		}
+		for _, v := range vs {
+			if !used[v.ID] {
+				unused[v.ID] = true
+			}
+		}
+		severity := protocol.SeverityInformation
+		var msg []string
+		if len(used) > 0 {
+			severity = protocol.SeverityWarning
+			msg = append(msg, fmt.Sprintf("%s has vulnerabilities used in the code: %s.", req.Mod.Path, sortedIDs(used)))
+		}
+		if len(unused) > 0 {
+			msg = append(msg, fmt.Sprintf("%s has vulnerabilities not used in the code: %s.", req.Mod.Path, sortedIDs(unused)))
+		}
+		var fixes []source.SuggestedFix
+		if fixed != "" && semver.Compare(fixed, req.Mod.Version) > 0 {
+			title := fmt.Sprintf("Upgrade to %v", fixed)
+			cmd, err := command.NewUpgradeDependencyCommand(title, command.DependencyArgs{
+				URI:        protocol.URIFromSpanURI(fh.URI()),
+				AddRequire: false,
+				GoCmdArgs:  []string{req.Mod.Path + "@" + fixed},
+			})
+			if err != nil {
+				return nil, err
+			}
+			fixes = append(fixes, source.SuggestedFixFromCommand(cmd, protocol.QuickFix))
+		}
+		diagnostics = append(diagnostics, &source.Diagnostic{
+			URI:            fh.URI(),
+			Range:          rng,
+			Severity:       severity,
+			Source:         source.Vulncheck,
+			Message:        strings.Join(msg, "\n"),
+			SuggestedFixes: fixes,
+		})
+	}
+	return diagnostics, nil
+}
+
+// sortedIDs returns the set of vulnerability IDs as a sorted list.
+func sortedIDs(ids map[string]bool) string {
+	var list []string
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// vulnCallDiagnostics returns a warning on each call in a file of the
+// workspace that is part of a call stack reaching a vulnerable symbol.
+func vulnCallDiagnostics(ctx context.Context, snapshot source.Snapshot, vulns []command.Vuln) (map[span.URI][]*source.Diagnostic, error) {
+	folder := snapshot.View().Folder().Filename()
+	type callSite struct {
+		uri  span.URI
+		line uint32
+		id   string
+	}
+	seen := make(map[callSite]bool)
+	diagnostics := make(map[span.URI][]*source.Diagnostic)
+	for _, v := range vulns {
+		for _, cs := range v.CallStacks {
+			// The last entry is the vulnerable symbol itself; each of the
+			// others is a call to the next.
+			for i := 0; i+1 < len(cs); i++ {
+				e := cs[i]
+				if e.URI == "" {
+					continue
+				}
+				uri := e.URI.SpanURI()
+				if !source.InDir(folder, uri.Filename()) {
+					continue // not in the workspace
+				}
+				site := callSite{uri, e.Pos.Line, v.ID}
+				if seen[site] {
+					continue
+				}
+				seen[site] = true
+				fh, err := snapshot.GetFile(ctx, uri)
+				if err != nil {
+					return nil, err
+				}
+				rng, ok := callRange(ctx, snapshot, fh, e.Pos.Line, calleeName(cs[i+1].Name))
+				if !ok {
+					continue
+				}
+				msg := fmt.Sprintf("this call reaches %s.%s, which has vulnerability %s", v.PkgPath, v.Symbol, v.ID)
+				if v.FixedVersion != "" {
+					msg += fmt.Sprintf(" (fixed in %s@%s)", v.ModPath, v.FixedVersion)
+				}
+				diagnostics[uri] = append(diagnostics[uri], &source.Diagnostic{
+					URI:      uri,
+					Range:    rng,
+					Severity: protocol.SeverityWarning,
+					Source:   source.Vulncheck,
+					Message:  msg,
+				})
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+// calleeName returns the unqualified name of the function described by a
+// call stack entry, such as Parse for golang.org/x/text/language.Parse.
+func calleeName(desc string) string {
+	desc = strings.TrimSuffix(desc, " [approx.]")
+	return desc[strings.LastIndex(desc, ".")+1:]
+}
+
+// callRange returns the range of the function expression of the call to
+// name on the given line of the Go file, or of the first call on the line if
+// there is none. Call stacks record only the lines of calls.
+func callRange(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, line uint32, name string) (protocol.Range, bool) {
+	pgf, err := snapshot.ParseGo(ctx, fh, source.ParseFull)
+	if err != nil {
+		return protocol.Range{}, false
+	}
+	var first, named ast.Expr
+	ast.Inspect(pgf.File, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || named != nil {
+			return named == nil
+		}
+		if uint32(pgf.Tok.Line(call.Lparen)-1) != line {
+			return true
+		}
+		if first == nil {
+			first = call.Fun
+		}
+		var id *ast.Ident
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		}
+		if id != nil && id.Name == name {
+			named = call.Fun
+		}
+		return true
+	})
+	if named == nil {
+		named = first
+	}
+	if named == nil {
+		return protocol.Range{}, false
+	}
+	rng, err := source.NewMappedRange(pgf.Tok, pgf.Mapper, named.Pos(), named.End()).Range()
+	if err != nil {
+		return protocol.Range{}, false
+	}
+	return rng, true
+}
@@ -226,6 +226,16 @@ func (e *Env) Hover(name string, pos fake.Pos) (*protocol.MarkupContent, fake.Po
 	return c, p
 }
 
+// SignatureHelp in the editor, calling t.Fatal on any error.
+func (e *Env) SignatureHelp(name string, pos fake.Pos) *protocol.SignatureHelp {
+	e.T.Helper()
+	help, err := e.Editor.SignatureHelp(e.Ctx, name, pos)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return help
+}
+
 func (e *Env) DocumentLink(name string) []protocol.DocumentLink {
 	e.T.Helper()
 	links, err := e.Editor.DocumentLink(e.Ctx, name)
@@ -311,6 +321,17 @@ func (e *Env) CodeLens(path string) []protocol.CodeLens {
 	return lens
 }
 
+// ResolveCodeLens resolves the command of a code lens, calling t.Fatal on
+// any error.
+func (e *Env) ResolveCodeLens(lens protocol.CodeLens) *protocol.CodeLens {
+	e.T.Helper()
+	resolved, err := e.Editor.ResolveCodeLens(e.Ctx, lens)
+	if err != nil {
+		e.T.Fatal(err)
+	}
+	return resolved
+}
+
 // ExecuteCodeLensCommand executes the command for the code lens matching the
 // given command name.
 func (e *Env) ExecuteCodeLensCommand(path string, cmd command.Command) {
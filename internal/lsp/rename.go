@@ -9,15 +9,25 @@ import (
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/template"
+	"github.com/cowpaths/golang-x-tools/internal/span"
 )
 
 func (s *Server) rename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
-	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.Go)
+	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.UnknownKind)
 	defer release()
 	if !ok {
 		return nil, err
 	}
-	edits, err := source.Rename(ctx, snapshot, fh, params.Position, params.NewName)
+	var edits map[span.URI][]protocol.TextEdit
+	switch snapshot.View().FileKind(fh) {
+	case source.Go:
+		edits, err = source.Rename(ctx, snapshot, fh, params.Position, params.NewName)
+	case source.Tmpl:
+		edits, err = template.Rename(ctx, snapshot, fh, params.Position, params.NewName)
+	default:
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -36,14 +46,24 @@ func (s *Server) rename(ctx context.Context, params *protocol.RenameParams) (*pr
 }
 
 func (s *Server) prepareRename(ctx context.Context, params *protocol.PrepareRenameParams) (*protocol.PrepareRename2Gn, error) {
-	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.Go)
+	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.UnknownKind)
 	defer release()
 	if !ok {
 		return nil, err
 	}
-	// Do not return errors here, as it adds clutter.
-	// Returning a nil result means there is not a valid rename.
-	item, usererr, err := source.PrepareRename(ctx, snapshot, fh, params.Position)
+	var item *source.PrepareItem
+	var usererr error
+	switch snapshot.View().FileKind(fh) {
+	case source.Go:
+		// Do not return errors here, as it adds clutter.
+		// Returning a nil result means there is not a valid rename.
+		item, usererr, err = source.PrepareRename(ctx, snapshot, fh, params.Position)
+	case source.Tmpl:
+		item, err = template.PrepareRename(ctx, snapshot, fh, params.Position)
+		usererr = err
+	default:
+		return nil, nil
+	}
 	if err != nil {
 		// Return usererr here rather than err, to avoid cluttering the UI with
 		// internal error details.
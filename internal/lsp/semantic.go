@@ -18,6 +18,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/printf"
+	"github.com/cowpaths/golang-x-tools/go/ast/astutil"
 	"github.com/cowpaths/golang-x-tools/internal/event"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/safetoken"
@@ -112,6 +114,7 @@ func (s *Server) computeSemanticTokens(ctx context.Context, td protocol.TextDocu
 		rng:      rng,
 		ti:       pkg.GetTypesInfo(),
 		pkg:      pkg,
+		snapshot: snapshot,
 		fset:     snapshot.FileSet(),
 		tokTypes: s.session.Options().SemanticTypes,
 		tokMods:  s.session.Options().SemanticMods,
@@ -171,6 +174,8 @@ const (
 	tokString    tokenType = "string"
 	tokNumber    tokenType = "number"
 	tokOperator  tokenType = "operator"
+	tokProperty  tokenType = "property"
+	tokRegexp    tokenType = "regexp"
 
 	tokMacro tokenType = "macro" // for templates
 )
@@ -229,12 +234,17 @@ type encoded struct {
 	rng               *protocol.Range
 	ti                *types.Info
 	pkg               source.Package
+	snapshot          source.Snapshot
 	fset              *token.FileSet
 	// allowed starting and ending token.Pos, set by init
 	// used to avoid looking at declarations not in range
 	start, end token.Pos
 	// path from the root of the parse tree, used for debugging
 	stack []ast.Node
+	// deprecations caches whether objects are documented as deprecated
+	deprecations map[types.Object]bool
+	// printf caches the result of the printf analyzer on the package
+	printf *printf.Result
 }
 
 // convert the stack to a string, for debugging
@@ -295,17 +305,11 @@ func (e *encoded) inspector(n ast.Node) bool {
 			e.multiline(x.Pos(), x.End(), x.Value, tokString)
 			break
 		}
-		ln := len(x.Value)
-		what := tokNumber
 		if x.Kind == token.STRING {
-			what = tokString
-			if _, ok := e.stack[len(e.stack)-2].(*ast.Field); ok {
-				// struct tags (this is probably pointless, as the
-				// TextMate grammar will treat all the other comments the same)
-				what = tokComment
-			}
+			e.stringLit(x)
+			break
 		}
-		e.token(x.Pos(), ln, what, nil)
+		e.token(x.Pos(), len(x.Value), tokNumber, nil)
 	case *ast.BinaryExpr:
 		e.token(x.OpPos, len(x.Op.String()), tokOperator, nil)
 	case *ast.BlockStmt:
@@ -451,6 +455,9 @@ func (e *encoded) ident(x *ast.Ident) {
 	def := e.ti.Defs[x]
 	if def != nil {
 		what, mods := e.definitionFor(x, def)
+		if e.deprecated(def) {
+			mods = append(mods, "deprecated")
+		}
 		if what != "" {
 			e.token(x.Pos(), len(x.String()), what, mods)
 		}
@@ -461,6 +468,9 @@ func (e *encoded) ident(x *ast.Ident) {
 	}
 	use := e.ti.Uses[x]
 	tok := func(pos token.Pos, lng int, tok tokenType, mods []string) {
+		if e.deprecated(use) {
+			mods = append(mods, "deprecated")
+		}
 		e.token(pos, lng, tok, mods)
 		q := "nil"
 		if use != nil {
@@ -683,18 +693,84 @@ func (e *encoded) unkIdent(x *ast.Ident) (tokenType, []string) {
 	return "", nil
 }
 
+// isDeprecated reports whether a doc comment has a paragraph starting with
+// "Deprecated:", the convention for marking deprecated identifiers.
 func isDeprecated(n *ast.CommentGroup) bool {
 	if n == nil {
 		return false
 	}
-	for _, c := range n.List {
-		if strings.HasPrefix(c.Text, "// Deprecated") {
+	for _, para := range strings.Split(n.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Deprecated:") {
 			return true
 		}
 	}
 	return false
 }
 
+// deprecated reports whether the declaration of a package-level object,
+// field or method is documented as deprecated. Only objects declared in the
+// current package or its direct imports are found.
+func (e *encoded) deprecated(obj types.Object) bool {
+	if obj == nil || obj.Pkg() == nil || (obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope()) {
+		return false
+	}
+	if dep, ok := e.deprecations[obj]; ok {
+		return dep
+	}
+	if e.deprecations == nil {
+		e.deprecations = make(map[types.Object]bool)
+	}
+	dep := false
+	pkg := e.pkg
+	if obj.Pkg() != pkg.GetTypes() {
+		pkg, _ = e.pkg.GetImport(obj.Pkg().Path())
+	}
+	if pkg != nil {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if safetoken.InRange(pgf.Tok, obj.Pos()) {
+				dep = declDeprecated(pgf.File, obj.Pos())
+				break
+			}
+		}
+	}
+	e.deprecations[obj] = dep
+	return dep
+}
+
+// declDeprecated reports whether the declaration of the identifier at pos
+// has a deprecated doc comment.
+func declDeprecated(f *ast.File, pos token.Pos) bool {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	if len(path) < 2 {
+		return false
+	}
+	if _, ok := path[0].(*ast.Ident); !ok {
+		return false
+	}
+	switch n := path[1].(type) {
+	case *ast.Field:
+		return isDeprecated(n.Doc)
+	case *ast.FuncDecl:
+		return isDeprecated(n.Doc)
+	case *ast.TypeSpec, *ast.ValueSpec:
+		var doc *ast.CommentGroup
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			doc = ts.Doc
+		} else {
+			doc = n.(*ast.ValueSpec).Doc
+		}
+		if isDeprecated(doc) {
+			return true
+		}
+		if len(path) > 2 {
+			if decl, ok := path[2].(*ast.GenDecl); ok {
+				return isDeprecated(decl.Doc)
+			}
+		}
+	}
+	return false
+}
+
 func (e *encoded) definitionFor(x *ast.Ident, def types.Object) (tokenType, []string) {
 	// PJW: def == types.Label? probably a nothing
 	// PJW: look into replaceing these syntactic tests with types more generally
@@ -708,9 +784,6 @@ func (e *encoded) definitionFor(x *ast.Ident, def types.Object) (tokenType, []st
 			}
 			return tokVariable, mods
 		case *ast.GenDecl:
-			if isDeprecated(y.Doc) {
-				mods = append(mods, "deprecated")
-			}
 			if y.Tok == token.CONST {
 				mods = append(mods, "readonly")
 			}
@@ -718,9 +791,6 @@ func (e *encoded) definitionFor(x *ast.Ident, def types.Object) (tokenType, []st
 		case *ast.FuncDecl:
 			// If x is immediately under a FuncDecl, it is a function or method
 			if i == len(e.stack)-2 {
-				if isDeprecated(y.Doc) {
-					mods = append(mods, "deprecated")
-				}
 				if y.Recv != nil {
 					return tokMethod, mods
 				}
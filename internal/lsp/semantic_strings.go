@@ -0,0 +1,337 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/analysis/passes/printf"
+	"github.com/cowpaths/golang-x-tools/go/types/typeutil"
+	"github.com/cowpaths/golang-x-tools/internal/event"
+)
+
+// This file tokenizes the languages embedded in Go string literals:
+// printf format strings, regular expressions and struct tags.
+
+// A subToken is a token within a string literal. Its offset is relative to
+// the start of the literal, including the opening quote.
+type subToken struct {
+	offset, len int
+	typ         tokenType
+}
+
+// stringLit emits tokens for a single-line string literal. Literals in a
+// recognized embedded language are split into the tokens of that language,
+// with the remaining text reported as strings.
+func (e *encoded) stringLit(x *ast.BasicLit) {
+	var subs []subToken
+	var fallback tokenType = tokString
+	switch parent := e.stack[len(e.stack)-2].(type) {
+	case *ast.Field:
+		// struct tags (if a tag is not well-formed, the TextMate grammar
+		// will treat it the same as any other comment)
+		fallback = tokComment
+		subs = structTagTokens(x.Value)
+	case *ast.CallExpr:
+		subs = e.callArgTokens(parent, x)
+	}
+	if len(subs) == 0 {
+		e.token(x.Pos(), len(x.Value), fallback, nil)
+		return
+	}
+	last := 0
+	for _, s := range subs {
+		if s.offset > last {
+			e.token(x.Pos()+token.Pos(last), s.offset-last, tokString, nil)
+		}
+		e.token(x.Pos()+token.Pos(s.offset), s.len, s.typ, nil)
+		last = s.offset + s.len
+	}
+	if last < len(x.Value) {
+		e.token(x.Pos()+token.Pos(last), len(x.Value)-last, tokString, nil)
+	}
+}
+
+// callArgTokens returns the tokens of lit, an argument of call, if it is the
+// format of a printf-like function or the pattern of a regexp function.
+func (e *encoded) callArgTokens(call *ast.CallExpr, lit *ast.BasicLit) []subToken {
+	if e.ti == nil {
+		return nil
+	}
+	fn, _ := typeutil.Callee(e.ti, call).(*types.Func)
+	if fn == nil {
+		return nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if isRegexpFunc(fn) {
+		if len(call.Args) > 0 && call.Args[0] == lit {
+			return regexpTokens(lit.Value)
+		}
+		return nil
+	}
+	if sig.Variadic() && e.isPrintfLike(fn) {
+		idx := sig.Params().Len() - 2
+		if idx >= 0 && idx < len(call.Args) && call.Args[idx] == lit {
+			return printfTokens(lit.Value)
+		}
+	}
+	return nil
+}
+
+// isPrintfLike reports whether fn is printf-like according to the printf
+// analyzer: one of the functions it knows, such as fmt.Printf and
+// log.Fatalf, or a wrapper of them declared in the package. Wrappers
+// declared in other packages are not recognized, as gopls does not analyze
+// dependencies and so has none of their facts.
+func (e *encoded) isPrintfLike(fn *types.Func) bool {
+	switch e.printfResult().Kind(fn) {
+	case printf.KindPrintf, printf.KindErrorf:
+		return true
+	}
+	return false
+}
+
+// printfResult returns the result of the printf analyzer on the package,
+// or an empty result if the analyzer is disabled or failed.
+func (e *encoded) printfResult() *printf.Result {
+	if e.printf != nil {
+		return e.printf
+	}
+	e.printf = new(printf.Result)
+	a := e.snapshot.View().Options().DefaultAnalyzers[printf.Analyzer.Name]
+	if a == nil || !a.IsEnabled(e.snapshot.View()) {
+		return e.printf
+	}
+	res, err := e.snapshot.AnalysisResult(e.ctx, e.pkg.ID(), a)
+	if err != nil {
+		event.Error(e.ctx, "semantic tokens: printf analysis", err)
+	} else if r, ok := res.(*printf.Result); ok {
+		e.printf = r
+	}
+	return e.printf
+}
+
+// isRegexpFunc reports whether fn is a function of package regexp whose
+// first argument is a regular expression.
+func isRegexpFunc(fn *types.Func) bool {
+	if fn.Pkg() == nil || fn.Pkg().Path() != "regexp" || fn.Type().(*types.Signature).Recv() != nil {
+		return false
+	}
+	switch fn.Name() {
+	case "Compile", "CompilePOSIX", "MustCompile", "MustCompilePOSIX",
+		"Match", "MatchReader", "MatchString":
+		return true
+	}
+	return false
+}
+
+// printfTokens returns an operator token for each formatting directive in
+// the quoted format string lit, such as %d, %-8.3[2]f or %%. The LSP has no
+// token type for format directives, and operators are the closest match.
+func printfTokens(lit string) []subToken {
+	var subs []subToken
+	raw := lit[0] == '`'
+	for i := 1; i < len(lit)-1; i++ {
+		switch lit[i] {
+		case '\\':
+			if !raw {
+				i++ // skip the escaped character
+			}
+			continue
+		case '%':
+		default:
+			continue
+		}
+		j := i + 1
+		if j < len(lit)-1 && lit[j] == '%' {
+			subs = append(subs, subToken{i, 2, tokOperator})
+			i = j
+			continue
+		}
+		for j < len(lit)-1 && strings.IndexByte("+-# 0", lit[j]) >= 0 {
+			j++
+		}
+		// argument index, width, precision and argument index again
+		j = skipArgIndex(lit, j)
+		j = skipWidth(lit, j)
+		if j < len(lit)-1 && lit[j] == '.' {
+			j = skipWidth(lit, skipArgIndex(lit, j+1))
+		}
+		j = skipArgIndex(lit, j)
+		if j >= len(lit)-1 || !isLetter(lit[j]) {
+			continue // not a valid directive
+		}
+		subs = append(subs, subToken{i, j + 1 - i, tokOperator})
+		i = j
+	}
+	return subs
+}
+
+// skipArgIndex returns the offset in s after an explicit argument index,
+// such as [2], starting at offset i.
+func skipArgIndex(s string, i int) int {
+	if i >= len(s) || s[i] != '[' {
+		return i
+	}
+	j := i + 1
+	for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+		j++
+	}
+	if j == i+1 || j >= len(s) || s[j] != ']' {
+		return i
+	}
+	return j + 1
+}
+
+// skipWidth returns the offset in s after a width or precision, such as 12
+// or *, starting at offset i.
+func skipWidth(s string, i int) int {
+	if i < len(s) && s[i] == '*' {
+		return i + 1
+	}
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func isLetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// regexpTokens returns the tokens of the quoted regular expression lit:
+// escapes and character classes are regexp tokens, and operators,
+// repetitions and groups are operator tokens. It returns nil if lit is not a
+// valid regular expression, or if it is an interpreted string literal
+// containing escapes, whose source offsets differ from those of its value.
+func regexpTokens(lit string) []subToken {
+	pattern := lit[1 : len(lit)-1]
+	if lit[0] != '`' && strings.Contains(pattern, `\`) {
+		return nil
+	}
+	if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+		return nil
+	}
+	var subs []subToken
+	add := func(start, end int, typ tokenType) {
+		subs = append(subs, subToken{start + 1, end - start, typ})
+	}
+	for i := 0; i < len(pattern); {
+		switch c := pattern[i]; c {
+		case '\\':
+			end := i + 2
+			if end < len(pattern) && strings.IndexByte("pPx", pattern[i+1]) >= 0 && pattern[end] == '{' {
+				if j := strings.IndexByte(pattern[end:], '}'); j >= 0 {
+					end += j + 1
+				}
+			}
+			add(i, end, tokRegexp)
+			i = end
+		case '[':
+			end := classEnd(pattern, i)
+			add(i, end, tokRegexp)
+			i = end
+		case '(':
+			end := i + 1
+			if end < len(pattern) && pattern[end] == '?' {
+				// flags, non-capturing and named groups, up to the name
+				if j := strings.IndexAny(pattern[end:], ":)>"); j >= 0 {
+					end += j + 1
+				}
+			}
+			add(i, end, tokOperator)
+			i = end
+		case '{':
+			end := i + 1
+			for end < len(pattern) && strings.IndexByte("0123456789,", pattern[end]) >= 0 {
+				end++
+			}
+			if end > i+1 && end < len(pattern) && pattern[end] == '}' {
+				add(i, end+1, tokOperator)
+				i = end + 1
+				continue
+			}
+			i++ // a literal brace
+		case ')', '|', '*', '+', '?', '^', '$', '.':
+			add(i, i+1, tokOperator)
+			i++
+		default:
+			i++
+		}
+	}
+	return subs
+}
+
+// classEnd returns the offset just after the character class that starts at
+// offset i of the valid pattern.
+func classEnd(pattern string, i int) int {
+	j := i + 1
+	if j < len(pattern) && pattern[j] == '^' {
+		j++
+	}
+	if j < len(pattern) && pattern[j] == ']' {
+		j++ // a leading ] is a literal
+	}
+	for j < len(pattern) {
+		switch {
+		case pattern[j] == '\\':
+			j += 2
+			continue
+		case strings.HasPrefix(pattern[j:], "[:"):
+			if k := strings.Index(pattern[j:], ":]"); k >= 0 {
+				j += k + 2
+				continue
+			}
+		case pattern[j] == ']':
+			return j + 1
+		}
+		j++
+	}
+	return len(pattern)
+}
+
+// structTagTokens returns the tokens of the raw string literal lit, a struct
+// tag in the conventional format described by reflect.StructTag: each key is
+// a property and each colon an operator, leaving the quoted values as
+// strings. It returns nil if the tag is not in that format.
+func structTagTokens(lit string) []subToken {
+	if lit[0] != '`' {
+		return nil
+	}
+	var subs []subToken
+	tag := lit[1 : len(lit)-1]
+	i := 0
+	for {
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		if i == len(tag) {
+			return subs
+		}
+		key := i
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == key || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil
+		}
+		subs = append(subs, subToken{key + 1, i - key, tokProperty}, subToken{i + 1, 1, tokOperator})
+		i += 2
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return nil
+		}
+		i++
+	}
+}
@@ -27,6 +27,8 @@ func NewServer(session source.Session, client protocol.ClientCloser) *Server {
 	return &Server{
 		diagnostics:           map[span.URI]*fileReports{},
 		gcOptimizationDetails: make(map[string]struct{}),
+		profileHotSpots:       make(map[span.URI]profileHotSpots),
+		coverage:              make(map[span.URI]fileCoverage),
 		watchedGlobPatterns:   make(map[string]struct{}),
 		changedFiles:          make(map[span.URI]struct{}),
 		session:               session,
@@ -78,6 +80,11 @@ type Server struct {
 	changedFilesMu sync.Mutex
 	changedFiles   map[span.URI]struct{}
 
+	// currentFile is the file most recently opened or changed, by proximity
+	// to which workspace symbols are ranked.
+	currentFileMu sync.Mutex
+	currentFile   span.URI
+
 	// folders is only valid between initialize and initialized, and holds the
 	// set of folders to build views for when we are ready
 	pendingFolders []protocol.WorkspaceFolder
@@ -98,6 +105,16 @@ type Server struct {
 	gcOptimizationDetailsMu sync.Mutex
 	gcOptimizationDetails   map[string]struct{}
 
+	// profileHotSpots holds the hot lines found by the most recent benchmark
+	// profile of each file's package.
+	profileHotSpotsMu sync.Mutex
+	profileHotSpots   map[span.URI]profileHotSpots
+
+	// coverage holds the test coverage of files, as most recently computed
+	// by the show_coverage command.
+	coverageMu sync.Mutex
+	coverage   map[span.URI]fileCoverage
+
 	// diagnosticsSema limits the concurrency of diagnostics runs, which can be
 	// expensive.
 	diagnosticsSema chan struct{}
@@ -232,8 +232,8 @@ func (s *Server) ResolveCodeAction(context.Context, *protocol.CodeAction) (*prot
 	return nil, notImplemented("ResolveCodeAction")
 }
 
-func (s *Server) ResolveCodeLens(context.Context, *protocol.CodeLens) (*protocol.CodeLens, error) {
-	return nil, notImplemented("ResolveCodeLens")
+func (s *Server) ResolveCodeLens(ctx context.Context, params *protocol.CodeLens) (*protocol.CodeLens, error) {
+	return s.resolveCodeLens(ctx, params)
 }
 
 func (s *Server) ResolveCompletionItem(context.Context, *protocol.CompletionItem) (*protocol.CompletionItem, error) {
@@ -11,15 +11,25 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/template"
 )
 
 func (s *Server) signatureHelp(ctx context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
-	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.Go)
+	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.UnknownKind)
 	defer release()
 	if !ok {
 		return nil, err
 	}
-	info, activeParameter, err := source.SignatureHelp(ctx, snapshot, fh, params.Position)
+	var info *protocol.SignatureInformation
+	var activeParameter int
+	switch snapshot.View().FileKind(fh) {
+	case source.Go:
+		info, activeParameter, err = source.SignatureHelp(ctx, snapshot, fh, params.Position)
+	case source.Tmpl:
+		info, activeParameter, err = template.SignatureHelp(ctx, snapshot, fh, params.Position)
+	default:
+		return nil, nil
+	}
 	if err != nil {
 		event.Error(ctx, "no signature help", err, tag.Position.Of(params.Position))
 		return nil, nil
@@ -26,6 +26,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Default:   "[\"-node_modules\"]",
 				Hierarchy: "build",
 			},
+			{
+				Name:      "buildConfigurations",
+				Type:      "[]string",
+				Doc:       "buildConfigurations lists additional build configurations, as\n`GOOS/GOARCH` pairs such as `windows/amd64`, for which `gopls` loads\nand type-checks the workspace packages in the background. The files\nthat are compiled only in one of these configurations, such as those\nconstrained by `//go:build windows`, get its type errors and analysis\ndiagnostics, with the configuration in their source. They are updated\nwhen files are saved, and when these files are edited.\n",
+				Default:   "[]",
+				Status:    "experimental",
+				Hierarchy: "build",
+			},
 			{
 				Name:      "templateExtensions",
 				Type:      "[]string",
@@ -48,6 +56,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "build",
 			},
+			{
+				Name:      "memoryBudget",
+				Type:      "string",
+				Doc:       "memoryBudget bounds the memory that `gopls` uses for the syntax and\ntype information of workspace packages, such as \"4GB\". When they exceed\nit, the least recently used packages without open files are evicted:\nthey are held only as the export data of their last type-check, from\nwhich their importers read their types, and with its diagnostics, until\nthey change. The features that need their syntax, such as Find\nReferences and whole-program analyzers, type-check them in full again\neach time, trading time for memory, and the template features do not\nsee them. The empty string means no limit.\n",
+				Default:   "\"\"",
+				Status:    "experimental",
+				Hierarchy: "build",
+			},
 			{
 				Name:      "expandWorkspaceToModule",
 				Type:      "bool",
@@ -72,6 +88,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "build",
 			},
+			{
+				Name:      "experimentalEarlyCutoff",
+				Type:      "bool",
+				Doc:       "experimentalEarlyCutoff controls whether a change to a package that\nleaves its export data identical, such as an edit of a function body\nthat does not move any declaration, preserves the type information\nof the packages that depend on it, rather than type-checking them\nagain. The package itself is type-checked again, so features that\nrelate its objects to those of its dependents by identity, such as\nimplementations, may miss some results until the dependents are\ntype-checked again.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "build",
+			},
 			{
 				Name:      "allowModfileModifications",
 				Type:      "bool",
@@ -393,6 +417,11 @@ var GeneratedAPIJSON = &APIJSON{
 							Doc:     "check for invalid conversions of uintptr to unsafe.Pointer\n\nThe unsafeptr analyzer reports likely incorrect uses of unsafe.Pointer\nto convert integers to pointers. A conversion from uintptr to\nunsafe.Pointer is invalid if it implies that there is a uintptr-typed\nword in memory that holds a pointer value, because that word will be\ninvisible to stack copying and to the garbage collector.",
 							Default: "true",
 						},
+						{
+							Name:    "\"unusedfunc\"",
+							Doc:     "check for exported functions that no package uses\n\nThe unusedfunc analyzer reports the exported functions that none of\nthe analyzed packages uses, such as the packages of the workspace and\ntheir tests. Unlike most analyzers, it looks at all the packages at once.\n\nTo reduce false positives it ignores:\n- methods, which may implement interfaces\n- functions in test files\n- functions with a directive comment, such as //export or //go:linkname",
+							Default: "false",
+						},
 						{
 							Name:    "\"unusedparams\"",
 							Doc:     "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used.\n\nTo reduce false positives it ignores:\n- methods\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
@@ -461,6 +490,22 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "ui.diagnostic",
 			},
+			{
+				Name:      "analysisPlugins",
+				Type:      "[]string",
+				Doc:       "analysisPlugins lists the analysis tools, built with the\n`go/analysis/pluginchecker` package, whose\nanalyzers `gopls` applies to the packages with open files, in\naddition to its own. Each entry is the path of a tool, optionally\nfollowed by flags of its analyzers, separated by spaces. The\n`analyses` setting enables or disables their analyzers by name.\n",
+				Default:   "[]",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
+			{
+				Name:      "vulncheck",
+				Type:      "bool",
+				Doc:       "vulncheck enables diagnostics for the vulnerabilities that govulncheck\nfinds in the required modules: on their require directives in go.mod\nfiles, and on the calls in the workspace that reach a vulnerable\nsymbol. The vulnerability database is read from the GOVULNDB\nenvironment variable, which may name a local directory with a\nfile:// URL for use without network access.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
 			{
 				Name: "annotations",
 				Type: "map[string]bool",
@@ -520,6 +565,11 @@ var GeneratedAPIJSON = &APIJSON{
 						Doc:     "Enable/disable inlay hints for variable types in assign statements:\n\n\ti/* int/*, j/* int/* := 0, len(r)-1",
 						Default: "false",
 					},
+					{
+						Name:    "\"closureParameterTypes\"",
+						Doc:     "Enable/disable inlay hints for the type parameters bound by closure parameters at generic call sites:\n\n\tMap(names, func(s /*T = */string) /*U = */int { return len(s) })",
+						Default: "false",
+					},
 					{
 						Name:    "\"compositeLiteralFields\"",
 						Doc:     "Enable/disable inlay hints for composite literal field names:\n\n\t{in: \"Hello, world\", want: \"dlrow ,olleH\"}",
@@ -540,6 +590,16 @@ var GeneratedAPIJSON = &APIJSON{
 						Doc:     "Enable/disable inlay hints for implicit type parameters on generic functions:\n\n\tmyFoo/*[int, string]*/(1, \"hello\")",
 						Default: "false",
 					},
+					{
+						Name:    "\"implicitConversions\"",
+						Doc:     "Enable/disable inlay hints for implicit conversions to interface types in calls and assignments:\n\n\tio.Copy(/*io.Writer(*/f/*)*/, /*io.Reader(*/r/*)*/)",
+						Default: "false",
+					},
+					{
+						Name:    "\"methodValueReceivers\"",
+						Doc:     "Enable/disable inlay hints for the receivers bound by method values:\n\n\tf := buf.Write/* receiver: &buf*/",
+						Default: "false",
+					},
 					{
 						Name:    "\"parameterNames\"",
 						Doc:     "Enable/disable inlay hints for parameter names:\n\n\tparseInt(/* str: */ \"123\", /* radix: */ 8)",
@@ -550,6 +610,11 @@ var GeneratedAPIJSON = &APIJSON{
 						Doc:     "Enable/disable inlay hints for variable types in range statements:\n\n\tfor k/* int*/, v/* string/* := range []string{} {\n\t\tfmt.Println(k, v)\n\t}",
 						Default: "false",
 					},
+					{
+						Name:    "\"untypedConstantTypes\"",
+						Doc:     "Enable/disable inlay hints for the default types of untyped constants:\n\n\tconst (\n\t\ttimeout/* float64*/ = 1.5\n\t\tretries/* int*/     = 3\n\t)",
+						Default: "false",
+					},
 				}},
 				Default:   "{}",
 				Status:    "experimental",
@@ -572,6 +637,21 @@ var GeneratedAPIJSON = &APIJSON{
 							Doc:     "Runs `go generate` for a given directory.",
 							Default: "true",
 						},
+						{
+							Name:    "\"implementations\"",
+							Doc:     "Shows the implementations of an interface or type.",
+							Default: "false",
+						},
+						{
+							Name:    "\"profile_benchmark\"",
+							Doc:     "Runs a benchmark with CPU or memory profiling, and annotates the\nhottest lines of its package with hint diagnostics.",
+							Default: "false",
+						},
+						{
+							Name:    "\"references\"",
+							Doc:     "Shows the references to a top-level declaration.",
+							Default: "false",
+						},
 						{
 							Name:    "\"regenerate_cgo\"",
 							Doc:     "Regenerates cgo definitions.",
@@ -658,6 +738,12 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Checks for module upgrades.",
 			ArgDoc:  "{\n\t// The go.mod file URI.\n\t\"URI\": string,\n\t// The modules to check.\n\t\"Modules\": []string,\n}",
 		},
+		{
+			Command: "gopls.clear_coverage",
+			Title:   "Clear test coverage",
+			Doc:     "Clears the test coverage annotations of a package.",
+			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
+		},
 		{
 			Command: "gopls.edit_go_directive",
 			Title:   "Run go mod edit -go=version",
@@ -682,12 +768,25 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "(Re)generate the gopls.mod file for a workspace.",
 			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 		},
+		{
+			Command: "gopls.generate_work_file",
+			Title:   "Generate go.work",
+			Doc:     "Generates a go.work file in a directory that uses all the modules in\nand beneath it. The directory must not already have a go.work file.",
+			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
+		},
 		{
 			Command: "gopls.go_get_package",
 			Title:   "go get a package",
 			Doc:     "Runs `go get` to fetch a package.",
 			ArgDoc:  "{\n\t// Any document URI within the relevant module.\n\t\"URI\": string,\n\t// The package to go get.\n\t\"Pkg\": string,\n\t\"AddRequire\": bool,\n}",
 		},
+		{
+			Command:   "gopls.implementations",
+			Title:     "Show implementations",
+			Doc:       "Shows the implementations of an interface or type.",
+			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n\t// The position of the declaring identifier.\n\t\"Position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n}",
+			ResultDoc: "[]{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+		},
 		{
 			Command:   "gopls.list_imports",
 			Title:     "List imports of a file and its package",
@@ -702,6 +801,26 @@ var GeneratedAPIJSON = &APIJSON{
 			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 			ResultDoc: "{\n\t// Packages is a list of packages relative\n\t// to the URIArg passed by the command request.\n\t// In other words, it omits paths that are already\n\t// imported or cannot be imported due to compiler\n\t// restrictions.\n\t\"Packages\": []string,\n}",
 		},
+		{
+			Command:   "gopls.module_graph",
+			Title:     "Show the module graph",
+			Doc:       "Returns the requirements of the module graph of a go.mod file, as\nprinted by `go mod graph`, that are relevant to a module.",
+			ArgDoc:    "{\n\t// The go.mod file.\n\t\"URI\": string,\n\t// The module path, optionally followed by @ and a version. Without a\n\t// version, the version selected by minimal version selection is used.\n\t\"Module\": string,\n\t// The query, named after those of cmd/digraph: \"somepath\" for the\n\t// shortest chain of requirements from a main module to the module,\n\t// \"reverse\" for all the requirements through which the module is\n\t// reached, or empty for the requirements of the module and those on it.\n\t\"Query\": string,\n}",
+			ResultDoc: "{\n\t// Requirements in the format of `go mod graph`: a module and a module\n\t// it requires, separated by a space.\n\t\"Requirements\": []string,\n}",
+		},
+		{
+			Command: "gopls.profile_benchmark",
+			Title:   "Profile a benchmark",
+			Doc:     "Runs a benchmark with CPU or memory profiling, and annotates the\nhottest lines of its package with hint diagnostics.",
+			ArgDoc:  "{\n\t// The test file containing the benchmark to profile.\n\t\"URI\": string,\n\t// The benchmark to profile, e.g. BenchmarkFoo.\n\t\"Benchmark\": string,\n\t// The kind of profile to collect: \"cpu\" (the default) or \"mem\".\n\t\"Kind\": string,\n}",
+		},
+		{
+			Command:   "gopls.references",
+			Title:     "Show references",
+			Doc:       "Shows the references to a top-level declaration.",
+			ArgDoc:    "{\n\t// The file URI.\n\t\"URI\": string,\n\t// The position of the declaring identifier.\n\t\"Position\": {\n\t\t\"line\": uint32,\n\t\t\"character\": uint32,\n\t},\n}",
+			ResultDoc: "[]{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+		},
 		{
 			Command: "gopls.regenerate_cgo",
 			Title:   "Regenerate cgo",
@@ -727,6 +846,12 @@ var GeneratedAPIJSON = &APIJSON{
 			ArgDoc:    "{\n\t// Dir is the directory from which vulncheck will run from.\n\t\"Dir\": string,\n\t// Package pattern. E.g. \"\", \".\", \"./...\".\n\t\"Pattern\": string,\n}",
 			ResultDoc: "{\n\t\"Vuln\": []{\n\t\t\"ID\": string,\n\t\t\"Details\": string,\n\t\t\"Aliases\": []string,\n\t\t\"Symbol\": string,\n\t\t\"PkgPath\": string,\n\t\t\"ModPath\": string,\n\t\t\"URL\": string,\n\t\t\"CurrentVersion\": string,\n\t\t\"FixedVersion\": string,\n\t\t\"CallStacks\": [][]github.com/cowpaths/golang-x-tools/internal/lsp/command.StackEntry,\n\t\t\"CallStackSummaries\": []string,\n\t},\n}",
 		},
+		{
+			Command: "gopls.show_coverage",
+			Title:   "Show test coverage",
+			Doc:     "Runs `go test -coverprofile` for a package, or reads an existing\ncoverage profile, and annotates the blocks that were not executed.",
+			ArgDoc:  "{\n\t// Any file in the package to test.\n\t\"URI\": string,\n\t// Optional: an existing coverage profile to read, in the format written\n\t// by `go test -coverprofile`. If set, no tests are run, and coverage is\n\t// shown for all workspace packages described by the profile.\n\t\"Profile\": string,\n}",
+		},
 		{
 			Command:   "gopls.start_debugging",
 			Title:     "Start the gopls debug server",
@@ -782,6 +907,21 @@ var GeneratedAPIJSON = &APIJSON{
 			Title: "Run go generate",
 			Doc:   "Runs `go generate` for a given directory.",
 		},
+		{
+			Lens:  "implementations",
+			Title: "Show implementations",
+			Doc:   "Shows the implementations of an interface or type.",
+		},
+		{
+			Lens:  "profile_benchmark",
+			Title: "Profile a benchmark",
+			Doc:   "Runs a benchmark with CPU or memory profiling, and annotates the\nhottest lines of its package with hint diagnostics.",
+		},
+		{
+			Lens:  "references",
+			Title: "Show references",
+			Doc:   "Shows the references to a top-level declaration.",
+		},
 		{
 			Lens:  "regenerate_cgo",
 			Title: "Regenerate cgo",
@@ -981,6 +1121,10 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "check for invalid conversions of uintptr to unsafe.Pointer\n\nThe unsafeptr analyzer reports likely incorrect uses of unsafe.Pointer\nto convert integers to pointers. A conversion from uintptr to\nunsafe.Pointer is invalid if it implies that there is a uintptr-typed\nword in memory that holds a pointer value, because that word will be\ninvisible to stack copying and to the garbage collector.",
 			Default: true,
 		},
+		{
+			Name: "unusedfunc",
+			Doc:  "check for exported functions that no package uses\n\nThe unusedfunc analyzer reports the exported functions that none of\nthe analyzed packages uses, such as the packages of the workspace and\ntheir tests. Unlike most analyzers, it looks at all the packages at once.\n\nTo reduce false positives it ignores:\n- methods, which may implement interfaces\n- functions in test files\n- functions with a directive comment, such as //export or //go:linkname",
+		},
 		{
 			Name: "unusedparams",
 			Doc:  "check for unused parameters of functions\n\nThe unusedparams analyzer checks functions to see if there are\nany parameters that are not being used.\n\nTo reduce false positives it ignores:\n- methods\n- parameters that do not have a name or are underscored\n- functions in test files\n- functions with empty bodies or those with just a return stmt",
@@ -1038,6 +1182,10 @@ var GeneratedAPIJSON = &APIJSON{
 			Name: "assignVariableTypes",
 			Doc:  "Enable/disable inlay hints for variable types in assign statements:\n\n\ti/* int/*, j/* int/* := 0, len(r)-1",
 		},
+		{
+			Name: "closureParameterTypes",
+			Doc:  "Enable/disable inlay hints for the type parameters bound by closure parameters at generic call sites:\n\n\tMap(names, func(s /*T = */string) /*U = */int { return len(s) })",
+		},
 		{
 			Name: "compositeLiteralFields",
 			Doc:  "Enable/disable inlay hints for composite literal field names:\n\n\t{in: \"Hello, world\", want: \"dlrow ,olleH\"}",
@@ -1054,6 +1202,14 @@ var GeneratedAPIJSON = &APIJSON{
 			Name: "functionTypeParameters",
 			Doc:  "Enable/disable inlay hints for implicit type parameters on generic functions:\n\n\tmyFoo/*[int, string]*/(1, \"hello\")",
 		},
+		{
+			Name: "implicitConversions",
+			Doc:  "Enable/disable inlay hints for implicit conversions to interface types in calls and assignments:\n\n\tio.Copy(/*io.Writer(*/f/*)*/, /*io.Reader(*/r/*)*/)",
+		},
+		{
+			Name: "methodValueReceivers",
+			Doc:  "Enable/disable inlay hints for the receivers bound by method values:\n\n\tf := buf.Write/* receiver: &buf*/",
+		},
 		{
 			Name: "parameterNames",
 			Doc:  "Enable/disable inlay hints for parameter names:\n\n\tparseInt(/* str: */ \"123\", /* radix: */ 8)",
@@ -1062,5 +1218,9 @@ var GeneratedAPIJSON = &APIJSON{
 			Name: "rangeVariableTypes",
 			Doc:  "Enable/disable inlay hints for variable types in range statements:\n\n\tfor k/* int*/, v/* string/* := range []string{} {\n\t\tfmt.Println(k, v)\n\t}",
 		},
+		{
+			Name: "untypedConstantTypes",
+			Doc:  "Enable/disable inlay hints for the default types of untyped constants:\n\n\tconst (\n\t\ttimeout/* float64*/ = 1.5\n\t\tretries/* int*/     = 3\n\t)",
+		},
 	},
 }
@@ -6,6 +6,7 @@ package source
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
@@ -23,13 +24,38 @@ type LensFunc func(context.Context, Snapshot, FileHandle) ([]protocol.CodeLens,
 // LensFuncs returns the supported lensFuncs for Go files.
 func LensFuncs() map[command.Command]LensFunc {
 	return map[command.Command]LensFunc{
-		command.Generate:      goGenerateCodeLens,
-		command.Test:          runTestCodeLens,
-		command.RegenerateCgo: regenerateCgoLens,
-		command.GCDetails:     toggleDetailsCodeLens,
+		command.Generate:         goGenerateCodeLens,
+		command.Test:             runTestCodeLens,
+		command.ProfileBenchmark: profileBenchmarkCodeLens,
+		command.RegenerateCgo:    regenerateCgoLens,
+		command.GCDetails:        toggleDetailsCodeLens,
+		command.References:       referencesCodeLens,
+		command.Implementations:  implementationsCodeLens,
 	}
 }
 
+// A ResolveLensFunc computes the command of a code lens that was returned
+// without one, in response to a codeLens/resolve request. Lenses whose
+// command is expensive to compute are resolved lazily so that they don't slow
+// down the textDocument/codeLens request.
+type ResolveLensFunc func(context.Context, Snapshot, FileHandle, command.PositionArgs) (protocol.Command, error)
+
+// ResolveLensFuncs returns the supported ResolveLensFuncs for Go files, keyed
+// by the lens that produced the unresolved code lens.
+func ResolveLensFuncs() map[command.Command]ResolveLensFunc {
+	return map[command.Command]ResolveLensFunc{
+		command.References:      resolveReferencesLens,
+		command.Implementations: resolveImplementationsLens,
+	}
+}
+
+// LensData is the data preserved on an unresolved code lens between the
+// textDocument/codeLens request and the codeLens/resolve request.
+type LensData struct {
+	Lens command.Command
+	Args command.PositionArgs
+}
+
 var (
 	testRe      = regexp.MustCompile("^Test[^a-z]")
 	benchmarkRe = regexp.MustCompile("^Benchmark[^a-z]")
@@ -84,6 +110,30 @@ func runTestCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle) ([]p
 	return codeLens, nil
 }
 
+func profileBenchmarkCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle) ([]protocol.CodeLens, error) {
+	fns, err := TestsAndBenchmarks(ctx, snapshot, fh)
+	if err != nil {
+		return nil, err
+	}
+	puri := protocol.URIFromSpanURI(fh.URI())
+	var codeLens []protocol.CodeLens
+	for _, fn := range fns.Benchmarks {
+		rng := protocol.Range{Start: fn.Rng.Start, End: fn.Rng.Start}
+		for _, kind := range []ProfileKind{CPUProfile, MemProfile} {
+			cmd, err := command.NewProfileBenchmarkCommand(fmt.Sprintf("profile benchmark (%s)", kind), command.ProfileBenchmarkArgs{
+				URI:       puri,
+				Benchmark: fn.Name,
+				Kind:      string(kind),
+			})
+			if err != nil {
+				return nil, err
+			}
+			codeLens = append(codeLens, protocol.CodeLens{Range: rng, Command: cmd})
+		}
+	}
+	return codeLens, nil
+}
+
 type testFn struct {
 	Name string
 	Rng  protocol.Range
@@ -242,3 +292,86 @@ func toggleDetailsCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle
 	}
 	return []protocol.CodeLens{{Range: rng, Command: cmd}}, nil
 }
+
+func referencesCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle) ([]protocol.CodeLens, error) {
+	return declCodeLens(ctx, snapshot, fh, command.References, false)
+}
+
+func implementationsCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle) ([]protocol.CodeLens, error) {
+	return declCodeLens(ctx, snapshot, fh, command.Implementations, true)
+}
+
+// declCodeLens returns an unresolved code lens for the given lens above each
+// top-level declaration in the file, or above each type declaration if
+// typesOnly is set. The command of each lens is computed by the corresponding
+// ResolveLensFunc.
+func declCodeLens(ctx context.Context, snapshot Snapshot, fh FileHandle, lens command.Command, typesOnly bool) ([]protocol.CodeLens, error) {
+	pgf, err := snapshot.ParseGo(ctx, fh, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	var idents []*ast.Ident
+	for _, decl := range pgf.File.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !typesOnly {
+				idents = append(idents, decl.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					idents = append(idents, spec.Name)
+				case *ast.ValueSpec:
+					if !typesOnly {
+						idents = append(idents, spec.Names...)
+					}
+				}
+			}
+		}
+	}
+	puri := protocol.URIFromSpanURI(fh.URI())
+	var codeLens []protocol.CodeLens
+	for _, id := range idents {
+		if id.Name == "_" {
+			continue
+		}
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, id.Pos(), id.End()).Range()
+		if err != nil {
+			return nil, err
+		}
+		codeLens = append(codeLens, protocol.CodeLens{
+			Range: protocol.Range{Start: rng.Start, End: rng.Start},
+			Data: LensData{
+				Lens: lens,
+				Args: command.PositionArgs{URI: puri, Position: rng.Start},
+			},
+		})
+	}
+	return codeLens, nil
+}
+
+func resolveReferencesLens(ctx context.Context, snapshot Snapshot, fh FileHandle, args command.PositionArgs) (protocol.Command, error) {
+	refs, err := References(ctx, snapshot, fh, args.Position, false)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return command.NewReferencesCommand(pluralize(len(refs), "reference"), args)
+}
+
+func resolveImplementationsLens(ctx context.Context, snapshot Snapshot, fh FileHandle, args command.PositionArgs) (protocol.Command, error) {
+	impls, err := Implementation(ctx, snapshot, fh, args.Position)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return command.NewImplementationsCommand(pluralize(len(impls), "implementation"), args)
+}
+
+// pluralize returns a count followed by the given noun, pluralized if
+// necessary, e.g. "1 reference" or "3 references".
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
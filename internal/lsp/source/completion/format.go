@@ -237,12 +237,12 @@ Suffixes:
 	uri := span.URIFromPath(pos.Filename)
 
 	// Find the source file of the candidate.
-	pkg, err := source.FindPackageFromPos(ctx, c.snapshot, obj.Pos())
+	declObj, pkg, err := source.FindCurrentObject(ctx, c.snapshot, obj)
 	if err != nil {
 		return item, nil
 	}
 
-	decl, _ := source.FindDeclAndField(pkg.GetSyntax(), obj.Pos()) // may be nil
+	decl, _ := source.FindDeclAndField(pkg.GetSyntax(), declObj.Pos()) // may be nil
 	hover, err := source.FindHoverContext(ctx, c.snapshot, pkg, obj, decl, nil)
 	if err != nil {
 		event.Error(ctx, "failed to find Hover", err, tag.URI.Of(uri))
@@ -0,0 +1,255 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/cover"
+	"github.com/cowpaths/golang-x-tools/internal/gocommand"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/safetoken"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// FileCoverage describes the test coverage of a single file.
+type FileCoverage struct {
+	// Uncovered holds a hint diagnostic for each block of the file that was
+	// not executed.
+	Uncovered []*Diagnostic
+
+	// Funcs holds the coverage of each function declared in the file.
+	Funcs []FuncCoverage
+}
+
+// FuncCoverage describes the test coverage of a single function.
+type FuncCoverage struct {
+	Name string
+	// Range is the range of the function's declaration.
+	Range protocol.Range
+	// Statements is the number of statements in the function, and Covered
+	// the number of them that were executed.
+	Statements, Covered int
+}
+
+// Percent returns the percentage of the function's statements that were
+// executed.
+func (f FuncCoverage) Percent() float64 {
+	if f.Statements == 0 {
+		return 100
+	}
+	return 100 * float64(f.Covered) / float64(f.Statements)
+}
+
+// Coverage computes the test coverage of Go files in the workspace.
+//
+// If profile is empty, Coverage runs `go test -coverprofile` for the package
+// containing uri and reports the coverage of that package. Otherwise it
+// reads the existing coverage profile at that location, and reports the
+// coverage of any workspace package it describes.
+//
+// The result holds the coverage of every compiled Go file of the packages
+// it describes, which is empty for files without code to cover, such as
+// test files.
+func Coverage(ctx context.Context, snapshot Snapshot, uri, profile span.URI) (map[span.URI]*FileCoverage, error) {
+	var (
+		pkgs     []Package
+		profiles []*cover.Profile
+		err      error
+	)
+	if profile == "" {
+		pkgs, err = snapshot.PackagesForFile(ctx, uri, TypecheckWorkspace, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(pkgs) == 0 {
+			return nil, fmt.Errorf("package could not be found for file: %s", uri.Filename())
+		}
+		pkgPath := pkgs[0].ForTest()
+		if pkgPath == "" {
+			pkgPath = pkgs[0].PkgPath()
+		}
+		tmpDir, err := ioutil.TempDir("", "gopls-cover")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+		out := filepath.Join(tmpDir, "cover.out")
+		inv := &gocommand.Invocation{
+			Verb:       "test",
+			Args:       []string{pkgPath, "-count=1", fmt.Sprintf("-coverprofile=%s", out)},
+			WorkingDir: filepath.Dir(uri.Filename()),
+		}
+		// Failing tests still write a profile, so only report an error if
+		// there is none.
+		_, runErr := snapshot.RunGoCommandDirect(ctx, Normal, inv)
+		if profiles, err = cover.ParseProfiles(out); err != nil {
+			if runErr != nil {
+				return nil, runErr
+			}
+			return nil, err
+		}
+	} else {
+		if profiles, err = cover.ParseProfiles(profile.Filename()); err != nil {
+			return nil, err
+		}
+		if pkgs, err = snapshot.ActivePackages(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Profiles name files by their package path and base name, except for
+	// packages outside of any module, which are named by their absolute
+	// path. The directory of a file need not match its package path, so
+	// files are resolved through the compiled files of the packages.
+	byPath := make(map[string][]Package)
+	for _, pkg := range pkgs {
+		byPath[pkg.PkgPath()] = append(byPath[pkg.PkgPath()], pkg)
+	}
+	resolve := func(name string) (Package, *ParsedGoFile) {
+		if filepath.IsAbs(name) {
+			for _, pkg := range pkgs {
+				for _, pgf := range pkg.CompiledGoFiles() {
+					if pgf.URI.Filename() == name {
+						return pkg, pgf
+					}
+				}
+			}
+			return nil, nil
+		}
+		dir, base := path.Split(name)
+		for _, pkg := range byPath[strings.TrimSuffix(dir, "/")] {
+			for _, pgf := range pkg.CompiledGoFiles() {
+				if filepath.Base(pgf.URI.Filename()) == base {
+					return pkg, pgf
+				}
+			}
+		}
+		return nil, nil
+	}
+	result := make(map[span.URI]*FileCoverage)
+	described := make(map[Package]bool)
+	for _, p := range profiles {
+		pkg, pgf := resolve(p.FileName)
+		if pgf == nil {
+			continue
+		}
+		// The syntax of a package may be trimmed, or parsed only up to
+		// its imports for a package held as export data.
+		if pgf.Mode != ParseFull {
+			fh, err := snapshot.GetFile(ctx, pgf.URI)
+			if err != nil {
+				return nil, err
+			}
+			if pgf, err = snapshot.ParseGo(ctx, fh, ParseFull); err != nil {
+				return nil, err
+			}
+		}
+		fc, err := fileCoverage(pgf, p)
+		if err != nil {
+			return nil, err
+		}
+		result[pgf.URI] = fc
+		described[pkg] = true
+	}
+	for _, pkg := range pkgs {
+		if !described[pkg] && profile != "" {
+			continue
+		}
+		for _, pgf := range pkg.CompiledGoFiles() {
+			if _, ok := result[pgf.URI]; !ok {
+				result[pgf.URI] = new(FileCoverage)
+			}
+		}
+	}
+	return result, nil
+}
+
+func fileCoverage(pgf *ParsedGoFile, p *cover.Profile) (*FileCoverage, error) {
+	fc := new(FileCoverage)
+
+	// Boundaries come in start/end pairs, and blocks don't overlap.
+	var start *cover.Boundary
+	for _, b := range p.Boundaries(pgf.Src) {
+		b := b
+		if b.Start {
+			start = &b
+			continue
+		}
+		if start == nil || start.Count > 0 {
+			start = nil
+			continue
+		}
+		if start.Offset > b.Offset || b.Offset > pgf.Tok.Size() {
+			return nil, fmt.Errorf("%s: invalid coverage block at offset %d", pgf.URI.Filename(), start.Offset)
+		}
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, pgf.Tok.Pos(start.Offset), pgf.Tok.Pos(b.Offset)).Range()
+		if err != nil {
+			return nil, err
+		}
+		fc.Uncovered = append(fc.Uncovered, &Diagnostic{
+			URI:      pgf.URI,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   CoverageReport,
+			Message:  "not covered by tests",
+		})
+		start = nil
+	}
+
+	for _, decl := range pgf.File.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, fn.Pos(), fn.End()).Range()
+		if err != nil {
+			return nil, err
+		}
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) == 1 {
+			name = fmt.Sprintf("(%s).%s", types.ExprString(fn.Recv.List[0].Type), name)
+		}
+		fnc := FuncCoverage{Name: name, Range: rng}
+		for _, b := range p.Blocks {
+			pos, ok := blockStart(pgf.Tok, b)
+			if !ok || pos < fn.Body.Pos() || pos >= fn.Body.End() {
+				continue
+			}
+			fnc.Statements += b.NumStmt
+			if b.Count > 0 {
+				fnc.Covered += b.NumStmt
+			}
+		}
+		fc.Funcs = append(fc.Funcs, fnc)
+	}
+	return fc, nil
+}
+
+// blockStart returns the position at which a coverage block starts. Block
+// lines and columns are 1-based, and columns are byte offsets.
+func blockStart(tok *token.File, b cover.ProfileBlock) (token.Pos, bool) {
+	if b.StartLine < 1 || b.StartLine > tok.LineCount() {
+		return token.NoPos, false
+	}
+	offset, err := safetoken.Offset(tok, tok.LineStart(b.StartLine))
+	if err != nil {
+		return token.NoPos, false
+	}
+	offset += b.StartCol - 1
+	if offset > tok.Size() {
+		return token.NoPos, false
+	}
+	return tok.Pos(offset), true
+}
@@ -6,7 +6,9 @@ package source
 
 import (
 	"context"
+	"go/ast"
 
+	"github.com/cowpaths/golang-x-tools/internal/analysisinternal"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/span"
 )
@@ -46,7 +48,18 @@ func Analyze(ctx context.Context, snapshot Snapshot, pkg Package, includeConveni
 		}
 	}
 
-	analysisDiagnostics, err := snapshot.Analyze(ctx, pkg.ID(), analyzers)
+	analysisDiagnostics, failed, err := snapshot.Analyze(ctx, pkg.ID(), analyzers)
+	if err != nil {
+		return nil, err
+	}
+	if !pkg.HasTypeErrors() {
+		pluginDiagnostics, err := snapshot.PluginDiagnostics(ctx, pkg.ID())
+		if err != nil {
+			return nil, err
+		}
+		analysisDiagnostics = append(analysisDiagnostics, pluginDiagnostics...)
+	}
+	analysisDiagnostics, err = suppressDiagnostics(ctx, snapshot, pkg, analyzers, failed, analysisDiagnostics)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +72,151 @@ func Analyze(ctx context.Context, snapshot Snapshot, pkg Package, includeConveni
 	return reports, nil
 }
 
+// suppressDiagnostics removes the diagnostics suppressed by the
+// //lint:ignore directives of the package, and adds diagnostics for the
+// malformed directives and the unused ones among those naming an enabled
+// analyzer.
+//
+// The directives naming a whole-program analyzer are never reported as
+// unused, as its diagnostics are computed separately by AnalyzeProgram,
+// nor are those naming an analyzer that failed.
+func suppressDiagnostics(ctx context.Context, snapshot Snapshot, pkg Package, analyzers, failed []*Analyzer, diagnostics []*Diagnostic) ([]*Diagnostic, error) {
+	pgfs, err := parseFull(ctx, snapshot, pkg.CompiledGoFiles())
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, pgf := range pgfs {
+		files = append(files, pgf.File)
+	}
+	ignores := analysisinternal.ParseIgnores(snapshot.FileSet(), files)
+	kept := filterIgnored(ignores, diagnostics)
+
+	enabled := make(map[string]bool)
+	for _, a := range analyzers {
+		if a.IsEnabled(snapshot.View()) && a.Analyzer.RunProgram == nil {
+			enabled[a.Analyzer.Name] = true
+		}
+	}
+	for _, a := range failed {
+		delete(enabled, a.Analyzer.Name)
+	}
+	for _, p := range ignores.Problems(func(name string) bool { return enabled[name] }) {
+		for _, pgf := range pgfs {
+			if pgf.Tok.Base() <= int(p.Pos) && int(p.Pos) <= pgf.Tok.Base()+pgf.Tok.Size() {
+				rng, err := NewMappedRange(pgf.Tok, pgf.Mapper, p.Pos, p.End).Range()
+				if err != nil {
+					return nil, err
+				}
+				kept = append(kept, &Diagnostic{
+					URI:      pgf.URI,
+					Range:    rng,
+					Severity: protocol.SeverityWarning,
+					Source:   LintIgnore,
+					Message:  p.Message,
+				})
+				break
+			}
+		}
+	}
+	return kept, nil
+}
+
+// AnalyzeProgram runs the enabled whole-program analyzers on the
+// packages, and returns their diagnostics that the //lint:ignore
+// directives of the packages do not suppress.
+func AnalyzeProgram(ctx context.Context, snapshot Snapshot, pkgs []Package) (map[span.URI][]*Diagnostic, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var analyzers []*Analyzer
+	for _, cat := range []map[string]*Analyzer{snapshot.View().Options().DefaultAnalyzers, snapshot.View().Options().StaticcheckAnalyzers} {
+		for _, a := range cat {
+			if a.Analyzer.RunProgram != nil && a.IsEnabled(snapshot.View()) {
+				analyzers = append(analyzers, a)
+			}
+		}
+	}
+	if len(analyzers) == 0 || len(pkgs) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	var files []*ast.File
+	seen := make(map[span.URI]bool) // files are shared by test variants
+	for _, pkg := range pkgs {
+		ids = append(ids, pkg.ID())
+		pgfs, err := parseFull(ctx, snapshot, pkg.CompiledGoFiles())
+		if err != nil {
+			return nil, err
+		}
+		for _, pgf := range pgfs {
+			if !seen[pgf.URI] {
+				seen[pgf.URI] = true
+				files = append(files, pgf.File)
+			}
+		}
+	}
+	diagnostics, err := snapshot.AnalyzeProgram(ctx, ids, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	ignores := analysisinternal.ParseIgnores(snapshot.FileSet(), files)
+
+	reports := map[span.URI][]*Diagnostic{}
+	for _, diag := range filterIgnored(ignores, diagnostics) {
+		reports[diag.URI] = append(reports[diag.URI], diag)
+	}
+	return reports, nil
+}
+
+// parseFull returns the files that have suppression directives, parsed
+// in full, since the syntax of a package may be trimmed, so as to find the
+// lines they cover. The trimmed syntax keeps the comments, so that the
+// other files need not be parsed again, but the files of a package held
+// as export data are parsed only up to their imports.
+func parseFull(ctx context.Context, snapshot Snapshot, pgfs []*ParsedGoFile) ([]*ParsedGoFile, error) {
+	var full []*ParsedGoFile
+	for _, pgf := range pgfs {
+		if pgf.Mode == ParseHeader {
+			fh, err := snapshot.GetFile(ctx, pgf.URI)
+			if err != nil {
+				return nil, err
+			}
+			if pgf, err = snapshot.ParseGo(ctx, fh, ParseFull); err != nil {
+				return nil, err
+			}
+		}
+		if !analysisinternal.HasIgnores(pgf.File) {
+			continue
+		}
+		if pgf.Mode != ParseFull {
+			fh, err := snapshot.GetFile(ctx, pgf.URI)
+			if err != nil {
+				return nil, err
+			}
+			if pgf, err = snapshot.ParseGo(ctx, fh, ParseFull); err != nil {
+				return nil, err
+			}
+		}
+		full = append(full, pgf)
+	}
+	return full, nil
+}
+
+// filterIgnored returns the diagnostics that the directives do not
+// suppress.
+func filterIgnored(ignores *analysisinternal.Ignores, diagnostics []*Diagnostic) []*Diagnostic {
+	var kept []*Diagnostic
+	for _, d := range diagnostics {
+		if d.Analyzer != nil && ignores.Ignored(d.Analyzer.Analyzer.Name, d.URI.Filename(), int(d.Range.Start.Line)+1) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
 func FileDiagnostics(ctx context.Context, snapshot Snapshot, uri span.URI) (VersionedFileIdentity, []*Diagnostic, error) {
 	fh, err := snapshot.GetVersionedFile(ctx, uri)
 	if err != nil {
@@ -282,16 +282,18 @@ func findIdentifier(ctx context.Context, snapshot Snapshot, pkg Package, pgf *Pa
 		}
 	}
 
-	rng, err := objToMappedRange(snapshot, pkg, result.Declaration.obj)
+	declObj, declPkg, err := FindCurrentObject(ctx, snapshot, result.Declaration.obj)
 	if err != nil {
 		return nil, err
 	}
-	result.Declaration.MappedRange = append(result.Declaration.MappedRange, rng)
+	result.Declaration.obj = declObj
 
-	declPkg, err := FindPackageFromPos(ctx, snapshot, result.Declaration.obj.Pos())
+	rng, err := objToMappedRange(snapshot, declPkg, result.Declaration.obj)
 	if err != nil {
 		return nil, err
 	}
+	result.Declaration.MappedRange = append(result.Declaration.MappedRange, rng)
+
 	result.Declaration.node, _ = FindDeclAndField(declPkg.GetSyntax(), result.Declaration.obj.Pos()) // may be nil
 
 	// Ensure that we have the full declaration, in case the declaration was
@@ -312,7 +314,11 @@ func findIdentifier(ctx context.Context, snapshot Snapshot, pkg Package, pgf *Pa
 		if hasErrorType(result.Type.Object) {
 			return result, nil
 		}
-		if result.Type.MappedRange, err = objToMappedRange(snapshot, pkg, result.Type.Object); err != nil {
+		typeObj, typePkg, err := FindCurrentObject(ctx, snapshot, result.Type.Object)
+		if err != nil {
+			return nil, err
+		}
+		if result.Type.MappedRange, err = objToMappedRange(snapshot, typePkg, typeObj); err != nil {
 			return nil, err
 		}
 	}
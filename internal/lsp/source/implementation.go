@@ -32,7 +32,13 @@ func Implementation(ctx context.Context, snapshot Snapshot, f FileHandle, pp pro
 		if impl.pkg == nil || len(impl.pkg.CompiledGoFiles()) == 0 {
 			continue
 		}
-		rng, err := objToMappedRange(snapshot, impl.pkg, impl.obj)
+		obj, pkg := impl.obj, impl.pkg
+		if pkg.InExportData(obj.Pos()) {
+			if obj, pkg, err = FindCurrentObject(ctx, snapshot, obj); err != nil {
+				return nil, err
+			}
+		}
+		rng, err := objToMappedRange(snapshot, pkg, obj)
 		if err != nil {
 			return nil, err
 		}
@@ -107,6 +113,18 @@ func implementations(ctx context.Context, s Snapshot, f FileHandle, pp protocol.
 		for _, pkg := range knownPkgs {
 			pkgs[pkg.GetTypes()] = pkg
 			info := pkg.GetTypesInfo()
+			// A package held as export data has no syntax, and so no
+			// Defs: its named types are those of its scope.
+			if len(info.Defs) == 0 {
+				scope := pkg.GetTypes().Scope()
+				for _, name := range scope.Names() {
+					if obj, ok := scope.Lookup(name).(*types.TypeName); ok && !obj.IsAlias() {
+						if named, ok := obj.Type().(*types.Named); ok {
+							allNamed = append(allNamed, named)
+						}
+					}
+				}
+			}
 			for _, obj := range info.Defs {
 				obj, ok := obj.(*types.TypeName)
 				// We ignore aliases 'type M = N' to avoid duplicate reporting
@@ -222,10 +240,16 @@ func qualifiedObjsAtProtocolPos(ctx context.Context, s Snapshot, uri span.URI, p
 	if len(pkgs) == 0 {
 		return nil, errNoObjectFound
 	}
-	pkg := pkgs[0]
-	pgf, err := pkg.File(uri)
-	if err != nil {
-		return nil, err
+	// The files of a package held as export data are parsed only up to
+	// their imports (see Package.InExportData).
+	var pgf *ParsedGoFile
+	for _, pkg := range pkgs {
+		if pgf, err = pkg.File(uri); err != nil {
+			return nil, err
+		}
+		if pgf.Mode != ParseHeader {
+			break
+		}
 	}
 	pos, err := pgf.Mapper.Pos(pp)
 	if err != nil {
@@ -282,6 +306,9 @@ func qualifiedObjsAtLocation(ctx context.Context, s Snapshot, key positionKey, s
 		if err != nil {
 			return nil, err
 		}
+		if pgf.Mode == ParseHeader {
+			continue // held as export data, without type information
+		}
 		pos := pgf.Tok.Pos(key.offset)
 		path := pathEnclosingObjNode(pgf.File, pos)
 		if path == nil {
@@ -349,7 +376,13 @@ func qualifiedObjsAtLocation(ctx context.Context, s Snapshot, key positionKey, s
 			// is in another package, but this should be good enough to find all
 			// uses.
 
-			if key, found := packagePositionKey(pkg, obj.Pos()); found {
+			declObj, declPkg := obj, pkg
+			if pkg.InExportData(obj.Pos()) {
+				if declObj, declPkg, err = FindCurrentObject(ctx, s, obj); err != nil {
+					return nil, err
+				}
+			}
+			if key, found := packagePositionKey(declPkg, declObj.Pos()); found {
 				otherObjs, err := qualifiedObjsAtLocation(ctx, s, key, seen)
 				if err != nil {
 					return nil, err
@@ -11,6 +11,7 @@ import (
 	"go/constant"
 	"go/token"
 	"go/types"
+	"sort"
 	"strings"
 
 	"github.com/cowpaths/golang-x-tools/internal/event"
@@ -39,6 +40,10 @@ const (
 	CompositeLiteralTypes      = "compositeLiteralTypes"
 	CompositeLiteralFieldNames = "compositeLiteralFields"
 	FunctionTypeParameters     = "functionTypeParameters"
+	ImplicitConversions        = "implicitConversions"
+	UntypedConstantTypes       = "untypedConstantTypes"
+	ClosureParameterTypes      = "closureParameterTypes"
+	MethodValueReceivers       = "methodValueReceivers"
 )
 
 var AllInlayHints = map[string]*Hint{
@@ -102,6 +107,37 @@ var AllInlayHints = map[string]*Hint{
 	myFoo/*[int, string]*/(1, "hello")`,
 		Run: funcTypeParams,
 	},
+	ImplicitConversions: {
+		Name: ImplicitConversions,
+		Doc: `Enable/disable inlay hints for implicit conversions to interface types in calls and assignments:
+
+	io.Copy(/*io.Writer(*/f/*)*/, /*io.Reader(*/r/*)*/)`,
+		Run: implicitConversions,
+	},
+	UntypedConstantTypes: {
+		Name: UntypedConstantTypes,
+		Doc: `Enable/disable inlay hints for the default types of untyped constants:
+
+	const (
+		timeout/* float64*/ = 1.5
+		retries/* int*/     = 3
+	)`,
+		Run: untypedConstantTypes,
+	},
+	ClosureParameterTypes: {
+		Name: ClosureParameterTypes,
+		Doc: `Enable/disable inlay hints for the type parameters bound by closure parameters at generic call sites:
+
+	Map(names, func(s /*T = */string) /*U = */int { return len(s) })`,
+		Run: closureParameterTypes,
+	},
+	MethodValueReceivers: {
+		Name: MethodValueReceivers,
+		Doc: `Enable/disable inlay hints for the receivers bound by method values:
+
+	f := buf.Write/* receiver: &buf*/`,
+		Run: methodValueReceivers,
+	},
 }
 
 func InlayHint(ctx context.Context, snapshot Snapshot, fh FileHandle, pRng protocol.Range) ([]protocol.InlayHint, error) {
@@ -113,13 +149,18 @@ func InlayHint(ctx context.Context, snapshot Snapshot, fh FileHandle, pRng proto
 		return nil, fmt.Errorf("getting file for InlayHint: %w", err)
 	}
 
-	// Collect a list of the inlay hints that are enabled.
+	// Collect a list of the inlay hints that are enabled, sorted by name so
+	// that hints of the same kind at the same position have a stable order.
 	inlayHintOptions := snapshot.View().Options().InlayHintOptions
-	var enabledHints []InlayHintFunc
+	var names []string
 	for hint, enabled := range inlayHintOptions.Hints {
-		if !enabled {
-			continue
+		if enabled {
+			names = append(names, hint)
 		}
+	}
+	sort.Strings(names)
+	var enabledHints []InlayHintFunc
+	for _, hint := range names {
 		if h, ok := AllInlayHints[hint]; ok {
 			enabledHints = append(enabledHints, h.Run)
 		}
@@ -154,6 +195,19 @@ func InlayHint(ctx context.Context, snapshot Snapshot, fh FileHandle, pRng proto
 		}
 		return true
 	})
+
+	// Several hints may share a position: order them by position, then
+	// by kind, so that their order does not depend on the enabled hints.
+	sort.SliceStable(hints, func(i, j int) bool {
+		pi, pj := hints[i].Position, hints[j].Position
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		if pi.Character != pj.Character {
+			return pi.Character < pj.Character
+		}
+		return hints[i].Kind < hints[j].Kind
+	})
 	return hints, nil
 }
 
@@ -228,6 +282,255 @@ func funcTypeParams(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, _
 	}}
 }
 
+func implicitConversions(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	var hints []protocol.InlayHint
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		if tv, ok := info.Types[n.Fun]; !ok || tv.IsType() || tv.IsBuiltin() {
+			return nil
+		}
+		signature, ok := info.TypeOf(n.Fun).(*types.Signature)
+		if !ok {
+			return nil
+		}
+		params := signature.Params()
+		for i, arg := range n.Args {
+			var typ types.Type
+			switch {
+			case signature.Variadic() && i >= params.Len()-1:
+				// Arguments passed with "..." are not converted.
+				if n.Ellipsis.IsValid() {
+					continue
+				}
+				typ = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+			case i < params.Len():
+				typ = params.At(i).Type()
+			default:
+				continue
+			}
+			hints = append(hints, interfaceConversion(arg, typ, tmap, info, q)...)
+		}
+	case *ast.AssignStmt:
+		if n.Tok != token.ASSIGN || len(n.Lhs) != len(n.Rhs) {
+			return nil
+		}
+		for i, lhs := range n.Lhs {
+			if typ := info.TypeOf(lhs); typ != nil {
+				hints = append(hints, interfaceConversion(n.Rhs[i], typ, tmap, info, q)...)
+			}
+		}
+	case *ast.ValueSpec:
+		if n.Type == nil || len(n.Names) != len(n.Values) {
+			return nil
+		}
+		if typ := info.TypeOf(n.Type); typ != nil {
+			for _, v := range n.Values {
+				hints = append(hints, interfaceConversion(v, typ, tmap, info, q)...)
+			}
+		}
+	}
+	return hints
+}
+
+// interfaceConversion returns hints surrounding e if its value is implicitly
+// converted to the interface type typ.
+func interfaceConversion(e ast.Expr, typ types.Type, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	if !types.IsInterface(typ) || typeparams.IsTypeParam(typ) {
+		return nil
+	}
+	from := info.TypeOf(e)
+	if from == nil || types.IsInterface(from) {
+		return nil
+	}
+	if b, ok := from.(*types.Basic); ok && b.Kind() == types.UntypedNil {
+		return nil
+	}
+	start, ok := tmap.Position(e.Pos())
+	if !ok {
+		return nil
+	}
+	end, ok := tmap.Position(e.End())
+	if !ok {
+		return nil
+	}
+	return []protocol.InlayHint{{
+		Position: &start,
+		Label:    buildLabel(types.TypeString(typ, *q) + "("),
+		Kind:     protocol.Type,
+	}, {
+		Position: &end,
+		Label:    buildLabel(")"),
+		Kind:     protocol.Type,
+	}}
+}
+
+func closureParameterTypes(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	ce, ok := node.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	fun, _, _, _ := typeparams.UnpackIndexExpr(ce.Fun)
+	if fun == nil {
+		fun = ce.Fun
+	}
+	var id *ast.Ident
+	switch f := fun.(type) {
+	case *ast.Ident:
+		id = f
+	case *ast.SelectorExpr:
+		id = f.Sel
+	default:
+		return nil
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok {
+		return nil
+	}
+	generic := fn.Type().(*types.Signature)
+	if typeparams.ForSignature(generic).Len() == 0 {
+		return nil
+	}
+	inst, ok := info.TypeOf(ce.Fun).(*types.Signature)
+	if !ok || inst.Params().Len() != generic.Params().Len() {
+		return nil
+	}
+
+	var hints []protocol.InlayHint
+	for i, arg := range ce.Args {
+		lit, ok := arg.(*ast.FuncLit)
+		if !ok || i >= generic.Params().Len() {
+			continue
+		}
+		sig, ok := generic.Params().At(i).Type().(*types.Signature)
+		if !ok {
+			continue
+		}
+		hints = append(hints, boundTypeParams(lit.Type.Params, sig.Params(), tmap, q)...)
+		hints = append(hints, boundTypeParams(lit.Type.Results, sig.Results(), tmap, q)...)
+	}
+	return hints
+}
+
+// boundTypeParams returns a hint before each type in fields whose
+// corresponding generic type in vars mentions a type parameter.
+func boundTypeParams(fields *ast.FieldList, vars *types.Tuple, tmap *lsppos.TokenMapper, q *types.Qualifier) []protocol.InlayHint {
+	if fields == nil {
+		return nil
+	}
+	var hints []protocol.InlayHint
+	i := 0
+	for _, f := range fields.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		if i+n > vars.Len() {
+			break
+		}
+		typ := vars.At(i).Type()
+		i += n
+		if !mentionsTypeParam(typ) {
+			continue
+		}
+		start, ok := tmap.Position(f.Type.Pos())
+		if !ok {
+			continue
+		}
+		hints = append(hints, protocol.InlayHint{
+			Position:     &start,
+			Label:        buildLabel(types.TypeString(typ, *q) + " ="),
+			Kind:         protocol.Type,
+			PaddingRight: true,
+		})
+	}
+	return hints
+}
+
+// mentionsTypeParam reports whether the string form of typ refers to a type
+// parameter, such as T or []T.
+func mentionsTypeParam(typ types.Type) bool {
+	switch t := typ.(type) {
+	case *typeparams.TypeParam:
+		return true
+	case *types.Pointer:
+		return mentionsTypeParam(t.Elem())
+	case *types.Slice:
+		return mentionsTypeParam(t.Elem())
+	case *types.Array:
+		return mentionsTypeParam(t.Elem())
+	case *types.Chan:
+		return mentionsTypeParam(t.Elem())
+	case *types.Map:
+		return mentionsTypeParam(t.Key()) || mentionsTypeParam(t.Elem())
+	case *types.Named:
+		targs := typeparams.NamedTypeArgs(t)
+		for i := 0; i < targs.Len(); i++ {
+			if mentionsTypeParam(targs.At(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func methodValueReceivers(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, _ *types.Qualifier) []protocol.InlayHint {
+	// A selector denotes a method value unless it is called directly, so
+	// inspect the children of node to know whether each one is called.
+	var fun ast.Expr
+	if ce, ok := node.(*ast.CallExpr); ok {
+		fun = ce.Fun
+	}
+	var hints []protocol.InlayHint
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == node {
+			return true
+		}
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || sel == fun {
+			return false
+		}
+		if h := methodValueReceiver(sel, tmap, info); h != nil {
+			hints = append(hints, *h)
+		}
+		return false
+	})
+	return hints
+}
+
+func methodValueReceiver(sel *ast.SelectorExpr, tmap *lsppos.TokenMapper, info *types.Info) *protocol.InlayHint {
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return nil
+	}
+	recv := selection.Obj().Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	_, ptrRecv := recv.Type().Underlying().(*types.Pointer)
+	_, ptrX := selection.Recv().Underlying().(*types.Pointer)
+	x := types.ExprString(sel.X)
+	var label string
+	switch {
+	case ptrRecv && !ptrX:
+		label = "&" + x
+	case !ptrRecv && ptrX:
+		label = "copy of *" + x
+	case !ptrRecv:
+		label = "copy of " + x
+	default:
+		label = x
+	}
+	end, ok := tmap.Position(sel.End())
+	if !ok {
+		return nil
+	}
+	return &protocol.InlayHint{
+		Position:    &end,
+		Label:       buildLabel("receiver: " + label),
+		PaddingLeft: true,
+	}
+}
+
 func assignVariableTypes(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
 	stmt, ok := node.(*ast.AssignStmt)
 	if !ok || stmt.Tok != token.DEFINE {
@@ -326,6 +629,41 @@ func constantValues(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, _
 	return hints
 }
 
+func untypedConstantTypes(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	genDecl, ok := node.(*ast.GenDecl)
+	if !ok || genDecl.Tok != token.CONST {
+		return nil
+	}
+
+	var hints []protocol.InlayHint
+	for _, v := range genDecl.Specs {
+		spec, ok := v.(*ast.ValueSpec)
+		if !ok || spec.Type != nil {
+			continue
+		}
+		for _, name := range spec.Names {
+			obj, ok := info.Defs[name].(*types.Const)
+			if !ok {
+				continue
+			}
+			if b, ok := obj.Type().(*types.Basic); !ok || b.Info()&types.IsUntyped == 0 {
+				continue
+			}
+			end, ok := tmap.Position(name.End())
+			if !ok {
+				continue
+			}
+			hints = append(hints, protocol.InlayHint{
+				Position:    &end,
+				Label:       buildLabel(types.TypeString(types.Default(obj.Type()), *q)),
+				Kind:        protocol.Type,
+				PaddingLeft: true,
+			})
+		}
+	}
+	return hints
+}
+
 func compositeLiteralFields(node ast.Node, tmap *lsppos.TokenMapper, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
 	compLit, ok := node.(*ast.CompositeLit)
 	if !ok {
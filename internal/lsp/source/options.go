@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -60,6 +61,7 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/simplifyslice"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/stubmethods"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/undeclaredname"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/unusedfunc"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/unusedparams"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/unusedvariable"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/analysis/useany"
@@ -107,7 +109,9 @@ func DefaultOptions() *Options {
 						protocol.SourceOrganizeImports: true,
 						protocol.QuickFix:              true,
 					},
-					Work: {},
+					Work: {
+						protocol.QuickFix: true,
+					},
 					Sum:  {},
 					Tmpl: {},
 				},
@@ -238,6 +242,15 @@ type BuildOptions struct {
 	// Include only project_a, but not node_modules inside it: `-`, `+project_a`, `-project_a/node_modules`
 	DirectoryFilters []string
 
+	// BuildConfigurations lists additional build configurations, as
+	// `GOOS/GOARCH` pairs such as `windows/amd64`, for which `gopls` loads
+	// and type-checks the workspace packages in the background. The files
+	// that are compiled only in one of these configurations, such as those
+	// constrained by `//go:build windows`, get its type errors and analysis
+	// diagnostics, with the configuration in their source. They are updated
+	// when files are saved, and when these files are edited.
+	BuildConfigurations []string `status:"experimental"`
+
 	// TemplateExtensions gives the extensions of file names that are treateed
 	// as template files. (The extension
 	// is the part of the file name after the final dot.)
@@ -249,6 +262,17 @@ type BuildOptions struct {
 	// Values other than `Normal` are untested and may break in surprising ways.
 	MemoryMode MemoryMode `status:"experimental"`
 
+	// MemoryBudget bounds the memory that `gopls` uses for the syntax and
+	// type information of workspace packages, such as "4GB". When they exceed
+	// it, the least recently used packages without open files are evicted:
+	// they are held only as the export data of their last type-check, from
+	// which their importers read their types, and with its diagnostics, until
+	// they change. The features that need their syntax, such as Find
+	// References and whole-program analyzers, type-check them in full again
+	// each time, trading time for memory, and the template features do not
+	// see them. The empty string means no limit.
+	MemoryBudget string `status:"experimental"`
+
 	// ExpandWorkspaceToModule instructs `gopls` to adjust the scope of the
 	// workspace to find the best available module root. `gopls` first looks for
 	// a go.mod file in any parent directory of the workspace folder, expanding
@@ -270,6 +294,16 @@ type BuildOptions struct {
 	// comprehensively test.
 	ExperimentalPackageCacheKey bool `status:"experimental"`
 
+	// ExperimentalEarlyCutoff controls whether a change to a package that
+	// leaves its export data identical, such as an edit of a function body
+	// that does not move any declaration, preserves the type information
+	// of the packages that depend on it, rather than type-checking them
+	// again. The package itself is type-checked again, so features that
+	// relate its objects to those of its dependents by identity, such as
+	// implementations, may miss some results until the dependents are
+	// type-checked again.
+	ExperimentalEarlyCutoff bool `status:"experimental"`
+
 	// AllowModfileModifications disables -mod=readonly, allowing imports from
 	// out-of-scope modules. This option will eventually be removed.
 	AllowModfileModifications bool `status:"experimental"`
@@ -388,6 +422,22 @@ type DiagnosticOptions struct {
 	// Staticcheck enables additional analyses from staticcheck.io.
 	Staticcheck bool `status:"experimental"`
 
+	// AnalysisPlugins lists the analysis tools, built with the
+	// `go/analysis/pluginchecker` package, whose
+	// analyzers `gopls` applies to the packages with open files, in
+	// addition to its own. Each entry is the path of a tool, optionally
+	// followed by flags of its analyzers, separated by spaces. The
+	// `analyses` setting enables or disables their analyzers by name.
+	AnalysisPlugins []string `status:"experimental"`
+
+	// Vulncheck enables diagnostics for the vulnerabilities that govulncheck
+	// finds in the required modules: on their require directives in go.mod
+	// files, and on the calls in the workspace that reach a vulnerable
+	// symbol. The vulnerability database is read from the GOVULNDB
+	// environment variable, which may name a local directory with a
+	// file:// URL for use without network access.
+	Vulncheck bool `status:"experimental"`
+
 	// Annotations specifies the various kinds of optimization diagnostics
 	// that should be reported by the gc_details command.
 	Annotations map[Annotation]bool `status:"experimental"`
@@ -760,6 +810,8 @@ func (o *Options) Clone() *Options {
 	result.SetEnvSlice(o.EnvSlice())
 	result.BuildFlags = copySlice(o.BuildFlags)
 	result.DirectoryFilters = copySlice(o.DirectoryFilters)
+	result.BuildConfigurations = copySlice(o.BuildConfigurations)
+	result.AnalysisPlugins = copySlice(o.AnalysisPlugins)
 
 	copyAnalyzerMap := func(src map[string]*Analyzer) map[string]*Analyzer {
 		dst := make(map[string]*Analyzer)
@@ -806,6 +858,43 @@ func (o *Options) enableAllExperimentMaps() {
 	}
 }
 
+// ParseBuildConfiguration returns the GOOS and GOARCH of a build
+// configuration of the form GOOS/GOARCH.
+func ParseBuildConfiguration(config string) (goos, goarch string, err error) {
+	parts := strings.Split(config, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid build configuration %q, expect GOOS/GOARCH", config)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ParseMemorySize returns the number of bytes of a size such as "512MB" or
+// "4GB". The empty string is 0.
+func ParseMemorySize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	for _, u := range units {
+		if n := strings.TrimSuffix(size, u.suffix); n != size {
+			v, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+			if err != nil || v < 0 {
+				break
+			}
+			return v * u.factor, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid memory size %q, expect a number of B, KB, MB or GB", size)
+}
+
 // validateDirectoryFilter validates if the filter string
 // - is not empty
 // - start with either + or -
@@ -882,6 +971,22 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 			filters = append(filters, strings.TrimRight(filepath.FromSlash(filter), "/"))
 		}
 		o.DirectoryFilters = filters
+	case "buildConfigurations":
+		iconfigs, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid type %T, expect list", value)
+			break
+		}
+		var configs []string
+		for _, iconfig := range iconfigs {
+			config := fmt.Sprintf("%v", iconfig)
+			if _, _, err := ParseBuildConfiguration(config); err != nil {
+				result.errorf(err.Error())
+				return result
+			}
+			configs = append(configs, config)
+		}
+		o.BuildConfigurations = configs
 	case "memoryMode":
 		if s, ok := result.asOneOf(
 			string(ModeNormal),
@@ -889,6 +994,14 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 		); ok {
 			o.MemoryMode = MemoryMode(s)
 		}
+	case "memoryBudget":
+		if s, ok := result.asString(); ok {
+			if _, err := ParseMemorySize(s); err != nil {
+				result.errorf(err.Error())
+				break
+			}
+			o.MemoryBudget = s
+		}
 	case "completionDocumentation":
 		result.setBool(&o.CompletionDocumentation)
 	case "usePlaceholders":
@@ -987,6 +1100,26 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 			}
 		}
 
+	case "analysisPlugins":
+		iplugins, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid type %T, expect list", value)
+			break
+		}
+		var plugins []string
+		for _, iplugin := range iplugins {
+			plugin := fmt.Sprintf("%v", iplugin)
+			if len(strings.Fields(plugin)) == 0 {
+				result.errorf("empty analysis plugin")
+				return result
+			}
+			plugins = append(plugins, plugin)
+		}
+		o.AnalysisPlugins = plugins
+
+	case "vulncheck":
+		result.setBool(&o.Vulncheck)
+
 	case "local":
 		result.setString(&o.Local)
 
@@ -1046,6 +1179,9 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "experimentalPackageCacheKey":
 		result.setBool(&o.ExperimentalPackageCacheKey)
 
+	case "experimentalEarlyCutoff":
+		result.setBool(&o.ExperimentalEarlyCutoff)
+
 	case "allowModfileModifications":
 		result.setBool(&o.AllowModfileModifications)
 
@@ -1358,6 +1494,7 @@ func defaultAnalyzers() map[string]*Analyzer {
 		shadow.Analyzer.Name:           {Analyzer: shadow.Analyzer, Enabled: false},
 		sortslice.Analyzer.Name:        {Analyzer: sortslice.Analyzer, Enabled: true},
 		testinggoroutine.Analyzer.Name: {Analyzer: testinggoroutine.Analyzer, Enabled: true},
+		unusedfunc.Analyzer.Name:       {Analyzer: unusedfunc.Analyzer, Enabled: false},
 		unusedparams.Analyzer.Name:     {Analyzer: unusedparams.Analyzer, Enabled: false},
 		unusedwrite.Analyzer.Name:      {Analyzer: unusedwrite.Analyzer, Enabled: false},
 		useany.Analyzer.Name:           {Analyzer: useany.Analyzer, Enabled: false},
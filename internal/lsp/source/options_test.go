@@ -156,6 +156,21 @@ func TestSetOption(t *testing.T) {
 				return len(o.DirectoryFilters) == 0
 			},
 		},
+		{
+			name:  "memoryBudget",
+			value: "512MB",
+			check: func(o Options) bool {
+				return o.MemoryBudget == "512MB"
+			},
+		},
+		{
+			name:      "memoryBudget",
+			value:     "lots",
+			wantError: true,
+			check: func(o Options) bool {
+				return o.MemoryBudget == ""
+			},
+		},
 		{
 			name: "annotations",
 			value: map[string]interface{}{
@@ -0,0 +1,173 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cowpaths/golang-x-tools/internal/gocommand"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/safetoken"
+	"github.com/cowpaths/golang-x-tools/internal/pprof"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// ProfileKind is the kind of profile collected when profiling a benchmark.
+type ProfileKind string
+
+const (
+	// CPUProfile records CPU time, as with `go test -cpuprofile`.
+	CPUProfile ProfileKind = "cpu"
+
+	// MemProfile records allocated bytes, as with `go test -memprofile`.
+	MemProfile ProfileKind = "mem"
+)
+
+// maxHotSpots is the maximum number of hot lines reported for a profile.
+const maxHotSpots = 10
+
+// BenchmarkProfile runs a single benchmark in the package of the given test
+// file with profiling enabled, and returns hint diagnostics for the hottest
+// lines of that package, attributing each sample to every line of its call
+// stack, so that a call is as hot as the functions it calls.
+func BenchmarkProfile(ctx context.Context, snapshot Snapshot, uri span.URI, benchmark string, kind ProfileKind) (map[span.URI][]*Diagnostic, error) {
+	var flag, sampleType string
+	switch kind {
+	case CPUProfile, "":
+		kind, flag, sampleType = CPUProfile, "-cpuprofile", "cpu"
+	case MemProfile:
+		flag, sampleType = "-memprofile", "alloc_space"
+	default:
+		return nil, fmt.Errorf("unknown profile kind %q", kind)
+	}
+
+	pkgs, err := snapshot.PackagesForFile(ctx, uri, TypecheckWorkspace, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("package could not be found for file: %s", uri.Filename())
+	}
+	pkgPath := pkgs[0].ForTest()
+	if pkgPath == "" {
+		pkgPath = pkgs[0].PkgPath()
+	}
+	files := make(map[string]*ParsedGoFile)
+	for _, pkg := range pkgs {
+		for _, pgf := range pkg.CompiledGoFiles() {
+			files[pgf.URI.Filename()] = pgf
+		}
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gopls-profile")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	profFile := filepath.Join(tmpDir, string(kind)+".prof")
+	inv := &gocommand.Invocation{
+		Verb: "test",
+		Args: []string{
+			pkgPath,
+			"-run=^$",
+			fmt.Sprintf("-bench=^%s$", benchmark),
+			fmt.Sprintf("%s=%s", flag, profFile),
+			// Profiling leaves the test binary behind, so keep it out of
+			// the package directory.
+			fmt.Sprintf("-o=%s", filepath.Join(tmpDir, "pkg.test")),
+		},
+		WorkingDir: filepath.Dir(uri.Filename()),
+	}
+	if _, err := snapshot.RunGoCommandDirect(ctx, Normal, inv); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(profFile)
+	if err != nil {
+		return nil, err
+	}
+	prof, err := pprof.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	i := prof.SampleIndex(sampleType)
+	if i < 0 {
+		return nil, fmt.Errorf("profile has no %s samples", sampleType)
+	}
+	unit := prof.SampleTypes[i].Unit
+	total := prof.Total(i)
+
+	reports := make(map[span.URI][]*Diagnostic)
+	var n int
+	for _, lv := range prof.Lines(i) {
+		if n == maxHotSpots {
+			break
+		}
+		pgf, ok := files[lv.Filename]
+		if !ok {
+			continue
+		}
+		rng, err := lineRange(pgf, int(lv.Line))
+		if err != nil {
+			continue
+		}
+		n++
+		reports[pgf.URI] = append(reports[pgf.URI], &Diagnostic{
+			URI:      pgf.URI,
+			Range:    rng,
+			Severity: protocol.SeverityHint,
+			Source:   ProfileHotSpot,
+			Message: fmt.Sprintf("%s: %.1f%% of %s (%s) in this line and its calls, %.1f%% (%s) in the line itself",
+				benchmark,
+				100*float64(lv.Cum)/float64(total), sampleType, formatProfileValue(lv.Cum, unit),
+				100*float64(lv.Flat)/float64(total), formatProfileValue(lv.Flat, unit)),
+		})
+	}
+	return reports, nil
+}
+
+// lineRange returns the range of the given 1-based line of a file,
+// excluding leading indentation.
+func lineRange(pgf *ParsedGoFile, line int) (protocol.Range, error) {
+	if line < 1 || line > pgf.Tok.LineCount() {
+		return protocol.Range{}, fmt.Errorf("line %d out of range", line)
+	}
+	start, err := safetoken.Offset(pgf.Tok, pgf.Tok.LineStart(line))
+	if err != nil {
+		return protocol.Range{}, err
+	}
+	end := start
+	content := pgf.Mapper.Content
+	for end < len(content) && content[end] != '\n' {
+		end++
+	}
+	for start < end && (content[start] == ' ' || content[start] == '\t') {
+		start++
+	}
+	return NewMappedRange(pgf.Tok, pgf.Mapper, pgf.Tok.Pos(start), pgf.Tok.Pos(end)).Range()
+}
+
+// formatProfileValue formats a sample value in the given pprof unit.
+func formatProfileValue(v int64, unit string) string {
+	switch unit {
+	case "nanoseconds":
+		return time.Duration(v).String()
+	case "bytes":
+		switch {
+		case v >= 1<<30:
+			return fmt.Sprintf("%.1fGB", float64(v)/(1<<30))
+		case v >= 1<<20:
+			return fmt.Sprintf("%.1fMB", float64(v)/(1<<20))
+		case v >= 1<<10:
+			return fmt.Sprintf("%.1fkB", float64(v)/(1<<10))
+		}
+		return fmt.Sprintf("%dB", v)
+	}
+	return fmt.Sprintf("%d %s", v, unit)
+}
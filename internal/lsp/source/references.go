@@ -15,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 
+	"github.com/cowpaths/golang-x-tools/go/types/objectpath"
 	"github.com/cowpaths/golang-x-tools/internal/event"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/bug"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
@@ -135,12 +136,20 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 	if pos == token.NoPos {
 		return nil, fmt.Errorf("no position for %s", qos[0].obj)
 	}
+	declObj, declPkg := qos[0].obj, qos[0].pkg
+	if declPkg.InExportData(pos) {
+		var err error
+		if declObj, declPkg, err = FindCurrentObject(ctx, snapshot, declObj); err != nil {
+			return nil, err
+		}
+		pos = declObj.Pos()
+	}
 	filename := snapshot.FileSet().Position(pos).Filename
-	pgf, err := qos[0].pkg.File(span.URIFromPath(filename))
+	pgf, err := declPkg.File(span.URIFromPath(filename))
 	if err != nil {
 		return nil, err
 	}
-	declIdent, err := findIdentifier(ctx, snapshot, qos[0].pkg, pgf, qos[0].obj.Pos())
+	declIdent, err := findIdentifier(ctx, snapshot, declPkg, pgf, pos)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +183,7 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 				// For instantiated objects (as in methods or fields on instantiated
 				// types), we may not have pointer-identical objects but still want to
 				// consider them references.
-				if !equalOrigin(obj, qo.obj) {
+				if !equalOrigin(snapshot.FileSet(), obj, qo.obj) {
 					// If ident is not a use of qo.obj, skip it, with one exception:
 					// uses of an embedded field can be considered references of the
 					// embedded type name
@@ -186,7 +195,7 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 						continue
 					}
 					named, ok := v.Type().(*types.Named)
-					if !ok || named.Obj() != qo.obj {
+					if !ok || !equalOrigin(snapshot.FileSet(), named.Obj(), qo.obj) {
 						continue
 					}
 				}
@@ -239,9 +248,29 @@ func references(ctx context.Context, snapshot Snapshot, qos []qualifiedObject, i
 
 // equalOrigin reports whether obj1 and obj2 have equivalent origin object.
 // This may be the case even if obj1 != obj2, if one or both of them is
-// instantiated.
-func equalOrigin(obj1, obj2 types.Object) bool {
-	return obj1.Pkg() == obj2.Pkg() && obj1.Pos() == obj2.Pos() && obj1.Name() == obj2.Name()
+// instantiated, or if they belong to distinct type-checks of the same
+// package, as when the dependents of a package that changed without
+// changing its API keep their types (see ExperimentalEarlyCutoff). The
+// objects of such type-checks have the same object path, or, if they are
+// not accessible from the package scope, are declared at the same line
+// and column.
+func equalOrigin(fset *token.FileSet, obj1, obj2 types.Object) bool {
+	if obj1.Name() != obj2.Name() {
+		return false
+	}
+	if obj1.Pkg() == obj2.Pkg() {
+		return obj1.Pos() == obj2.Pos()
+	}
+	if obj1.Pkg() == nil || obj2.Pkg() == nil || obj1.Pkg().Path() != obj2.Pkg().Path() {
+		return false
+	}
+	path1, err1 := objectpath.For(obj1)
+	path2, err2 := objectpath.For(obj2)
+	if err1 == nil && err2 == nil {
+		return path1 == path2
+	}
+	pos1, pos2 := fset.Position(obj1.Pos()), fset.Position(obj2.Pos())
+	return pos1.IsValid() && pos1.Filename == pos2.Filename && pos1.Line == pos2.Line && pos1.Column == pos2.Column
 }
 
 // interfaceReferences returns the references to the interfaces implemented by
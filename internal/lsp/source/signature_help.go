@@ -94,11 +94,11 @@ FindCall:
 		comment *ast.CommentGroup
 	)
 	if obj != nil {
-		declPkg, err := FindPackageFromPos(ctx, snapshot, obj.Pos())
+		declObj, declPkg, err := FindCurrentObject(ctx, snapshot, obj)
 		if err != nil {
 			return nil, 0, err
 		}
-		node, _ := FindDeclAndField(declPkg.GetSyntax(), obj.Pos()) // may be nil
+		node, _ := FindDeclAndField(declPkg.GetSyntax(), declObj.Pos()) // may be nil
 		d, err := FindHoverContext(ctx, snapshot, pkg, obj, node, nil)
 		if err != nil {
 			return nil, 0, err
@@ -685,7 +685,7 @@ func (r *runner) Highlight(t *testing.T, src span.Span, locations []span.Span) {
 	}
 }
 
-func (r *runner) InlayHints(t *testing.T, src span.Span) {
+func (r *runner) InlayHints(t *testing.T, src span.Span, hints []string) {
 	// TODO(golang/go#53315): add source test
 }
 
@@ -914,7 +914,7 @@ func (r *runner) callWorkspaceSymbols(t *testing.T, uri span.URI, query string,
 	t.Helper()
 
 	matcher := tests.WorkspaceSymbolsTestTypeToMatcher(typ)
-	gotSymbols, err := source.WorkspaceSymbols(r.ctx, matcher, r.view.Options().SymbolStyle, []source.View{r.view}, query)
+	gotSymbols, err := source.WorkspaceSymbols(r.ctx, matcher, r.view.Options().SymbolStyle, []source.View{r.view}, query, "")
 	if err != nil {
 		t.Fatal(err)
 	}
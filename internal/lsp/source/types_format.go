@@ -254,12 +254,12 @@ func NewSignature(ctx context.Context, s Snapshot, pkg Package, sig *types.Signa
 // To do this, it looks in the AST of the file in which the object is declared.
 // On any errors, it always falls back to types.TypeString.
 func FormatVarType(ctx context.Context, snapshot Snapshot, srcpkg Package, obj *types.Var, qf types.Qualifier) string {
-	pkg, err := FindPackageFromPos(ctx, snapshot, obj.Pos())
+	declObj, pkg, err := FindCurrentObject(ctx, snapshot, obj)
 	if err != nil {
 		return types.TypeString(obj.Type(), qf)
 	}
 
-	_, field := FindDeclAndField(pkg.GetSyntax(), obj.Pos())
+	_, field := FindDeclAndField(pkg.GetSyntax(), declObj.Pos())
 	if field == nil {
 		return types.TypeString(obj.Type(), qf)
 	}
@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/cowpaths/golang-x-tools/go/types/objectpath"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/bug"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/span"
@@ -152,6 +153,20 @@ func objToMappedRange(snapshot Snapshot, pkg Package, obj types.Object) (MappedR
 	return posToMappedRange(snapshot, pkg, obj.Pos(), obj.Pos()+token.Pos(nameLen))
 }
 
+// ObjectLocation returns the location of the declaring identifier of obj,
+// which must be among the transitive dependencies of pkg.
+func ObjectLocation(snapshot Snapshot, pkg Package, obj types.Object) (protocol.Location, error) {
+	mrng, err := objToMappedRange(snapshot, pkg, obj)
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	rng, err := mrng.Range()
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	return protocol.Location{URI: protocol.URIFromSpanURI(mrng.URI()), Range: rng}, nil
+}
+
 // posToMappedRange returns the MappedRange for the given [start, end) span,
 // which must be among the transitive dependencies of pkg.
 func posToMappedRange(snapshot Snapshot, pkg Package, pos, end token.Pos) (MappedRange, error) {
@@ -298,14 +313,24 @@ func CompareDiagnostic(a, b *Diagnostic) int {
 // FindPackageFromPos finds the first package containing pos in its
 // type-checked AST.
 func FindPackageFromPos(ctx context.Context, snapshot Snapshot, pos token.Pos) (Package, error) {
+	pkg, _, err := findPackageFromPos(ctx, snapshot, pos)
+	return pkg, err
+}
+
+// findPackageFromPos finds the first package containing pos in its
+// type-checked AST, and the current type-check of that package, which
+// differs if pos is that of an object of a previous type-check whose API
+// the importers of the package still use (see Package.APIPackage), or of
+// an object read from export data (see Package.InExportData).
+func findPackageFromPos(ctx context.Context, snapshot Snapshot, pos token.Pos) (Package, Package, error) {
 	tok := snapshot.FileSet().File(pos)
 	if tok == nil {
-		return nil, fmt.Errorf("no file for pos %v", pos)
+		return nil, nil, fmt.Errorf("no file for pos %v", pos)
 	}
 	uri := span.URIFromPath(tok.Name())
 	pkgs, err := snapshot.PackagesForFile(ctx, uri, TypecheckAll, true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Only return the package if it actually type-checked the given position.
 	for _, pkg := range pkgs {
@@ -315,13 +340,52 @@ func FindPackageFromPos(ctx context.Context, snapshot Snapshot, pos token.Pos) (
 			// The logic in Identifier seems to think so.
 			// Should it be a postcondition of PackagesForFile?
 			// And perhaps PackagesForFile should return the PGFs too.
-			return nil, err
+			return nil, nil, err
 		}
 		if parsed != nil && parsed.Tok.Base() == tok.Base() {
-			return pkg, nil
+			return pkg, pkg, nil
+		}
+		if api := pkg.APIPackage(); api != pkg {
+			if parsed, err := api.File(uri); err == nil && parsed.Tok.Base() == tok.Base() {
+				return api, pkg, nil
+			}
+		}
+	}
+	// The types of a package held as export data have positions of their
+	// own, and its type-check in full has the current objects.
+	for _, pkg := range pkgs {
+		if !pkg.InExportData(pos) {
+			continue
+		}
+		for _, full := range pkgs {
+			if full.ID() == pkg.ID() && full.ParseMode() == ParseFull {
+				return pkg, full, nil
+			}
+		}
+		return pkg, pkg, nil
+	}
+	return nil, nil, fmt.Errorf("no package for given file position")
+}
+
+// FindCurrentObject returns obj and the package that declares it, unless
+// obj belongs to a previous type-check of the package whose API its
+// importers still use, or was read from its export data: as its
+// declarations may have moved since, or have no syntax, it then returns
+// the corresponding object of the current type-check, if any, and that
+// package.
+func FindCurrentObject(ctx context.Context, snapshot Snapshot, obj types.Object) (types.Object, Package, error) {
+	declPkg, current, err := findPackageFromPos(ctx, snapshot, obj.Pos())
+	if err != nil {
+		return nil, nil, err
+	}
+	if current != declPkg {
+		if path, err := objectpath.For(obj); err == nil {
+			if curObj, err := objectpath.Object(current.GetTypes(), path); err == nil {
+				return curObj, current, nil
+			}
 		}
 	}
-	return nil, fmt.Errorf("no package for given file position")
+	return obj, declPkg, nil
 }
 
 // findFileInDeps finds uri in pkg or its dependencies.
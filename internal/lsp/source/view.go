@@ -21,6 +21,7 @@ import (
 	"github.com/cowpaths/golang-x-tools/go/packages"
 	"github.com/cowpaths/golang-x-tools/internal/gocommand"
 	"github.com/cowpaths/golang-x-tools/internal/imports"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/command"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/progress"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/span"
@@ -75,6 +76,13 @@ type Snapshot interface {
 	// Templates returns the .tmpl files
 	Templates() map[span.URI]VersionedFileHandle
 
+	// TemplateResult returns the result of compute on the snapshot, which
+	// depends only on its files of the given kind: its template files if
+	// kind is Tmpl, and its Go packages otherwise. The result is computed
+	// once for each key, and kept until a file of that kind changes. It
+	// must not be modified.
+	TemplateResult(ctx context.Context, key string, kind FileKind, compute func(context.Context, Snapshot) interface{}) (interface{}, error)
+
 	// ParseGo returns the parsed AST for the file.
 	// If the file is not available, returns nil and an error.
 	ParseGo(ctx context.Context, fh FileHandle, mode ParseMode) (*ParsedGoFile, error)
@@ -84,7 +92,22 @@ type Snapshot interface {
 	DiagnosePackage(ctx context.Context, pkg Package) (map[span.URI][]*Diagnostic, error)
 
 	// Analyze runs the analyses for the given package at this snapshot.
-	Analyze(ctx context.Context, pkgID string, analyzers []*Analyzer) ([]*Diagnostic, error)
+	// It returns their diagnostics, and the analyzers that failed, which
+	// are logged.
+	Analyze(ctx context.Context, pkgID string, analyzers []*Analyzer) ([]*Diagnostic, []*Analyzer, error)
+
+	// AnalysisResult returns the result of the analyzer on the given
+	// package at this snapshot. Its objects are those of the package
+	// type-checked in full.
+	AnalysisResult(ctx context.Context, pkgID string, analyzer *Analyzer) (interface{}, error)
+
+	// AnalyzeProgram runs the whole-program analyses on the given
+	// packages at this snapshot.
+	AnalyzeProgram(ctx context.Context, pkgIDs []string, analyzers []*Analyzer) ([]*Diagnostic, error)
+
+	// PluginDiagnostics returns the diagnostics of the analyzers of the
+	// analysis plugins on the given package at this snapshot.
+	PluginDiagnostics(ctx context.Context, pkgID string) ([]*Diagnostic, error)
 
 	// RunGoCommandPiped runs the given `go` command, writing its output
 	// to stdout and stderr. Verb, Args, and WorkingDir must be specified.
@@ -117,6 +140,19 @@ type Snapshot interface {
 	// the given go.mod file.
 	ModWhy(ctx context.Context, fh FileHandle) (map[string]string, error)
 
+	// ModGraph returns the requirements of the module graph, as printed by
+	// `go mod graph`, for the module specified by the given go.mod file.
+	ModGraph(ctx context.Context, fh FileHandle) ([]ModuleEdge, error)
+
+	// Vulnerabilities returns the vulnerabilities found by govulncheck in
+	// the packages of the view, with the call stacks that reach them.
+	Vulnerabilities(ctx context.Context) ([]command.Vuln, error)
+
+	// BuildConfigDiagnostics returns the diagnostics of the workspace files
+	// that are compiled only in the given GOOS/GOARCH build configuration,
+	// with an entry for each such file.
+	BuildConfigDiagnostics(ctx context.Context, config string) (map[span.URI][]*Diagnostic, error)
+
 	// ModTidy returns the results of `go mod tidy` for the module specified by
 	// the given go.mod file.
 	ModTidy(ctx context.Context, pm *ParsedModule) (*TidiedModule, error)
@@ -161,6 +197,9 @@ type Snapshot interface {
 	//
 	// In normal memory mode, this is all workspace packages. In degraded memory
 	// mode, this is just the reverse transitive closure of open packages.
+	// The packages evicted to keep within the memoryBudget option are held
+	// as export data (see Package.InExportData): their syntax stops at
+	// their imports and their type information is empty.
 	ActivePackages(ctx context.Context) ([]Package, error)
 
 	// Symbols returns all symbols in the snapshot.
@@ -267,6 +306,10 @@ type View interface {
 
 	// FileKind returns the type of a file
 	FileKind(FileHandle) FileKind
+
+	// IsDependencyFile reports whether uri is in the Go root or the module
+	// cache of the view, which hold the sources of its dependencies.
+	IsDependencyFile(uri span.URI) bool
 }
 
 // A FileSource maps uris to FileHandles. This abstraction exists both for
@@ -315,6 +358,12 @@ type TidiedModule struct {
 	TidiedContent []byte
 }
 
+// A ModuleEdge is a requirement in the module graph: the From module
+// requires the To module. Main modules have no version.
+type ModuleEdge struct {
+	From, To module.Version
+}
+
 // Metadata represents package metadata retrieved from go/packages.
 type Metadata interface {
 	// PackageName is the package name.
@@ -646,6 +695,17 @@ type Package interface {
 	HasListOrParseErrors() bool
 	HasTypeErrors() bool
 	ParseMode() ParseMode
+
+	// APIPackage returns the type-check of the package whose types its
+	// importers use, which may be a previous one with the same API.
+	APIPackage() Package
+
+	// InExportData reports whether pos is a position of the types of the
+	// package that were read from its export data, rather than of its
+	// files, as for the dependencies of the workspace whose files are not
+	// open, and the packages evicted to keep within the memoryBudget
+	// option.
+	InExportData(pos token.Pos) bool
 }
 
 type CriticalError struct {
@@ -691,6 +751,10 @@ const (
 	UpgradeNotification      DiagnosticSource = "upgrade available"
 	TemplateError            DiagnosticSource = "template"
 	WorkFileError            DiagnosticSource = "go.work file"
+	ProfileHotSpot           DiagnosticSource = "profile"
+	CoverageReport           DiagnosticSource = "coverage"
+	Vulncheck                DiagnosticSource = "govulncheck"
+	LintIgnore               DiagnosticSource = "lintignore"
 )
 
 func AnalyzerErrorKind(name string) DiagnosticSource {
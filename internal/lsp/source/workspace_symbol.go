@@ -52,12 +52,20 @@ const maxSymbols = 100
 // with a different configured SymbolMatcher per View. Therefore we assume that
 // Session level configuration will define the SymbolMatcher to be used for the
 // WorkspaceSymbols method.
-func WorkspaceSymbols(ctx context.Context, matcher SymbolMatcher, style SymbolStyle, views []View, query string) ([]protocol.SymbolInformation, error) {
+//
+// The query may restrict the scope of the search with filters (see
+// parseScope). The symbols closest to the current file, if it is known, are
+// ranked first.
+func WorkspaceSymbols(ctx context.Context, matcher SymbolMatcher, style SymbolStyle, views []View, query string, current span.URI) ([]protocol.SymbolInformation, error) {
 	ctx, done := event.Start(ctx, "source.WorkspaceSymbols")
 	defer done()
 	if query == "" {
 		return nil, nil
 	}
+	scope, query, err := parseScope(query)
+	if err != nil {
+		return nil, err
+	}
 
 	var s symbolizer
 	switch style {
@@ -71,7 +79,133 @@ func WorkspaceSymbols(ctx context.Context, matcher SymbolMatcher, style SymbolSt
 		panic(fmt.Errorf("unknown symbol style: %v", style))
 	}
 
-	return collectSymbols(ctx, views, matcher, s, query)
+	return collectSymbols(ctx, views, matcher, s, query, scope, current)
+}
+
+// A symbolScope restricts the symbols of a workspace symbol search, as
+// specified by the filters of its query. The filters of the same sort match
+// if any of them does.
+type symbolScope struct {
+	pkgs  []*regexp.Regexp             // patterns of package paths
+	kinds map[protocol.SymbolKind]bool // nil if any
+	files []string                     // glob patterns of file names or paths
+	deps  bool                         // whether to include files outside the workspace
+}
+
+// symbolKinds maps the names of the kind filter to the symbol kinds.
+var symbolKinds = map[string][]protocol.SymbolKind{
+	"func":      {protocol.Function},
+	"method":    {protocol.Method},
+	"type":      {protocol.Class, protocol.Struct, protocol.Interface},
+	"struct":    {protocol.Struct},
+	"interface": {protocol.Interface},
+	"field":     {protocol.Field},
+	"var":       {protocol.Variable},
+	"const":     {protocol.Constant},
+}
+
+// parseScope extracts the filters of a symbol query, returning its scope
+// and the rest of the query. The filters are:
+//
+//	pkg:<pattern>  symbols of the packages whose path matches the pattern,
+//	               in which "..." matches any string, as for the go command
+//	kind:<kind>    symbols of the kind: func, method, type, struct,
+//	               interface, field, var or const
+//	file:<glob>    symbols of the files whose name matches the glob, or
+//	               whose path relative to the workspace folder does if the
+//	               glob contains a slash
+//	dep:           symbols of the dependencies too, outside of the
+//	               workspace folders
+func parseScope(query string) (symbolScope, string, error) {
+	var (
+		scope symbolScope
+		rest  []string
+	)
+	for _, field := range strings.Fields(query) {
+		switch {
+		case strings.HasPrefix(field, "pkg:"):
+			scope.pkgs = append(scope.pkgs, packagePatternRegexp(strings.TrimPrefix(field, "pkg:")))
+		case strings.HasPrefix(field, "kind:"):
+			kinds, ok := symbolKinds[strings.TrimPrefix(field, "kind:")]
+			if !ok {
+				return symbolScope{}, "", fmt.Errorf("unknown symbol kind in %q", field)
+			}
+			if scope.kinds == nil {
+				scope.kinds = make(map[protocol.SymbolKind]bool)
+			}
+			for _, kind := range kinds {
+				scope.kinds[kind] = true
+			}
+		case strings.HasPrefix(field, "file:"):
+			glob := strings.TrimPrefix(field, "file:")
+			if _, err := path.Match(glob, ""); err != nil {
+				return symbolScope{}, "", fmt.Errorf("invalid file pattern in %q: %v", field, err)
+			}
+			scope.files = append(scope.files, glob)
+		case field == "dep:":
+			scope.deps = true
+		default:
+			rest = append(rest, field)
+		}
+	}
+	return scope, strings.Join(rest, " "), nil
+}
+
+// packagePatternRegexp returns the regular expression of a package pattern,
+// in which "..." matches any string and a trailing "/..." also matches the
+// empty string, as for the go command.
+func packagePatternRegexp(pattern string) *regexp.Regexp {
+	re := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + re + `$`)
+}
+
+// includes reports whether the scope includes the symbols of the file of the
+// package, whose path relative to the workspace folder is rel.
+func (sc symbolScope) includes(md Metadata, uri span.URI, rel string) bool {
+	if len(sc.pkgs) > 0 {
+		match := false
+		for _, re := range sc.pkgs {
+			if re.MatchString(md.PackagePath()) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(sc.files) > 0 {
+		match := false
+		base := path.Base(filepath.ToSlash(uri.Filename()))
+		rel = strings.TrimPrefix(rel, "/")
+		for _, glob := range sc.files {
+			name := base
+			if strings.Contains(glob, "/") {
+				name = rel
+			}
+			if ok, _ := path.Match(glob, name); ok {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// matchName is the matcherFunc of a query that consists only of filters. It
+// matches every symbol, at the start of its name, which is the last chunk.
+func matchName(chunks []string) (int, float64) {
+	idx := 0
+	for _, chunk := range chunks[:len(chunks)-1] {
+		idx += len(chunk)
+	}
+	return idx, 1
 }
 
 // A matcherFunc returns the index and score of a symbol match.
@@ -291,7 +425,10 @@ func (c comboMatcher) match(chunks []string) (int, float64) {
 //     of zero indicates no match.
 //   - A symbolizer determines how we extract the symbol for an object. This
 //     enables the 'symbolStyle' configuration option.
-func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher, symbolizer symbolizer, query string) ([]protocol.SymbolInformation, error) {
+//
+// The symbols are restricted to the scope, and ranked by proximity to the
+// current file, if it is known.
+func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher, symbolizer symbolizer, query string, scope symbolScope, current span.URI) ([]protocol.SymbolInformation, error) {
 
 	// Extract symbols from all files.
 	var work []symbolFile
@@ -329,6 +466,9 @@ func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher
 				continue
 			}
 			seen[uri] = true
+			if !scope.includes(mds[0], uri, nm) {
+				continue
+			}
 			work = append(work, symbolFile{uri, mds[0], syms})
 		}
 	}
@@ -340,11 +480,14 @@ func collectSymbols(ctx context.Context, views []View, matcherType SymbolMatcher
 	results := make(chan *symbolStore)
 	for i := 0; i < nmatchers; i++ {
 		go func(i int) {
-			matcher := buildMatcher(matcherType, query)
+			matcher := matchName
+			if query != "" {
+				matcher = buildMatcher(matcherType, query)
+			}
 			store := new(symbolStore)
 			// Assign files to workers in round-robin fashion.
 			for j := i; j < len(work); j += nmatchers {
-				matchFile(store, symbolizer, matcher, roots, work[j])
+				matchFile(store, symbolizer, matcher, roots, scope, current, work[j])
 			}
 			results <- store
 		}(i)
@@ -433,9 +576,24 @@ type symbolFile struct {
 }
 
 // matchFile scans a symbol file and adds matching symbols to the store.
-func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, roots []string, i symbolFile) {
+func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, roots []string, scope symbolScope, current span.URI, i symbolFile) {
+	inWorkspace := false
+	for _, root := range roots {
+		if strings.HasPrefix(string(i.uri), root) {
+			inWorkspace = true
+			break
+		}
+	}
+	if !inWorkspace && !scope.deps {
+		return
+	}
+	nearness := proximity(i.uri, current)
+
 	space := make([]string, 0, 3)
 	for _, sym := range i.syms {
+		if scope.kinds != nil && !scope.kinds[sym.Kind] {
+			continue
+		}
 		symbolParts, score := symbolizer(space, sym.Name, i.md, matcher)
 
 		// Check if the score is too low before applying any downranking.
@@ -481,14 +639,6 @@ func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, r
 			}
 		}
 
-		inWorkspace := false
-		for _, root := range roots {
-			if strings.HasPrefix(string(i.uri), root) {
-				inWorkspace = true
-				break
-			}
-		}
-
 		// Apply downranking based on workspace position.
 		if !inWorkspace {
 			score *= nonWorkspaceFactor
@@ -503,6 +653,9 @@ func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, r
 		}
 		score *= 1.0 - depth*depthFactor
 
+		// Apply downranking based on the distance to the current file.
+		score *= nearness
+
 		if store.tooLow(score) {
 			continue
 		}
@@ -519,6 +672,30 @@ func matchFile(store *symbolStore, symbolizer symbolizer, matcher matcherFunc, r
 	}
 }
 
+// proximity returns the factor applied to the scores of the symbols of a
+// file for its distance to the current file, if known: every directory step
+// between them decreases it by proximityFactor, up to maxProximitySteps.
+func proximity(uri, current span.URI) float64 {
+	const (
+		proximityFactor   = 0.05
+		maxProximitySteps = 10
+	)
+	if current == "" {
+		return 1
+	}
+	dir := strings.Split(filepath.ToSlash(filepath.Dir(uri.Filename())), "/")
+	currentDir := strings.Split(filepath.ToSlash(filepath.Dir(current.Filename())), "/")
+	common := 0
+	for common < len(dir) && common < len(currentDir) && dir[common] == currentDir[common] {
+		common++
+	}
+	steps := len(dir) + len(currentDir) - 2*common
+	if steps > maxProximitySteps {
+		steps = maxProximitySteps
+	}
+	return 1 - float64(steps)*proximityFactor
+}
+
 type symbolStore struct {
 	res [maxSymbols]symbolInformation
 }
@@ -5,7 +5,11 @@
 package source
 
 import (
+	"math"
 	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/span"
 )
 
 func TestParseQuery(t *testing.T) {
@@ -129,3 +133,70 @@ func TestFiltererDisallow(t *testing.T) {
 		}
 	}
 }
+
+func TestParseScope(t *testing.T) {
+	scope, rest, err := parseScope("pkg:net/... Foo kind:method file:*_test.go dep: Bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest != "Foo Bar" {
+		t.Errorf("parseScope returned query %q, want %q", rest, "Foo Bar")
+	}
+	if len(scope.pkgs) != 1 || !scope.kinds[protocol.Method] || len(scope.kinds) != 1 || len(scope.files) != 1 || !scope.deps {
+		t.Errorf("parseScope returned scope %+v", scope)
+	}
+
+	for _, query := range []string{"kind:nonsense", "file:[a"} {
+		if _, _, err := parseScope(query); err == nil {
+			t.Errorf("parseScope(%q) succeeded, want error", query)
+		}
+	}
+}
+
+func TestPackagePatternRegexp(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		included []string
+		excluded []string
+	}{
+		{"net/http", []string{"net/http"}, []string{"net/http/httptest", "net", "golang.org/x/net/http"}},
+		{"net/...", []string{"net", "net/http", "net/http/httptest"}, []string{"netx", "golang.org/x/net"}},
+		{"...http", []string{"net/http", "golang.org/x/net/http"}, []string{"net/http/httptest"}},
+		{"net/.../httptest", []string{"net/http/httptest"}, []string{"net/http"}},
+	}
+	for _, test := range tests {
+		re := packagePatternRegexp(test.pattern)
+		for _, path := range test.included {
+			if !re.MatchString(path) {
+				t.Errorf("pattern %q does not match %q", test.pattern, path)
+			}
+		}
+		for _, path := range test.excluded {
+			if re.MatchString(path) {
+				t.Errorf("pattern %q matches %q", test.pattern, path)
+			}
+		}
+	}
+}
+
+func TestProximity(t *testing.T) {
+	current := span.URIFromPath("/src/a/b/current.go")
+	tests := []struct {
+		path string
+		want float64
+	}{
+		{"/src/a/b/other.go", 1},
+		{"/src/a/b/c/other.go", 0.95},
+		{"/src/a/other.go", 0.95},
+		{"/src/x/y/other.go", 0.8},
+		{"/elsewhere/a/b/c/d/e/f/g/other.go", 0.5},
+	}
+	for _, test := range tests {
+		if got := proximity(span.URIFromPath(test.path), current); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("proximity(%s) = %v, want %v", test.path, got, test.want)
+		}
+	}
+	if got := proximity(span.URIFromPath("/src/x/other.go"), ""); got != 1 {
+		t.Errorf("proximity without current file = %v, want 1", got)
+	}
+}
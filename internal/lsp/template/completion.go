@@ -10,7 +10,10 @@ import (
 	"fmt"
 	"go/scanner"
 	"go/token"
+	"go/types"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
@@ -23,6 +26,8 @@ type completer struct {
 	offset int // offset of the start of the Token
 	ctx    protocol.CompletionContext
 	syms   map[string]symbol
+	types  *typeInfo // if the template names its Go data type
+	funcs  map[string]*funcInfo
 }
 
 func Completion(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, pos protocol.Position, context protocol.CompletionContext) (*protocol.CompletionList, error) {
@@ -51,10 +56,32 @@ func Completion(ctx context.Context, snapshot source.Snapshot, fh source.Version
 		offset: start + len(Left),
 		ctx:    context,
 		syms:   syms,
+		// The action being completed is usually incomplete, so check the
+		// template without it.
+		types: checkTypes(ctx, snapshot, parseBuffer(blankToken(p, start))),
+		funcs: findFuncs(ctx, snapshot),
 	}
 	return c.complete()
 }
 
+// blankToken returns a copy of the template with the token that starts at
+// offset start replaced by spaces, preserving line breaks and offsets.
+func blankToken(p *Parsed, start int) []byte {
+	buf := make([]byte, len(p.buf))
+	copy(buf, p.buf)
+	for _, tk := range p.tokens {
+		if tk.Start != start {
+			continue
+		}
+		for i := tk.Start; i < tk.End; i++ {
+			if buf[i] != '\n' {
+				buf[i] = ' '
+			}
+		}
+	}
+	return buf
+}
+
 func filterSyms(syms map[string]symbol, ns []symbol) {
 	for _, xsym := range ns {
 		switch xsym.kind {
@@ -117,6 +144,12 @@ func (c *completer) complete() (*protocol.CompletionList, error) {
 	if len(sofar) == 0 || sofar[len(sofar)-1] == ' ' || sofar[len(sofar)-1] == '\t' {
 		return ans, nil
 	}
+	if c.types != nil {
+		if items, ok := c.typedCompletions(sofar, start); ok {
+			ans.Items = append(ans.Items, items...)
+			return ans, nil
+		}
+	}
 	// sofar could be parsed by either c.analyzer() or scan(). The latter is precise
 	// and slower, but fast enough
 	words := scan(sofar)
@@ -165,8 +198,17 @@ func (c *completer) complete() (*protocol.CompletionList, error) {
 		}
 	}
 	// and functions
+	for _, f := range sortedFuncs(c.funcs) {
+		if weakMatch(f.name, pattern) != 0 {
+			ans.Items = append(ans.Items, protocol.CompletionItem{
+				Label:  f.name,
+				Kind:   protocol.FunctionCompletion,
+				Detail: f.String(),
+			})
+		}
+	}
 	for _, s := range c.syms {
-		if s.kind == protocol.Function && weakMatch(s.name, pattern) != 0 {
+		if s.kind == protocol.Function && c.funcs[s.name] == nil && weakMatch(s.name, pattern) != 0 {
 			ans.Items = append(ans.Items, protocol.CompletionItem{
 				Label:  s.name,
 				Kind:   protocol.FunctionCompletion,
@@ -189,6 +231,119 @@ func (c *completer) complete() (*protocol.CompletionList, error) {
 	return ans, nil
 }
 
+// typedCompletions returns the fields and methods that can follow a chain
+// such as .A.B or $x.A at the end of sofar, using the Go type of dot or of
+// the variable at offset. It reports false if the type is not known.
+func (c *completer) typedCompletions(sofar []byte, offset int) ([]protocol.CompletionItem, bool) {
+	i := len(sofar)
+	for i > 0 && isChainByte(sofar[i-1]) {
+		i--
+	}
+	chain := string(sofar[i:])
+	if !strings.Contains(chain, ".") || i > 0 && sofar[i-1] == ')' {
+		return nil, false
+	}
+	sc := c.types.scopeAt(offset)
+	if sc == nil {
+		return nil, false
+	}
+	names := strings.Split(chain, ".")
+	t := sc.dot
+	if names[0] != "" {
+		if names[0][0] != '$' {
+			return nil, false
+		}
+		t = sc.vars[names[0]]
+	}
+	prefix := names[len(names)-1]
+	for _, name := range names[1 : len(names)-1] {
+		if t == nil {
+			break
+		}
+		obj, elem, ok := lookupField(t, name)
+		if !ok {
+			return nil, true // nothing can follow an unknown field
+		}
+		switch obj := obj.(type) {
+		case nil:
+			t = elem
+		case *types.Var:
+			t = obj.Type()
+		case *types.Func:
+			t = nil
+			if res := obj.Type().(*types.Signature).Results(); res.Len() > 0 {
+				t = res.At(0).Type()
+			}
+		}
+	}
+	if t == nil {
+		return nil, false
+	}
+	var items []protocol.CompletionItem
+	for _, obj := range fieldsAndMethods(t) {
+		if prefix != "" && weakMatch("."+obj.Name(), "."+prefix) == 0 {
+			continue
+		}
+		item := protocol.CompletionItem{
+			Label:  obj.Name(),
+			Kind:   protocol.FieldCompletion,
+			Detail: types.TypeString(obj.Type(), packageName),
+		}
+		if _, ok := obj.(*types.Func); ok {
+			item.Kind = protocol.MethodCompletion
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+func isChainByte(b byte) bool {
+	return b == '.' || b == '$' || b == '_' || b >= utf8.RuneSelf ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// fieldsAndMethods returns the exported fields, including promoted ones, and
+// the exported methods of a value of type t, sorted by name.
+func fieldsAndMethods(t types.Type) []types.Object {
+	var objs []types.Object
+	seen := make(map[string]bool)
+	add := func(obj types.Object) {
+		if obj.Exported() && !seen[obj.Name()] {
+			seen[obj.Name()] = true
+			objs = append(objs, obj)
+		}
+	}
+	mt := t
+	if _, ok := t.Underlying().(*types.Pointer); !ok && !types.IsInterface(t) {
+		mt = types.NewPointer(t)
+	}
+	mset := types.NewMethodSet(mt)
+	for i := 0; i < mset.Len(); i++ {
+		add(mset.At(i).Obj())
+	}
+	var addFields func(t types.Type, depth int)
+	addFields = func(t types.Type, depth int) {
+		if ptr, ok := t.Underlying().(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok || depth > 4 {
+			return
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			add(st.Field(i))
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Anonymous() {
+				addFields(st.Field(i).Type(), depth+1)
+			}
+		}
+	}
+	addFields(t, 0)
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Name() < objs[j].Name() })
+	return objs
+}
+
 // someday think about comments, strings, backslashes, etc
 // this would repeat some of the template parsing, but because the user is typing
 // there may be no parse tree here.
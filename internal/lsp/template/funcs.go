@@ -0,0 +1,349 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+// This file finds the functions that Go code makes available to templates
+// through the FuncMaps passed to the Funcs method of text/template and
+// html/template, such as
+//	t.Funcs(template.FuncMap{"upper": strings.ToUpper})
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/go/ast/astutil"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+)
+
+// A funcInfo is a function defined in a FuncMap.
+type funcInfo struct {
+	name string
+	sig  *types.Signature
+	// loc is the declaration of the Go function, or the FuncMap key if the
+	// function is not declared elsewhere, as for a function literal.
+	loc protocol.Location
+}
+
+// String returns the signature of the function as it is called in a
+// template, such as upper(s string) string.
+func (f *funcInfo) String() string {
+	return f.name + strings.TrimPrefix(types.TypeString(f.sig, packageName), "func")
+}
+
+// findFuncs returns the functions defined by the FuncMaps that the
+// workspace packages pass to the Funcs method of a template, by name,
+// computing them once per snapshot. The result must not be modified.
+func findFuncs(ctx context.Context, snapshot source.Snapshot) map[string]*funcInfo {
+	type funcsResult struct {
+		funcs map[string]*funcInfo
+		err   error
+	}
+	v, err := snapshot.TemplateResult(ctx, "findFuncs", source.Go, func(ctx context.Context, snapshot source.Snapshot) interface{} {
+		funcs, err := funcMapFuncs(ctx, snapshot)
+		return funcsResult{funcs, err}
+	})
+	if err != nil {
+		return nil
+	}
+	res := v.(funcsResult)
+	if res.err != nil {
+		return nil
+	}
+	return res.funcs
+}
+
+// funcMapFuncs returns the functions defined by the FuncMaps that the
+// workspace packages pass to the Funcs method of a template, by name. A
+// FuncMap is recognized if it is a composite literal, or a variable
+// initialized by one, and keys set by indexing such a variable are included.
+func funcMapFuncs(ctx context.Context, snapshot source.Snapshot) (map[string]*funcInfo, error) {
+	pkgs, err := snapshot.ActivePackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	funcs := make(map[string]*funcInfo)
+	for _, pkg := range pkgs {
+		if pkg.ForTest() != "" {
+			continue
+		}
+		info := pkg.GetTypesInfo()
+		// The FuncMap literals assigned to variables, and the variables
+		// passed to Funcs.
+		lits := make(map[types.Object][]*ast.CompositeLit)
+		sets := make(map[types.Object][][2]ast.Expr)
+		passed := make(map[types.Object]bool)
+		var direct []*ast.CompositeLit
+		for _, pgf := range pkg.CompiledGoFiles() {
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					if !isTemplateMethod(info, n, "Funcs") || len(n.Args) != 1 {
+						break
+					}
+					switch arg := astutil.Unparen(n.Args[0]).(type) {
+					case *ast.CompositeLit:
+						direct = append(direct, arg)
+					case *ast.Ident:
+						if obj := info.Uses[arg]; obj != nil {
+							passed[obj] = true
+						}
+					}
+				case *ast.ValueSpec:
+					for i, name := range n.Names {
+						if i < len(n.Values) {
+							if lit, ok := funcMapLit(info, n.Values[i]); ok {
+								obj := info.Defs[name]
+								lits[obj] = append(lits[obj], lit)
+							}
+						}
+					}
+				case *ast.AssignStmt:
+					if len(n.Lhs) != len(n.Rhs) {
+						break
+					}
+					for i, lhs := range n.Lhs {
+						switch lhs := lhs.(type) {
+						case *ast.Ident:
+							if lit, ok := funcMapLit(info, n.Rhs[i]); ok {
+								obj := info.ObjectOf(lhs)
+								lits[obj] = append(lits[obj], lit)
+							}
+						case *ast.IndexExpr:
+							if id, ok := lhs.X.(*ast.Ident); ok && isFuncMap(info.TypeOf(id)) {
+								obj := info.ObjectOf(id)
+								sets[obj] = append(sets[obj], [2]ast.Expr{lhs.Index, n.Rhs[i]})
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+		add := func(key, value ast.Expr) {
+			lit, ok := key.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return
+			}
+			name, err := strconv.Unquote(lit.Value)
+			if err != nil || funcs[name] != nil {
+				return
+			}
+			t := info.TypeOf(value)
+			if t == nil {
+				return
+			}
+			sig, ok := t.Underlying().(*types.Signature)
+			if !ok {
+				return
+			}
+			loc, ok := funcLocation(snapshot, pkg, info, key, value)
+			if !ok {
+				return
+			}
+			funcs[name] = &funcInfo{name: name, sig: sig, loc: loc}
+		}
+		addLit := func(lit *ast.CompositeLit) {
+			for _, elt := range lit.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					add(kv.Key, kv.Value)
+				}
+			}
+		}
+		for _, lit := range direct {
+			addLit(lit)
+		}
+		for obj := range passed {
+			for _, lit := range lits[obj] {
+				addLit(lit)
+			}
+			for _, set := range sets[obj] {
+				add(set[0], set[1])
+			}
+		}
+	}
+	return funcs, nil
+}
+
+// funcMapLit returns e as a FuncMap composite literal, if it is one.
+func funcMapLit(info *types.Info, e ast.Expr) (*ast.CompositeLit, bool) {
+	lit, ok := astutil.Unparen(e).(*ast.CompositeLit)
+	if !ok || !isFuncMap(info.TypeOf(lit)) {
+		return nil, false
+	}
+	return lit, true
+}
+
+// isFuncMap reports whether t is the FuncMap type of text/template or
+// html/template.
+func isFuncMap(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Name() != "FuncMap" || named.Obj().Pkg() == nil {
+		return false
+	}
+	path := named.Obj().Pkg().Path()
+	return path == "text/template" || path == "html/template"
+}
+
+// funcLocation returns the location of the declaration of the function
+// value, or of key if value is not a named function.
+func funcLocation(snapshot source.Snapshot, pkg source.Package, info *types.Info, key, value ast.Expr) (protocol.Location, bool) {
+	var id *ast.Ident
+	switch v := astutil.Unparen(value).(type) {
+	case *ast.Ident:
+		id = v
+	case *ast.SelectorExpr:
+		id = v.Sel
+	}
+	if fn, ok := info.Uses[id].(*types.Func); ok {
+		if loc, err := source.ObjectLocation(snapshot, pkg, fn); err == nil {
+			return loc, true
+		}
+	}
+	for _, pgf := range pkg.CompiledGoFiles() {
+		if pgf.Tok.Base() <= int(key.Pos()) && int(key.Pos()) <= pgf.Tok.Base()+pgf.Tok.Size() {
+			rng, err := source.NewMappedRange(pgf.Tok, pgf.Mapper, key.Pos(), key.End()).Range()
+			if err != nil {
+				return protocol.Location{}, false
+			}
+			return protocol.Location{URI: protocol.URIFromSpanURI(pgf.URI), Range: rng}, true
+		}
+	}
+	return protocol.Location{}, false
+}
+
+// sortedFuncs returns the functions of funcs sorted by name.
+func sortedFuncs(funcs map[string]*funcInfo) []*funcInfo {
+	var ans []*funcInfo
+	for _, f := range funcs {
+		ans = append(ans, f)
+	}
+	sort.Slice(ans, func(i, j int) bool { return ans[i].name < ans[j].name })
+	return ans
+}
+
+// SignatureHelp returns the signature of the FuncMap function called by the
+// command enclosing loc, and the index of the argument at loc.
+func SignatureHelp(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, loc protocol.Position) (*protocol.SignatureInformation, int, error) {
+	buf, err := fh.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	p := parseBuffer(buf)
+	start := inTemplate(p, loc)
+	if start == -1 {
+		return nil, 0, fmt.Errorf("not in a template action")
+	}
+	name, arg := commandAt(string(p.buf[start+len(Left) : p.FromPosition(loc)]))
+	f := findFuncs(ctx, snapshot)[name]
+	if f == nil {
+		return nil, 0, fmt.Errorf("no FuncMap function called at position")
+	}
+	var params []protocol.ParameterInformation
+	for i := 0; i < f.sig.Params().Len(); i++ {
+		v := f.sig.Params().At(i)
+		typ := types.TypeString(v.Type(), packageName)
+		if f.sig.Variadic() && i == f.sig.Params().Len()-1 {
+			typ = "..." + types.TypeString(v.Type().(*types.Slice).Elem(), packageName)
+			if arg > i {
+				arg = i
+			}
+		}
+		label := typ
+		if v.Name() != "" {
+			label = v.Name() + " " + typ
+		}
+		params = append(params, protocol.ParameterInformation{Label: label})
+	}
+	return &protocol.SignatureInformation{
+		Label:      f.String(),
+		Parameters: params,
+	}, arg, nil
+}
+
+// commandAt returns the function name of the innermost command at the end
+// of the partial action text, and the index of the argument being typed.
+// The command starts after the last unclosed parenthesis or pipe.
+func commandAt(text string) (name string, arg int) {
+	cmdStart := 0
+	var open []int // offsets of the commands enclosing the last one
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case c == '(':
+			open = append(open, cmdStart)
+			cmdStart = i + 1
+		case c == ')':
+			if len(open) > 0 {
+				cmdStart = open[len(open)-1]
+				open = open[:len(open)-1]
+			}
+		case c == '|':
+			cmdStart = i + 1
+		}
+	}
+	words := actionFields(text[cmdStart:])
+	if len(words) == 0 {
+		return "", 0
+	}
+	arg = len(words) - 1
+	if last := text[len(text)-1]; last != ' ' && last != '\t' && last != '\n' {
+		arg-- // still typing the last word
+	}
+	if arg < 0 {
+		arg = 0
+	}
+	return words[0], arg
+}
+
+// actionFields splits the text of a command into its words, treating quoted
+// strings and parenthesized pipelines as single words.
+func actionFields(text string) []string {
+	var words []string
+	start, depth := -1, 0
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if start < 0 && c != ' ' && c != '\t' && c != '\n' {
+			start = i
+		}
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case (c == ' ' || c == '\t' || c == '\n') && depth == 0 && start >= 0:
+			words = append(words, text[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
@@ -7,6 +7,8 @@ package template
 import (
 	"context"
 	"fmt"
+	"go/types"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"time"
@@ -22,7 +24,10 @@ var errRe = regexp.MustCompile(`template.*:(\d+): (.*)`)
 // Diagnose returns parse errors. There is only one.
 // The errors are not always helpful. For instance { {end}}
 // will likely point to the end of the file.
-func Diagnose(f source.VersionedFileHandle) []*source.Diagnostic {
+// If the template parses, Diagnose warns of invoked templates that no
+// template file defines, and if it names its Go data type with a gotype
+// comment, returns the errors found by checking it against that type.
+func Diagnose(ctx context.Context, snapshot source.Snapshot, f source.VersionedFileHandle) []*source.Diagnostic {
 	// no need for skipTemplate check, as Diagnose is called on the
 	// snapshot's template files
 	buf, err := f.Read()
@@ -35,7 +40,8 @@ func Diagnose(f source.VersionedFileHandle) []*source.Diagnostic {
 	}
 	p := parseBuffer(buf)
 	if p.ParseErr == nil {
-		return nil
+		diags := undefinedDiagnostics(ctx, snapshot, f.URI(), p)
+		return append(diags, typeDiagnostics(ctx, snapshot, f.URI(), p)...)
 	}
 	unknownError := func(msg string) []*source.Diagnostic {
 		s := fmt.Sprintf("malformed template error %q: %s", p.ParseErr.Error(), msg)
@@ -72,12 +78,31 @@ func Diagnose(f source.VersionedFileHandle) []*source.Diagnostic {
 // Definition finds the definitions of the symbol at loc. It
 // does not understand scoping (if any) in templates. This code is
 // for definitions, type definitions, and implementations.
-// Results only for variables and templates.
-func Definition(snapshot source.Snapshot, fh source.VersionedFileHandle, loc protocol.Position) ([]protocol.Location, error) {
-	x, _, err := symAtPosition(fh, loc)
+// Results only for variables and templates, for the fields and methods
+// of a template's Go data type, and for the functions of FuncMaps.
+func Definition(ctx context.Context, snapshot source.Snapshot, fh source.VersionedFileHandle, loc protocol.Position) ([]protocol.Location, error) {
+	x, p, err := symAtPosition(fh, loc)
 	if err != nil {
 		return nil, err
 	}
+	switch x.kind {
+	case protocol.Function:
+		if f := findFuncs(ctx, snapshot)[x.name]; f != nil {
+			return []protocol.Location{f.loc}, nil
+		}
+	case protocol.Package, protocol.Namespace:
+		return New(snapshot.Templates()).nameLocations(x.name, false), nil
+	case protocol.Method:
+		if info := checkTypes(ctx, snapshot, p); info != nil {
+			if obj := info.refAt(p.FromPosition(loc)); obj != nil {
+				l, err := source.ObjectLocation(snapshot, info.pkg, obj)
+				if err != nil {
+					return nil, err
+				}
+				return []protocol.Location{l}, nil
+			}
+		}
+	}
 	sym := x.name
 	ans := []protocol.Location{}
 	// PJW: this is probably a pattern to abstract
@@ -102,14 +127,25 @@ func Hover(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle,
 	switch sym.kind {
 	case protocol.Function:
 		ans.Contents.Value = fmt.Sprintf("function: %s", sym.name)
+		if f := findFuncs(ctx, snapshot)[sym.name]; f != nil {
+			ans.Contents.Value = fmt.Sprintf("```go\nfunc %s\n```", f)
+		}
 	case protocol.Variable:
 		ans.Contents.Value = fmt.Sprintf("variable: %s", sym.name)
 	case protocol.Constant:
 		ans.Contents.Value = fmt.Sprintf("constant %s", sym.name)
 	case protocol.Method: // field or method
 		ans.Contents.Value = fmt.Sprintf("%s: field or method", sym.name)
+		if info := checkTypes(ctx, snapshot, p); info != nil {
+			if obj := info.refAt(p.FromPosition(position)); obj != nil {
+				ans.Contents.Value = types.ObjectString(obj, types.RelativeTo(info.pkg.GetTypes()))
+			}
+		}
 	case protocol.Package: // template use, template def (PJW: do we want two?)
 		ans.Contents.Value = fmt.Sprintf("template %s\n(add definition)", sym.name)
+		if defs := New(snapshot.Templates()).nameLocations(sym.name, false); len(defs) > 0 {
+			ans.Contents.Value = fmt.Sprintf("template %s, defined in %s", sym.name, filepath.Base(defs[0].URI.SpanURI().Filename()))
+		}
 	case protocol.Namespace:
 		ans.Contents.Value = fmt.Sprintf("template %s defined", sym.name)
 	case protocol.Number:
@@ -185,5 +221,3 @@ func SemanticTokens(ctx context.Context, snapshot source.Snapshot, spn span.URI,
 	}
 	return ans, nil
 }
-
-// still need to do rename, etc
@@ -0,0 +1,210 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+// This file resolves template names across all the template files, for
+// {{define}}, {{block}} and {{template}} actions.
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// definedNames returns the names of the templates defined by the template
+// files of snapshot, parsing them once per snapshot.
+func definedNames(ctx context.Context, snapshot source.Snapshot) (map[string]bool, error) {
+	v, err := snapshot.TemplateResult(ctx, "definedNames", source.Tmpl, func(ctx context.Context, snapshot source.Snapshot) interface{} {
+		return New(snapshot.Templates()).defined()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]bool), nil
+}
+
+// isTemplateName reports whether s is a definition or use of a template
+// name.
+func isTemplateName(s symbol) bool {
+	return s.kind == protocol.Namespace || s.kind == protocol.Package
+}
+
+// defined returns the names of the templates defined by the template files,
+// including the base names of the files themselves, as used by ParseFiles
+// and ParseGlob.
+func (a *All) defined() map[string]bool {
+	names := make(map[string]bool)
+	for uri, p := range a.files {
+		names[filepath.Base(uri.Filename())] = true
+		for _, s := range p.symbols {
+			if s.kind == protocol.Namespace {
+				names[s.name] = true
+			}
+		}
+	}
+	return names
+}
+
+// nameLocations returns the locations of the definitions and, if uses is
+// set, the uses of the template name, sorted by file.
+func (a *All) nameLocations(name string, uses bool) []protocol.Location {
+	var uris []span.URI
+	for uri := range a.files {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	var ans []protocol.Location
+	for _, uri := range uris {
+		p := a.files[uri]
+		for _, s := range p.symbols {
+			if s.name != name || !isTemplateName(s) || s.kind == protocol.Package && !uses {
+				continue
+			}
+			ans = append(ans, protocol.Location{URI: protocol.URIFromSpanURI(uri), Range: p.Range(s.start, s.length)})
+		}
+	}
+	return ans
+}
+
+// undefinedDiagnostics returns a warning for each {{template}} action in p
+// that invokes a template not defined by any template file. The template may
+// still be defined by Go code, so these are not errors.
+func undefinedDiagnostics(ctx context.Context, snapshot source.Snapshot, uri span.URI, p *Parsed) []*source.Diagnostic {
+	defined, err := definedNames(ctx, snapshot)
+	if err != nil {
+		return nil
+	}
+	named := make(map[string]bool) // this file may not be saved yet
+	for _, t := range p.named {
+		named[t.Name()] = true
+	}
+	var diags []*source.Diagnostic
+	for _, s := range p.symbols {
+		if s.kind != protocol.Package || defined[s.name] || named[s.name] {
+			continue
+		}
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Range:    p.Range(s.start, s.length),
+			Severity: protocol.SeverityWarning,
+			Source:   source.TemplateError,
+			Message:  fmt.Sprintf("no template named %q is defined", s.name),
+		})
+	}
+	return diags
+}
+
+// PrepareRename returns the range of the template name at loc, the only
+// kind of symbol that can be renamed in a template file.
+func PrepareRename(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, loc protocol.Position) (*source.PrepareItem, error) {
+	sym, p, err := symAtPosition(fh, loc)
+	if err != nil {
+		return nil, err
+	}
+	if !isTemplateName(*sym) {
+		return nil, fmt.Errorf("only template names can be renamed")
+	}
+	return &source.PrepareItem{Range: p.Range(sym.start, sym.length), Text: sym.name}, nil
+}
+
+// Rename returns the edits that rename the template name at loc in all the
+// template files, and in the names passed to ExecuteTemplate by the
+// workspace packages.
+func Rename(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, loc protocol.Position, newName string) (map[span.URI][]protocol.TextEdit, error) {
+	sym, _, err := symAtPosition(fh, loc)
+	if err != nil {
+		return nil, err
+	}
+	if !isTemplateName(*sym) {
+		return nil, fmt.Errorf("only template names can be renamed")
+	}
+	if newName == "" || strings.ContainsAny(newName, "\"\n") {
+		return nil, fmt.Errorf("invalid template name %q", newName)
+	}
+	a := New(snapshot.Templates())
+	if a.defined()[newName] {
+		return nil, fmt.Errorf("a template named %q is already defined", newName)
+	}
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for _, l := range a.nameLocations(sym.name, true) {
+		uri := l.URI.SpanURI()
+		edits[uri] = append(edits[uri], protocol.TextEdit{Range: l.Range, NewText: newName})
+	}
+	locs, err := executeLocations(ctx, snapshot, sym.name)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locs {
+		uri := l.URI.SpanURI()
+		edits[uri] = append(edits[uri], protocol.TextEdit{Range: l.Range, NewText: strconv.Quote(newName)})
+	}
+	return edits, nil
+}
+
+// executeLocations returns the locations of the string literals that name
+// the template name in the calls of the ExecuteTemplate method of
+// text/template and html/template in the workspace packages.
+func executeLocations(ctx context.Context, snapshot source.Snapshot, name string) ([]protocol.Location, error) {
+	pkgs, err := snapshot.ActivePackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[protocol.Location]bool) // test variants share files
+	var ans []protocol.Location
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) != 3 || !isTemplateMethod(info, call, "ExecuteTemplate") {
+					return true
+				}
+				lit, ok := call.Args[1].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				if s, err := strconv.Unquote(lit.Value); err != nil || s != name {
+					return true
+				}
+				rng, err := source.NewMappedRange(pgf.Tok, pgf.Mapper, lit.Pos(), lit.End()).Range()
+				if err != nil {
+					return true
+				}
+				loc := protocol.Location{URI: protocol.URIFromSpanURI(pgf.URI), Range: rng}
+				if !seen[loc] {
+					seen[loc] = true
+					ans = append(ans, loc)
+				}
+				return true
+			})
+		}
+	}
+	return ans, nil
+}
+
+// isTemplateMethod reports whether call is a call of the named method of
+// text/template or html/template.
+func isTemplateMethod(info *types.Info, call *ast.CallExpr, name string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	path := fn.Pkg().Path()
+	return path == "text/template" || path == "html/template"
+}
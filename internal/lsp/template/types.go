@@ -0,0 +1,585 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+// This file contains the code that checks a template against the Go type
+// of its data, named by a magic comment such as
+//	{{/* gotype: example.com/app/views.Page */}}
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/types"
+	"regexp"
+	"sort"
+	"text/template/parse"
+	"unicode/utf8"
+
+	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
+	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
+	"github.com/cowpaths/golang-x-tools/internal/span"
+)
+
+// gotypeRe matches the magic comment that binds a template to the Go type of
+// its data. The type is a package path and a type name, optionally preceded
+// by a * for a pointer type.
+var gotypeRe = regexp.MustCompile(`{{-?\s*/\*\s*gotype:\s*(\*?)(\S+)\.([\pL_][\pL\pN_]*)\s*\*/\s*-?}}`)
+
+// typeInfo is the result of checking a template against its Go data type.
+type typeInfo struct {
+	pkg    source.Package // the package declaring the data type
+	root   types.Type     // the data type
+	errs   []typeError
+	refs   []fieldRef
+	scopes []scope
+}
+
+// A typeError is an error found while checking a template.
+type typeError struct {
+	start, length int
+	msg           string
+}
+
+// A fieldRef is a use of a Go field or method in a template.
+type fieldRef struct {
+	start, length int
+	obj           types.Object
+}
+
+// A scope is a region of a template with a known type of dot, and the
+// types of the variables declared in it.
+type scope struct {
+	start, end int
+	dot        types.Type
+	vars       map[string]types.Type
+}
+
+// checkTypes checks the template p against the Go type named by its gotype
+// comment. It returns nil if there is no such comment.
+func checkTypes(ctx context.Context, snapshot source.Snapshot, p *Parsed) *typeInfo {
+	m := gotypeRe.FindSubmatchIndex(p.buf)
+	if m == nil || p.ParseErr != nil {
+		return nil
+	}
+	path, name := string(p.buf[m[4]:m[5]]), string(p.buf[m[6]:m[7]])
+	info := new(typeInfo)
+	pkg, obj := findGoType(ctx, snapshot, path, name)
+	if obj == nil {
+		info.errs = append(info.errs, typeError{m[4], utf8.RuneCount(p.buf[m[4]:m[7]]),
+			fmt.Sprintf("cannot find Go type %s.%s", path, name)})
+		return info
+	}
+	info.pkg, info.root = pkg, obj.Type()
+	if m[3] > m[2] {
+		info.root = types.NewPointer(info.root)
+	}
+
+	c := &checker{p: p, info: info, invoked: make(map[string]types.Type), funcs: findFuncs(ctx, snapshot)}
+	named := make(map[string]*parse.Tree)
+	for _, t := range p.named {
+		if t.Tree == nil {
+			continue
+		}
+		if t.Name() == "" {
+			c.walk(t.Root, info.root, map[string]types.Type{"$": info.root}, len(p.buf))
+		} else {
+			named[t.Name()] = t.Tree
+		}
+	}
+	// Check the templates invoked with a known type of dot, until no new
+	// ones are found.
+	checked := make(map[string]bool)
+	for {
+		var todo []string
+		for name := range c.invoked {
+			if !checked[name] && named[name] != nil {
+				todo = append(todo, name)
+			}
+		}
+		if len(todo) == 0 {
+			break
+		}
+		sort.Strings(todo)
+		for _, name := range todo {
+			checked[name] = true
+			dot := c.invoked[name]
+			root := named[name].Root
+			c.walk(root, dot, map[string]types.Type{"$": dot}, templateEnd(p, root))
+		}
+	}
+	// The type of dot is unknown in the other templates.
+	for name, tree := range named {
+		if !checked[name] {
+			info.scopes = append(info.scopes, scope{int(tree.Root.Pos), templateEnd(p, tree.Root), nil, nil})
+		}
+	}
+	return info
+}
+
+// findGoType finds the named type declared in the package with the given
+// path, among the workspace packages and their direct imports.
+func findGoType(ctx context.Context, snapshot source.Snapshot, path, name string) (source.Package, *types.TypeName) {
+	pkgs, err := snapshot.ActivePackages(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	lookup := func(pkg source.Package) *types.TypeName {
+		tn, _ := pkg.GetTypes().Scope().Lookup(name).(*types.TypeName)
+		return tn
+	}
+	for _, pkg := range pkgs {
+		if pkg.PkgPath() == path && pkg.ForTest() == "" {
+			return pkg, lookup(pkg)
+		}
+	}
+	for _, pkg := range pkgs {
+		if imp, err := pkg.GetImport(path); err == nil {
+			return imp, lookup(imp)
+		}
+	}
+	return nil, nil
+}
+
+// templateEnd returns the offset of the end of the body of a named
+// template, which is the start of its {{end}}, or of the end of the file.
+func templateEnd(p *Parsed, root *parse.ListNode) int {
+	depth := 0
+	for _, tok := range p.tokens {
+		if tok.Start < int(root.Pos) {
+			continue
+		}
+		action := bytes.TrimLeft(p.buf[tok.Start+len(Left):tok.End], "- \t\n")
+		i := 0
+		for i < len(action) && 'a' <= action[i] && action[i] <= 'z' {
+			i++
+		}
+		switch string(action[:i]) {
+		case "if", "with", "range", "block", "define":
+			depth++
+		case "end":
+			if depth == 0 {
+				return tok.Start
+			}
+			depth--
+		}
+	}
+	return len(p.buf)
+}
+
+// A checker walks a template's parse tree, tracking the type of dot and of
+// variables. A nil type is unknown, and is not checked.
+type checker struct {
+	p    *Parsed
+	info *typeInfo
+	// invoked holds the type of dot passed to each invoked template
+	invoked map[string]types.Type
+	funcs   map[string]*funcInfo // the functions of the workspace FuncMaps
+}
+
+func (c *checker) errorf(start int, name string, format string, args ...interface{}) {
+	c.info.errs = append(c.info.errs, typeError{start, utf8.RuneCountInString(name), fmt.Sprintf(format, args...)})
+}
+
+// walk checks the node n, which extends to offset end.
+func (c *checker) walk(n parse.Node, dot types.Type, vars map[string]types.Type, end int) {
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x == nil {
+			return
+		}
+		// Variables declared in the list are not visible outside it.
+		vars = copyVars(vars)
+		c.info.scopes = append(c.info.scopes, scope{int(x.Pos), end, dot, vars})
+		for i, nd := range x.Nodes {
+			ndEnd := end
+			if i+1 < len(x.Nodes) {
+				ndEnd = int(x.Nodes[i+1].Position())
+			}
+			c.walk(nd, dot, vars, ndEnd)
+		}
+	case *parse.ActionNode:
+		c.pipe(x.Pipe, dot, vars)
+	case *parse.IfNode:
+		c.branch(&x.BranchNode, dot, vars, end, func(types.Type) types.Type { return dot })
+	case *parse.WithNode:
+		c.branch(&x.BranchNode, dot, vars, end, func(t types.Type) types.Type { return t })
+	case *parse.RangeNode:
+		c.branch(&x.BranchNode, dot, vars, end, nil)
+	case *parse.TemplateNode:
+		t := c.pipe(x.Pipe, dot, vars)
+		if x.Pipe == nil || t == nil {
+			return
+		}
+		if _, ok := c.invoked[x.Name]; !ok {
+			c.invoked[x.Name] = t
+		}
+	}
+}
+
+// branch checks an if, with or range node. The body's dot is computed from
+// the type of the pipeline by bodyDot, or is the element type of a range.
+func (c *checker) branch(x *parse.BranchNode, dot types.Type, vars map[string]types.Type, end int, bodyDot func(types.Type) types.Type) {
+	vars = copyVars(vars)
+	var body types.Type
+	if bodyDot != nil {
+		body = bodyDot(c.pipe(x.Pipe, dot, vars))
+	} else {
+		// Range variables are the key and element, not the pipeline.
+		key, elem := rangeTypes(c.pipeline(x.Pipe, dot, vars))
+		switch decl := x.Pipe.Decl; len(decl) {
+		case 1:
+			vars[decl[0].Ident[0]] = elem
+		case 2:
+			vars[decl[0].Ident[0]] = key
+			vars[decl[1].Ident[0]] = elem
+		}
+		body = elem
+	}
+	listEnd := end
+	if x.ElseList != nil {
+		listEnd = int(x.ElseList.Pos)
+	}
+	c.walk(x.List, body, vars, listEnd)
+	c.walk(x.ElseList, dot, vars, end)
+}
+
+// pipe checks a pipeline, assigning its type to any variables it declares,
+// and returns its type.
+func (c *checker) pipe(x *parse.PipeNode, dot types.Type, vars map[string]types.Type) types.Type {
+	t := c.pipeline(x, dot, vars)
+	if x != nil {
+		for _, v := range x.Decl {
+			vars[v.Ident[0]] = t
+		}
+	}
+	return t
+}
+
+// pipeline checks the commands of a pipeline and returns its type.
+func (c *checker) pipeline(x *parse.PipeNode, dot types.Type, vars map[string]types.Type) types.Type {
+	if x == nil {
+		return nil
+	}
+	var t types.Type
+	for i, cmd := range x.Cmds {
+		t = c.command(cmd, dot, vars, i > 0)
+	}
+	return t
+}
+
+// command checks a command, which receives the result of the previous
+// command of a pipeline as an extra argument if piped is set.
+func (c *checker) command(x *parse.CommandNode, dot types.Type, vars map[string]types.Type, piped bool) types.Type {
+	for _, arg := range x.Args[1:] {
+		c.arg(arg, dot, vars)
+	}
+	nargs := len(x.Args) - 1
+	if piped {
+		nargs++
+	}
+	switch n := x.Args[0].(type) {
+	case *parse.IdentifierNode:
+		return c.function(n, nargs)
+	case *parse.FieldNode:
+		return c.fieldChain(dot, n.Ident, c.fieldOffsets(n), nargs)
+	case *parse.VariableNode:
+		return c.variable(n, vars, nargs)
+	case *parse.ChainNode:
+		return c.fieldChain(c.arg(n.Node, dot, vars), n.Field, c.p.identOffsets(int(n.Pos), n.Field), nargs)
+	}
+	return c.arg(x.Args[0], dot, vars)
+}
+
+// arg checks an argument of a command and returns its type.
+func (c *checker) arg(n parse.Node, dot types.Type, vars map[string]types.Type) types.Type {
+	switch n := n.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.fieldChain(dot, n.Ident, c.fieldOffsets(n), 0)
+	case *parse.VariableNode:
+		return c.variable(n, vars, 0)
+	case *parse.ChainNode:
+		return c.fieldChain(c.arg(n.Node, dot, vars), n.Field, c.p.identOffsets(int(n.Pos), n.Field), 0)
+	case *parse.PipeNode:
+		return c.pipe(n, dot, copyVars(vars))
+	case *parse.IdentifierNode:
+		return c.function(n, 0)
+	case *parse.StringNode:
+		return types.Typ[types.String]
+	case *parse.BoolNode:
+		return types.Typ[types.Bool]
+	}
+	return nil
+}
+
+func (c *checker) variable(n *parse.VariableNode, vars map[string]types.Type, nargs int) types.Type {
+	t, ok := vars[n.Ident[0]]
+	if !ok || len(n.Ident) == 1 {
+		return t
+	}
+	start := int(n.Pos) + len(n.Ident[0])
+	return c.fieldChain(t, n.Ident[1:], c.p.identOffsets(start, n.Ident[1:]), nargs)
+}
+
+// fieldOffsets returns the offsets of the identifiers of a field node.
+func (c *checker) fieldOffsets(n *parse.FieldNode) []int {
+	syms := c.p.fields(n.Ident, n)
+	if len(syms) != len(n.Ident) {
+		return nil
+	}
+	offsets := make([]int, len(syms))
+	for i, s := range syms {
+		offsets[i] = s.start
+	}
+	return offsets
+}
+
+// identOffsets returns the offsets of the identifiers of a chain of
+// fields, such as .A.B, that starts at or after offset start.
+func (p *Parsed) identOffsets(start int, names []string) []int {
+	var offsets []int
+	at := start
+	for _, name := range names {
+		ix := bytes.Index(p.buf[at:], []byte("."+name))
+		if ix < 0 {
+			return nil
+		}
+		at += ix + 1
+		offsets = append(offsets, at)
+		at += len(name)
+	}
+	return offsets
+}
+
+// fieldChain checks a chain of field and method names applied to a value of
+// type t, the last of which is given nargs arguments, and returns the type
+// of the result. offsets holds the offset of each name, if known.
+func (c *checker) fieldChain(t types.Type, names []string, offsets []int, nargs int) types.Type {
+	for i, name := range names {
+		if t == nil {
+			return nil
+		}
+		start := -1
+		if i < len(offsets) {
+			start = offsets[i]
+		}
+		args := 0
+		if i == len(names)-1 {
+			args = nargs
+		}
+		obj, elem, ok := lookupField(t, name)
+		if !ok {
+			if start >= 0 {
+				c.errorf(start, name, "can't evaluate field %s in type %s", name, c.typeString(t))
+			}
+			return nil
+		}
+		if obj == nil { // a map element, or a dynamic interface value
+			t = elem
+			continue
+		}
+		if start >= 0 {
+			c.info.refs = append(c.info.refs, fieldRef{start, utf8.RuneCountInString(name), obj})
+		}
+		switch obj := obj.(type) {
+		case *types.Var:
+			if args > 0 && start >= 0 {
+				c.errorf(start, name, "%s has arguments but cannot be invoked as function", name)
+			}
+			t = obj.Type()
+		case *types.Func:
+			t = c.call(obj.Type().(*types.Signature), name, start, args)
+		}
+	}
+	return t
+}
+
+// call checks a call of a method or a FuncMap function with signature sig
+// and nargs arguments, and returns the type of its result.
+func (c *checker) call(sig *types.Signature, name string, start, nargs int) types.Type {
+	params := sig.Params().Len()
+	if sig.Variadic() && nargs < params-1 || !sig.Variadic() && nargs != params {
+		if start >= 0 {
+			want := fmt.Sprint(params)
+			if sig.Variadic() {
+				want = fmt.Sprintf("at least %d", params-1)
+			}
+			c.errorf(start, name, "wrong number of args for %s: want %s got %d", name, want, nargs)
+		}
+		return nil
+	}
+	results := sig.Results()
+	switch {
+	case results.Len() == 1:
+	case results.Len() == 2 && types.Identical(results.At(1).Type(), types.Universe.Lookup("error").Type()):
+	default:
+		if start >= 0 {
+			c.errorf(start, name, "can't call method/function %q with %d results", name, results.Len())
+		}
+		return nil
+	}
+	return results.At(0).Type()
+}
+
+// builtins holds the minimum and maximum (or -1) number of arguments of the
+// functions predefined by text/template, and their result types if known.
+var builtins = map[string]struct {
+	min, max int
+	result   types.Type
+}{
+	"and":      {1, -1, nil},
+	"or":       {1, -1, nil},
+	"not":      {1, 1, types.Typ[types.Bool]},
+	"len":      {1, 1, types.Typ[types.Int]},
+	"index":    {1, -1, nil},
+	"slice":    {1, 4, nil},
+	"call":     {1, -1, nil},
+	"html":     {0, -1, types.Typ[types.String]},
+	"js":       {0, -1, types.Typ[types.String]},
+	"urlquery": {0, -1, types.Typ[types.String]},
+	"print":    {0, -1, types.Typ[types.String]},
+	"printf":   {1, -1, types.Typ[types.String]},
+	"println":  {0, -1, types.Typ[types.String]},
+	"eq":       {2, -1, types.Typ[types.Bool]},
+	"ne":       {2, 2, types.Typ[types.Bool]},
+	"lt":       {2, 2, types.Typ[types.Bool]},
+	"le":       {2, 2, types.Typ[types.Bool]},
+	"gt":       {2, 2, types.Typ[types.Bool]},
+	"ge":       {2, 2, types.Typ[types.Bool]},
+}
+
+// function checks a call of a predefined or FuncMap function with nargs
+// arguments, and returns the type of its result. Other functions are not
+// checked.
+func (c *checker) function(n *parse.IdentifierNode, nargs int) types.Type {
+	b, ok := builtins[n.Ident]
+	if !ok {
+		if f := c.funcs[n.Ident]; f != nil {
+			return c.call(f.sig, n.Ident, int(n.Pos), nargs)
+		}
+		return nil
+	}
+	if nargs < b.min || b.max >= 0 && nargs > b.max {
+		want := fmt.Sprint(b.min)
+		switch {
+		case b.max < 0:
+			want = fmt.Sprintf("at least %d", b.min)
+		case b.max != b.min:
+			want = fmt.Sprintf("%d to %d", b.min, b.max)
+		}
+		c.errorf(int(n.Pos), n.Ident, "wrong number of args for %s: want %s got %d", n.Ident, want, nargs)
+	}
+	return b.result
+}
+
+// typeString formats t as text/template reports it, qualified by package
+// name, since the template has no package of its own.
+func (c *checker) typeString(t types.Type) string {
+	return types.TypeString(t, packageName)
+}
+
+func packageName(p *types.Package) string {
+	return p.Name()
+}
+
+// lookupField looks up the exported field or method name of a value of type
+// t, as text/template does. It reports false if there is none. If the value
+// is a map or an interface without such a method, the result is found at
+// run time, so obj is nil and elem is the map's element type, if any.
+func lookupField(t types.Type, name string) (obj types.Object, elem types.Type, ok bool) {
+	obj, _, _ = types.LookupFieldOrMethod(t, true, nil, name)
+	if obj != nil {
+		return obj, nil, true
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		if b, ok := u.Key().Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+			return nil, u.Elem(), true
+		}
+	case *types.Interface:
+		return nil, nil, true
+	}
+	return nil, nil, false
+}
+
+// rangeTypes returns the key and element types of ranging over a value of
+// type t.
+func rangeTypes(t types.Type) (key, elem types.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	u := t.Underlying()
+	if ptr, ok := u.(*types.Pointer); ok {
+		if arr, ok := ptr.Elem().Underlying().(*types.Array); ok {
+			u = arr
+		}
+	}
+	switch u := u.(type) {
+	case *types.Slice:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Array:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Map:
+		return u.Key(), u.Elem()
+	case *types.Chan:
+		return u.Elem(), u.Elem()
+	}
+	return nil, nil
+}
+
+func copyVars(vars map[string]types.Type) map[string]types.Type {
+	m := make(map[string]types.Type, len(vars))
+	for k, v := range vars {
+		m[k] = v
+	}
+	return m
+}
+
+// scopeAt returns the innermost scope containing offset.
+func (info *typeInfo) scopeAt(offset int) *scope {
+	var best *scope
+	for i := range info.scopes {
+		s := &info.scopes[i]
+		if s.start <= offset && offset <= s.end && (best == nil || s.start >= best.start) {
+			best = s
+		}
+	}
+	return best
+}
+
+// refAt returns the field or method used at offset, or nil.
+func (info *typeInfo) refAt(offset int) types.Object {
+	for _, r := range info.refs {
+		if r.start <= offset && offset < r.start+r.length {
+			return r.obj
+		}
+	}
+	return nil
+}
+
+// typeDiagnostics returns the errors found by checking the template p
+// against its Go data type.
+func typeDiagnostics(ctx context.Context, snapshot source.Snapshot, uri span.URI, p *Parsed) []*source.Diagnostic {
+	info := checkTypes(ctx, snapshot, p)
+	if info == nil {
+		return nil
+	}
+	var diags []*source.Diagnostic
+	for _, e := range info.errs {
+		diags = append(diags, &source.Diagnostic{
+			URI:      uri,
+			Range:    p.Range(e.start, e.length),
+			Severity: protocol.SeverityError,
+			Source:   source.TemplateError,
+			Message:  e.msg,
+		})
+	}
+	return diags
+}
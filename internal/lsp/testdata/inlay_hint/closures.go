@@ -0,0 +1,16 @@
+//go:build go1.18
+// +build go1.18
+
+package inlayHint //@inlayHint("package", closureParameterTypes)
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	var r []U
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func closures() {
+	Map([]string{"a"}, func(s string) int { return len(s) })
+}
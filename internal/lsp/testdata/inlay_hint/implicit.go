@@ -0,0 +1,38 @@
+package inlayHint //@inlayHint("package", implicitConversions, untypedConstantTypes, methodValueReceivers)
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	timeout       = 1.5
+	retries       = 3
+	name          = "gopls"
+	typed   int64 = 4
+)
+
+type counter int
+
+func (c counter) Get() int { return int(c) }
+
+func (c *counter) Inc() { *c++ }
+
+func implicit(w io.Writer) {
+	var buf bytes.Buffer
+	io.Copy(w, &buf)
+	fmt.Fprint(w, retries, name)
+
+	var r io.Reader = os.Stdin
+	r = &buf
+	_ = r
+
+	var c counter
+	p := &c
+	get, inc := c.Get, c.Inc
+	pget, pinc := p.Get, p.Inc
+	c.Inc()
+	_, _, _, _ = get, inc, pget, pinc
+}
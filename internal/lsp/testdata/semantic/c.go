@@ -0,0 +1,31 @@
+package semantictokens //@ semantic("")
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type Config struct {
+	Name string `json:"name,omitempty" yaml:"name"`
+	Old  int    `deprecated`
+
+	// Deprecated: use Name.
+	Label string
+}
+
+// Deprecated: use NewConfig.
+func MakeConfig() *Config { return nil }
+
+var ident = regexp.MustCompile(`^(?P<first>[a-zA-Z_]\w*)(\.[a-z]+){0,2}$`)
+
+// logf is printf-like according to the printf analyzer.
+func logf(format string, args ...interface{}) {
+	fmt.Printf(format, args...)
+}
+
+func describe(c *Config) string {
+	logf("%s: %-8.3[2]f%%", c.Label, 1.5)
+	fmt.Printf("%s: %-8.3[2]f%%\n", c.Label, 1.5)
+	MakeConfig()
+	return fmt.Sprintf("%q has %d names\n", c.Name, 1)
+}
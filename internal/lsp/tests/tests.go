@@ -81,7 +81,7 @@ type PrepareRenames map[span.Span]*source.PrepareItem
 type Symbols map[span.URI][]protocol.DocumentSymbol
 type SymbolsChildren map[string][]protocol.DocumentSymbol
 type SymbolInformation map[span.Span]protocol.SymbolInformation
-type InlayHints []span.Span
+type InlayHints map[span.Span][]string
 type WorkspaceSymbols map[WorkspaceSymbolsTestType]map[span.URI][]string
 type Signatures map[span.Span]*protocol.SignatureHelp
 type Links map[span.URI][]Link
@@ -158,7 +158,7 @@ type Tests interface {
 	Definition(*testing.T, span.Span, Definition)
 	Implementation(*testing.T, span.Span, []span.Span)
 	Highlight(*testing.T, span.Span, []span.Span)
-	InlayHints(*testing.T, span.Span)
+	InlayHints(*testing.T, span.Span, []string)
 	References(*testing.T, span.Span, []span.Span)
 	Rename(*testing.T, span.Span, string)
 	PrepareRename(*testing.T, span.Span, *source.PrepareItem)
@@ -302,6 +302,7 @@ func load(t testing.TB, mode string, dir string) *Data {
 		Definitions:              make(Definitions),
 		Implementations:          make(Implementations),
 		Highlights:               make(Highlights),
+		InlayHints:               make(InlayHints),
 		References:               make(References),
 		Renames:                  make(Renames),
 		PrepareRenames:           make(PrepareRenames),
@@ -788,10 +789,10 @@ func Run(t *testing.T, tests Tests, data *Data) {
 
 	t.Run("InlayHints", func(t *testing.T) {
 		t.Helper()
-		for _, src := range data.InlayHints {
+		for src, hints := range data.InlayHints {
 			t.Run(SpanName(src), func(t *testing.T) {
 				t.Helper()
-				tests.InlayHints(t, src)
+				tests.InlayHints(t, src, hints)
 			})
 		}
 	})
@@ -1307,8 +1308,9 @@ func (data *Data) collectHighlights(src span.Span, expected []span.Span) {
 	data.Highlights[src] = append(data.Highlights[src], expected...)
 }
 
-func (data *Data) collectInlayHints(src span.Span) {
-	data.InlayHints = append(data.InlayHints, src)
+func (data *Data) collectInlayHints(src span.Span, hints []string) {
+	// Declaring inlay hints in a test file: @inlayHint(src, hint1, hint2)
+	data.InlayHints[src] = hints
 }
 
 func (data *Data) collectReferences(src span.Span, expected []span.Span) {
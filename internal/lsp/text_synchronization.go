@@ -91,6 +91,7 @@ func (s *Server) didOpen(ctx context.Context, params *protocol.DidOpenTextDocume
 			return err
 		}
 	}
+	s.setCurrentFile(uri)
 	return s.didModifyFiles(ctx, []source.FileModification{{
 		URI:        uri,
 		Action:     source.Open,
@@ -116,12 +117,20 @@ func (s *Server) didChange(ctx context.Context, params *protocol.DidChangeTextDo
 		Version: params.TextDocument.Version,
 		Text:    text,
 	}
+	s.setCurrentFile(uri)
 	if err := s.didModifyFiles(ctx, []source.FileModification{c}, FromDidChange); err != nil {
 		return err
 	}
 	return s.warnAboutModifyingGeneratedFiles(ctx, uri)
 }
 
+// setCurrentFile records the file as the one the user is working on.
+func (s *Server) setCurrentFile(uri span.URI) {
+	s.currentFileMu.Lock()
+	s.currentFile = uri
+	s.currentFileMu.Unlock()
+}
+
 // warnAboutModifyingGeneratedFiles shows a warning if a user tries to edit a
 // generated file for the first time.
 func (s *Server) warnAboutModifyingGeneratedFiles(ctx context.Context, uri span.URI) error {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cowpaths/golang-x-tools/internal/event"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/debug/tag"
@@ -16,6 +17,8 @@ import (
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
 	"github.com/cowpaths/golang-x-tools/internal/span"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.VersionedFileIdentity][]*source.Diagnostic, error) {
@@ -58,6 +61,7 @@ func DiagnosticsForWork(ctx context.Context, snapshot source.Snapshot, fh source
 
 	// Add diagnostic if a directory does not contain a module.
 	var diagnostics []*source.Diagnostic
+	var used []usedModule
 	for _, use := range pw.File.Use {
 		rng, err := source.LineToRange(pw.Mapper, fh.URI(), use.Syntax.Start, use.Syntax.End)
 		if err != nil {
@@ -76,11 +80,270 @@ func DiagnosticsForWork(ctx context.Context, snapshot source.Snapshot, fh source
 				Source:   source.UnknownError, // Do we need a new source for this?
 				Message:  fmt.Sprintf("directory %v does not contain a module", use.Path),
 			})
+			continue
+		}
+		pm, err := snapshot.ParseMod(ctx, modfh)
+		if err != nil || pm.File == nil {
+			continue // reported in the go.mod file
+		}
+		used = append(used, usedModule{use, rng, pm})
+	}
+	goDiags, err := goVersionDiagnostics(snapshot, pw, used)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics = append(diagnostics, goDiags...)
+	replaceDiags, err := replaceDiagnostics(snapshot, pw, used)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics = append(diagnostics, replaceDiags...)
+	return diagnostics, nil
+}
+
+// A usedModule is a module in the use list of a go.work file.
+type usedModule struct {
+	use *modfile.Use
+	rng protocol.Range // of the use directive
+	pm  *source.ParsedModule
+}
+
+// goVersionDiagnostics reports an error on the go directive of the go.work
+// file if its version is lower than that of a used module, which the go
+// command does not allow.
+func goVersionDiagnostics(snapshot source.Snapshot, pw *source.ParsedWorkFile, used []usedModule) ([]*source.Diagnostic, error) {
+	if pw.File.Go == nil {
+		return nil, nil
+	}
+	var highest *usedModule
+	for i, m := range used {
+		if m.pm.File.Go == nil || semver.Compare("v"+m.pm.File.Go.Version, "v"+pw.File.Go.Version) <= 0 {
+			continue
+		}
+		if highest == nil || semver.Compare("v"+m.pm.File.Go.Version, "v"+highest.pm.File.Go.Version) > 0 {
+			highest = &used[i]
+		}
+	}
+	if highest == nil {
+		return nil, nil
+	}
+	rng, err := source.LineToRange(pw.Mapper, pw.URI, pw.File.Go.Syntax.Start, pw.File.Go.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	version := highest.pm.File.Go.Version
+	edits, err := editWorkFile(snapshot, pw, func(wf *modfile.WorkFile) error {
+		return wf.AddGoStmt(version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*source.Diagnostic{{
+		URI:      pw.URI,
+		Range:    rng,
+		Severity: protocol.SeverityError,
+		Source:   source.WorkFileError,
+		Message: fmt.Sprintf("go.work requires go %s, lower than go %s required by module %s",
+			pw.File.Go.Version, version, highest.use.Path),
+		SuggestedFixes: []source.SuggestedFix{{
+			Title:      fmt.Sprintf("Update go.work to go %s", version),
+			Edits:      map[span.URI][]protocol.TextEdit{pw.URI: edits},
+			ActionKind: protocol.QuickFix,
+		}},
+	}}, nil
+}
+
+// replaceDiagnostics reports the modules that are replaced differently by
+// used modules, unless the go.work file replaces them itself, which the go
+// command requires. The fixes add each of the conflicting replacements to
+// the go.work file.
+func replaceDiagnostics(snapshot source.Snapshot, pw *source.ParsedWorkFile, used []usedModule) ([]*source.Diagnostic, error) {
+	workdir := filepath.Dir(pw.URI.Filename())
+	replaced := make(map[module.Version]bool)
+	for _, r := range pw.File.Replace {
+		replaced[r.Old] = true
+		replaced[module.Version{Path: r.Old.Path}] = true
+	}
+	// A replacement, with a local directory made absolute.
+	type replacement struct {
+		m   *usedModule
+		new module.Version
+	}
+	byOld := make(map[module.Version][]replacement)
+	var olds []module.Version
+	for i, m := range used {
+		moddir := filepath.Dir(m.pm.URI.Filename())
+		for _, r := range m.pm.File.Replace {
+			if replaced[r.Old] || replaced[module.Version{Path: r.Old.Path}] {
+				continue
+			}
+			new := r.New
+			if modfile.IsDirectoryPath(new.Path) && !filepath.IsAbs(new.Path) {
+				new.Path = filepath.Join(moddir, filepath.FromSlash(new.Path))
+			}
+			if byOld[r.Old] == nil {
+				olds = append(olds, r.Old)
+			}
+			byOld[r.Old] = append(byOld[r.Old], replacement{&used[i], new})
+		}
+	}
+	var diagnostics []*source.Diagnostic
+	for _, old := range olds {
+		reps := byOld[old]
+		conflict := false
+		for _, r := range reps[1:] {
+			if r.new != reps[0].new {
+				conflict = true
+			}
+		}
+		if !conflict {
+			continue
+		}
+		var fixes []source.SuggestedFix
+		seen := make(map[module.Version]bool)
+		for _, r := range reps {
+			if seen[r.new] {
+				continue
+			}
+			seen[r.new] = true
+			new := r.new
+			if new.Version == "" { // a directory
+				new.Path = relPath(workdir, new.Path)
+			}
+			edits, err := editWorkFile(snapshot, pw, func(wf *modfile.WorkFile) error {
+				return wf.AddReplace(old.Path, old.Version, new.Path, new.Version)
+			})
+			if err != nil {
+				return nil, err
+			}
+			fixes = append(fixes, source.SuggestedFix{
+				Title:      fmt.Sprintf("Replace %s with %s in go.work, as module %s does", old, new, r.m.use.Path),
+				Edits:      map[span.URI][]protocol.TextEdit{pw.URI: edits},
+				ActionKind: protocol.QuickFix,
+			})
+		}
+		for _, r := range reps {
+			diagnostics = append(diagnostics, &source.Diagnostic{
+				URI:            pw.URI,
+				Range:          r.m.rng,
+				Severity:       protocol.SeverityError,
+				Source:         source.WorkFileError,
+				Message:        fmt.Sprintf("conflicting replacements for %s in the used modules: add a replace directive to go.work", old),
+				SuggestedFixes: fixes,
+			})
 		}
 	}
 	return diagnostics, nil
 }
 
+// editWorkFile returns the edits that apply the change to the go.work file.
+func editWorkFile(snapshot source.Snapshot, pw *source.ParsedWorkFile, change func(*modfile.WorkFile) error) ([]protocol.TextEdit, error) {
+	// We need a private copy of the parsed go.work file, since we're going
+	// to modify it.
+	copied, err := modfile.ParseWork("", pw.Mapper.Content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(copied); err != nil {
+		return nil, err
+	}
+	copied.Cleanup()
+	newContent := modfile.Format(copied.Syntax)
+	diff, err := snapshot.View().Options().ComputeEdits(pw.URI, string(pw.Mapper.Content), string(newContent))
+	if err != nil {
+		return nil, err
+	}
+	return source.ToProtocolEdits(pw.Mapper, diff)
+}
+
+// DiagnosticsForFile reports a Go file that belongs to a module that is not
+// in the use list of the go.work file, with a fix that adds the module.
+// Only the files in the folder of the view are reported, other than the
+// sources of its dependencies.
+func DiagnosticsForFile(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]*source.Diagnostic, error) {
+	uri := snapshot.WorkFile()
+	view := snapshot.View()
+	if uri == "" || view.FileKind(fh) != source.Go {
+		return nil, nil
+	}
+	if !source.InDir(view.Folder().Filename(), fh.URI().Filename()) || view.IsDependencyFile(fh.URI()) {
+		return nil, nil
+	}
+	workfh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := snapshot.ParseWork(ctx, workfh)
+	if err != nil {
+		return nil, nil // reported in the go.work file
+	}
+	// Find the module containing the file.
+	var modfh source.FileHandle
+	for dir := filepath.Dir(fh.URI().Filename()); ; dir = filepath.Dir(dir) {
+		modfh, err = snapshot.GetFile(ctx, span.URIFromPath(filepath.Join(dir, "go.mod")))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := modfh.Read(); err == nil {
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, nil // not in a module
+		}
+	}
+	for _, use := range pw.File.Use {
+		if modFileURI(pw, use) == modfh.URI() {
+			return nil, nil
+		}
+	}
+	pm, err := snapshot.ParseMod(ctx, modfh)
+	if err != nil || pm.File == nil || pm.File.Module == nil {
+		return nil, nil
+	}
+	pgf, err := snapshot.ParseGo(ctx, fh, source.ParseHeader)
+	if err != nil || !pgf.File.Name.Pos().IsValid() {
+		return nil, nil
+	}
+	rng, err := source.NewMappedRange(pgf.Tok, pgf.Mapper, pgf.File.Name.Pos(), pgf.File.Name.End()).Range()
+	if err != nil {
+		return nil, err
+	}
+	path := relPath(filepath.Dir(uri.Filename()), filepath.Dir(modfh.URI().Filename()))
+	modpath := pm.File.Module.Mod.Path
+	edits, err := editWorkFile(snapshot, pw, func(wf *modfile.WorkFile) error {
+		return wf.AddUse(path, modpath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*source.Diagnostic{{
+		URI:      fh.URI(),
+		Range:    rng,
+		Severity: protocol.SeverityWarning,
+		Source:   source.WorkFileError,
+		Message:  fmt.Sprintf("module %s containing this file is not used by go.work", modpath),
+		SuggestedFixes: []source.SuggestedFix{{
+			Title:      fmt.Sprintf("Add %s to go.work", path),
+			Edits:      map[span.URI][]protocol.TextEdit{uri: edits},
+			ActionKind: protocol.QuickFix,
+		}},
+	}}, nil
+}
+
+// relPath returns the directory path dir relative to base, in the form of
+// the module paths of go.work files, or dir if there is no relative path.
+func relPath(base, dir string) string {
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return dir
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
+
 func modFileURI(pw *source.ParsedWorkFile, use *modfile.Use) span.URI {
 	workdir := filepath.Dir(pw.URI.Filename())
 
@@ -0,0 +1,86 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package work
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// Generate returns the contents of a go.work file for the directory dir that
+// uses every module in and beneath it, as `go work use -r` would, except for
+// those in testdata, vendor and hidden directories. Its go version is the
+// highest of those of the modules.
+func Generate(dir string) ([]byte, error) {
+	wf := new(modfile.WorkFile)
+	wf.Syntax = new(modfile.FileSyntax)
+	version := ""
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != dir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "go.mod" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		mf, err := modfile.ParseLax(path, content, nil)
+		if err != nil {
+			return err
+		}
+		if mf.Module == nil {
+			return fmt.Errorf("%s: no module directive", path)
+		}
+		if mf.Go != nil && (version == "" || semver.Compare("v"+mf.Go.Version, "v"+version) > 0) {
+			version = mf.Go.Version
+		}
+		return wf.AddUse(relPath(dir, filepath.Dir(path)), mf.Module.Mod.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(wf.Use) == 0 {
+		return nil, fmt.Errorf("no modules found in %s", dir)
+	}
+	if version == "" {
+		version = "1.18" // the first version to support workspaces
+	}
+	if err := wf.AddGoStmt(version); err != nil {
+		return nil, err
+	}
+	wf.SortBlocks()
+	wf.Cleanup()
+	return modfile.Format(wf.Syntax), nil
+}
+
+// WriteGenerated writes the go.work file generated for the directory dir,
+// which must not already have one.
+func WriteGenerated(dir string) error {
+	filename := filepath.Join(dir, "go.work")
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%s already exists", filename)
+	}
+	content, err := Generate(dir)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0644)
+}
@@ -7,6 +7,7 @@ package lsp
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"github.com/cowpaths/golang-x-tools/internal/lsp/protocol"
 	"github.com/cowpaths/golang-x-tools/internal/lsp/source"
@@ -58,6 +59,11 @@ func (s *Server) didChangeConfiguration(ctx context.Context, _ *protocol.DidChan
 		if err := s.fetchConfig(ctx, view.Name(), view.Folder(), options); err != nil {
 			return err
 		}
+		// The files of the build configurations that are no longer
+		// diagnosed would keep their diagnostics.
+		if !reflect.DeepEqual(view.Options().BuildConfigurations, options.BuildConfigurations) {
+			s.clearDiagnosticSource(buildConfigSource)
+		}
 		view, err := view.SetOptions(ctx, options)
 		if err != nil {
 			return err
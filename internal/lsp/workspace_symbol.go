@@ -19,5 +19,8 @@ func (s *Server) symbol(ctx context.Context, params *protocol.WorkspaceSymbolPar
 	views := s.session.Views()
 	matcher := s.session.Options().SymbolMatcher
 	style := s.session.Options().SymbolStyle
-	return source.WorkspaceSymbols(ctx, matcher, style, views, params.Query)
+	s.currentFileMu.Lock()
+	current := s.currentFile
+	s.currentFileMu.Unlock()
+	return source.WorkspaceSymbols(ctx, matcher, style, views, params.Query, current)
 }
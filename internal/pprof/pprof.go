@@ -0,0 +1,475 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pprof decodes the subset of the pprof profile format needed to
+// attribute samples to source lines, such as the CPU and memory profiles
+// written by `go test -cpuprofile` and `go test -memprofile`.
+//
+// The format is a (usually gzipped) protocol buffer described by
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+// Mappings, labels and other fields not needed to locate samples in source
+// are skipped.
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// A Profile is a decoded pprof profile.
+type Profile struct {
+	SampleTypes []ValueType
+	Samples     []*Sample
+	Locations   []*Location
+	Functions   []*Function
+}
+
+// A ValueType describes the semantics and measurement units of a value.
+type ValueType struct {
+	Type string // e.g. "cpu", "alloc_space"
+	Unit string // e.g. "nanoseconds", "bytes"
+}
+
+// A Sample is a set of values recorded at a call stack.
+type Sample struct {
+	// Locations is the call stack, leaf first.
+	Locations []*Location
+	// Values holds one value for each of the profile's SampleTypes.
+	Values []int64
+}
+
+// A Location is a program location. It has more than one Line if functions
+// were inlined at that location, in which case the first Line is the
+// innermost one.
+type Location struct {
+	ID    uint64
+	Lines []Line
+}
+
+// A Line is a source line within a function.
+type Line struct {
+	Function *Function
+	Line     int64
+}
+
+// A Function is a function referenced by a Line.
+type Function struct {
+	ID       uint64
+	Name     string
+	Filename string
+}
+
+// SampleIndex returns the index of the sample type with the given type name,
+// or -1 if there is none.
+func (p *Profile) SampleIndex(typ string) int {
+	for i, st := range p.SampleTypes {
+		if st.Type == typ {
+			return i
+		}
+	}
+	return -1
+}
+
+// Total returns the sum of the values at index i of all samples.
+func (p *Profile) Total(i int) int64 {
+	var total int64
+	for _, s := range p.Samples {
+		if i < len(s.Values) {
+			total += s.Values[i]
+		}
+	}
+	return total
+}
+
+// A LineValue holds the values attributed to a single source line.
+type LineValue struct {
+	Filename string
+	Line     int64
+	Function string
+	Flat     int64 // value of the samples whose innermost line it is
+	Cum      int64 // value of the samples whose call stack includes it
+}
+
+// Lines returns the values at index i of the profile's samples attributed
+// to each source line with a non-zero value. The flat value of a line is
+// that of the samples whose innermost line it is, and its cumulative value
+// is that of the samples whose call stack includes it, counted once per
+// sample even if it occurs several times, as in recursive calls.
+// The result is sorted by decreasing cumulative value, then flat value.
+func (p *Profile) Lines(i int) []LineValue {
+	type key struct {
+		filename string
+		line     int64
+	}
+	values := make(map[key]*LineValue)
+	add := func(l Line) *LineValue {
+		k := key{l.Function.Filename, l.Line}
+		lv, ok := values[k]
+		if !ok {
+			lv = &LineValue{Filename: k.filename, Line: k.line, Function: l.Function.Name}
+			values[k] = lv
+		}
+		return lv
+	}
+	seen := make(map[key]bool)
+	for _, s := range p.Samples {
+		if i >= len(s.Values) || s.Values[i] == 0 {
+			continue
+		}
+		v := s.Values[i]
+		for k := range seen {
+			delete(seen, k)
+		}
+		innermost := true
+		for _, loc := range s.Locations {
+			for _, l := range loc.Lines {
+				if l.Function == nil {
+					continue
+				}
+				lv := add(l)
+				if innermost {
+					lv.Flat += v
+					innermost = false
+				}
+				if k := (key{l.Function.Filename, l.Line}); !seen[k] {
+					seen[k] = true
+					lv.Cum += v
+				}
+			}
+		}
+	}
+	result := make([]LineValue, 0, len(values))
+	for _, lv := range values {
+		result = append(result, *lv)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	return result
+}
+
+// Parse decodes a profile, which may be gzip-compressed.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %v", err)
+		}
+		data, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %v", err)
+		}
+	}
+	var raw rawProfile
+	if err := raw.decode(data); err != nil {
+		return nil, fmt.Errorf("decoding profile: %v", err)
+	}
+	return raw.resolve()
+}
+
+// The rawProfile types hold the profile as encoded, with references by ID
+// and strings by index into the string table.
+type rawProfile struct {
+	sampleTypes []rawValueType
+	samples     []rawSample
+	locations   []rawLocation
+	functions   []rawFunction
+	strings     []string
+}
+
+type rawValueType struct{ typ, unit int64 }
+
+type rawSample struct {
+	locationIDs []uint64
+	values      []int64
+}
+
+type rawLocation struct {
+	id    uint64
+	lines []rawLine
+}
+
+type rawLine struct {
+	functionID uint64
+	line       int64
+}
+
+type rawFunction struct {
+	id             uint64
+	name, filename int64
+}
+
+// Field numbers, from profile.proto.
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID       = 1
+	functionName     = 2
+	functionFilename = 4
+)
+
+func (p *rawProfile) decode(data []byte) error {
+	return decodeMessage(data, func(field int, b *buffer) error {
+		switch field {
+		case profileSampleType:
+			var vt rawValueType
+			err := decodeMessage(b.data, func(field int, b *buffer) error {
+				switch field {
+				case valueTypeType:
+					vt.typ = int64(b.u64)
+				case valueTypeUnit:
+					vt.unit = int64(b.u64)
+				}
+				return nil
+			})
+			p.sampleTypes = append(p.sampleTypes, vt)
+			return err
+		case profileSample:
+			var s rawSample
+			err := decodeMessage(b.data, func(field int, b *buffer) error {
+				switch field {
+				case sampleLocationID:
+					return b.repeated(func(v uint64) { s.locationIDs = append(s.locationIDs, v) })
+				case sampleValue:
+					return b.repeated(func(v uint64) { s.values = append(s.values, int64(v)) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case profileLocation:
+			var loc rawLocation
+			err := decodeMessage(b.data, func(field int, b *buffer) error {
+				switch field {
+				case locationID:
+					loc.id = b.u64
+				case locationLine:
+					var l rawLine
+					err := decodeMessage(b.data, func(field int, b *buffer) error {
+						switch field {
+						case lineFunctionID:
+							l.functionID = b.u64
+						case lineLine:
+							l.line = int64(b.u64)
+						}
+						return nil
+					})
+					loc.lines = append(loc.lines, l)
+					return err
+				}
+				return nil
+			})
+			p.locations = append(p.locations, loc)
+			return err
+		case profileFunction:
+			var fn rawFunction
+			err := decodeMessage(b.data, func(field int, b *buffer) error {
+				switch field {
+				case functionID:
+					fn.id = b.u64
+				case functionName:
+					fn.name = int64(b.u64)
+				case functionFilename:
+					fn.filename = int64(b.u64)
+				}
+				return nil
+			})
+			p.functions = append(p.functions, fn)
+			return err
+		case profileStringTable:
+			p.strings = append(p.strings, string(b.data))
+		}
+		return nil
+	})
+}
+
+func (p *rawProfile) resolve() (*Profile, error) {
+	str := func(i int64) (string, error) {
+		if i < 0 || i >= int64(len(p.strings)) {
+			return "", fmt.Errorf("string index %d out of range", i)
+		}
+		return p.strings[i], nil
+	}
+	var err error
+	prof := new(Profile)
+	for _, vt := range p.sampleTypes {
+		var t ValueType
+		if t.Type, err = str(vt.typ); err != nil {
+			return nil, err
+		}
+		if t.Unit, err = str(vt.unit); err != nil {
+			return nil, err
+		}
+		prof.SampleTypes = append(prof.SampleTypes, t)
+	}
+	functions := make(map[uint64]*Function)
+	for _, rf := range p.functions {
+		fn := &Function{ID: rf.id}
+		if fn.Name, err = str(rf.name); err != nil {
+			return nil, err
+		}
+		if fn.Filename, err = str(rf.filename); err != nil {
+			return nil, err
+		}
+		functions[fn.ID] = fn
+		prof.Functions = append(prof.Functions, fn)
+	}
+	locations := make(map[uint64]*Location)
+	for _, rl := range p.locations {
+		loc := &Location{ID: rl.id}
+		for _, l := range rl.lines {
+			fn, ok := functions[l.functionID]
+			if !ok {
+				return nil, fmt.Errorf("location %d references unknown function %d", rl.id, l.functionID)
+			}
+			loc.Lines = append(loc.Lines, Line{Function: fn, Line: l.line})
+		}
+		locations[loc.ID] = loc
+		prof.Locations = append(prof.Locations, loc)
+	}
+	for _, rs := range p.samples {
+		if len(rs.values) != len(prof.SampleTypes) {
+			return nil, fmt.Errorf("sample has %d values, want %d", len(rs.values), len(prof.SampleTypes))
+		}
+		s := &Sample{Values: rs.values}
+		for _, id := range rs.locationIDs {
+			loc, ok := locations[id]
+			if !ok {
+				return nil, fmt.Errorf("sample references unknown location %d", id)
+			}
+			s.Locations = append(s.Locations, loc)
+		}
+		prof.Samples = append(prof.Samples, s)
+	}
+	return prof, nil
+}
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// A buffer holds the value of a single decoded field: u64 for scalar
+// fields, and data for length-delimited ones.
+type buffer struct {
+	wireType int
+	u64      uint64
+	data     []byte
+}
+
+// repeated calls f for each value of a repeated integer field, which may be
+// either packed or not.
+func (b *buffer) repeated(f func(uint64)) error {
+	if b.wireType != wireBytes {
+		f(b.u64)
+		return nil
+	}
+	for data := b.data; len(data) > 0; {
+		v, n := decodeVarint(data)
+		if n == 0 {
+			return errors.New("bad packed varint")
+		}
+		f(v)
+		data = data[n:]
+	}
+	return nil
+}
+
+// decodeMessage calls f for each field of the encoded message in data.
+func decodeMessage(data []byte, f func(field int, b *buffer) error) error {
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		if n == 0 {
+			return errors.New("bad field key")
+		}
+		data = data[n:]
+		b := buffer{wireType: int(key & 7)}
+		switch b.wireType {
+		case wireVarint:
+			b.u64, n = decodeVarint(data)
+			if n == 0 {
+				return errors.New("bad varint")
+			}
+		case wireFixed64:
+			if len(data) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			for i := 7; i >= 0; i-- {
+				b.u64 = b.u64<<8 | uint64(data[i])
+			}
+			n = 8
+		case wireFixed32:
+			if len(data) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			for i := 3; i >= 0; i-- {
+				b.u64 = b.u64<<8 | uint64(data[i])
+			}
+			n = 4
+		case wireBytes:
+			size, m := decodeVarint(data)
+			// Compare in uint64 so that a huge size cannot overflow int.
+			if m == 0 || size > uint64(len(data)-m) {
+				return errors.New("truncated length-delimited field")
+			}
+			n = m + int(size)
+			b.data = data[m:n]
+		default:
+			return fmt.Errorf("unsupported wire type %d", b.wireType)
+		}
+		data = data[n:]
+		if err := f(int(key>>3), &b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeVarint decodes a varint from the start of data, returning the value
+// and the number of bytes consumed, or 0 if data does not start with a
+// valid varint.
+func decodeVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		b := data[i]
+		v |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/cowpaths/golang-x-tools/internal/pprof"
+)
+
+var sink []byte
+
+//go:noinline
+func allocate() {
+	for i := 0; i < 1000; i++ {
+		sink = make([]byte, 1024)
+	}
+}
+
+//go:noinline
+func callAllocate() {
+	allocate()
+}
+
+// heapProfile returns a heap profile that records the allocations of
+// callAllocate.
+func heapProfile(t *testing.T) []byte {
+	defer func(rate int) { runtime.MemProfileRate = rate }(runtime.MemProfileRate)
+	runtime.MemProfileRate = 1
+	callAllocate()
+	runtime.GC() // heap profiles are only updated at GC
+
+	var buf bytes.Buffer
+	if err := runtimepprof.Lookup("allocs").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseHeapProfile(t *testing.T) {
+	prof, err := pprof.Parse(heapProfile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := prof.SampleIndex("alloc_space")
+	if i < 0 {
+		t.Fatalf("no alloc_space sample type in %v", prof.SampleTypes)
+	}
+	if got := prof.SampleTypes[i].Unit; got != "bytes" {
+		t.Errorf("alloc_space unit = %q, want bytes", got)
+	}
+	// The allocating line of allocate has the flat value, and the line
+	// of callAllocate that calls it only the cumulative one.
+	var found, foundCaller bool
+	for _, lv := range prof.Lines(i) {
+		switch {
+		case strings.HasSuffix(lv.Function, ".allocate"):
+			found = true
+			if !strings.HasSuffix(lv.Filename, "pprof_test.go") {
+				t.Errorf("allocate is in %s, want pprof_test.go", lv.Filename)
+			}
+			if lv.Flat < 1000*1024 || lv.Cum < lv.Flat {
+				t.Errorf("allocate allocated %d bytes (cumulative %d), want at least %d", lv.Flat, lv.Cum, 1000*1024)
+			}
+		case strings.HasSuffix(lv.Function, ".callAllocate"):
+			foundCaller = true
+			if lv.Flat != 0 || lv.Cum < 1000*1024 {
+				t.Errorf("callAllocate allocated %d bytes (cumulative %d), want 0 (cumulative at least %d)", lv.Flat, lv.Cum, 1000*1024)
+			}
+		}
+	}
+	if !found {
+		t.Error("no samples attributed to allocate")
+	}
+	if !foundCaller {
+		t.Error("no samples attributed to callAllocate")
+	}
+	if total := prof.Total(i); total < 1000*1024 {
+		t.Errorf("Total = %d, want at least %d", total, 1000*1024)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x0a},             // truncated key and length
+		{0x0a, 0x05, 0x08}, // length exceeds data
+		{0x0b},             // unsupported wire type
+		{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, // length overflows int
+		{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f, 0x08},                         // length exceeds data
+		{0x12, 0x02, 0x12, 0x01},                                           // truncated packed value
+		{0x22, 0x02, 0x08, 0x80},                                           // truncated nested varint
+		{0x32, 0x00, 0x0a, 0x02, 0x08, 0x05},                               // string index out of range
+	} {
+		if _, err := pprof.Parse(data); err == nil {
+			t.Errorf("Parse(%x) succeeded, want error", data)
+		}
+	}
+}
+
+// TestParseCorrupt checks that Parse does not panic on truncated or
+// corrupted profiles.
+func TestParseCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	zr, err := gzip.NewReader(bytes.NewReader(heapProfile(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buf.ReadFrom(zr); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for n := range data {
+		pprof.Parse(data[:n])
+	}
+	rng := rand.New(rand.NewSource(1))
+	corrupt := make([]byte, len(data))
+	for i := 0; i < 1000; i++ {
+		copy(corrupt, data)
+		for j := 0; j < 1+rng.Intn(4); j++ {
+			corrupt[rng.Intn(len(corrupt))] = byte(rng.Intn(256))
+		}
+		pprof.Parse(corrupt)
+	}
+}